@@ -1,7 +1,6 @@
 package main
 
-// XXX(slankdev): ygot generator isn't working fine....
-////go:generate generator -package_name=yang -path=yang -output_file=../../pkg/linux-agent/yang/generated.go -generate_fakeroot -fakeroot_name=device -shorten_enum_leaf_names -typedef_enum_with_defmod ../../yang/linux-agent.yang
+//go:generate go run ../vtyang generate go -y ../../yang -m linux-agent --package yang -o ../../pkg/linux-agent/yang/generated.go
 
 import (
 	"context"
@@ -9,14 +8,17 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/k0kubun/pp"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 
 	vtyangapi "github.com/slankdev/vtyang/pkg/grpc/api"
+	"github.com/slankdev/vtyang/pkg/linux-agent/agent"
 	"github.com/slankdev/vtyang/pkg/linux-agent/yang"
 	"github.com/slankdev/vtyang/pkg/util"
 )
@@ -31,6 +33,9 @@ func main() {
 var (
 	clioptNetns   string
 	clioptConnect string
+	clioptDryRun  bool
+	clioptName    string
+	clioptToken   string
 )
 
 func NewCommand() *cobra.Command {
@@ -43,12 +48,27 @@ func NewCommand() *cobra.Command {
 	rootCmd.Flags().StringVar(&clioptConnect, "connect",
 		"192.168.64.1:8080",
 		"vtyang server")
+	rootCmd.Flags().StringVar(&clioptName, "name", "linux-agent",
+		"name of the agent registered to vtyang")
+	rootCmd.Flags().StringVar(&clioptToken, "token-file", "",
+		"file of the token required by vtyang")
+	rootCmd.Flags().BoolVar(&clioptDryRun, "dry-run", false,
+		"print the changes without applying them")
 	rootCmd.AddCommand(util.NewCommandCompletion(rootCmd))
 	rootCmd.AddCommand(util.NewCommandVersion())
 	return rootCmd
 }
 
 func f(cmd *cobra.Command, args []string) error {
+	a, err := agent.New(agent.Options{
+		Netns:  clioptNetns,
+		DryRun: clioptDryRun,
+	})
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
 	conn, err := grpc.Dial(
 		clioptConnect,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
@@ -59,159 +79,131 @@ func f(cmd *cobra.Command, args []string) error {
 	}
 	pp.Println("connected")
 	defer conn.Close()
-	client := vtyangapi.NewGreetingServiceClient(conn)
-	stream, err := client.HelloStream(context.Background())
+	ctx := context.Background()
+	if clioptToken != "" {
+		b, err := os.ReadFile(clioptToken)
+		if err != nil {
+			return err
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization",
+			"Bearer "+strings.TrimSpace(string(b)))
+	}
+	client := vtyangapi.NewAgentServiceClient(conn)
+	stream, err := client.Connect(ctx)
 	if err != nil {
 		return err
 	}
-	loop := true
-	for loop {
-		res, err := stream.Recv()
+	if err := stream.Send(&vtyangapi.AgentMessage{
+		Message: &vtyangapi.AgentMessage_Register{
+			Register: &vtyangapi.AgentRegister{
+				Name:           clioptName,
+				OperStatePaths: []string{"/linux-agent:state"},
+				ConfigPaths: []string{
+					"/linux-agent:interfaces",
+					"/linux-agent:vrfs",
+					"/linux-agent:routes",
+					"/linux-agent:sysctls",
+				},
+			},
+		},
+	}); err != nil {
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
 		if err != nil {
 			fmt.Println(err.Error())
-			loop = false
-			continue
+			return nil
 		}
-		device := yang.Device{}
-		if err := json.Unmarshal([]byte(res.Data), &device); err != nil {
-			return err
+		switch r := req.Request.(type) {
+		case *vtyangapi.AgentRequest_ConfigApplyReq:
+			err = applyConfig(stream, a, r.ConfigApplyReq)
+		case *vtyangapi.AgentRequest_OperStateReq:
+			err = replyOperState(stream, a, r.OperStateReq)
+		default:
+			fmt.Printf("Error: unexpected request %T\n", r)
 		}
-		pp.Println(device)
-		if err := validate(&device); err != nil {
-			return err
-		}
-		if err := commit(&device); err != nil {
+		if err != nil {
 			return err
 		}
 	}
-	return nil
 }
 
-func validate(_ *yang.Device) error {
-	// TODO(slankdev): it's not implemented
-	return nil
-}
-
-func commit(device *yang.Device) error {
-	foundIfaces := []string{}
-	for _, iface := range device.Interfaces.Interface {
-		if iface.Name == nil {
-			continue
-		}
-		pp.Println(*iface.Name)
-		foundIfaces = append(foundIfaces, *iface.Name)
-
-		// get current status
-		addrs := []Addr{}
-		if err := util.LocalExecutefJsonMarshal(
-			&addrs, "ip -j -n %s addr show dev %s",
-			clioptNetns, *(iface.Name)); err != nil {
+// applyConfig reconciles the kernel state with the config and reports
+// the config read back from the kernel, so that vtyang can detect the
+// drift.
+func applyConfig(stream vtyangapi.AgentService_ConnectClient,
+	a *agent.Agent, req *vtyangapi.ConfigApplyRequest) error {
+	reply := &vtyangapi.ConfigApplyReply{ReqId: req.ReqId}
+	device := yang.Device{}
+	if err := json.Unmarshal([]byte(req.Config), &device); err != nil {
+		reply.Error = err.Error()
+	} else if err := device.Validate(); err != nil {
+		reply.Error = err.Error()
+	} else if err := commit(a, &device); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		reply.Error = err.Error()
+	}
+	if reply.Error == "" && !clioptDryRun {
+		if err := sendConfigState(stream, a, &device); err != nil {
 			return err
 		}
-		if len(addrs) != 1 {
-			return fmt.Errorf("undefined case")
-		}
-
-		// ip addr add
-		found := false
-		pp.Println("DEBUG1", addrs)
-		for _, addrInfo := range addrs[0].AddrInfo {
-			addr1 := *iface.Address
-			addr2 := fmt.Sprintf("%s/%d", addrInfo.Local, addrInfo.Prefixlen)
-			pp.Println("a1: ", addr1)
-			pp.Println("a2: ", addr2)
-			if addr1 == addr2 {
-				found = true
-				break
-			}
-		}
-		if !found {
-			if _, err := util.LocalExecutef("ip netns exec %s ip addr add %s dev %s",
-				clioptNetns, *iface.Address, *iface.Name); err != nil {
-				return err
-			}
-		}
-
-		// ip addr del
-		for _, addrInfo := range addrs[0].AddrInfo {
-			if addrInfo.Family == "inet" {
-				keep := false
-				if fmt.Sprintf("%s/%d", addrInfo.Local, addrInfo.Prefixlen) == *iface.Address {
-					keep = true
-				}
-				if !keep {
-					if _, err := util.LocalExecutef(
-						"ip netns exec %s ip addr del %s/%d dev %s",
-						clioptNetns, addrInfo.Local, addrInfo.Prefixlen,
-						*iface.Name); err != nil {
-						return err
-					}
-				}
-			}
-		}
 	}
+	return stream.Send(&vtyangapi.AgentMessage{
+		Message: &vtyangapi.AgentMessage_ConfigApplyReply{
+			ConfigApplyReply: reply,
+		},
+	})
+}
 
-	// ip addr flush
-	// ip link set down
-	allAddrs := []Addr{}
-	if err := util.LocalExecutefJsonMarshal(
-		&allAddrs, " sudo ip -j -n %s addr",
-		clioptNetns); err != nil {
-		return err
+// sendConfigState reports the config as applied in the kernel. When the
+// kernel state can't be read, the last reported one is kept by vtyang.
+func sendConfigState(stream vtyangapi.AgentService_ConnectClient,
+	a *agent.Agent, device *yang.Device) error {
+	data, err := a.ConfigState(device)
+	if err != nil {
+		fmt.Printf("Error: ConfigState: %s\n", err)
+		return nil
 	}
-	for _, addr := range allAddrs {
-		found := false
-		for _, iface := range foundIfaces {
-			if iface == addr.Ifname {
-				found = true
-				break
-			}
-		}
-		if !found {
-			if _, err := util.LocalExecutef(
-				"ip netns exec %s ip addr flush dev %s",
-				clioptNetns, addr.Ifname); err != nil {
-				return err
-			}
-			if _, err := util.LocalExecutef(
-				"ip netns exec %s ip link set down dev %s",
-				clioptNetns, addr.Ifname); err != nil {
-				return err
-			}
-		}
+	return stream.Send(&vtyangapi.AgentMessage{
+		Message: &vtyangapi.AgentMessage_ConfigState{
+			ConfigState: &vtyangapi.ConfigState{Data: yangData(data)},
+		},
+	})
+}
+
+func replyOperState(stream vtyangapi.AgentService_ConnectClient,
+	a *agent.Agent, req *vtyangapi.OperStateRequest) error {
+	reply := &vtyangapi.OperStateReply{ReqId: req.ReqId}
+	if data, err := a.State(); err != nil {
+		reply.Error = err.Error()
+	} else {
+		reply.Data = yangData(data)
 	}
+	return stream.Send(&vtyangapi.AgentMessage{
+		Message: &vtyangapi.AgentMessage_OperStateReply{
+			OperStateReply: reply,
+		},
+	})
+}
 
-	return nil
+func yangData(data []agent.YangData) []*vtyangapi.YangData {
+	ret := []*vtyangapi.YangData{}
+	for _, d := range data {
+		ret = append(ret, &vtyangapi.YangData{Xpath: d.XPath, Value: d.Value})
+	}
+	return ret
 }
 
-// sudo ip -j -n ns0 addr show dev dum0 | jq
-// [
-//
-//	{
-//	  "ifindex": 2,
-//	  "ifname": "dum0",
-//	  "mtu": 1500,
-//	  "operstate": "DOWN",
-//	  "link_type": "ether",
-//	  "address": "d6:3e:c5:91:e9:0c",
-//	  "addr_info": [
-//	    {
-//	      "family": "inet",
-//	      "local": "10.0.0.1",
-//	      "prefixlen": 24
-//	    }
-//	  ]
-//	}
-//
-// ]
-type Addr struct {
-	Ifindex  int    `json:"ifindex"`
-	Ifname   string `json:"ifname"`
-	MTU      int    `json:"mtu"`
-	Address  string `json:"address"`
-	AddrInfo []struct {
-		Family    string `json:"family"`
-		Local     string `json:"local"`
-		Prefixlen int    `json:"prefixlen"`
-	} `json:"addr_info"`
+func commit(a *agent.Agent, device *yang.Device) error {
+	changes, err := a.Reconcile(device)
+	for _, c := range changes {
+		if clioptDryRun {
+			fmt.Printf("(dry-run) %s\n", c)
+		} else {
+			fmt.Printf("%s\n", c)
+		}
+	}
+	return err
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"os"
@@ -37,6 +38,8 @@ func NewCommand() *cobra.Command {
 }
 
 func f(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	// Init Client
 	client, err := mgmtd.NewClient(sock, name)
 	if err != nil {
@@ -46,7 +49,7 @@ func f(cmd *cobra.Command, args []string) error {
 
 	// STEP1: get config /
 	fmt.Println("[+] STEP1 get running-config")
-	ret, err := client.GetReq(&mgmtd.FeGetReq{
+	ret, err := client.GetReq(ctx, &mgmtd.FeGetReq{
 		SessionId: client.GetSessionId(),
 		Config:    util.NewBoolPointer(true),
 		DsId:      mgmtd.DatastoreId_RUNNING_DS.Enum(),
@@ -69,7 +72,7 @@ func f(cmd *cobra.Command, args []string) error {
 
 	// STEP2: lock running_ds
 	fmt.Println("[+] STEP2 Lock")
-	if err := client.LockReq(&mgmtd.FeLockDsReq{
+	if err := client.LockReq(ctx, &mgmtd.FeLockDsReq{
 		SessionId: client.GetSessionId(),
 		ReqId:     util.NewUint64Pointer(0),
 		DsId:      mgmtd.DatastoreId_RUNNING_DS.Enum(),
@@ -77,7 +80,7 @@ func f(cmd *cobra.Command, args []string) error {
 	}); err != nil {
 		return errors.Wrap(err, "client.LockReq(running_ds)")
 	}
-	if err := client.LockReq(&mgmtd.FeLockDsReq{
+	if err := client.LockReq(ctx, &mgmtd.FeLockDsReq{
 		SessionId: client.GetSessionId(),
 		ReqId:     util.NewUint64Pointer(0),
 		DsId:      mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
@@ -91,7 +94,7 @@ func f(cmd *cobra.Command, args []string) error {
 	// mgmt set-config /frr-filter:lib/prefix-list[type='ipv4'][name='hoge']/entry[sequence='10']/ipv4-prefix 10.255.0.0/16
 	// mgmt set-config /frr-filter:lib/prefix-list[type='ipv4'][name='hoge']/entry[sequence='10']/ipv4-prefix-length-lesser-or-equal 32
 	fmt.Println("[+] STEP3: set-config")
-	if err := client.SetConfig(&mgmtd.FeSetConfigReq{
+	if err := client.SetConfig(ctx, &mgmtd.FeSetConfigReq{
 		SessionId:      client.GetSessionId(),
 		DsId:           mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
 		CommitDsId:     mgmtd.DatastoreId_RUNNING_DS.Enum(),
@@ -138,7 +141,7 @@ func f(cmd *cobra.Command, args []string) error {
 
 	// STEP4: get config /
 	fmt.Println("STEP4 get running-config")
-	configRunningDs, err := client.GetReq(&mgmtd.FeGetReq{
+	configRunningDs, err := client.GetReq(ctx, &mgmtd.FeGetReq{
 		SessionId: client.GetSessionId(),
 		Config:    util.NewBoolPointer(true),
 		DsId:      mgmtd.DatastoreId_RUNNING_DS.Enum(),
@@ -161,7 +164,7 @@ func f(cmd *cobra.Command, args []string) error {
 
 	// STEP5: get config /
 	fmt.Println("STEP5 get candidate-config")
-	configCandidateDs, err := client.GetReq(&mgmtd.FeGetReq{
+	configCandidateDs, err := client.GetReq(ctx, &mgmtd.FeGetReq{
 		SessionId: client.GetSessionId(),
 		Config:    util.NewBoolPointer(true),
 		DsId:      mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
@@ -186,7 +189,7 @@ func f(cmd *cobra.Command, args []string) error {
 	fmt.Println("STEP6 commit if-diff")
 	if !reflect.DeepEqual(configRunningDs, configCandidateDs) {
 		fmt.Println("commit")
-		if err := client.CommitConfig(&mgmtd.FeCommitConfigReq{
+		if err := client.CommitConfig(ctx, &mgmtd.FeCommitConfigReq{
 			SessionId:    client.GetSessionId(),
 			ReqId:        util.NewUint64Pointer(0),
 			SrcDsId:      mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
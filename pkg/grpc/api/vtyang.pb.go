@@ -122,6 +122,834 @@ func (x *HelloResponse) GetData() string {
 	return ""
 }
 
+type YangData struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Xpath string `protobuf:"bytes,1,opt,name=xpath,proto3" json:"xpath,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *YangData) Reset() {
+	*x = YangData{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *YangData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*YangData) ProtoMessage() {}
+
+func (x *YangData) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use YangData.ProtoReflect.Descriptor instead.
+func (*YangData) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *YangData) GetXpath() string {
+	if x != nil {
+		return x.Xpath
+	}
+	return ""
+}
+
+func (x *YangData) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+type AgentRegister struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name           string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	OperStatePaths []string `protobuf:"bytes,2,rep,name=oper_state_paths,json=operStatePaths,proto3" json:"oper_state_paths,omitempty"`
+	Rpcs           []string `protobuf:"bytes,3,rep,name=rpcs,proto3" json:"rpcs,omitempty"`
+	ConfigPaths    []string `protobuf:"bytes,4,rep,name=config_paths,json=configPaths,proto3" json:"config_paths,omitempty"`
+}
+
+func (x *AgentRegister) Reset() {
+	*x = AgentRegister{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentRegister) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentRegister) ProtoMessage() {}
+
+func (x *AgentRegister) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentRegister.ProtoReflect.Descriptor instead.
+func (*AgentRegister) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AgentRegister) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AgentRegister) GetOperStatePaths() []string {
+	if x != nil {
+		return x.OperStatePaths
+	}
+	return nil
+}
+
+func (x *AgentRegister) GetRpcs() []string {
+	if x != nil {
+		return x.Rpcs
+	}
+	return nil
+}
+
+func (x *AgentRegister) GetConfigPaths() []string {
+	if x != nil {
+		return x.ConfigPaths
+	}
+	return nil
+}
+
+type OperStateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ReqId uint64 `protobuf:"varint,1,opt,name=req_id,json=reqId,proto3" json:"req_id,omitempty"`
+	Xpath string `protobuf:"bytes,2,opt,name=xpath,proto3" json:"xpath,omitempty"`
+}
+
+func (x *OperStateRequest) Reset() {
+	*x = OperStateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OperStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OperStateRequest) ProtoMessage() {}
+
+func (x *OperStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OperStateRequest.ProtoReflect.Descriptor instead.
+func (*OperStateRequest) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *OperStateRequest) GetReqId() uint64 {
+	if x != nil {
+		return x.ReqId
+	}
+	return 0
+}
+
+func (x *OperStateRequest) GetXpath() string {
+	if x != nil {
+		return x.Xpath
+	}
+	return ""
+}
+
+type OperStateReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ReqId uint64      `protobuf:"varint,1,opt,name=req_id,json=reqId,proto3" json:"req_id,omitempty"`
+	Data  []*YangData `protobuf:"bytes,2,rep,name=data,proto3" json:"data,omitempty"`
+	Error string      `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *OperStateReply) Reset() {
+	*x = OperStateReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OperStateReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OperStateReply) ProtoMessage() {}
+
+func (x *OperStateReply) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OperStateReply.ProtoReflect.Descriptor instead.
+func (*OperStateReply) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *OperStateReply) GetReqId() uint64 {
+	if x != nil {
+		return x.ReqId
+	}
+	return 0
+}
+
+func (x *OperStateReply) GetData() []*YangData {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *OperStateReply) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type RPCRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ReqId uint64 `protobuf:"varint,1,opt,name=req_id,json=reqId,proto3" json:"req_id,omitempty"`
+	Xpath string `protobuf:"bytes,2,opt,name=xpath,proto3" json:"xpath,omitempty"`
+	Input string `protobuf:"bytes,3,opt,name=input,proto3" json:"input,omitempty"`
+}
+
+func (x *RPCRequest) Reset() {
+	*x = RPCRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RPCRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPCRequest) ProtoMessage() {}
+
+func (x *RPCRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPCRequest.ProtoReflect.Descriptor instead.
+func (*RPCRequest) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RPCRequest) GetReqId() uint64 {
+	if x != nil {
+		return x.ReqId
+	}
+	return 0
+}
+
+func (x *RPCRequest) GetXpath() string {
+	if x != nil {
+		return x.Xpath
+	}
+	return ""
+}
+
+func (x *RPCRequest) GetInput() string {
+	if x != nil {
+		return x.Input
+	}
+	return ""
+}
+
+type RPCReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ReqId  uint64 `protobuf:"varint,1,opt,name=req_id,json=reqId,proto3" json:"req_id,omitempty"`
+	Output string `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"`
+	Error  string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *RPCReply) Reset() {
+	*x = RPCReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RPCReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPCReply) ProtoMessage() {}
+
+func (x *RPCReply) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPCReply.ProtoReflect.Descriptor instead.
+func (*RPCReply) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RPCReply) GetReqId() uint64 {
+	if x != nil {
+		return x.ReqId
+	}
+	return 0
+}
+
+func (x *RPCReply) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *RPCReply) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type Notification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Stream    string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Xpath     string `protobuf:"bytes,2,opt,name=xpath,proto3" json:"xpath,omitempty"`
+	Data      string `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	EventTime string `protobuf:"bytes,4,opt,name=event_time,json=eventTime,proto3" json:"event_time,omitempty"`
+}
+
+func (x *Notification) Reset() {
+	*x = Notification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Notification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Notification) ProtoMessage() {}
+
+func (x *Notification) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Notification.ProtoReflect.Descriptor instead.
+func (*Notification) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Notification) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *Notification) GetXpath() string {
+	if x != nil {
+		return x.Xpath
+	}
+	return ""
+}
+
+func (x *Notification) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *Notification) GetEventTime() string {
+	if x != nil {
+		return x.EventTime
+	}
+	return ""
+}
+
+type ConfigState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []*YangData `protobuf:"bytes,1,rep,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *ConfigState) Reset() {
+	*x = ConfigState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConfigState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigState) ProtoMessage() {}
+
+func (x *ConfigState) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigState.ProtoReflect.Descriptor instead.
+func (*ConfigState) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ConfigState) GetData() []*YangData {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ConfigApplyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ReqId  uint64 `protobuf:"varint,1,opt,name=req_id,json=reqId,proto3" json:"req_id,omitempty"`
+	Config string `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"`
+}
+
+func (x *ConfigApplyRequest) Reset() {
+	*x = ConfigApplyRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConfigApplyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigApplyRequest) ProtoMessage() {}
+
+func (x *ConfigApplyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigApplyRequest.ProtoReflect.Descriptor instead.
+func (*ConfigApplyRequest) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ConfigApplyRequest) GetReqId() uint64 {
+	if x != nil {
+		return x.ReqId
+	}
+	return 0
+}
+
+func (x *ConfigApplyRequest) GetConfig() string {
+	if x != nil {
+		return x.Config
+	}
+	return ""
+}
+
+type ConfigApplyReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ReqId uint64 `protobuf:"varint,1,opt,name=req_id,json=reqId,proto3" json:"req_id,omitempty"`
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *ConfigApplyReply) Reset() {
+	*x = ConfigApplyReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConfigApplyReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigApplyReply) ProtoMessage() {}
+
+func (x *ConfigApplyReply) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigApplyReply.ProtoReflect.Descriptor instead.
+func (*ConfigApplyReply) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ConfigApplyReply) GetReqId() uint64 {
+	if x != nil {
+		return x.ReqId
+	}
+	return 0
+}
+
+func (x *ConfigApplyReply) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type AgentMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Message:
+	//	*AgentMessage_Register
+	//	*AgentMessage_OperStateReply
+	//	*AgentMessage_RpcReply
+	//	*AgentMessage_Notification
+	//	*AgentMessage_ConfigState
+	//	*AgentMessage_ConfigApplyReply
+	Message isAgentMessage_Message `protobuf_oneof:"message"`
+}
+
+func (x *AgentMessage) Reset() {
+	*x = AgentMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentMessage) ProtoMessage() {}
+
+func (x *AgentMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentMessage.ProtoReflect.Descriptor instead.
+func (*AgentMessage) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{12}
+}
+
+func (m *AgentMessage) GetMessage() isAgentMessage_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetRegister() *AgentRegister {
+	if x, ok := x.GetMessage().(*AgentMessage_Register); ok {
+		return x.Register
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetOperStateReply() *OperStateReply {
+	if x, ok := x.GetMessage().(*AgentMessage_OperStateReply); ok {
+		return x.OperStateReply
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetRpcReply() *RPCReply {
+	if x, ok := x.GetMessage().(*AgentMessage_RpcReply); ok {
+		return x.RpcReply
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetNotification() *Notification {
+	if x, ok := x.GetMessage().(*AgentMessage_Notification); ok {
+		return x.Notification
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetConfigState() *ConfigState {
+	if x, ok := x.GetMessage().(*AgentMessage_ConfigState); ok {
+		return x.ConfigState
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetConfigApplyReply() *ConfigApplyReply {
+	if x, ok := x.GetMessage().(*AgentMessage_ConfigApplyReply); ok {
+		return x.ConfigApplyReply
+	}
+	return nil
+}
+
+type isAgentMessage_Message interface {
+	isAgentMessage_Message()
+}
+
+type AgentMessage_Register struct {
+	Register *AgentRegister `protobuf:"bytes,1,opt,name=register,proto3,oneof"`
+}
+
+type AgentMessage_OperStateReply struct {
+	OperStateReply *OperStateReply `protobuf:"bytes,2,opt,name=oper_state_reply,json=operStateReply,proto3,oneof"`
+}
+
+type AgentMessage_RpcReply struct {
+	RpcReply *RPCReply `protobuf:"bytes,3,opt,name=rpc_reply,json=rpcReply,proto3,oneof"`
+}
+
+type AgentMessage_Notification struct {
+	Notification *Notification `protobuf:"bytes,4,opt,name=notification,proto3,oneof"`
+}
+
+type AgentMessage_ConfigState struct {
+	ConfigState *ConfigState `protobuf:"bytes,5,opt,name=config_state,json=configState,proto3,oneof"`
+}
+
+type AgentMessage_ConfigApplyReply struct {
+	ConfigApplyReply *ConfigApplyReply `protobuf:"bytes,6,opt,name=config_apply_reply,json=configApplyReply,proto3,oneof"`
+}
+
+func (*AgentMessage_Register) isAgentMessage_Message() {}
+
+func (*AgentMessage_OperStateReply) isAgentMessage_Message() {}
+
+func (*AgentMessage_RpcReply) isAgentMessage_Message() {}
+
+func (*AgentMessage_Notification) isAgentMessage_Message() {}
+
+func (*AgentMessage_ConfigState) isAgentMessage_Message() {}
+
+func (*AgentMessage_ConfigApplyReply) isAgentMessage_Message() {}
+
+type AgentRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Request:
+	//	*AgentRequest_OperStateReq
+	//	*AgentRequest_RpcReq
+	//	*AgentRequest_ConfigApplyReq
+	Request isAgentRequest_Request `protobuf_oneof:"request"`
+}
+
+func (x *AgentRequest) Reset() {
+	*x = AgentRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_vtyang_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentRequest) ProtoMessage() {}
+
+func (x *AgentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vtyang_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentRequest.ProtoReflect.Descriptor instead.
+func (*AgentRequest) Descriptor() ([]byte, []int) {
+	return file_vtyang_proto_rawDescGZIP(), []int{13}
+}
+
+func (m *AgentRequest) GetRequest() isAgentRequest_Request {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (x *AgentRequest) GetOperStateReq() *OperStateRequest {
+	if x, ok := x.GetRequest().(*AgentRequest_OperStateReq); ok {
+		return x.OperStateReq
+	}
+	return nil
+}
+
+func (x *AgentRequest) GetRpcReq() *RPCRequest {
+	if x, ok := x.GetRequest().(*AgentRequest_RpcReq); ok {
+		return x.RpcReq
+	}
+	return nil
+}
+
+func (x *AgentRequest) GetConfigApplyReq() *ConfigApplyRequest {
+	if x, ok := x.GetRequest().(*AgentRequest_ConfigApplyReq); ok {
+		return x.ConfigApplyReq
+	}
+	return nil
+}
+
+type isAgentRequest_Request interface {
+	isAgentRequest_Request()
+}
+
+type AgentRequest_OperStateReq struct {
+	OperStateReq *OperStateRequest `protobuf:"bytes,1,opt,name=oper_state_req,json=operStateReq,proto3,oneof"`
+}
+
+type AgentRequest_RpcReq struct {
+	RpcReq *RPCRequest `protobuf:"bytes,2,opt,name=rpc_req,json=rpcReq,proto3,oneof"`
+}
+
+type AgentRequest_ConfigApplyReq struct {
+	ConfigApplyReq *ConfigApplyRequest `protobuf:"bytes,3,opt,name=config_apply_req,json=configApplyReq,proto3,oneof"`
+}
+
+func (*AgentRequest_OperStateReq) isAgentRequest_Request() {}
+
+func (*AgentRequest_RpcReq) isAgentRequest_Request() {}
+
+func (*AgentRequest_ConfigApplyReq) isAgentRequest_Request() {}
+
 var File_vtyang_proto protoreflect.FileDescriptor
 
 var file_vtyang_proto_rawDesc = []byte{
@@ -132,17 +960,109 @@ var file_vtyang_proto_rawDesc = []byte{
 	0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65,
 	0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73,
 	0x73, 0x61, 0x67, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x32, 0x83, 0x01, 0x0a, 0x0f, 0x47, 0x72, 0x65,
-	0x65, 0x74, 0x69, 0x6e, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x32, 0x0a, 0x05,
-	0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x13, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x48, 0x65,
-	0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x6d, 0x79, 0x61,
-	0x70, 0x70, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x12, 0x3c, 0x0a, 0x0b, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12,
-	0x13, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x48, 0x65, 0x6c,
-	0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42, 0x0a,
-	0x5a, 0x08, 0x70, 0x6b, 0x67, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x28, 0x09, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x36, 0x0a, 0x08, 0x59, 0x61, 0x6e, 0x67,
+	0x44, 0x61, 0x74, 0x61, 0x12, 0x14, 0x0a, 0x05, 0x78, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x78, 0x70, 0x61, 0x74, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x22, 0x84, 0x01, 0x0a, 0x0d, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74,
+	0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x28, 0x0a, 0x10, 0x6f, 0x70, 0x65, 0x72, 0x5f, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x0e, 0x6f, 0x70, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x50, 0x61, 0x74, 0x68, 0x73,
+	0x12, 0x12, 0x0a, 0x04, 0x72, 0x70, 0x63, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04,
+	0x72, 0x70, 0x63, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x5f, 0x70,
+	0x61, 0x74, 0x68, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x50, 0x61, 0x74, 0x68, 0x73, 0x22, 0x3f, 0x0a, 0x10, 0x4f, 0x70, 0x65, 0x72, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x72,
+	0x65, 0x71, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x72, 0x65, 0x71,
+	0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x78, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x78, 0x70, 0x61, 0x74, 0x68, 0x22, 0x62, 0x0a, 0x0e, 0x4f, 0x70, 0x65, 0x72,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x15, 0x0a, 0x06, 0x72, 0x65,
+	0x71, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x72, 0x65, 0x71, 0x49,
+	0x64, 0x12, 0x23, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x0f, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x59, 0x61, 0x6e, 0x67, 0x44, 0x61, 0x74, 0x61,
+	0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x4f, 0x0a, 0x0a,
+	0x52, 0x50, 0x43, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x72, 0x65,
+	0x71, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x72, 0x65, 0x71, 0x49,
+	0x64, 0x12, 0x14, 0x0a, 0x05, 0x78, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x78, 0x70, 0x61, 0x74, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x22, 0x4f, 0x0a,
+	0x08, 0x52, 0x50, 0x43, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x15, 0x0a, 0x06, 0x72, 0x65, 0x71,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x72, 0x65, 0x71, 0x49, 0x64,
+	0x12, 0x16, 0x0a, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f,
+	0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x6f,
+	0x0a, 0x0c, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16,
+	0x0a, 0x06, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x78, 0x70, 0x61, 0x74, 0x68, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x78, 0x70, 0x61, 0x74, 0x68, 0x12, 0x12, 0x0a, 0x04,
+	0x64, 0x61, 0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61,
+	0x12, 0x1d, 0x0a, 0x0a, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x22,
+	0x32, 0x0a, 0x0b, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x23,
+	0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x6d,
+	0x79, 0x61, 0x70, 0x70, 0x2e, 0x59, 0x61, 0x6e, 0x67, 0x44, 0x61, 0x74, 0x61, 0x52, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x22, 0x43, 0x0a, 0x12, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x41, 0x70, 0x70,
+	0x6c, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x72, 0x65, 0x71,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x72, 0x65, 0x71, 0x49, 0x64,
+	0x12, 0x16, 0x0a, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x22, 0x3f, 0x0a, 0x10, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x41, 0x70, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x15, 0x0a, 0x06,
+	0x72, 0x65, 0x71, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x72, 0x65,
+	0x71, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0xfd, 0x02, 0x0a, 0x0c, 0x41, 0x67,
+	0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x32, 0x0a, 0x08, 0x72, 0x65,
+	0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6d,
+	0x79, 0x61, 0x70, 0x70, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74,
+	0x65, 0x72, 0x48, 0x00, 0x52, 0x08, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x12, 0x41,
+	0x0a, 0x10, 0x6f, 0x70, 0x65, 0x72, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x72, 0x65, 0x70,
+	0x6c, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70,
+	0x2e, 0x4f, 0x70, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x48,
+	0x00, 0x52, 0x0e, 0x6f, 0x70, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c,
+	0x79, 0x12, 0x2e, 0x0a, 0x09, 0x72, 0x70, 0x63, 0x5f, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x52, 0x50, 0x43,
+	0x52, 0x65, 0x70, 0x6c, 0x79, 0x48, 0x00, 0x52, 0x08, 0x72, 0x70, 0x63, 0x52, 0x65, 0x70, 0x6c,
+	0x79, 0x12, 0x39, 0x0a, 0x0c, 0x6e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e,
+	0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x0c,
+	0x6e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x37, 0x0a, 0x0c,
+	0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x53, 0x74, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x47, 0x0a, 0x12, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x5f,
+	0x61, 0x70, 0x70, 0x6c, 0x79, 0x5f, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x17, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67,
+	0x41, 0x70, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x48, 0x00, 0x52, 0x10, 0x63, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x41, 0x70, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x42, 0x09,
+	0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0xcf, 0x01, 0x0a, 0x0c, 0x41, 0x67,
+	0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3f, 0x0a, 0x0e, 0x6f, 0x70,
+	0x65, 0x72, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x72, 0x65, 0x71, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x4f, 0x70, 0x65, 0x72, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0c, 0x6f,
+	0x70, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x12, 0x2c, 0x0a, 0x07, 0x72,
+	0x70, 0x63, 0x5f, 0x72, 0x65, 0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6d,
+	0x79, 0x61, 0x70, 0x70, 0x2e, 0x52, 0x50, 0x43, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48,
+	0x00, 0x52, 0x06, 0x72, 0x70, 0x63, 0x52, 0x65, 0x71, 0x12, 0x45, 0x0a, 0x10, 0x63, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x5f, 0x61, 0x70, 0x70, 0x6c, 0x79, 0x5f, 0x72, 0x65, 0x71, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x41, 0x70, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00,
+	0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x41, 0x70, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x71,
+	0x42, 0x09, 0x0a, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x32, 0x83, 0x01, 0x0a, 0x0f,
+	0x47, 0x72, 0x65, 0x65, 0x74, 0x69, 0x6e, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12,
+	0x32, 0x0a, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x13, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70,
+	0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e,
+	0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x3c, 0x0a, 0x0b, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x12, 0x13, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e,
+	0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30,
+	0x01, 0x32, 0x47, 0x0a, 0x0c, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x12, 0x37, 0x0a, 0x07, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x12, 0x13, 0x2e, 0x6d,
+	0x79, 0x61, 0x70, 0x70, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x1a, 0x13, 0x2e, 0x6d, 0x79, 0x61, 0x70, 0x70, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x28, 0x01, 0x30, 0x01, 0x42, 0x0a, 0x5a, 0x08, 0x70, 0x6b,
+	0x67, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -157,21 +1077,46 @@ func file_vtyang_proto_rawDescGZIP() []byte {
 	return file_vtyang_proto_rawDescData
 }
 
-var file_vtyang_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_vtyang_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_vtyang_proto_goTypes = []interface{}{
-	(*HelloRequest)(nil),  // 0: myapp.HelloRequest
-	(*HelloResponse)(nil), // 1: myapp.HelloResponse
+	(*HelloRequest)(nil),       // 0: myapp.HelloRequest
+	(*HelloResponse)(nil),      // 1: myapp.HelloResponse
+	(*YangData)(nil),           // 2: myapp.YangData
+	(*AgentRegister)(nil),      // 3: myapp.AgentRegister
+	(*OperStateRequest)(nil),   // 4: myapp.OperStateRequest
+	(*OperStateReply)(nil),     // 5: myapp.OperStateReply
+	(*RPCRequest)(nil),         // 6: myapp.RPCRequest
+	(*RPCReply)(nil),           // 7: myapp.RPCReply
+	(*Notification)(nil),       // 8: myapp.Notification
+	(*ConfigState)(nil),        // 9: myapp.ConfigState
+	(*ConfigApplyRequest)(nil), // 10: myapp.ConfigApplyRequest
+	(*ConfigApplyReply)(nil),   // 11: myapp.ConfigApplyReply
+	(*AgentMessage)(nil),       // 12: myapp.AgentMessage
+	(*AgentRequest)(nil),       // 13: myapp.AgentRequest
 }
 var file_vtyang_proto_depIdxs = []int32{
-	0, // 0: myapp.GreetingService.Hello:input_type -> myapp.HelloRequest
-	0, // 1: myapp.GreetingService.HelloStream:input_type -> myapp.HelloRequest
-	1, // 2: myapp.GreetingService.Hello:output_type -> myapp.HelloResponse
-	1, // 3: myapp.GreetingService.HelloStream:output_type -> myapp.HelloResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2,  // 0: myapp.OperStateReply.data:type_name -> myapp.YangData
+	2,  // 1: myapp.ConfigState.data:type_name -> myapp.YangData
+	3,  // 2: myapp.AgentMessage.register:type_name -> myapp.AgentRegister
+	5,  // 3: myapp.AgentMessage.oper_state_reply:type_name -> myapp.OperStateReply
+	7,  // 4: myapp.AgentMessage.rpc_reply:type_name -> myapp.RPCReply
+	8,  // 5: myapp.AgentMessage.notification:type_name -> myapp.Notification
+	9,  // 6: myapp.AgentMessage.config_state:type_name -> myapp.ConfigState
+	11, // 7: myapp.AgentMessage.config_apply_reply:type_name -> myapp.ConfigApplyReply
+	4,  // 8: myapp.AgentRequest.oper_state_req:type_name -> myapp.OperStateRequest
+	6,  // 9: myapp.AgentRequest.rpc_req:type_name -> myapp.RPCRequest
+	10, // 10: myapp.AgentRequest.config_apply_req:type_name -> myapp.ConfigApplyRequest
+	0,  // 11: myapp.GreetingService.Hello:input_type -> myapp.HelloRequest
+	0,  // 12: myapp.GreetingService.HelloStream:input_type -> myapp.HelloRequest
+	12, // 13: myapp.AgentService.Connect:input_type -> myapp.AgentMessage
+	1,  // 14: myapp.GreetingService.Hello:output_type -> myapp.HelloResponse
+	1,  // 15: myapp.GreetingService.HelloStream:output_type -> myapp.HelloResponse
+	13, // 16: myapp.AgentService.Connect:output_type -> myapp.AgentRequest
+	14, // [14:17] is the sub-list for method output_type
+	11, // [11:14] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_vtyang_proto_init() }
@@ -204,6 +1149,163 @@ func file_vtyang_proto_init() {
 				return nil
 			}
 		}
+		file_vtyang_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*YangData); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentRegister); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OperStateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OperStateReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RPCRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RPCReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Notification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConfigState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConfigApplyRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConfigApplyReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_vtyang_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_vtyang_proto_msgTypes[12].OneofWrappers = []interface{}{
+		(*AgentMessage_Register)(nil),
+		(*AgentMessage_OperStateReply)(nil),
+		(*AgentMessage_RpcReply)(nil),
+		(*AgentMessage_Notification)(nil),
+		(*AgentMessage_ConfigState)(nil),
+		(*AgentMessage_ConfigApplyReply)(nil),
+	}
+	file_vtyang_proto_msgTypes[13].OneofWrappers = []interface{}{
+		(*AgentRequest_OperStateReq)(nil),
+		(*AgentRequest_RpcReq)(nil),
+		(*AgentRequest_ConfigApplyReq)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -211,9 +1313,9 @@ func file_vtyang_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_vtyang_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   14,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_vtyang_proto_goTypes,
 		DependencyIndexes: file_vtyang_proto_depIdxs,
@@ -34,6 +34,9 @@ type commonState struct {
 	shouldRestart     ShouldRestart
 	noBeep            bool
 	needRefresh       bool
+
+	// Added by slankdev
+	out io.Writer
 }
 
 // TabStyle is used to select how tab completions are displayed.
@@ -260,7 +263,7 @@ func (s *State) SetBeep(beep bool) {
 
 func (s *State) promptUnsupported(p string) (string, error) {
 	if !s.inputRedirected || !s.terminalSupported {
-		fmt.Print(p)
+		fmt.Fprint(s.out, p)
 	}
 	linebuf, _, err := s.r.ReadLine()
 	if err != nil {
@@ -31,6 +31,7 @@ func (s *State) PasswordPrompt(p string) (string, error) {
 // editing. Patches welcome.
 func NewLiner() *State {
 	var s State
+	s.out = os.Stdout
 	s.r = bufio.NewReader(os.Stdin)
 	return &s
 }
@@ -6,6 +6,7 @@ package liner
 import (
 	"bufio"
 	"errors"
+	"io"
 	"os"
 	"os/signal"
 	"strconv"
@@ -31,6 +32,10 @@ type State struct {
 
 	// Added by slankdev
 	binder map[rune]Binder
+	// remote is true for the terminal other than the one of the process,
+	// the columns are given by SetColumns
+	remote bool
+	resize chan int
 }
 
 // NewLiner initializes a new *State, and sets the terminal into raw mode. To
@@ -41,6 +46,7 @@ func NewLiner() *State {
 
 	// Added by slakdev
 	s.binder = map[rune]Binder{}
+	s.out = os.Stdout
 
 	s.terminalSupported = TerminalSupported()
 	if m, err := TerminalMode(); err == nil {
@@ -77,10 +83,44 @@ func NewLiner() *State {
 	return &s
 }
 
+// NewLinerTerminal initializes a new *State on the terminal other than
+// the one of the process, such as the pty of ssh. The terminal is expected
+// to be in raw mode already, and to translate nothing of the output.
+// Added by slankdev
+func NewLinerTerminal(r io.Reader, w io.Writer, term string, columns int) *State {
+	var s State
+	s.r = bufio.NewReader(r)
+	s.out = w
+	s.binder = map[rune]Binder{}
+	s.remote = true
+	s.resize = make(chan int, 1)
+	s.columns = columns
+	s.terminalSupported = !badTerminal(term)
+	s.useCHA = strings.Contains(strings.ToLower(term), "xterm")
+	return &s
+}
+
+// SetColumns changes the width of the remote terminal, for the window
+// change of the pty. Added by slankdev
+func (s *State) SetColumns(columns int) {
+	for {
+		select {
+		case s.resize <- columns:
+			return
+		default:
+		}
+		// Replace the change not read yet
+		select {
+		case <-s.resize:
+		default:
+		}
+	}
+}
+
 var errTimedOut = errors.New("timeout")
 
 func (s *State) startPrompt() {
-	if s.terminalSupported {
+	if s.terminalSupported && !s.remote {
 		if m, err := TerminalMode(); err == nil {
 			s.defaultMode = *m.(*termios)
 			mode := s.defaultMode
@@ -113,7 +153,7 @@ func (s *State) restartPrompt() {
 }
 
 func (s *State) stopPrompt() {
-	if s.terminalSupported {
+	if s.terminalSupported && !s.remote {
 		s.defaultMode.ApplyMode()
 	}
 }
@@ -155,6 +195,9 @@ func (s *State) readNext() (interface{}, error) {
 	case <-s.winch:
 		s.getColumns()
 		return winch, nil
+	case columns := <-s.resize:
+		s.columns = columns
+		return winch, nil
 	}
 	if r != esc {
 		return r, nil
@@ -361,6 +404,9 @@ func (s *State) readNext() (interface{}, error) {
 
 // Close returns the terminal to its previous mode
 func (s *State) Close() error {
+	if s.remote {
+		return nil
+	}
 	signal.Stop(s.winch)
 	if !s.inputRedirected {
 		s.origMode.ApplyMode()
@@ -374,6 +420,10 @@ func (s *State) Close() error {
 // Note that TerminalSupported does not check all factors that may
 // cause liner to not fully support the terminal (such as stdin redirection)
 func TerminalSupported() bool {
+	return !badTerminal(os.Getenv("TERM"))
+}
+
+func badTerminal(term string) bool {
 	bad := map[string]bool{"": true, "dumb": true, "cons25": true}
-	return !bad[strings.ToLower(os.Getenv("TERM"))]
+	return bad[strings.ToLower(term)]
 }
@@ -108,7 +108,7 @@ func (s *State) refresh(prompt []rune, buf []rune, pos int) error {
 
 func (s *State) refreshSingleLine(prompt []rune, buf []rune, pos int) error {
 	s.cursorPos(0)
-	_, err := fmt.Print(string(prompt))
+	_, err := fmt.Fprint(s.out, string(prompt))
 	if err != nil {
 		return err
 	}
@@ -121,7 +121,7 @@ func (s *State) refreshSingleLine(prompt []rune, buf []rune, pos int) error {
 	}
 	pos = countGlyphs(buf[:pos])
 	if pLen+bLen < s.columns {
-		_, err = fmt.Print(string(buf))
+		_, err = fmt.Fprint(s.out, string(buf))
 		s.eraseLine()
 		s.cursorPos(pLen + pos)
 	} else {
@@ -152,11 +152,11 @@ func (s *State) refreshSingleLine(prompt []rune, buf []rune, pos int) error {
 
 		// Output
 		if start > 0 {
-			fmt.Print("{")
+			fmt.Fprint(s.out, "{")
 		}
-		fmt.Print(string(line))
+		fmt.Fprint(s.out, string(line))
 		if end < bLen {
-			fmt.Print("}")
+			fmt.Fprint(s.out, "}")
 		}
 
 		// Set cursor position
@@ -205,10 +205,10 @@ func (s *State) refreshMultiLine(prompt []rune, buf []rune, pos int) error {
 	s.eraseLine()
 
 	/* Write the prompt and the current buffer content */
-	if _, err := fmt.Print(string(prompt)); err != nil {
+	if _, err := fmt.Fprint(s.out, string(prompt)); err != nil {
 		return err
 	}
-	if _, err := fmt.Print(string(buf)); err != nil {
+	if _, err := fmt.Fprint(s.out, string(buf)); err != nil {
 		return err
 	}
 
@@ -243,7 +243,7 @@ func (s *State) resetMultiLine(prompt []rune, buf []rune, pos int) {
 	cursorRows := (columns + s.columns) / s.columns
 	if s.maxRows-cursorRows > 0 {
 		for i := 0; i < s.maxRows-cursorRows; i++ {
-			fmt.Println() // always moves the cursor down or scrolls the window up as needed
+			fmt.Fprintln(s.out) // always moves the cursor down or scrolls the window up as needed
 		}
 	}
 	s.maxRows = 1
@@ -319,7 +319,7 @@ func (s *State) printedTabs(items []string) func(tabDirection) (string, error) {
 
 		if numTabs == 2 {
 			if len(items) > 100 {
-				fmt.Printf("\nDisplay all %d possibilities? (y or n) ", len(items))
+				fmt.Fprintf(s.out, "\nDisplay all %d possibilities? (y or n) ", len(items))
 			prompt:
 				for {
 					next, err := s.readNext()
@@ -339,7 +339,7 @@ func (s *State) printedTabs(items []string) func(tabDirection) (string, error) {
 					}
 				}
 			}
-			fmt.Println("")
+			fmt.Fprintln(s.out, "")
 
 			numColumns, numRows, maxWidth := calculateColumns(s.columns, items)
 
@@ -347,13 +347,13 @@ func (s *State) printedTabs(items []string) func(tabDirection) (string, error) {
 				for j := 0; j < numColumns*numRows; j += numRows {
 					if i+j < len(items) {
 						if maxWidth > 0 {
-							fmt.Printf("%-*.[1]*s", maxWidth, items[i+j])
+							fmt.Fprintf(s.out, "%-*.[1]*s", maxWidth, items[i+j])
 						} else {
-							fmt.Printf("%v ", items[i+j])
+							fmt.Fprintf(s.out, "%v ", items[i+j])
 						}
 					}
 				}
-				fmt.Println("")
+				fmt.Fprintln(s.out, "")
 			}
 		} else {
 			numTabs++
@@ -622,7 +622,7 @@ func (s *State) PromptWithSuggestion(prompt string, text string, pos int) (strin
 	s.historyMutex.RLock()
 	defer s.historyMutex.RUnlock()
 
-	fmt.Print(prompt)
+	fmt.Fprint(s.out, prompt)
 	var line = []rune(text)
 	historyEnd := ""
 	var historyPrefix []string
@@ -672,7 +672,7 @@ mainLoop:
 				if s.multiLineMode {
 					s.resetMultiLine(p, line, pos)
 				}
-				fmt.Println()
+				fmt.Fprintln(s.out)
 				break mainLoop
 			case ctrlA: // Start of line
 				pos = 0
@@ -782,7 +782,7 @@ mainLoop:
 				s.eraseScreen()
 				s.needRefresh = true
 			case ctrlC: // reset
-				fmt.Println("^C")
+				fmt.Fprintln(s.out, "^C")
 				if s.multiLineMode {
 					s.resetMultiLine(p, line, pos)
 				}
@@ -791,7 +791,7 @@ mainLoop:
 				}
 				line = line[:0]
 				pos = 0
-				fmt.Print(prompt)
+				fmt.Fprint(s.out, prompt)
 				s.restartPrompt()
 			case ctrlH, bs: // Backspace
 				if pos <= 0 {
@@ -848,7 +848,7 @@ mainLoop:
 					len(p)+len(line) < s.columns*4 && // Avoid countGlyphs on large lines
 					countGlyphs(p)+countGlyphs(line) < s.columns-1 {
 					line = append(line, v)
-					fmt.Printf("%c", v)
+					fmt.Fprintf(s.out, "%c", v)
 					pos++
 				} else {
 					line = append(line[:pos], append([]rune{v}, line[pos:]...)...)
@@ -1047,7 +1047,7 @@ restart:
 	s.startPrompt()
 	s.getColumns()
 
-	fmt.Print(prompt)
+	fmt.Fprint(s.out, prompt)
 	var line []rune
 	pos := 0
 
@@ -1065,7 +1065,7 @@ mainLoop:
 		case rune:
 			switch v {
 			case cr, lf:
-				fmt.Println()
+				fmt.Fprintln(s.out)
 				break mainLoop
 			case ctrlD: // del
 				if pos == 0 && len(line) == 0 {
@@ -1091,13 +1091,13 @@ mainLoop:
 					pos -= n
 				}
 			case ctrlC:
-				fmt.Println("^C")
+				fmt.Fprintln(s.out, "^C")
 				if s.ctrlCAborts {
 					return "", ErrPromptAborted
 				}
 				line = line[:0]
 				pos = 0
-				fmt.Print(prompt)
+				fmt.Fprint(s.out, prompt)
 				s.restartPrompt()
 			// Unused keys
 			case esc, tab, ctrlA, ctrlB, ctrlE, ctrlF, ctrlG, ctrlK, ctrlN, ctrlO, ctrlP, ctrlQ, ctrlR, ctrlS,
@@ -1116,6 +1116,9 @@ mainLoop:
 }
 
 func (s *State) tooNarrow(prompt string) (string, error) {
+	if s.remote {
+		return s.promptUnsupported(prompt)
+	}
 	// Docker and OpenWRT and etc sometimes return 0 column width
 	// Reset mode temporarily. Restore baked mode in case the terminal
 	// is wide enough for the next Prompt attempt.
@@ -1174,6 +1177,6 @@ func (s *State) eraseWord(pos int, line []rune, killAction int) (int, []rune, in
 
 func (s *State) doBeep() {
 	if !s.noBeep {
-		fmt.Print(beep)
+		fmt.Fprint(s.out, beep)
 	}
 }
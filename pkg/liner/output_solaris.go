@@ -5,6 +5,9 @@ import (
 )
 
 func (s *State) getColumns() bool {
+	if s.remote {
+		return true
+	}
 	ws, err := unix.IoctlGetWinsize(unix.Stdout, unix.TIOCGWINSZ)
 	if err != nil {
 		return false
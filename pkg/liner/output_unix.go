@@ -9,6 +9,9 @@ import (
 )
 
 func (s *State) getColumns() bool {
+	if s.remote {
+		return true
+	}
 	var ws winSize
 	ok, _, _ := syscall.Syscall(syscall.SYS_IOCTL, uintptr(syscall.Stdout),
 		syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
@@ -0,0 +1,209 @@
+// Package agent reconciles the kernel network state with the config of
+// linux-agent.yang through netlink.
+package agent
+
+import (
+	"net"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+
+	"github.com/slankdev/vtyang/pkg/linux-agent/yang"
+)
+
+type Options struct {
+	// Netns is the name of the network namespace (e.g. ns0) or the path of
+	// its handle (e.g. /proc/1/ns/net). The current one is used when empty.
+	Netns string
+	// DryRun only plans the changes without applying them.
+	DryRun bool
+}
+
+type Agent struct {
+	opts   Options
+	ns     netns.NsHandle
+	handle *netlink.Handle
+}
+
+func New(opts Options) (*Agent, error) {
+	if opts.Netns == "" {
+		h, err := netlink.NewHandle()
+		if err != nil {
+			return nil, errors.Wrap(err, "netlink.NewHandle")
+		}
+		return &Agent{opts: opts, ns: netns.None(), handle: h}, nil
+	}
+
+	var ns netns.NsHandle
+	var err error
+	if strings.Contains(opts.Netns, "/") {
+		ns, err = netns.GetFromPath(opts.Netns)
+	} else {
+		ns, err = netns.GetFromName(opts.Netns)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "netns(%s)", opts.Netns)
+	}
+	h, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		ns.Close()
+		return nil, errors.Wrap(err, "netlink.NewHandleAt")
+	}
+	return &Agent{opts: opts, ns: ns, handle: h}, nil
+}
+
+func (a *Agent) Close() {
+	a.handle.Delete()
+	if a.ns.IsOpen() {
+		a.ns.Close()
+	}
+}
+
+// inNetns calls f in the netns of the agent. It's needed for the
+// operations done without netlink, such as sysctl. When the original
+// netns can't be restored, the thread is kept locked, so that no other
+// goroutine runs in the netns and the runtime terminates the thread when
+// the goroutine exits.
+func (a *Agent) inNetns(f func() error) error {
+	if !a.ns.IsOpen() {
+		return f()
+	}
+	runtime.LockOSThread()
+	orig, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return errors.Wrap(err, "netns.Get")
+	}
+	defer orig.Close()
+	if err := netns.Set(a.ns); err != nil {
+		runtime.UnlockOSThread()
+		return errors.Wrap(err, "netns.Set")
+	}
+	ret := f()
+	if err := netns.Set(orig); err != nil {
+		return errors.Wrap(err, "netns.Set(restore)")
+	}
+	runtime.UnlockOSThread()
+	return ret
+}
+
+// Change is an operation on the kernel state. It's described in the
+// syntax of iproute2.
+type Change struct {
+	Description string
+	apply       func() error
+}
+
+func (c Change) String() string {
+	return c.Description
+}
+
+// Reconcile validates device and changes the kernel state to match it.
+// The planned changes are returned, and they are only applied when the
+// agent isn't in dry-run mode. On failure, the changes applied before
+// the failing one are returned with the error.
+func (a *Agent) Reconcile(device *yang.Device) ([]Change, error) {
+	if err := Validate(device); err != nil {
+		return nil, errors.Wrap(err, "Validate")
+	}
+	changes, err := a.plan(device)
+	if err != nil {
+		return nil, errors.Wrap(err, "plan")
+	}
+	if a.opts.DryRun {
+		return changes, nil
+	}
+	for idx, c := range changes {
+		if err := c.apply(); err != nil {
+			return changes[:idx], errors.Wrapf(err, "%s", c)
+		}
+	}
+	return changes, nil
+}
+
+// state is the kernel state the changes are planned from.
+type state struct {
+	links map[string]netlink.Link
+	// addrs are the addresses managed by the agent, i.e. the addresses
+	// except loopback and IPv6 link-local ones.
+	addrs map[string][]string
+	// created are the types of the links created by the planned changes
+	// indexed by name.
+	created map[string]string
+}
+
+func (s *state) linkExists(name string) bool {
+	_, ok := s.links[name]
+	return ok || s.created[name] != ""
+}
+
+func (s *state) linkByIndex(index int) netlink.Link {
+	if index == 0 {
+		return nil
+	}
+	for _, link := range s.links {
+		if link.Attrs().Index == index {
+			return link
+		}
+	}
+	return nil
+}
+
+func (a *Agent) getState() (*state, error) {
+	links, err := a.handle.LinkList()
+	if err != nil {
+		return nil, errors.Wrap(err, "LinkList")
+	}
+	s := &state{
+		links:   map[string]netlink.Link{},
+		addrs:   map[string][]string{},
+		created: map[string]string{},
+	}
+	for _, link := range links {
+		name := link.Attrs().Name
+		s.links[name] = link
+		addrs, err := a.handle.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "AddrList(%s)", name)
+		}
+		for _, addr := range addrs {
+			if addr.IP.IsLoopback() || addr.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			ones, _ := addr.Mask.Size()
+			s.addrs[name] = append(s.addrs[name],
+				formatPrefix(addr.IP, ones))
+		}
+	}
+	return s, nil
+}
+
+// formatPrefix formats the address as net.ParseCIDR accepts, keeping the
+// host part.
+func formatPrefix(ip net.IP, ones int) string {
+	return (&net.IPNet{IP: ip, Mask: net.CIDRMask(ones, len(ip)*8)}).String()
+}
+
+// normalizePrefix returns s in the format of formatPrefix.
+func normalizePrefix(s string) (string, error) {
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return "", err
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	ones, _ := ipnet.Mask.Size()
+	return formatPrefix(ip, ones), nil
+}
+
+func (a *Agent) linkByName(name string) (netlink.Link, error) {
+	link, err := a.handle.LinkByName(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "LinkByName(%s)", name)
+	}
+	return link, nil
+}
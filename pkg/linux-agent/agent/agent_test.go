@@ -0,0 +1,656 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/slankdev/vtyang/pkg/linux-agent/yang"
+)
+
+const envInNetns = "LINUX_AGENT_TEST_IN_NETNS"
+
+// TestMain re-executes the tests in a new user and network namespace, so
+// that the kernel state can be changed without privileges. When the user
+// namespace isn't available, the tests needing it are skipped.
+func TestMain(m *testing.M) {
+	if os.Getenv(envInNetns) == "" {
+		cmd := exec.Command(os.Args[0], os.Args[1:]...)
+		cmd.Env = append(os.Environ(), envInNetns+"=1")
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+			UidMappings: []syscall.SysProcIDMap{
+				{ContainerID: 0, HostID: os.Getuid(), Size: 1},
+			},
+			GidMappings: []syscall.SysProcIDMap{
+				{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+			},
+		}
+		err := cmd.Run()
+		if err == nil {
+			os.Exit(0)
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "user namespace unavailable: %s\n", err)
+		os.Setenv(envInNetns, "skip")
+	}
+	os.Exit(m.Run())
+}
+
+func requireNetns(t *testing.T) {
+	if os.Getenv(envInNetns) != "1" {
+		t.Skip("user namespace unavailable")
+	}
+}
+
+func newTestVeth(t *testing.T, name, peer string) {
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = name
+	if err := netlink.LinkAdd(&netlink.Veth{
+		LinkAttrs: attrs,
+		PeerName:  peer,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if link, err := netlink.LinkByName(name); err == nil {
+			netlink.LinkDel(link)
+		}
+	})
+}
+
+func newTestAgent(t *testing.T, dryRun bool) *Agent {
+	// The handle is opened from the path to test the netns support
+	a, err := New(Options{Netns: "/proc/self/ns/net", DryRun: dryRun})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.Close)
+	return a
+}
+
+func changeStrings(changes []Change) []string {
+	ret := []string{}
+	for _, c := range changes {
+		ret = append(ret, c.String())
+	}
+	return ret
+}
+
+func strp(s string) *string { return &s }
+func u16p(v uint16) *uint16 { return &v }
+func boolp(v bool) *bool    { return &v }
+func u8p(v uint8) *uint8    { return &v }
+func u32p(v uint32) *uint32 { return &v }
+func device(ifaces ...yang.LinuxAgent_Interfaces_Interface) *yang.Device {
+	return &yang.Device{
+		Interfaces: yang.LinuxAgent_Interfaces{Interface: ifaces},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testcases := []struct {
+		iface yang.LinuxAgent_Interfaces_Interface
+		err   string
+	}{
+		{
+			iface: yang.LinuxAgent_Interfaces_Interface{
+				Name:    strp("eth0"),
+				Address: []string{"10.0.0.1/24", "2001:db8::1/64"},
+				Mtu:     u16p(9000),
+			},
+		},
+		{
+			iface: yang.LinuxAgent_Interfaces_Interface{
+				Name: strp("interface-name-too-long"),
+			},
+			err: "interface interface-name-too-long: name is longer than 15",
+		},
+		{
+			iface: yang.LinuxAgent_Interfaces_Interface{
+				Name:    strp("eth0"),
+				Address: []string{"10.0.0.1"},
+			},
+			err: "interface eth0: address \"10.0.0.1\" is invalid",
+		},
+		{
+			iface: yang.LinuxAgent_Interfaces_Interface{
+				Name:    strp("eth0"),
+				Address: []string{"10.0.0.1/24", "10.0.0.1/24"},
+			},
+			err: "interface eth0: address 10.0.0.1/24 is duplicated",
+		},
+		{
+			iface: yang.LinuxAgent_Interfaces_Interface{
+				Name:    strp("eth0"),
+				Address: []string{"2001:db8::1/64"},
+				Mtu:     u16p(1000),
+			},
+			err: "interface eth0: mtu 1000 is less than 1280 required by IPv6",
+		},
+		{
+			iface: yang.LinuxAgent_Interfaces_Interface{
+				Name: strp("eth0.10"),
+				Vlan: &yang.LinuxAgent_Interfaces_Interface_Vlan{
+					Id: u16p(10),
+				},
+			},
+			err: "interface eth0.10: vlan parent is required",
+		},
+		{
+			iface: yang.LinuxAgent_Interfaces_Interface{
+				Name: strp("eth0.4095"),
+				Vlan: &yang.LinuxAgent_Interfaces_Interface_Vlan{
+					Parent: strp("eth0"),
+					Id:     u16p(4095),
+				},
+			},
+			err: "interface eth0.4095: vlan id 4095 is out of range 1..4094",
+		},
+	}
+	for _, tc := range testcases {
+		err := Validate(device(tc.iface))
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tc.err {
+			t.Errorf("expected %q, got %q", tc.err, errStr)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	requireNetns(t)
+	newTestVeth(t, "v0", "v1")
+	v1, err := netlink.LinkByName("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, _ := netlink.ParseAddr("10.0.1.1/24")
+	if err := netlink.AddrAdd(v1, addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := netlink.LinkSetUp(v1); err != nil {
+		t.Fatal(err)
+	}
+
+	config := device(yang.LinuxAgent_Interfaces_Interface{
+		Name:    strp("v0"),
+		Address: []string{"10.0.0.1/24", "2001:db8::1/64"},
+		Mtu:     u16p(9000),
+	})
+	expected := []string{
+		"ip link set dev v0 mtu 9000",
+		"ip addr add 10.0.0.1/24 dev v0",
+		"ip addr add 2001:db8::1/64 dev v0",
+		"ip link set dev v0 up",
+		"ip addr flush dev v1",
+		"ip link set dev v1 down",
+	}
+
+	// Dry-run doesn't change the kernel state
+	for i := 0; i < 2; i++ {
+		changes, err := newTestAgent(t, true).Reconcile(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := changeStrings(changes); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("unexpected changes %q", got)
+		}
+	}
+
+	a := newTestAgent(t, false)
+	changes, err := a.Reconcile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changeStrings(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected changes %q", got)
+	}
+	changes, err = a.Reconcile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("not converged %q", changeStrings(changes))
+	}
+
+	config.Interfaces.Interface[0].Address = []string{"10.0.0.1/24"}
+	config.Interfaces.Interface[0].Enabled = boolp(false)
+	changes, err = a.Reconcile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"ip addr del 2001:db8::1/64 dev v0",
+		"ip link set dev v0 down",
+	}
+	if got := changeStrings(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected changes %q", got)
+	}
+	v0, err := netlink.LinkByName("v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := netlink.AddrList(v0, netlink.FAMILY_ALL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0].IPNet.String() != "10.0.0.1/24" ||
+		v0.Attrs().MTU != 9000 {
+		t.Errorf("unexpected state mtu=%d addrs=%v", v0.Attrs().MTU, addrs)
+	}
+
+	// The config state is read back from the kernel, the change by the
+	// other tools is reported
+	if err := netlink.LinkSetMTU(v0, 1500); err != nil {
+		t.Fatal(err)
+	}
+	data, err := a.ConfigState(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedData := []YangData{
+		{"/linux-agent:interfaces/interface[name='v0']/address", "10.0.0.1/24"},
+		{"/linux-agent:interfaces/interface[name='v0']/enabled", "false"},
+		{"/linux-agent:interfaces/interface[name='v0']/mtu", "1500"},
+	}
+	if !reflect.DeepEqual(data, expectedData) {
+		t.Errorf("unexpected config state %v", data)
+	}
+}
+
+func TestPlanLinkDeletion(t *testing.T) {
+	link := func(name, alias string, index int) netlink.LinkAttrs {
+		attrs := netlink.NewLinkAttrs()
+		attrs.Name = name
+		attrs.Alias = alias
+		attrs.Index = index
+		return attrs
+	}
+	newState := func() *state {
+		return &state{
+			links: map[string]netlink.Link{
+				"v0": &netlink.Veth{LinkAttrs: link("v0", "", 1)},
+				"v0.10": &netlink.Vlan{
+					LinkAttrs: link("v0.10", "", 2), VlanId: 10,
+				},
+				"v0.20": &netlink.Vlan{
+					LinkAttrs: link("v0.20", LinkAlias, 3), VlanId: 20,
+				},
+				"vrf0": &netlink.Vrf{LinkAttrs: link("vrf0", "", 4), Table: 10},
+				"vrf1": &netlink.Vrf{
+					LinkAttrs: link("vrf1", LinkAlias, 5), Table: 11,
+				},
+			},
+			addrs:   map[string][]string{},
+			created: map[string]string{},
+		}
+	}
+	a := &Agent{}
+
+	// Only the links created by the agent are deleted
+	changes, err := a.planLinkDeletion(newState(), device())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"ip link del dev v0.20",
+		"ip link del dev vrf1",
+	}
+	if got := changeStrings(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected changes %q", got)
+	}
+
+	// The links not created by the agent aren't replaced
+	config := device(yang.LinuxAgent_Interfaces_Interface{
+		Name: strp("v0.10"),
+		Vlan: &yang.LinuxAgent_Interfaces_Interface_Vlan{
+			Parent: strp("v0"),
+			Id:     u16p(30),
+		},
+	})
+	_, err = a.planLinkDeletion(newState(), config)
+	if err == nil || err.Error() !=
+		"vlan v0.10: configured differently and not created by the agent" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	config = device()
+	config.Vrfs.Vrf = []yang.LinuxAgent_Vrfs_Vrf{
+		{Name: strp("vrf0"), Table: u32p(12)},
+	}
+	_, err = a.planLinkDeletion(newState(), config)
+	if err == nil || err.Error() !=
+		"vrf vrf0: configured differently and not created by the agent" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestReconcileVlanVrf(t *testing.T) {
+	requireNetns(t)
+	newTestVeth(t, "v0", "v1")
+
+	// VLAN and VRF devices aren't available in all kernels, the plan is
+	// tested in dry-run mode
+	a := newTestAgent(t, true)
+	changes, err := a.Reconcile(device(
+		yang.LinuxAgent_Interfaces_Interface{
+			Name: strp("v0"),
+		},
+		yang.LinuxAgent_Interfaces_Interface{
+			Name:    strp("v0.10"),
+			Address: []string{"10.0.10.1/24"},
+			Vlan: &yang.LinuxAgent_Interfaces_Interface_Vlan{
+				Parent: strp("v0"),
+				Id:     u16p(10),
+			},
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"ip link add link v0 name v0.10 type vlan id 10",
+		"ip link set dev v0 up",
+		"ip addr add 10.0.10.1/24 dev v0.10",
+		"ip link set dev v0.10 up",
+	}
+	if got := changeStrings(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected changes %q", got)
+	}
+
+	config := device(yang.LinuxAgent_Interfaces_Interface{
+		Name:    strp("v0"),
+		Address: []string{"10.0.0.1/24"},
+		Vrf:     strp("vrf0"),
+	})
+	_, err = a.Reconcile(config)
+	if err == nil ||
+		err.Error() != "Validate: interface v0: vrf vrf0 not configured" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	config.Vrfs.Vrf = []yang.LinuxAgent_Vrfs_Vrf{
+		{Name: strp("vrf0"), Table: u32p(10)},
+	}
+	config.Routes.Route = []yang.LinuxAgent_Routes_Route{
+		{
+			Table:  u32p(10),
+			Prefix: strp("10.1.0.0/16"),
+			Nexthop: []yang.LinuxAgent_Routes_Route_Nexthop{
+				{Index: u8p(0), Gateway: strp("10.0.0.2"), Interface: strp("v0")},
+			},
+		},
+	}
+	config.Sysctls.Sysctl = []yang.LinuxAgent_Sysctls_Sysctl{
+		{Name: strp("net.ipv4.conf.vrf0.forwarding"), Value: strp("1")},
+	}
+	changes, err = a.Reconcile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"ip link add vrf0 type vrf table 10",
+		"ip link set dev vrf0 up",
+		"ip link set dev v0 master vrf0",
+		"ip addr add 10.0.0.1/24 dev v0",
+		"ip link set dev v0 up",
+		"sysctl -w net.ipv4.conf.vrf0.forwarding=1",
+		"ip route add 10.1.0.0/16 via 10.0.0.2 dev v0 table 10 proto static",
+	}
+	if got := changeStrings(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected changes %q", got)
+	}
+
+	_, err = a.Reconcile(device(yang.LinuxAgent_Interfaces_Interface{
+		Name: strp("v1"),
+		Vlan: &yang.LinuxAgent_Interfaces_Interface_Vlan{
+			Parent: strp("v0"),
+			Id:     u16p(10),
+		},
+	}))
+	if err == nil || err.Error() != "plan: interface v1: exists as veth link" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestValidateDevice(t *testing.T) {
+	route := func(table uint32, prefix string,
+		nexthops ...yang.LinuxAgent_Routes_Route_Nexthop) *yang.Device {
+		return &yang.Device{Routes: yang.LinuxAgent_Routes{
+			Route: []yang.LinuxAgent_Routes_Route{
+				{Table: u32p(table), Prefix: strp(prefix), Nexthop: nexthops},
+			},
+		}}
+	}
+	gateway := func(gw string) yang.LinuxAgent_Routes_Route_Nexthop {
+		return yang.LinuxAgent_Routes_Route_Nexthop{
+			Index: u8p(0), Gateway: strp(gw),
+		}
+	}
+	sysctl := func(name, value string) *yang.Device {
+		return &yang.Device{Sysctls: yang.LinuxAgent_Sysctls{
+			Sysctl: []yang.LinuxAgent_Sysctls_Sysctl{
+				{Name: strp(name), Value: strp(value)},
+			},
+		}}
+	}
+	testcases := []struct {
+		device *yang.Device
+		err    string
+	}{
+		{
+			device: &yang.Device{Vrfs: yang.LinuxAgent_Vrfs{
+				Vrf: []yang.LinuxAgent_Vrfs_Vrf{
+					{Name: strp("vrf0"), Table: u32p(10)},
+					{Name: strp("vrf1"), Table: u32p(10)},
+				},
+			}},
+			err: "vrf vrf1: table 10 is used by vrf vrf0",
+		},
+		{
+			device: &yang.Device{Vrfs: yang.LinuxAgent_Vrfs{
+				Vrf: []yang.LinuxAgent_Vrfs_Vrf{
+					{Name: strp("vrf0"), Table: u32p(254)},
+				},
+			}},
+			err: "vrf vrf0: table 254 is reserved",
+		},
+		{
+			device: route(254, "10.1.0.0/16", gateway("10.0.0.2")),
+		},
+		{
+			device: route(254, "::/0", gateway("2001:db8::1")),
+		},
+		{
+			device: route(255, "10.1.0.0/16", gateway("10.0.0.2")),
+			err:    "route 10.1.0.0/16: table 255 is reserved",
+		},
+		{
+			device: route(254, "10.1.0.1/16", gateway("10.0.0.2")),
+			err:    "route 10.1.0.1/16: prefix has host bits, 10.1.0.0/16 is expected",
+		},
+		{
+			device: route(254, "10.1.0.0/16"),
+			err:    "route 10.1.0.0/16: nexthop is required",
+		},
+		{
+			device: route(254, "10.1.0.0/16", gateway("2001:db8::1")),
+			err:    "route 10.1.0.0/16: nexthop 0: gateway 2001:db8::1 is in another family",
+		},
+		{
+			device: route(254, "10.1.0.0/16",
+				yang.LinuxAgent_Routes_Route_Nexthop{Index: u8p(1)}),
+			err: "route 10.1.0.0/16: nexthop 1: gateway or interface is required",
+		},
+		{
+			device: sysctl("net/ipv4/conf/eth0.10/rp_filter", "2"),
+		},
+		{
+			device: sysctl("kernel.panic", "1"),
+			err:    "sysctl kernel.panic: not whitelisted",
+		},
+		{
+			device: sysctl("net.ipv4.conf.//.rp_filter", "1"),
+			err:    "sysctl net.ipv4.conf.//.rp_filter: not whitelisted",
+		},
+		{
+			device: sysctl("net/ipv4/conf/./forwarding", "1"),
+			err:    "sysctl net/ipv4/conf/./forwarding: not whitelisted",
+		},
+		{
+			device: sysctl("net.ipv4.ip_forward", ""),
+			err:    "sysctl net.ipv4.ip_forward: value is required",
+		},
+	}
+	for _, tc := range testcases {
+		err := Validate(tc.device)
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tc.err {
+			t.Errorf("expected %q, got %q", tc.err, errStr)
+		}
+	}
+}
+
+func TestReconcileRoutes(t *testing.T) {
+	requireNetns(t)
+	newTestVeth(t, "v0", "v1")
+
+	config := device(
+		yang.LinuxAgent_Interfaces_Interface{
+			Name:    strp("v0"),
+			Address: []string{"10.0.0.1/24"},
+		},
+		yang.LinuxAgent_Interfaces_Interface{
+			Name:    strp("v1"),
+			Address: []string{"10.0.1.1/24"},
+		},
+	)
+	config.Routes.Route = []yang.LinuxAgent_Routes_Route{
+		{
+			Table:  u32p(10),
+			Prefix: strp("10.1.0.0/16"),
+			Nexthop: []yang.LinuxAgent_Routes_Route_Nexthop{
+				{Index: u8p(0), Gateway: strp("10.0.0.2")},
+			},
+		},
+		{
+			Table:  u32p(254),
+			Prefix: strp("10.2.0.0/16"),
+			Metric: u32p(100),
+			Nexthop: []yang.LinuxAgent_Routes_Route_Nexthop{
+				{Index: u8p(0), Gateway: strp("10.0.0.2"), Weight: u8p(2)},
+				{Index: u8p(1), Gateway: strp("10.0.1.2")},
+			},
+		},
+	}
+	config.Sysctls.Sysctl = []yang.LinuxAgent_Sysctls_Sysctl{
+		{Name: strp("net.ipv4.ip_forward"), Value: strp("1")},
+	}
+
+	a := newTestAgent(t, false)
+	changes, err := a.Reconcile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"ip addr add 10.0.0.1/24 dev v0",
+		"ip link set dev v0 up",
+		"ip addr add 10.0.1.1/24 dev v1",
+		"ip link set dev v1 up",
+		"sysctl -w net.ipv4.ip_forward=1",
+		"ip route add 10.1.0.0/16 via 10.0.0.2 table 10 proto static",
+		"ip route add 10.2.0.0/16 table 254 metric 100 " +
+			"nexthop via 10.0.0.2 weight 2 nexthop via 10.0.1.2 weight 1 proto static",
+	}
+	if got := changeStrings(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected changes %q", got)
+	}
+	changes, err = a.Reconcile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("not converged %q", changeStrings(changes))
+	}
+
+	state, err := a.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, d := range state {
+		values[d.XPath] = d.Value
+	}
+	for xpath, value := range map[string]string{
+		"/linux-agent:state/interface[name='v0']/type":                                          "veth",
+		"/linux-agent:state/interface[name='v0']/admin-status":                                  "up",
+		"/linux-agent:state/interface[name='v0']/address":                                       "10.0.0.1/24",
+		"/linux-agent:state/route[table='10'][prefix='10.1.0.0/16']/nexthop[index='0']/gateway": "10.0.0.2",
+		"/linux-agent:state/route[table='254'][prefix='10.2.0.0/16']/metric":                    "100",
+		"/linux-agent:state/route[table='254'][prefix='10.2.0.0/16']/nexthop[index='0']/weight": "2",
+		"/linux-agent:state/sysctl[name='net.ipv4.ip_forward']/value":                           "1",
+	} {
+		if values[xpath] != value {
+			t.Errorf("%s: expected %q, got %q", xpath, value, values[xpath])
+		}
+	}
+
+	// The route is replaced on the change of nexthop and deleted when
+	// it's unconfigured
+	config.Routes.Route[0].Nexthop[0].Gateway = strp("10.0.0.3")
+	config.Routes.Route = config.Routes.Route[:1]
+	changes, err = a.Reconcile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"ip route del 10.2.0.0/16 table 254 metric 100 " +
+			"nexthop via 10.0.0.2 dev v0 weight 2 nexthop via 10.0.1.2 dev v1 weight 1 proto static",
+		"ip route replace 10.1.0.0/16 via 10.0.0.3 table 10 proto static",
+	}
+	if got := changeStrings(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected changes %q", got)
+	}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+		Table: 10,
+	}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0].Gw.String() != "10.0.0.3" {
+		t.Errorf("unexpected routes %v", routes)
+	}
+
+	// The config state reports the nexthop in the kernel
+	config.Routes.Route[0].Nexthop[0].Gateway = strp("10.0.0.2")
+	data, err := a.ConfigState(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values = map[string]string{}
+	for _, d := range data {
+		values[d.XPath] = d.Value
+	}
+	for xpath, value := range map[string]string{
+		"/linux-agent:routes/route[table='10'][prefix='10.1.0.0/16']/nexthop[index='0']/gateway": "10.0.0.3",
+		"/linux-agent:sysctls/sysctl[name='net.ipv4.ip_forward']/value":                          "1",
+	} {
+		if values[xpath] != value {
+			t.Errorf("%s: expected %q, got %q", xpath, value, values[xpath])
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+
+	"github.com/slankdev/vtyang/pkg/linux-agent/yang"
+)
+
+// LinkAlias marks the links created by the agent. Only the links with
+// the alias are deleted when they aren't configured.
+var LinkAlias = "linux-agent"
+
+// plan returns the changes from the kernel state to device in the order
+// to apply:
+//  1. delete the VLAN and VRF links created by the agent which aren't
+//     configured or configured differently
+//  2. create the VRF and VLAN links
+//  3. set the mtu, the vrf, the addresses and the admin state of each
+//     configured interface
+//  4. flush the addresses and set down the interfaces not configured,
+//     except the loopback
+//  5. set the sysctls
+//  6. delete, add and replace the routes
+func (a *Agent) plan(device *yang.Device) ([]Change, error) {
+	s, err := a.getState()
+	if err != nil {
+		return nil, errors.Wrap(err, "getState")
+	}
+	changes := []Change{}
+	for _, f := range []func(*state, *yang.Device) ([]Change, error){
+		a.planLinkDeletion,
+		a.planVrfs,
+		a.planVlans,
+		a.planInterfaces,
+		a.planSysctls,
+		a.planRoutes,
+	} {
+		c, err := f(s, device)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c...)
+	}
+	return changes, nil
+}
+
+// planLinkDeletion deletes the links created by the agent which aren't
+// configured or configured differently. They are removed from s. The
+// links are owned by the agent when their alias is LinkAlias, and the
+// links created by the other tools are never deleted.
+func (a *Agent) planLinkDeletion(s *state,
+	device *yang.Device) ([]Change, error) {
+	vlans := map[string]*yang.LinuxAgent_Interfaces_Interface_Vlan{}
+	for _, iface := range device.Interfaces.Interface {
+		if iface.Vlan != nil {
+			vlans[*iface.Name] = iface.Vlan
+		}
+	}
+	vrfs := map[string]uint32{}
+	for _, vrf := range device.Vrfs.Vrf {
+		vrfs[*vrf.Name] = *vrf.Table
+	}
+
+	changes := []Change{}
+	for _, name := range sortedLinkNames(s) {
+		link := s.links[name]
+		configured := false
+		switch link := link.(type) {
+		case *netlink.Vlan:
+			vlan, ok := vlans[name]
+			if ok && a.vlanMatch(s, link, vlan) {
+				continue
+			}
+			configured = ok
+		case *netlink.Vrf:
+			table, ok := vrfs[name]
+			if ok && link.Table == table {
+				continue
+			}
+			configured = ok
+		default:
+			continue
+		}
+		if link.Attrs().Alias != LinkAlias {
+			if configured {
+				return nil, errors.Errorf("%s %s: configured differently and "+
+					"not created by the agent", link.Type(), name)
+			}
+			continue
+		}
+		changes = append(changes, a.linkDel(name))
+		delete(s.links, name)
+		delete(s.addrs, name)
+	}
+	return changes, nil
+}
+
+func (a *Agent) planVlans(s *state, device *yang.Device) ([]Change, error) {
+	changes := []Change{}
+	for _, iface := range device.Interfaces.Interface {
+		if iface.Vlan == nil {
+			continue
+		}
+		if link, ok := s.links[*iface.Name]; ok {
+			if link.Type() != "vlan" {
+				return nil, errors.Errorf("interface %s: exists as %s link",
+					*iface.Name, link.Type())
+			}
+			continue
+		}
+		if !s.linkExists(*iface.Vlan.Parent) {
+			return nil, errors.Errorf("interface %s: vlan parent %s not found",
+				*iface.Name, *iface.Vlan.Parent)
+		}
+		changes = append(changes, a.vlanAdd(*iface.Name, *iface.Vlan))
+		s.created[*iface.Name] = "vlan"
+	}
+	return changes, nil
+}
+
+func (a *Agent) planInterfaces(s *state,
+	device *yang.Device) ([]Change, error) {
+	configured := map[string]bool{}
+	changes := []Change{}
+	for idx := range device.Interfaces.Interface {
+		iface := &device.Interfaces.Interface[idx]
+		configured[*iface.Name] = true
+		ifaceChanges, err := a.planInterface(s, iface)
+		if err != nil {
+			return nil, errors.Wrapf(err, "interface %s", *iface.Name)
+		}
+		changes = append(changes, ifaceChanges...)
+	}
+
+	for _, name := range sortedLinkNames(s) {
+		link := s.links[name]
+		if configured[name] || link.Type() == "vrf" ||
+			link.Attrs().Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if len(s.addrs[name]) > 0 {
+			changes = append(changes, a.addrFlush(name))
+		}
+		if link.Attrs().Flags&net.FlagUp != 0 {
+			changes = append(changes, a.linkSetUp(name, false))
+		}
+	}
+	return changes, nil
+}
+
+func (a *Agent) vlanMatch(s *state, v *netlink.Vlan,
+	vlan *yang.LinuxAgent_Interfaces_Interface_Vlan) bool {
+	if v.VlanId != int(*vlan.Id) {
+		return false
+	}
+	parent, ok := s.links[*vlan.Parent]
+	return ok && parent.Attrs().Index == v.ParentIndex
+}
+
+// planInterface plans the changes of the interface. The interface which
+// doesn't exist yet is created by the changes planned before.
+func (a *Agent) planInterface(s *state,
+	iface *yang.LinuxAgent_Interfaces_Interface) ([]Change, error) {
+	name := *iface.Name
+	if !s.linkExists(name) {
+		return nil, errors.Errorf("not found")
+	}
+	var attrs netlink.LinkAttrs
+	if link, ok := s.links[name]; ok {
+		attrs = *link.Attrs()
+	}
+
+	changes := []Change{}
+	if iface.Mtu != nil && int(*iface.Mtu) != attrs.MTU {
+		changes = append(changes, a.linkSetMTU(name, int(*iface.Mtu)))
+	}
+
+	master := s.linkByIndex(attrs.MasterIndex)
+	if iface.Vrf != nil {
+		if master == nil || master.Attrs().Name != *iface.Vrf {
+			changes = append(changes, a.linkSetMaster(name, *iface.Vrf))
+		}
+	} else if master != nil && master.Type() == "vrf" {
+		changes = append(changes, a.linkSetNoMaster(name))
+	}
+
+	desired := map[string]bool{}
+	for _, addr := range iface.Address {
+		prefix, err := normalizePrefix(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "address %s", addr)
+		}
+		desired[prefix] = true
+	}
+	current := map[string]bool{}
+	for _, addr := range s.addrs[name] {
+		current[addr] = true
+		if !desired[addr] {
+			changes = append(changes, a.addrDel(name, addr))
+		}
+	}
+	for _, addr := range iface.Address {
+		prefix, _ := normalizePrefix(addr)
+		if !current[prefix] {
+			changes = append(changes, a.addrAdd(name, prefix))
+		}
+	}
+
+	enabled := iface.Enabled == nil || *iface.Enabled
+	if enabled != (attrs.Flags&net.FlagUp != 0) {
+		changes = append(changes, a.linkSetUp(name, enabled))
+	}
+	return changes, nil
+}
+
+func (a *Agent) linkDel(name string) Change {
+	return Change{
+		Description: fmt.Sprintf("ip link del dev %s", name),
+		apply: func() error {
+			link, err := a.linkByName(name)
+			if err != nil {
+				return err
+			}
+			return a.handle.LinkDel(link)
+		},
+	}
+}
+
+func (a *Agent) vlanAdd(name string,
+	vlan yang.LinuxAgent_Interfaces_Interface_Vlan) Change {
+	return Change{
+		Description: fmt.Sprintf("ip link add link %s name %s type vlan id %d",
+			*vlan.Parent, name, *vlan.Id),
+		apply: func() error {
+			parent, err := a.linkByName(*vlan.Parent)
+			if err != nil {
+				return err
+			}
+			attrs := netlink.NewLinkAttrs()
+			attrs.Name = name
+			attrs.ParentIndex = parent.Attrs().Index
+			return a.linkAdd(&netlink.Vlan{
+				LinkAttrs: attrs,
+				VlanId:    int(*vlan.Id),
+			})
+		},
+	}
+}
+
+// linkAdd creates the link and marks it with LinkAlias as created by the
+// agent.
+func (a *Agent) linkAdd(link netlink.Link) error {
+	if err := a.handle.LinkAdd(link); err != nil {
+		return err
+	}
+	created, err := a.linkByName(link.Attrs().Name)
+	if err != nil {
+		return err
+	}
+	return a.handle.LinkSetAlias(created, LinkAlias)
+}
+
+func (a *Agent) linkSetMTU(name string, mtu int) Change {
+	return Change{
+		Description: fmt.Sprintf("ip link set dev %s mtu %d", name, mtu),
+		apply: func() error {
+			link, err := a.linkByName(name)
+			if err != nil {
+				return err
+			}
+			return a.handle.LinkSetMTU(link, mtu)
+		},
+	}
+}
+
+func (a *Agent) linkSetMaster(name, master string) Change {
+	return Change{
+		Description: fmt.Sprintf("ip link set dev %s master %s", name, master),
+		apply: func() error {
+			link, err := a.linkByName(name)
+			if err != nil {
+				return err
+			}
+			m, err := a.linkByName(master)
+			if err != nil {
+				return err
+			}
+			return a.handle.LinkSetMasterByIndex(link, m.Attrs().Index)
+		},
+	}
+}
+
+func (a *Agent) linkSetNoMaster(name string) Change {
+	return Change{
+		Description: fmt.Sprintf("ip link set dev %s nomaster", name),
+		apply: func() error {
+			link, err := a.linkByName(name)
+			if err != nil {
+				return err
+			}
+			return a.handle.LinkSetNoMaster(link)
+		},
+	}
+}
+
+func (a *Agent) linkSetUp(name string, up bool) Change {
+	state := "down"
+	if up {
+		state = "up"
+	}
+	return Change{
+		Description: fmt.Sprintf("ip link set dev %s %s", name, state),
+		apply: func() error {
+			link, err := a.linkByName(name)
+			if err != nil {
+				return err
+			}
+			if up {
+				return a.handle.LinkSetUp(link)
+			}
+			return a.handle.LinkSetDown(link)
+		},
+	}
+}
+
+func (a *Agent) addrAdd(name, prefix string) Change {
+	return Change{
+		Description: fmt.Sprintf("ip addr add %s dev %s", prefix, name),
+		apply: func() error {
+			return a.addrOp(name, prefix, a.handle.AddrAdd)
+		},
+	}
+}
+
+func (a *Agent) addrDel(name, prefix string) Change {
+	return Change{
+		Description: fmt.Sprintf("ip addr del %s dev %s", prefix, name),
+		apply: func() error {
+			return a.addrOp(name, prefix, a.handle.AddrDel)
+		},
+	}
+}
+
+func (a *Agent) addrOp(name, prefix string,
+	op func(netlink.Link, *netlink.Addr) error) error {
+	link, err := a.linkByName(name)
+	if err != nil {
+		return err
+	}
+	addr, err := netlink.ParseAddr(prefix)
+	if err != nil {
+		return errors.Wrapf(err, "ParseAddr(%s)", prefix)
+	}
+	return op(link, addr)
+}
+
+// addrFlush deletes the addresses managed by the agent.
+func (a *Agent) addrFlush(name string) Change {
+	return Change{
+		Description: fmt.Sprintf("ip addr flush dev %s", name),
+		apply: func() error {
+			s, err := a.getState()
+			if err != nil {
+				return err
+			}
+			for _, addr := range s.addrs[name] {
+				if err := a.addrOp(name, addr, a.handle.AddrDel); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func sortedLinkNames(s *state) []string {
+	names := []string{}
+	for name := range s.links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
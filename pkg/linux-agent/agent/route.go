@@ -0,0 +1,297 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/slankdev/vtyang/pkg/linux-agent/yang"
+)
+
+// RouteProtocol marks the routes installed by the agent. The routes of
+// the protocol which aren't configured are deleted.
+var RouteProtocol = unix.RTPROT_STATIC
+
+// ipv6DefaultMetric is set by the kernel to the IPv6 routes without
+// metric.
+const ipv6DefaultMetric = 1024
+
+type nexthopSpec struct {
+	gateway string
+	iface   string
+	weight  int
+}
+
+// routeSpec is a route in the form comparable between the config and the
+// kernel state.
+type routeSpec struct {
+	table    int
+	prefix   string
+	metric   int
+	nexthops []nexthopSpec
+}
+
+func (r routeSpec) key() string {
+	return fmt.Sprintf("%d %s %d", r.table, r.prefix, r.metric)
+}
+
+// String returns the route in the syntax of ip-route(8).
+func (r routeSpec) String() string {
+	words := []string{r.prefix}
+	if len(r.nexthops) == 1 {
+		words = append(words, r.nexthops[0].args()...)
+	}
+	words = append(words, "table", fmt.Sprint(r.table))
+	if r.metric != 0 {
+		words = append(words, "metric", fmt.Sprint(r.metric))
+	}
+	if len(r.nexthops) > 1 {
+		for _, nh := range r.nexthops {
+			words = append(words, "nexthop")
+			words = append(words, nh.args()...)
+			words = append(words, "weight", fmt.Sprint(nh.weight))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func (nh nexthopSpec) args() []string {
+	words := []string{}
+	if nh.gateway != "" {
+		words = append(words, "via", nh.gateway)
+	}
+	if nh.iface != "" {
+		words = append(words, "dev", nh.iface)
+	}
+	return words
+}
+
+// normalize sorts the nexthops. The weight of single nexthop isn't kept
+// by the kernel, so it's ignored.
+func (r *routeSpec) normalize() {
+	sort.Slice(r.nexthops, func(i, j int) bool {
+		a, b := r.nexthops[i], r.nexthops[j]
+		if a.gateway != b.gateway {
+			return a.gateway < b.gateway
+		}
+		return a.iface < b.iface
+	})
+	if len(r.nexthops) == 1 {
+		r.nexthops[0].weight = 1
+	}
+}
+
+// satisfies reports whether the route in the kernel r satisfies the
+// configured route desired. The interface of the nexthop is resolved by
+// the kernel when it isn't configured.
+func (r routeSpec) satisfies(desired routeSpec) bool {
+	if len(r.nexthops) != len(desired.nexthops) {
+		return false
+	}
+	for idx, nh := range desired.nexthops {
+		cur := r.nexthops[idx]
+		if cur.gateway != nh.gateway || cur.weight != nh.weight ||
+			(nh.iface != "" && cur.iface != nh.iface) {
+			return false
+		}
+	}
+	return true
+}
+
+func configRouteSpec(route *yang.LinuxAgent_Routes_Route) (routeSpec, error) {
+	_, ipnet, err := net.ParseCIDR(*route.Prefix)
+	if err != nil {
+		return routeSpec{}, err
+	}
+	r := routeSpec{
+		table:  int(*route.Table),
+		prefix: ipnet.String(),
+	}
+	if route.Metric != nil {
+		r.metric = int(*route.Metric)
+	} else if ipnet.IP.To4() == nil {
+		r.metric = ipv6DefaultMetric
+	}
+	for _, nh := range route.Nexthop {
+		spec := nexthopSpec{weight: 1}
+		if nh.Gateway != nil {
+			spec.gateway = net.ParseIP(*nh.Gateway).String()
+		}
+		if nh.Interface != nil {
+			spec.iface = *nh.Interface
+		}
+		if nh.Weight != nil {
+			spec.weight = int(*nh.Weight)
+		}
+		r.nexthops = append(r.nexthops, spec)
+	}
+	r.normalize()
+	return r, nil
+}
+
+// getRoutes returns the routes of RouteProtocol indexed by key. The
+// IPv6 routes with multiple nexthops can be dumped as separate routes,
+// they are merged by key.
+func (a *Agent) getRoutes(s *state) (map[string]*routeSpec, error) {
+	ret := map[string]*routeSpec{}
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		routes, err := a.handle.RouteListFiltered(family, &netlink.Route{
+			Protocol: RouteProtocol,
+			Table:    unix.RT_TABLE_UNSPEC,
+		}, netlink.RT_FILTER_PROTOCOL|netlink.RT_FILTER_TABLE)
+		if err != nil {
+			return nil, errors.Wrap(err, "RouteListFiltered")
+		}
+		for _, route := range routes {
+			r := routeSpec{
+				table:  route.Table,
+				metric: route.Priority,
+			}
+			if route.Dst != nil {
+				r.prefix = route.Dst.String()
+			} else if family == netlink.FAMILY_V4 {
+				r.prefix = "0.0.0.0/0"
+			} else {
+				r.prefix = "::/0"
+			}
+			if len(route.MultiPath) == 0 {
+				r.nexthops = append(r.nexthops,
+					s.nexthopSpec(route.Gw, route.LinkIndex, 0))
+			}
+			for _, nh := range route.MultiPath {
+				r.nexthops = append(r.nexthops,
+					s.nexthopSpec(nh.Gw, nh.LinkIndex, nh.Hops))
+			}
+			if merged, ok := ret[r.key()]; ok {
+				merged.nexthops = append(merged.nexthops, r.nexthops...)
+				continue
+			}
+			ret[r.key()] = &r
+		}
+	}
+	for _, r := range ret {
+		r.normalize()
+	}
+	return ret, nil
+}
+
+func (s *state) nexthopSpec(gw net.IP, linkIndex, hops int) nexthopSpec {
+	nh := nexthopSpec{weight: hops + 1}
+	if gw != nil {
+		nh.gateway = gw.String()
+	}
+	if link := s.linkByIndex(linkIndex); link != nil {
+		nh.iface = link.Attrs().Name
+	}
+	return nh
+}
+
+// planRoutes deletes the routes not configured first, then adds or
+// replaces the routes configured.
+func (a *Agent) planRoutes(s *state, device *yang.Device) ([]Change, error) {
+	current, err := a.getRoutes(s)
+	if err != nil {
+		return nil, err
+	}
+	desired := []routeSpec{}
+	for idx := range device.Routes.Route {
+		r, err := configRouteSpec(&device.Routes.Route[idx])
+		if err != nil {
+			return nil, errors.Wrapf(err, "route %s", *device.Routes.Route[idx].Prefix)
+		}
+		for _, nh := range r.nexthops {
+			if nh.iface != "" && !s.linkExists(nh.iface) {
+				return nil, errors.Errorf("route %s: interface %s not found",
+					r.prefix, nh.iface)
+			}
+		}
+		desired = append(desired, r)
+	}
+
+	adds := []Change{}
+	for _, r := range desired {
+		c, ok := current[r.key()]
+		if !ok {
+			adds = append(adds, a.routeChange("add", r))
+			continue
+		}
+		delete(current, r.key())
+		if !c.satisfies(r) {
+			adds = append(adds, a.routeChange("replace", r))
+		}
+	}
+	keys := []string{}
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	changes := []Change{}
+	for _, key := range keys {
+		changes = append(changes, a.routeChange("del", *current[key]))
+	}
+	return append(changes, adds...), nil
+}
+
+func (a *Agent) routeChange(op string, r routeSpec) Change {
+	return Change{
+		Description: fmt.Sprintf("ip route %s %s proto static", op, r),
+		apply: func() error {
+			route, err := a.netlinkRoute(r)
+			if err != nil {
+				return err
+			}
+			switch op {
+			case "add":
+				return a.handle.RouteAdd(route)
+			case "replace":
+				return a.handle.RouteReplace(route)
+			}
+			// The IPv6 nexthops can be separate routes, they are deleted
+			// one by one
+			for i := 0; i < len(r.nexthops); i++ {
+				if err := a.handle.RouteDel(route); err != nil && i == 0 {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (a *Agent) netlinkRoute(r routeSpec) (*netlink.Route, error) {
+	_, dst, err := net.ParseCIDR(r.prefix)
+	if err != nil {
+		return nil, err
+	}
+	route := &netlink.Route{
+		Dst:      dst,
+		Table:    r.table,
+		Priority: r.metric,
+		Protocol: RouteProtocol,
+	}
+	for _, nh := range r.nexthops {
+		info := &netlink.NexthopInfo{
+			Gw:   net.ParseIP(nh.gateway),
+			Hops: nh.weight - 1,
+		}
+		if nh.iface != "" {
+			link, err := a.linkByName(nh.iface)
+			if err != nil {
+				return nil, err
+			}
+			info.LinkIndex = link.Attrs().Index
+		}
+		route.MultiPath = append(route.MultiPath, info)
+	}
+	if len(route.MultiPath) == 1 {
+		route.Gw = route.MultiPath[0].Gw
+		route.LinkIndex = route.MultiPath[0].LinkIndex
+		route.MultiPath = nil
+	}
+	return route, nil
+}
@@ -0,0 +1,237 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+
+	"github.com/slankdev/vtyang/pkg/linux-agent/yang"
+)
+
+const statePrefix = "/linux-agent:state"
+
+// YangData is a leaf of linux-agent.yang and its value. The values of
+// leaf-lists are separated by space.
+type YangData struct {
+	XPath string
+	Value string
+}
+
+// State returns the operational state of the links, the routes installed
+// by the agent and the whitelisted sysctls without wildcard.
+func (a *Agent) State() ([]YangData, error) {
+	s, err := a.getState()
+	if err != nil {
+		return nil, err
+	}
+	data := []YangData{}
+	add := func(xpath, value string) {
+		data = append(data, YangData{XPath: xpath, Value: value})
+	}
+
+	for _, name := range sortedLinkNames(s) {
+		link := s.links[name]
+		attrs := link.Attrs()
+		prefix := fmt.Sprintf("%s/interface[name='%s']", statePrefix, name)
+		add(prefix+"/ifindex", fmt.Sprint(attrs.Index))
+		add(prefix+"/type", link.Type())
+		add(prefix+"/mtu", fmt.Sprint(attrs.MTU))
+		admin := "down"
+		if attrs.Flags&net.FlagUp != 0 {
+			admin = "up"
+		}
+		add(prefix+"/admin-status", admin)
+		add(prefix+"/oper-status", attrs.OperState.String())
+		if len(attrs.HardwareAddr) > 0 {
+			add(prefix+"/mac-address", attrs.HardwareAddr.String())
+		}
+		if master := s.linkByIndex(attrs.MasterIndex); master != nil &&
+			master.Type() == "vrf" {
+			add(prefix+"/vrf", master.Attrs().Name)
+		}
+		if addrs := s.addrs[name]; len(addrs) > 0 {
+			add(prefix+"/address", strings.Join(addrs, " "))
+		}
+	}
+
+	routes, err := a.getRoutes(s)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for key := range routes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r := routes[key]
+		prefix := fmt.Sprintf("%s/route[table='%d'][prefix='%s']",
+			statePrefix, r.table, r.prefix)
+		add(prefix+"/metric", fmt.Sprint(r.metric))
+		for idx, nh := range r.nexthops {
+			nhPrefix := fmt.Sprintf("%s/nexthop[index='%d']", prefix, idx)
+			if nh.gateway != "" {
+				add(nhPrefix+"/gateway", nh.gateway)
+			}
+			if nh.iface != "" {
+				add(nhPrefix+"/interface", nh.iface)
+			}
+			add(nhPrefix+"/weight", fmt.Sprint(nh.weight))
+		}
+	}
+
+	for _, name := range SysctlWhitelist {
+		if strings.Contains(name, "*") {
+			continue
+		}
+		value, err := a.sysctlRead(name)
+		if err != nil {
+			continue
+		}
+		add(fmt.Sprintf("%s/sysctl[name='%s']/value", statePrefix, name), value)
+	}
+	return data, nil
+}
+
+// ConfigState returns the config of device as applied in the kernel, so
+// that vtyang can detect the drift. The leaves set in device are read
+// back from the links, the routes and the sysctls, and the entries which
+// don't exist in the kernel are omitted. The list keys are part of the
+// xpaths.
+func (a *Agent) ConfigState(device *yang.Device) ([]YangData, error) {
+	s, err := a.getState()
+	if err != nil {
+		return nil, err
+	}
+	data := []YangData{}
+	add := func(xpath string, value interface{}) {
+		data = append(data, YangData{XPath: xpath, Value: fmt.Sprint(value)})
+	}
+
+	for _, iface := range device.Interfaces.Interface {
+		link, ok := s.links[*iface.Name]
+		if !ok {
+			continue
+		}
+		attrs := link.Attrs()
+		prefix := fmt.Sprintf("/linux-agent:interfaces/interface[name='%s']",
+			*iface.Name)
+		addrs := configuredAddrs(iface.Address, s.addrs[*iface.Name])
+		if len(addrs) > 0 {
+			add(prefix+"/address", strings.Join(addrs, " "))
+		}
+		enabled := attrs.Flags&net.FlagUp != 0
+		if iface.Enabled != nil || !enabled {
+			add(prefix+"/enabled", enabled)
+		}
+		if iface.Mtu != nil {
+			add(prefix+"/mtu", attrs.MTU)
+		}
+		if master := s.linkByIndex(attrs.MasterIndex); master != nil &&
+			master.Type() == "vrf" {
+			add(prefix+"/vrf", master.Attrs().Name)
+		}
+		if vlan, ok := link.(*netlink.Vlan); ok && iface.Vlan != nil {
+			if parent := s.linkByIndex(vlan.ParentIndex); parent != nil {
+				add(prefix+"/vlan/parent", parent.Attrs().Name)
+			}
+			add(prefix+"/vlan/id", vlan.VlanId)
+		}
+	}
+
+	for _, vrf := range device.Vrfs.Vrf {
+		if link, ok := s.links[*vrf.Name].(*netlink.Vrf); ok {
+			add(fmt.Sprintf("/linux-agent:vrfs/vrf[name='%s']/table", *vrf.Name),
+				link.Table)
+		}
+	}
+
+	routes, err := a.getRoutes(s)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range device.Routes.Route {
+		route := &device.Routes.Route[idx]
+		desired, err := configRouteSpec(route)
+		if err != nil {
+			return nil, errors.Wrapf(err, "route %s", *route.Prefix)
+		}
+		current, ok := routes[desired.key()]
+		if !ok {
+			continue
+		}
+		prefix := fmt.Sprintf("/linux-agent:routes/route[table='%d'][prefix='%s']",
+			*route.Table, *route.Prefix)
+		if route.Metric != nil {
+			add(prefix+"/metric", *route.Metric)
+		}
+		// The configured nexthops are reported as they are when the
+		// kernel satisfies them, since their indexes aren't kept by the
+		// kernel
+		if current.satisfies(desired) {
+			for _, nh := range route.Nexthop {
+				nhPrefix := fmt.Sprintf("%s/nexthop[index='%d']", prefix, *nh.Index)
+				if nh.Gateway != nil {
+					add(nhPrefix+"/gateway", *nh.Gateway)
+				}
+				if nh.Interface != nil {
+					add(nhPrefix+"/interface", *nh.Interface)
+				}
+				if nh.Weight != nil {
+					add(nhPrefix+"/weight", *nh.Weight)
+				}
+			}
+			continue
+		}
+		for idx, nh := range current.nexthops {
+			nhPrefix := fmt.Sprintf("%s/nexthop[index='%d']", prefix, idx)
+			if nh.gateway != "" {
+				add(nhPrefix+"/gateway", nh.gateway)
+			}
+			if nh.iface != "" {
+				add(nhPrefix+"/interface", nh.iface)
+			}
+			add(nhPrefix+"/weight", nh.weight)
+		}
+	}
+
+	for _, sysctl := range device.Sysctls.Sysctl {
+		value, err := a.sysctlRead(*sysctl.Name)
+		if err != nil {
+			continue
+		}
+		add(fmt.Sprintf("/linux-agent:sysctls/sysctl[name='%s']/value",
+			*sysctl.Name), value)
+	}
+	return data, nil
+}
+
+// configuredAddrs returns the addresses of the kernel current. The
+// configured ones come first in the configured order and format, so
+// that the unchanged addresses are reported as configured.
+func configuredAddrs(configured, current []string) []string {
+	exists := map[string]bool{}
+	for _, addr := range current {
+		exists[addr] = true
+	}
+	ret := []string{}
+	reported := map[string]bool{}
+	for _, addr := range configured {
+		prefix, err := normalizePrefix(addr)
+		if err != nil || !exists[prefix] || reported[prefix] {
+			continue
+		}
+		ret = append(ret, addr)
+		reported[prefix] = true
+	}
+	for _, addr := range current {
+		if !reported[addr] {
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
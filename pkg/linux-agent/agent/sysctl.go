@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/slankdev/vtyang/pkg/linux-agent/yang"
+)
+
+// SysctlWhitelist are the sysctls the agent is allowed to set. A "*"
+// matches a word of the name, which is usually an interface name.
+var SysctlWhitelist = []string{
+	"net.ipv4.ip_forward",
+	"net.ipv4.fib_multipath_hash_policy",
+	"net.ipv4.tcp_l3mdev_accept",
+	"net.ipv4.udp_l3mdev_accept",
+	"net.ipv4.conf.*.forwarding",
+	"net.ipv4.conf.*.rp_filter",
+	"net.ipv4.conf.*.proxy_arp",
+	"net.ipv4.conf.*.accept_redirects",
+	"net.ipv4.conf.*.send_redirects",
+	"net.ipv6.fib_multipath_hash_policy",
+	"net.ipv6.conf.*.forwarding",
+	"net.ipv6.conf.*.disable_ipv6",
+	"net.ipv6.conf.*.accept_ra",
+	"net.ipv6.conf.*.accept_redirects",
+}
+
+var sysctlRoot = "/proc/sys"
+
+// sysctlWords splits the name of sysctl into the words. As in sysctl(8),
+// the words are separated by "." or "/" and the other separator is
+// used for the dots in interface names (e.g. net.ipv4.conf.eth0/10.rp_filter).
+func sysctlWords(name string) []string {
+	slash, dot := strings.Index(name, "/"), strings.Index(name, ".")
+	if slash >= 0 && (dot < 0 || slash < dot) {
+		name = strings.NewReplacer("/", ".", ".", "/").Replace(name)
+	}
+	words := strings.Split(name, ".")
+	for idx := range words {
+		words[idx] = strings.ReplaceAll(words[idx], "/", ".")
+	}
+	return words
+}
+
+// sysctlWhitelisted checks the name matches the whitelist. The words
+// which aren't the single file name under /proc/sys (e.g. "..") are
+// rejected first, as "*" matches them.
+func sysctlWhitelisted(name string) bool {
+	words := sysctlWords(name)
+	for _, word := range words {
+		if word == "." || word == ".." || strings.Contains(word, "/") {
+			return false
+		}
+	}
+	for _, pattern := range SysctlWhitelist {
+		patternWords := strings.Split(pattern, ".")
+		if len(patternWords) != len(words) {
+			continue
+		}
+		match := true
+		for idx := range words {
+			if ok, _ := path.Match(patternWords[idx], words[idx]); !ok ||
+				words[idx] == "" {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func sysctlPath(name string) string {
+	return filepath.Join(append([]string{sysctlRoot}, sysctlWords(name)...)...)
+}
+
+func (a *Agent) sysctlRead(name string) (string, error) {
+	value := ""
+	err := a.inNetns(func() error {
+		b, err := os.ReadFile(sysctlPath(name))
+		if err != nil {
+			return err
+		}
+		value = strings.TrimSpace(string(b))
+		return nil
+	})
+	return value, err
+}
+
+func (a *Agent) sysctlWrite(name, value string) Change {
+	return Change{
+		Description: fmt.Sprintf("sysctl -w %s=%s", name, value),
+		apply: func() error {
+			return a.inNetns(func() error {
+				return os.WriteFile(sysctlPath(name), []byte(value), 0644)
+			})
+		},
+	}
+}
+
+// planSysctls writes the sysctls whose value differs. The sysctls of the
+// interfaces created by the planned changes are always written.
+func (a *Agent) planSysctls(s *state, device *yang.Device) ([]Change, error) {
+	changes := []Change{}
+	for _, sysctl := range device.Sysctls.Sysctl {
+		name := *sysctl.Name
+		value := ""
+		if sysctl.Value != nil {
+			value = *sysctl.Value
+		}
+		current, err := a.sysctlRead(name)
+		if err != nil && !a.sysctlOfCreatedLink(s, name) {
+			return nil, errors.Wrapf(err, "sysctl %s", name)
+		}
+		if err == nil && current == value {
+			continue
+		}
+		changes = append(changes, a.sysctlWrite(name, value))
+	}
+	return changes, nil
+}
+
+func (a *Agent) sysctlOfCreatedLink(s *state, name string) bool {
+	words := sysctlWords(name)
+	return len(words) == 5 && words[2] == "conf" && s.created[words[3]] != ""
+}
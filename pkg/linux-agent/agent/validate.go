@@ -0,0 +1,208 @@
+package agent
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/slankdev/vtyang/pkg/linux-agent/yang"
+)
+
+const (
+	ifNameMax   = 15 // IFNAMSIZ without the terminating null
+	mtuMin      = 68
+	mtuMinIPv6  = 1280
+	vlanIdMin   = 1
+	vlanIdMax   = 4094
+	defaultName = "<noname>"
+
+	tableUnspec = 0
+	tableLocal  = 255
+)
+
+// Validate checks the constraints of device which can't be expressed in
+// linux-agent.yang, without looking at the kernel state.
+func Validate(device *yang.Device) error {
+	vrfs := map[string]bool{}
+	tables := map[uint32]string{}
+	for _, vrf := range device.Vrfs.Vrf {
+		name := defaultName
+		if vrf.Name != nil {
+			name = *vrf.Name
+		}
+		if err := validateVrf(&vrf); err != nil {
+			return errors.Wrapf(err, "vrf %s", name)
+		}
+		if vrfs[name] {
+			return errors.Errorf("vrf %s: duplicated", name)
+		}
+		if other, ok := tables[*vrf.Table]; ok {
+			return errors.Errorf("vrf %s: table %d is used by vrf %s",
+				name, *vrf.Table, other)
+		}
+		vrfs[name] = true
+		tables[*vrf.Table] = name
+	}
+
+	names := map[string]bool{}
+	for _, iface := range device.Interfaces.Interface {
+		name := defaultName
+		if iface.Name != nil {
+			name = *iface.Name
+		}
+		if err := validateInterface(&iface); err != nil {
+			return errors.Wrapf(err, "interface %s", name)
+		}
+		if names[name] {
+			return errors.Errorf("interface %s: duplicated", name)
+		}
+		if vrfs[name] {
+			return errors.Errorf("interface %s: conflicts with vrf", name)
+		}
+		if iface.Vrf != nil && !vrfs[*iface.Vrf] {
+			return errors.Errorf("interface %s: vrf %s not configured",
+				name, *iface.Vrf)
+		}
+		names[name] = true
+	}
+
+	for _, route := range device.Routes.Route {
+		name := defaultName
+		if route.Prefix != nil {
+			name = *route.Prefix
+		}
+		if err := validateRoute(&route); err != nil {
+			return errors.Wrapf(err, "route %s", name)
+		}
+	}
+
+	for _, sysctl := range device.Sysctls.Sysctl {
+		if sysctl.Name == nil || *sysctl.Name == "" {
+			return errors.Errorf("sysctl %s: name is required", defaultName)
+		}
+		if !sysctlWhitelisted(*sysctl.Name) {
+			return errors.Errorf("sysctl %s: not whitelisted", *sysctl.Name)
+		}
+		if sysctl.Value == nil || *sysctl.Value == "" {
+			return errors.Errorf("sysctl %s: value is required", *sysctl.Name)
+		}
+	}
+	return nil
+}
+
+func validateVrf(vrf *yang.LinuxAgent_Vrfs_Vrf) error {
+	if vrf.Name == nil || *vrf.Name == "" {
+		return errors.Errorf("name is required")
+	}
+	if len(*vrf.Name) > ifNameMax {
+		return errors.Errorf("name is longer than %d", ifNameMax)
+	}
+	if vrf.Table == nil {
+		return errors.Errorf("table is required")
+	}
+	switch *vrf.Table {
+	case tableUnspec, tableLocal, unix.RT_TABLE_MAIN, unix.RT_TABLE_DEFAULT:
+		return errors.Errorf("table %d is reserved", *vrf.Table)
+	}
+	return nil
+}
+
+func validateRoute(route *yang.LinuxAgent_Routes_Route) error {
+	if route.Prefix == nil || route.Table == nil {
+		return errors.Errorf("table and prefix are required")
+	}
+	if *route.Table == tableUnspec || *route.Table == tableLocal {
+		return errors.Errorf("table %d is reserved", *route.Table)
+	}
+	ip, ipnet, err := net.ParseCIDR(*route.Prefix)
+	if err != nil {
+		return errors.Errorf("prefix is invalid")
+	}
+	if !ip.Equal(ipnet.IP) {
+		return errors.Errorf("prefix has host bits, %s is expected", ipnet)
+	}
+	if len(route.Nexthop) == 0 {
+		return errors.Errorf("nexthop is required")
+	}
+	for _, nh := range route.Nexthop {
+		index := 0
+		if nh.Index != nil {
+			index = int(*nh.Index)
+		}
+		if nh.Gateway == nil && nh.Interface == nil {
+			return errors.Errorf("nexthop %d: gateway or interface is required",
+				index)
+		}
+		if nh.Gateway == nil {
+			continue
+		}
+		gw := net.ParseIP(*nh.Gateway)
+		if gw == nil {
+			return errors.Errorf("nexthop %d: gateway %q is invalid",
+				index, *nh.Gateway)
+		}
+		if (gw.To4() == nil) != (ip.To4() == nil) {
+			return errors.Errorf("nexthop %d: gateway %s is in another family",
+				index, gw)
+		}
+	}
+	return nil
+}
+
+func validateInterface(iface *yang.LinuxAgent_Interfaces_Interface) error {
+	if iface.Name == nil || *iface.Name == "" {
+		return errors.Errorf("name is required")
+	}
+	if len(*iface.Name) > ifNameMax {
+		return errors.Errorf("name is longer than %d", ifNameMax)
+	}
+
+	addrs := map[string]bool{}
+	ipv6 := false
+	for _, addr := range iface.Address {
+		prefix, err := normalizePrefix(addr)
+		if err != nil {
+			return errors.Errorf("address %q is invalid", addr)
+		}
+		if addrs[prefix] {
+			return errors.Errorf("address %s is duplicated", addr)
+		}
+		addrs[prefix] = true
+		if ip, _, _ := net.ParseCIDR(addr); ip.To4() == nil {
+			ipv6 = true
+		}
+	}
+
+	if iface.Mtu != nil {
+		if *iface.Mtu < mtuMin {
+			return errors.Errorf("mtu %d is less than %d", *iface.Mtu, mtuMin)
+		}
+		if ipv6 && *iface.Mtu < mtuMinIPv6 {
+			return errors.Errorf("mtu %d is less than %d required by IPv6",
+				*iface.Mtu, mtuMinIPv6)
+		}
+	}
+
+	if iface.Vrf != nil && *iface.Vrf == *iface.Name {
+		return errors.Errorf("vrf %s is the interface itself", *iface.Vrf)
+	}
+
+	if vlan := iface.Vlan; vlan != nil {
+		if vlan.Parent == nil || *vlan.Parent == "" {
+			return errors.Errorf("vlan parent is required")
+		}
+		if *vlan.Parent == *iface.Name {
+			return errors.Errorf("vlan parent %s is the interface itself",
+				*vlan.Parent)
+		}
+		if vlan.Id == nil {
+			return errors.Errorf("vlan id is required")
+		}
+		if *vlan.Id < vlanIdMin || *vlan.Id > vlanIdMax {
+			return errors.Errorf("vlan id %d is out of range %d..%d",
+				*vlan.Id, vlanIdMin, vlanIdMax)
+		}
+	}
+	return nil
+}
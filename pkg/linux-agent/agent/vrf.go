@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+
+	"github.com/slankdev/vtyang/pkg/linux-agent/yang"
+)
+
+// planVrfs creates the VRF links and sets them up. The links configured
+// differently are deleted by planLinkDeletion before.
+func (a *Agent) planVrfs(s *state, device *yang.Device) ([]Change, error) {
+	changes := []Change{}
+	for _, vrf := range device.Vrfs.Vrf {
+		name := *vrf.Name
+		link, ok := s.links[name]
+		if !ok {
+			changes = append(changes, a.vrfAdd(name, *vrf.Table))
+			changes = append(changes, a.linkSetUp(name, true))
+			s.created[name] = "vrf"
+			continue
+		}
+		if link.Type() != "vrf" {
+			return nil, errors.Errorf("vrf %s: exists as %s link", name, link.Type())
+		}
+		if link.Attrs().Flags&net.FlagUp == 0 {
+			changes = append(changes, a.linkSetUp(name, true))
+		}
+	}
+	return changes, nil
+}
+
+func (a *Agent) vrfAdd(name string, table uint32) Change {
+	return Change{
+		Description: fmt.Sprintf("ip link add %s type vrf table %d", name, table),
+		apply: func() error {
+			attrs := netlink.NewLinkAttrs()
+			attrs.Name = name
+			return a.linkAdd(&netlink.Vrf{
+				LinkAttrs: attrs,
+				Table:     table,
+			})
+		},
+	}
+}
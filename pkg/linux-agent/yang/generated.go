@@ -1,18 +1,667 @@
-/*
-Package yang is a generated package which contains definitions
-of structs which represent a YANG schema. The generated schema can be
-compressed by a series of transformations (compression was false
-in this case).
-
-This package was generated by /Users/hiroki.shirokura/go/pkg/mod/github.com/openconfig/ygot@v0.29.18/genutil/names.go
-using the following YANG input files:
-  - ../../yang/linux-agent.yang
-
-Imported modules were sourced from:
-  - yang/...
-*/
+// Code generated by "vtyang generate go". DO NOT EDIT.
+
+// Package yang contains the structs of the YANG modules:
+//   - linux-agent
 package yang
 
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Device represents the /device YANG schema element.
+type Device struct {
+	Interfaces LinuxAgent_Interfaces `path:"interfaces" module:"linux-agent"`
+	Routes     LinuxAgent_Routes     `path:"routes" module:"linux-agent"`
+	State      LinuxAgent_State      `path:"state" module:"linux-agent"`
+	Sysctls    LinuxAgent_Sysctls    `path:"sysctls" module:"linux-agent"`
+	Vrfs       LinuxAgent_Vrfs       `path:"vrfs" module:"linux-agent"`
+}
+
+// LinuxAgent_Interfaces represents the /linux-agent/interfaces YANG schema element.
+type LinuxAgent_Interfaces struct {
+	Interface []LinuxAgent_Interfaces_Interface `path:"interface" module:"linux-agent"`
+}
+
+// LinuxAgent_Interfaces_Interface represents the /linux-agent/interfaces/interface YANG schema element.
+type LinuxAgent_Interfaces_Interface struct {
+	Address []string                              `path:"address" module:"linux-agent"`
+	Enabled *bool                                 `path:"enabled" module:"linux-agent"`
+	Mtu     *uint16                               `path:"mtu" module:"linux-agent"`
+	Name    *string                               `path:"name" module:"linux-agent"`
+	Vlan    *LinuxAgent_Interfaces_Interface_Vlan `path:"vlan" module:"linux-agent"`
+	Vrf     *string                               `path:"vrf" module:"linux-agent"`
+}
+
+// LinuxAgent_Interfaces_Interface_Vlan represents the /linux-agent/interfaces/interface/vlan YANG schema element.
+type LinuxAgent_Interfaces_Interface_Vlan struct {
+	Id     *uint16 `path:"id" module:"linux-agent"`
+	Parent *string `path:"parent" module:"linux-agent"`
+}
+
+// LinuxAgent_Routes represents the /linux-agent/routes YANG schema element.
+type LinuxAgent_Routes struct {
+	Route []LinuxAgent_Routes_Route `path:"route" module:"linux-agent"`
+}
+
+// LinuxAgent_Routes_Route represents the /linux-agent/routes/route YANG schema element.
+type LinuxAgent_Routes_Route struct {
+	Metric  *uint32                           `path:"metric" module:"linux-agent"`
+	Nexthop []LinuxAgent_Routes_Route_Nexthop `path:"nexthop" module:"linux-agent"`
+	Prefix  *string                           `path:"prefix" module:"linux-agent"`
+	Table   *uint32                           `path:"table" module:"linux-agent"`
+}
+
+// LinuxAgent_Routes_Route_Nexthop represents the /linux-agent/routes/route/nexthop YANG schema element.
+type LinuxAgent_Routes_Route_Nexthop struct {
+	Gateway   *string `path:"gateway" module:"linux-agent"`
+	Index     *uint8  `path:"index" module:"linux-agent"`
+	Interface *string `path:"interface" module:"linux-agent"`
+	Weight    *uint8  `path:"weight" module:"linux-agent"`
+}
+
+// LinuxAgent_State represents the /linux-agent/state YANG schema element.
+type LinuxAgent_State struct {
+	Interface []LinuxAgent_State_Interface `path:"interface" module:"linux-agent"`
+	Route     []LinuxAgent_State_Route     `path:"route" module:"linux-agent"`
+	Sysctl    []LinuxAgent_State_Sysctl    `path:"sysctl" module:"linux-agent"`
+}
+
+// LinuxAgent_State_Interface represents the /linux-agent/state/interface YANG schema element.
+type LinuxAgent_State_Interface struct {
+	Address     []string `path:"address" module:"linux-agent"`
+	AdminStatus *string  `path:"admin-status" module:"linux-agent"`
+	Ifindex     *uint32  `path:"ifindex" module:"linux-agent"`
+	MacAddress  *string  `path:"mac-address" module:"linux-agent"`
+	Mtu         *uint32  `path:"mtu" module:"linux-agent"`
+	Name        *string  `path:"name" module:"linux-agent"`
+	OperStatus  *string  `path:"oper-status" module:"linux-agent"`
+	Type        *string  `path:"type" module:"linux-agent"`
+	Vrf         *string  `path:"vrf" module:"linux-agent"`
+}
+
+// LinuxAgent_State_Route represents the /linux-agent/state/route YANG schema element.
+type LinuxAgent_State_Route struct {
+	Metric  *uint32                          `path:"metric" module:"linux-agent"`
+	Nexthop []LinuxAgent_State_Route_Nexthop `path:"nexthop" module:"linux-agent"`
+	Prefix  *string                          `path:"prefix" module:"linux-agent"`
+	Table   *uint32                          `path:"table" module:"linux-agent"`
+}
+
+// LinuxAgent_State_Route_Nexthop represents the /linux-agent/state/route/nexthop YANG schema element.
+type LinuxAgent_State_Route_Nexthop struct {
+	Gateway   *string `path:"gateway" module:"linux-agent"`
+	Index     *uint8  `path:"index" module:"linux-agent"`
+	Interface *string `path:"interface" module:"linux-agent"`
+	Weight    *uint8  `path:"weight" module:"linux-agent"`
+}
+
+// LinuxAgent_State_Sysctl represents the /linux-agent/state/sysctl YANG schema element.
+type LinuxAgent_State_Sysctl struct {
+	Name  *string `path:"name" module:"linux-agent"`
+	Value *string `path:"value" module:"linux-agent"`
+}
+
+// LinuxAgent_Sysctls represents the /linux-agent/sysctls YANG schema element.
+type LinuxAgent_Sysctls struct {
+	Sysctl []LinuxAgent_Sysctls_Sysctl `path:"sysctl" module:"linux-agent"`
+}
+
+// LinuxAgent_Sysctls_Sysctl represents the /linux-agent/sysctls/sysctl YANG schema element.
+type LinuxAgent_Sysctls_Sysctl struct {
+	Name  *string `path:"name" module:"linux-agent"`
+	Value *string `path:"value" module:"linux-agent"`
+}
+
+// LinuxAgent_Vrfs represents the /linux-agent/vrfs YANG schema element.
+type LinuxAgent_Vrfs struct {
+	Vrf []LinuxAgent_Vrfs_Vrf `path:"vrf" module:"linux-agent"`
+}
+
+// LinuxAgent_Vrfs_Vrf represents the /linux-agent/vrfs/vrf YANG schema element.
+type LinuxAgent_Vrfs_Vrf struct {
+	Name  *string `path:"name" module:"linux-agent"`
+	Table *uint32 `path:"table" module:"linux-agent"`
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s Device) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+// The members of the modules not generated are ignored.
+func (s *Device) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, true)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *Device) Validate() error {
+	return s.validate("")
+}
+
+func (s *Device) validate(path string) error {
+	if err := s.Interfaces.validate(path + "/linux-agent:interfaces"); err != nil {
+		return err
+	}
+	if err := s.Routes.validate(path + "/linux-agent:routes"); err != nil {
+		return err
+	}
+	if err := s.State.validate(path + "/linux-agent:state"); err != nil {
+		return err
+	}
+	if err := s.Sysctls.validate(path + "/linux-agent:sysctls"); err != nil {
+		return err
+	}
+	if err := s.Vrfs.validate(path + "/linux-agent:vrfs"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Interfaces) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Interfaces) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Interfaces) Validate() error {
+	return s.validate("/linux-agent:interfaces")
+}
+
+func (s *LinuxAgent_Interfaces) validate(path string) error {
+	if len(s.Interface) > 0 {
+		keys := map[string]bool{}
+		for i := range s.Interface {
+			e := &s.Interface[i]
+			if e.Name == nil {
+				return fmt.Errorf("%s: key name is missing", path+"/interface")
+			}
+			key := fmt.Sprintf("[name='%v']", *e.Name)
+			if keys[key] {
+				return fmt.Errorf("%s%s: duplicated", path+"/interface", key)
+			}
+			keys[key] = true
+			if err := e.validate(path + "/interface" + key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Interfaces_Interface) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Interfaces_Interface) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Interfaces_Interface) Validate() error {
+	return s.validate("/linux-agent:interfaces/interface")
+}
+
+func (s *LinuxAgent_Interfaces_Interface) validate(path string) error {
+	if s.Mtu != nil {
+		v := *s.Mtu
+		if !(v >= 68) {
+			return fmt.Errorf("%s: %v is out of range 68..65535", path+"/mtu", v)
+		}
+	}
+	if s.Name != nil {
+		v := *s.Name
+		if n := uint64(utf8.RuneCountInString(v)); !(n >= 1 && n <= 15) {
+			return fmt.Errorf("%s: length of %q is out of range 1..15", path+"/name", v)
+		}
+	}
+	if s.Vlan != nil {
+		if err := s.Vlan.validate(path + "/vlan"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Interfaces_Interface_Vlan) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Interfaces_Interface_Vlan) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Interfaces_Interface_Vlan) Validate() error {
+	return s.validate("/linux-agent:interfaces/interface/vlan")
+}
+
+func (s *LinuxAgent_Interfaces_Interface_Vlan) validate(path string) error {
+	if s.Id != nil {
+		v := *s.Id
+		if !(v >= 1 && v <= 4094) {
+			return fmt.Errorf("%s: %v is out of range 1..4094", path+"/id", v)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Routes) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Routes) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Routes) Validate() error {
+	return s.validate("/linux-agent:routes")
+}
+
+func (s *LinuxAgent_Routes) validate(path string) error {
+	if len(s.Route) > 0 {
+		keys := map[string]bool{}
+		for i := range s.Route {
+			e := &s.Route[i]
+			if e.Table == nil {
+				return fmt.Errorf("%s: key table is missing", path+"/route")
+			}
+			if e.Prefix == nil {
+				return fmt.Errorf("%s: key prefix is missing", path+"/route")
+			}
+			key := fmt.Sprintf("[table='%v'][prefix='%v']", *e.Table, *e.Prefix)
+			if keys[key] {
+				return fmt.Errorf("%s%s: duplicated", path+"/route", key)
+			}
+			keys[key] = true
+			if err := e.validate(path + "/route" + key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Routes_Route) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Routes_Route) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Routes_Route) Validate() error {
+	return s.validate("/linux-agent:routes/route")
+}
+
+func (s *LinuxAgent_Routes_Route) validate(path string) error {
+	if len(s.Nexthop) > 0 {
+		keys := map[string]bool{}
+		for i := range s.Nexthop {
+			e := &s.Nexthop[i]
+			if e.Index == nil {
+				return fmt.Errorf("%s: key index is missing", path+"/nexthop")
+			}
+			key := fmt.Sprintf("[index='%v']", *e.Index)
+			if keys[key] {
+				return fmt.Errorf("%s%s: duplicated", path+"/nexthop", key)
+			}
+			keys[key] = true
+			if err := e.validate(path + "/nexthop" + key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Routes_Route_Nexthop) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Routes_Route_Nexthop) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Routes_Route_Nexthop) Validate() error {
+	return s.validate("/linux-agent:routes/route/nexthop")
+}
+
+func (s *LinuxAgent_Routes_Route_Nexthop) validate(path string) error {
+	if s.Weight != nil {
+		v := *s.Weight
+		if !(v >= 1) {
+			return fmt.Errorf("%s: %v is out of range 1..255", path+"/weight", v)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_State) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_State) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_State) Validate() error {
+	return s.validate("/linux-agent:state")
+}
+
+func (s *LinuxAgent_State) validate(path string) error {
+	if len(s.Interface) > 0 {
+		keys := map[string]bool{}
+		for i := range s.Interface {
+			e := &s.Interface[i]
+			if e.Name == nil {
+				return fmt.Errorf("%s: key name is missing", path+"/interface")
+			}
+			key := fmt.Sprintf("[name='%v']", *e.Name)
+			if keys[key] {
+				return fmt.Errorf("%s%s: duplicated", path+"/interface", key)
+			}
+			keys[key] = true
+			if err := e.validate(path + "/interface" + key); err != nil {
+				return err
+			}
+		}
+	}
+	if len(s.Route) > 0 {
+		keys := map[string]bool{}
+		for i := range s.Route {
+			e := &s.Route[i]
+			if e.Table == nil {
+				return fmt.Errorf("%s: key table is missing", path+"/route")
+			}
+			if e.Prefix == nil {
+				return fmt.Errorf("%s: key prefix is missing", path+"/route")
+			}
+			key := fmt.Sprintf("[table='%v'][prefix='%v']", *e.Table, *e.Prefix)
+			if keys[key] {
+				return fmt.Errorf("%s%s: duplicated", path+"/route", key)
+			}
+			keys[key] = true
+			if err := e.validate(path + "/route" + key); err != nil {
+				return err
+			}
+		}
+	}
+	if len(s.Sysctl) > 0 {
+		keys := map[string]bool{}
+		for i := range s.Sysctl {
+			e := &s.Sysctl[i]
+			if e.Name == nil {
+				return fmt.Errorf("%s: key name is missing", path+"/sysctl")
+			}
+			key := fmt.Sprintf("[name='%v']", *e.Name)
+			if keys[key] {
+				return fmt.Errorf("%s%s: duplicated", path+"/sysctl", key)
+			}
+			keys[key] = true
+			if err := e.validate(path + "/sysctl" + key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_State_Interface) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_State_Interface) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_State_Interface) Validate() error {
+	return s.validate("/linux-agent:state/interface")
+}
+
+func (s *LinuxAgent_State_Interface) validate(path string) error {
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_State_Route) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_State_Route) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_State_Route) Validate() error {
+	return s.validate("/linux-agent:state/route")
+}
+
+func (s *LinuxAgent_State_Route) validate(path string) error {
+	if len(s.Nexthop) > 0 {
+		keys := map[string]bool{}
+		for i := range s.Nexthop {
+			e := &s.Nexthop[i]
+			if e.Index == nil {
+				return fmt.Errorf("%s: key index is missing", path+"/nexthop")
+			}
+			key := fmt.Sprintf("[index='%v']", *e.Index)
+			if keys[key] {
+				return fmt.Errorf("%s%s: duplicated", path+"/nexthop", key)
+			}
+			keys[key] = true
+			if err := e.validate(path + "/nexthop" + key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_State_Route_Nexthop) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_State_Route_Nexthop) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_State_Route_Nexthop) Validate() error {
+	return s.validate("/linux-agent:state/route/nexthop")
+}
+
+func (s *LinuxAgent_State_Route_Nexthop) validate(path string) error {
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_State_Sysctl) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_State_Sysctl) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_State_Sysctl) Validate() error {
+	return s.validate("/linux-agent:state/sysctl")
+}
+
+func (s *LinuxAgent_State_Sysctl) validate(path string) error {
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Sysctls) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Sysctls) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Sysctls) Validate() error {
+	return s.validate("/linux-agent:sysctls")
+}
+
+func (s *LinuxAgent_Sysctls) validate(path string) error {
+	if len(s.Sysctl) > 0 {
+		keys := map[string]bool{}
+		for i := range s.Sysctl {
+			e := &s.Sysctl[i]
+			if e.Name == nil {
+				return fmt.Errorf("%s: key name is missing", path+"/sysctl")
+			}
+			key := fmt.Sprintf("[name='%v']", *e.Name)
+			if keys[key] {
+				return fmt.Errorf("%s%s: duplicated", path+"/sysctl", key)
+			}
+			keys[key] = true
+			if err := e.validate(path + "/sysctl" + key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Sysctls_Sysctl) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Sysctls_Sysctl) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Sysctls_Sysctl) Validate() error {
+	return s.validate("/linux-agent:sysctls/sysctl")
+}
+
+func (s *LinuxAgent_Sysctls_Sysctl) validate(path string) error {
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Vrfs) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Vrfs) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Vrfs) Validate() error {
+	return s.validate("/linux-agent:vrfs")
+}
+
+func (s *LinuxAgent_Vrfs) validate(path string) error {
+	if len(s.Vrf) > 0 {
+		keys := map[string]bool{}
+		for i := range s.Vrf {
+			e := &s.Vrf[i]
+			if e.Name == nil {
+				return fmt.Errorf("%s: key name is missing", path+"/vrf")
+			}
+			key := fmt.Sprintf("[name='%v']", *e.Name)
+			if keys[key] {
+				return fmt.Errorf("%s%s: duplicated", path+"/vrf", key)
+			}
+			keys[key] = true
+			if err := e.validate(path + "/vrf" + key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the JSON encoding of RFC 7951.
+func (s LinuxAgent_Vrfs_Vrf) MarshalJSON() ([]byte, error) {
+	return ygenMarshal(&s)
+}
+
+// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The
+// member names are also accepted without their module name.
+func (s *LinuxAgent_Vrfs_Vrf) UnmarshalJSON(b []byte) error {
+	return ygenUnmarshal(b, s, false)
+}
+
+// Validate checks the constraints of the YANG schema of s.
+func (s *LinuxAgent_Vrfs_Vrf) Validate() error {
+	return s.validate("/linux-agent:vrfs/vrf")
+}
+
+func (s *LinuxAgent_Vrfs_Vrf) validate(path string) error {
+	if s.Name != nil {
+		v := *s.Name
+		if n := uint64(utf8.RuneCountInString(v)); !(n >= 1 && n <= 15) {
+			return fmt.Errorf("%s: length of %q is out of range 1..15", path+"/name", v)
+		}
+	}
+	if s.Table == nil {
+		return fmt.Errorf("%s: mandatory leaf is missing", path+"/table")
+	}
+	return nil
+}
+
 // Binary is a type that is used for fields that have a YANG type of
 // binary. It is used such that binary fields can be distinguished from
 // leaf-lists of uint8s (which are mapped to []uint8, equivalent to
@@ -24,19 +673,212 @@ type Binary []byte
 // in the generated code.
 type YANGEmpty bool
 
-// Device represents the /device YANG schema element.
-type Device struct {
-	Interfaces LinuxAgent_Interfaces `json:"interfaces"`
+var (
+	ygenBinaryType = reflect.TypeOf(Binary(nil))
+	ygenEmptyType  = reflect.TypeOf(YANGEmpty(false))
+)
+
+func ygenMarshal(s interface{}) ([]byte, error) {
+	return json.Marshal(ygenEncodeStruct(reflect.ValueOf(s).Elem(), ""))
 }
 
-// LinuxAgent_Interfaces represents the /linux-agent/interfaces YANG schema element.
-type LinuxAgent_Interfaces struct {
-	Interface []LinuxAgent_Interfaces_Interface `json:"interface"`
+func ygenEncodeStruct(v reflect.Value, module string) map[string]interface{} {
+	m := map[string]interface{}{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, fmodule := t.Field(i).Tag.Get("path"), t.Field(i).Tag.Get("module")
+		if fmodule != module {
+			name = fmodule + ":" + name
+		}
+		if value, ok := ygenEncodeValue(v.Field(i), fmodule); ok {
+			m[name] = value
+		}
+	}
+	return m
 }
 
-// LinuxAgent_Interfaces_Interface represents the /linux-agent/interfaces/interface YANG schema element.
-type LinuxAgent_Interfaces_Interface struct {
-	Address *string `json:"address"`
-	Enabled *bool   `json:"enabled"`
-	Name    *string `json:"name"`
+// ygenEncodeValue returns the value of v in JSON. The 64-bit numbers are
+// encoded as strings as in RFC 7951.
+func ygenEncodeValue(v reflect.Value, module string) (interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, false
+		}
+		return ygenEncodeValue(v.Elem(), module)
+	case reflect.Struct:
+		m := ygenEncodeStruct(v, module)
+		return m, len(m) > 0
+	case reflect.Slice:
+		if v.Type() == ygenBinaryType {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), v.Len() > 0
+		}
+		a := []interface{}{}
+		for i := 0; i < v.Len(); i++ {
+			e, _ := ygenEncodeValue(v.Index(i), module)
+			a = append(a, e)
+		}
+		return a, len(a) > 0
+	case reflect.Bool:
+		if v.Type() == ygenEmptyType {
+			return []interface{}{nil}, v.Bool()
+		}
+		return v.Bool(), true
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return v.Int(), true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return v.Uint(), true
+	case reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	case reflect.String:
+		return v.String(), true
+	}
+	return nil, false
+}
+
+func ygenUnmarshal(b []byte, s interface{}, root bool) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	m := map[string]interface{}{}
+	if err := d.Decode(&m); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(s).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return ygenDecodeStruct(m, v, "", root)
+}
+
+func ygenDecodeStruct(m map[string]interface{}, v reflect.Value,
+	path string, root bool) error {
+	t := v.Type()
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		name, module := t.Field(i).Tag.Get("path"), t.Field(i).Tag.Get("module")
+		fields[name] = i
+		fields[module+":"+name] = i
+	}
+	for name, value := range m {
+		i, ok := fields[name]
+		if !ok {
+			if root {
+				continue
+			}
+			return fmt.Errorf("%s/%s: unknown member", path, name)
+		}
+		if err := ygenDecodeValue(value, v.Field(i), path+"/"+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ygenDecodeValue sets value to v. The numbers and booleans are also
+// accepted as strings, and the strings as numbers and booleans.
+func ygenDecodeValue(value interface{}, v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		n := reflect.New(v.Type().Elem())
+		if err := ygenDecodeValue(value, n.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(n)
+		return nil
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: object is expected", path)
+		}
+		return ygenDecodeStruct(m, v, path, false)
+	case reflect.Slice:
+		if v.Type() == ygenBinaryType {
+			s, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s: base64 string is expected", path)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		a, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: array is expected", path)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(a), len(a))
+		for i, e := range a {
+			if err := ygenDecodeValue(e, slice.Index(i), path); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Bool:
+		if v.Type() == ygenEmptyType {
+			v.SetBool(true)
+			return nil
+		}
+		if b, ok := value.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+		b, err := strconv.ParseBool(ygenScalarString(value))
+		if err != nil {
+			return fmt.Errorf("%s: boolean is expected", path)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		if b, ok := value.(bool); ok {
+			v.SetString(strconv.FormatBool(b))
+			return nil
+		}
+		switch value.(type) {
+		case string, json.Number:
+			v.SetString(ygenScalarString(value))
+			return nil
+		}
+		return fmt.Errorf("%s: string is expected", path)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(ygenScalarString(value), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(ygenScalarString(value), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(ygenScalarString(value), 64)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("%s: unsupported type %s", path, v.Type())
+}
+
+func ygenScalarString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return fmt.Sprint(value)
 }
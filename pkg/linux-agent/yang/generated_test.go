@@ -0,0 +1,96 @@
+package yang
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalMarshal(t *testing.T) {
+	// vtyang sends the member names without module name
+	input := `{
+  "interfaces": {"interface": [
+    {"name": "eth0", "mtu": 9000, "address": ["10.0.0.1/24"], "enabled": true},
+    {"name": "eth0.10", "vlan": {"parent": "eth0", "id": 10}}
+  ]},
+  "routes": {"route": [
+    {"table": 254, "prefix": "10.1.0.0/16", "nexthop": [{"index": 0, "gateway": "10.0.0.2"}]}
+  ]},
+  "frr-interface:lib": {"interface": []}
+}`
+	device := Device{}
+	if err := json.Unmarshal([]byte(input), &device); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(device.Interfaces.Interface); n != 2 {
+		t.Fatalf("unexpected %d interfaces", n)
+	}
+	if mtu := device.Interfaces.Interface[0].Mtu; mtu == nil || *mtu != 9000 {
+		t.Errorf("unexpected mtu %v", mtu)
+	}
+	if vlan := device.Interfaces.Interface[1].Vlan; vlan == nil ||
+		*vlan.Parent != "eth0" || *vlan.Id != 10 {
+		t.Errorf("unexpected vlan %+v", vlan)
+	}
+
+	out, err := json.Marshal(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"linux-agent:interfaces":{"interface":[` +
+		`{"address":["10.0.0.1/24"],"enabled":true,"mtu":9000,"name":"eth0"},` +
+		`{"name":"eth0.10","vlan":{"id":10,"parent":"eth0"}}]},` +
+		`"linux-agent:routes":{"route":[{"nexthop":[{"gateway":"10.0.0.2","index":0}],` +
+		`"prefix":"10.1.0.0/16","table":254}]}}`
+	if string(out) != expected {
+		t.Errorf("unexpected json\nexpected: %s\nresult:   %s", expected, out)
+	}
+
+	again := Device{}
+	if err := json.Unmarshal(out, &again); err != nil {
+		t.Fatal(err)
+	}
+	if out2, _ := json.Marshal(again); string(out2) != string(out) {
+		t.Errorf("not round-tripped: %s", out2)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testcases := []struct {
+		input string
+		err   string
+	}{
+		{
+			input: `{"interfaces": {"interface": [{"name": "eth0", "mtu": 10}]}}`,
+			err:   "/linux-agent:interfaces/interface[name='eth0']/mtu: 10 is out of range 68..65535",
+		},
+		{
+			input: `{"interfaces": {"interface": [{"name": "a-very-long-name"}]}}`,
+			err:   "/linux-agent:interfaces/interface[name='a-very-long-name']/name: length of \"a-very-long-name\" is out of range 1..15",
+		},
+		{
+			input: `{"interfaces": {"interface": [{"name": "eth0"}, {"name": "eth0"}]}}`,
+			err:   "/linux-agent:interfaces/interface[name='eth0']: duplicated",
+		},
+		{
+			input: `{"interfaces": {"interface": [{"mtu": 1500}]}}`,
+			err:   "/linux-agent:interfaces/interface: key name is missing",
+		},
+		{
+			input: `{"vrfs": {"vrf": [{"name": "vrf0"}]}}`,
+			err:   "/linux-agent:vrfs/vrf[name='vrf0']/table: mandatory leaf is missing",
+		},
+	}
+	for _, tc := range testcases {
+		device := Device{}
+		if err := json.Unmarshal([]byte(tc.input), &device); err != nil {
+			t.Fatal(err)
+		}
+		err := device.Validate()
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.input, err)
+		}
+	}
+}
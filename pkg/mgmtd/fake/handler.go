@@ -0,0 +1,353 @@
+package fake
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+	"github.com/slankdev/vtyang/pkg/util"
+)
+
+// handle processes the request and returns the replies with the delay to
+// send them.
+func (s *Server) handle(c *conn, msg *mgmtd.FeMessage) ([]*mgmtd.FeMessage,
+	time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reqType := ""
+	switch msg.Message.(type) {
+	case *mgmtd.FeMessage_SessionReq:
+		reqType = RequestSession
+	case *mgmtd.FeMessage_LockdsReq:
+		reqType = RequestLock
+	case *mgmtd.FeMessage_SetcfgReq:
+		reqType = RequestSetConfig
+	case *mgmtd.FeMessage_CommcfgReq:
+		reqType = RequestCommit
+	case *mgmtd.FeMessage_GetReq:
+		reqType = RequestGet
+	}
+	errStr := ""
+	delay := time.Duration(0)
+	if f := s.fault(reqType); f != nil {
+		errStr = f.Error
+		delay = f.Delay
+	}
+
+	switch m := msg.Message.(type) {
+	case *mgmtd.FeMessage_SessionReq:
+		return s.handleSession(c, m.SessionReq, errStr), delay
+	case *mgmtd.FeMessage_LockdsReq:
+		return s.handleLock(m.LockdsReq, errStr), delay
+	case *mgmtd.FeMessage_SetcfgReq:
+		return s.handleSetConfig(m.SetcfgReq, errStr), delay
+	case *mgmtd.FeMessage_CommcfgReq:
+		return s.handleCommit(m.CommcfgReq, errStr), delay
+	case *mgmtd.FeMessage_GetReq:
+		return s.handleGet(m.GetReq, errStr), delay
+	case *mgmtd.FeMessage_RegnotifyReq:
+		paths := []string{}
+		for _, x := range m.RegnotifyReq.DataXpath {
+			paths = append(paths, x.GetXpath())
+		}
+		if m.RegnotifyReq.GetRegisterReq() {
+			c.notifyPaths = append(c.notifyPaths, paths...)
+		} else {
+			c.notifyPaths = nil
+		}
+		return nil, 0
+	default:
+		return nil, 0
+	}
+}
+
+// destroySession deletes the session and releases its locks.
+func (s *Server) destroySession(id uint64) {
+	delete(s.sessions, id)
+	for ds, owner := range s.locks {
+		if owner == id {
+			delete(s.locks, ds)
+		}
+	}
+}
+
+// checkLock returns the error message when the datastore is locked by
+// another session.
+func (s *Server) checkLock(sessionId uint64, dsId mgmtd.DatastoreId) string {
+	if owner, ok := s.locks[dsId]; ok && owner != sessionId {
+		return "Lock already taken on DS by another session!"
+	}
+	return ""
+}
+
+func (s *Server) handleSession(c *conn, req *mgmtd.FeSessionReq,
+	errStr string) []*mgmtd.FeMessage {
+	reply := &mgmtd.FeSessionReply{
+		Create:    req.Create,
+		Success:   util.NewBoolPointer(errStr == ""),
+		SessionId: util.NewUint64Pointer(req.GetSessionId()),
+	}
+	switch {
+	case errStr != "":
+	case req.GetCreate():
+		id := s.nextSessionId
+		s.nextSessionId++
+		s.sessions[id] = c
+		reply.ClientConnId = util.NewUint64Pointer(req.GetClientConnId())
+		reply.SessionId = util.NewUint64Pointer(id)
+	default:
+		id := req.GetSessionId()
+		if _, ok := s.sessions[id]; !ok {
+			reply.Success = util.NewBoolPointer(false)
+		}
+		s.destroySession(id)
+	}
+	return []*mgmtd.FeMessage{{
+		Message: &mgmtd.FeMessage_SessionReply{SessionReply: reply},
+	}}
+}
+
+func (s *Server) handleLock(req *mgmtd.FeLockDsReq,
+	errStr string) []*mgmtd.FeMessage {
+	id := req.GetSessionId()
+	ds := req.GetDsId()
+	if errStr == "" {
+		owner, locked := s.locks[ds]
+		switch {
+		case req.GetLock() && locked && owner != id:
+			errStr = "Lock already taken on DS by another session!"
+		case req.GetLock():
+			s.locks[ds] = id
+		case !locked || owner != id:
+			errStr = "Lock on DS was not taken by this session!"
+		default:
+			delete(s.locks, ds)
+		}
+	}
+	reply := &mgmtd.FeLockDsReply{
+		SessionId: req.SessionId,
+		ReqId:     req.ReqId,
+		DsId:      req.DsId,
+		Lock:      req.Lock,
+		Success:   util.NewBoolPointer(errStr == ""),
+	}
+	if errStr != "" {
+		reply.ErrorIfAny = util.NewStringPointer(errStr)
+	}
+	return []*mgmtd.FeMessage{{
+		Message: &mgmtd.FeMessage_LockdsReply{LockdsReply: reply},
+	}}
+}
+
+func (s *Server) handleSetConfig(req *mgmtd.FeSetConfigReq,
+	errStr string) []*mgmtd.FeMessage {
+	s.setConfigs = append(s.setConfigs, req)
+	if errStr == "" {
+		errStr = s.checkLock(req.GetSessionId(), req.GetDsId())
+	}
+	if errStr == "" {
+		ds := s.datastores[req.GetDsId()]
+		for _, d := range req.Data {
+			xpath := d.GetData().GetXpath()
+			switch d.GetReqType() {
+			case mgmtd.CfgDataReqType_DELETE_DATA, mgmtd.CfgDataReqType_REMOVE_DATA:
+				for k := range ds {
+					if matchXPath(xpath, k) {
+						delete(ds, k)
+					}
+				}
+			default:
+				ds[normalizeXPath(xpath)] = d.GetData().GetValue().GetEncodedStrVal()
+			}
+		}
+	}
+	reply := &mgmtd.FeSetConfigReply{
+		SessionId:      req.SessionId,
+		DsId:           req.DsId,
+		ReqId:          req.ReqId,
+		Success:        util.NewBoolPointer(errStr == ""),
+		ImplicitCommit: req.ImplicitCommit,
+	}
+	if errStr != "" {
+		reply.ErrorIfAny = util.NewStringPointer(errStr)
+	}
+	return []*mgmtd.FeMessage{{
+		Message: &mgmtd.FeMessage_SetcfgReply{SetcfgReply: reply},
+	}}
+}
+
+func (s *Server) handleCommit(req *mgmtd.FeCommitConfigReq,
+	errStr string) []*mgmtd.FeMessage {
+	s.commits = append(s.commits, req)
+	src := req.GetSrcDsId()
+	dst := req.GetDstDsId()
+	if errStr == "" {
+		errStr = s.checkLock(req.GetSessionId(), src)
+	}
+	if errStr == "" {
+		errStr = s.checkLock(req.GetSessionId(), dst)
+	}
+	if errStr == "" {
+		switch {
+		case req.GetAbort():
+			s.datastores[src] = copyDatastore(s.datastores[dst])
+		case req.GetValidateOnly():
+		default:
+			s.datastores[dst] = copyDatastore(s.datastores[src])
+		}
+	}
+	reply := &mgmtd.FeCommitConfigReply{
+		SessionId:    req.SessionId,
+		SrcDsId:      req.SrcDsId,
+		DstDsId:      req.DstDsId,
+		ReqId:        req.ReqId,
+		ValidateOnly: req.ValidateOnly,
+		Abort:        req.Abort,
+		Success:      util.NewBoolPointer(errStr == ""),
+	}
+	if errStr != "" {
+		reply.ErrorIfAny = util.NewStringPointer(errStr)
+	}
+	return []*mgmtd.FeMessage{{
+		Message: &mgmtd.FeMessage_CommcfgReply{CommcfgReply: reply},
+	}}
+}
+
+// handleGet replies the data under the requested xpaths sorted by xpath,
+// split into batches of BatchSize. next_indx of the last batch is -1.
+func (s *Server) handleGet(req *mgmtd.FeGetReq,
+	errStr string) []*mgmtd.FeMessage {
+	if errStr != "" {
+		return []*mgmtd.FeMessage{{
+			Message: &mgmtd.FeMessage_GetReply{
+				GetReply: &mgmtd.FeGetReply{
+					SessionId:  req.SessionId,
+					Config:     req.Config,
+					DsId:       req.DsId,
+					ReqId:      req.ReqId,
+					Success:    util.NewBoolPointer(false),
+					ErrorIfAny: util.NewStringPointer(errStr),
+				},
+			},
+		}}
+	}
+
+	ds := s.datastores[req.GetDsId()]
+	xpaths := []string{}
+	for xpath := range ds {
+		for _, d := range req.Data {
+			if matchXPath(d.GetData().GetXpath(), xpath) {
+				xpaths = append(xpaths, xpath)
+				break
+			}
+		}
+	}
+	sort.Strings(xpaths)
+
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(xpaths) + 1
+	}
+	ret := []*mgmtd.FeMessage{}
+	for idx := 0; idx == 0 || idx < len(xpaths); idx += batchSize {
+		datas := []*mgmtd.YangData{}
+		end := idx + batchSize
+		if end > len(xpaths) {
+			end = len(xpaths)
+		}
+		for _, xpath := range xpaths[idx:end] {
+			datas = append(datas, &mgmtd.YangData{
+				Xpath: util.NewStringPointer(xpath),
+				Value: &mgmtd.YangDataValue{
+					Value: &mgmtd.YangDataValue_EncodedStrVal{
+						EncodedStrVal: ds[xpath],
+					},
+				},
+			})
+		}
+		nextIndx := int64(end)
+		if end >= len(xpaths) {
+			nextIndx = -1
+		}
+		ret = append(ret, &mgmtd.FeMessage{
+			Message: &mgmtd.FeMessage_GetReply{
+				GetReply: &mgmtd.FeGetReply{
+					SessionId: req.SessionId,
+					Config:    req.Config,
+					DsId:      req.DsId,
+					ReqId:     req.ReqId,
+					Success:   util.NewBoolPointer(true),
+					Data: &mgmtd.YangDataReply{
+						Data:     datas,
+						NextIndx: util.NewInt64Pointer(nextIndx),
+					},
+				},
+			},
+		})
+	}
+	return ret
+}
+
+// matchXPath returns true when xpath is same as or under the prefix.
+func matchXPath(prefix, xpath string) bool {
+	prefix = normalizeXPath(prefix)
+	xpath = normalizeXPath(xpath)
+	if prefix == "/" || prefix == xpath {
+		return true
+	}
+	return strings.HasPrefix(xpath, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// normalizeXPath drops the module prefixes which are same as the parent
+// node, as "/a:x/a:y" and "/a:x/y" point the same node.
+func normalizeXPath(xpath string) string {
+	segs := []string{}
+	depth := 0
+	start := 0
+	for i, c := range xpath {
+		switch {
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0:
+			segs = append(segs, xpath[start:i])
+			start = i + 1
+		}
+	}
+	segs = append(segs, xpath[start:])
+
+	ret := ""
+	module := ""
+	for _, seg := range segs {
+		if seg == "" {
+			continue
+		}
+		name := seg
+		if idx := strings.IndexAny(seg, "["); idx >= 0 {
+			name = seg[:idx]
+		}
+		if idx := strings.Index(name, ":"); idx >= 0 {
+			if name[:idx] == module {
+				seg = seg[idx+1:]
+			} else {
+				module = name[:idx]
+			}
+		}
+		ret += "/" + seg
+	}
+	if ret == "" {
+		return "/"
+	}
+	return ret
+}
+
+func copyDatastore(ds map[string]string) map[string]string {
+	ret := map[string]string{}
+	for k, v := range ds {
+		ret[k] = v
+	}
+	return ret
+}
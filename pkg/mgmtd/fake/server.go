@@ -0,0 +1,306 @@
+// Package fake implements a fake FRR mgmtd frontend server. It speaks the
+// same framed protobuf messages over unix socket and keeps the datastores
+// as lists of xpath and value, so that mgmtd clients can be tested
+// without FRR. Datastore locks are enforced as same as mgmtd and faults
+// can be injected to the replies.
+package fake
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+)
+
+// Request types to inject faults.
+const (
+	RequestSession   = "session"
+	RequestLock      = "lock"
+	RequestSetConfig = "setcfg"
+	RequestCommit    = "commit"
+	RequestGet       = "get"
+)
+
+// Fault is injected to the replies of a request type.
+type Fault struct {
+	// Error makes the request fail with the message without touching the
+	// datastores.
+	Error string
+	// Delay delays the reply. Replies of the following requests are not
+	// blocked, so they can be sent before the delayed one.
+	Delay time.Duration
+	// Count is the number of requests to inject the fault. Zero means all
+	// requests until the fault is cleared.
+	Count int
+}
+
+// Server is a fake mgmtd frontend server.
+type Server struct {
+	// BatchSize is the max number of data in one FeGetReply.
+	BatchSize int
+	// SplitFrameSize makes the server write frames in chunks of the size,
+	// so that clients receive partial frames. Zero disables it.
+	SplitFrameSize int
+
+	lis           net.Listener
+	lock          sync.Mutex
+	nextSessionId uint64
+	sessions      map[uint64]*conn
+	conns         map[*conn]struct{}
+	datastores    map[mgmtd.DatastoreId]map[string]string
+	locks         map[mgmtd.DatastoreId]uint64
+	faults        map[string]*Fault
+	commits       []*mgmtd.FeCommitConfigReq
+	setConfigs    []*mgmtd.FeSetConfigReq
+	wg            sync.WaitGroup
+}
+
+type conn struct {
+	net.Conn
+	s           *Server
+	writeLock   sync.Mutex
+	notifyPaths []string
+}
+
+func (c *conn) write(msg *mgmtd.FeMessage) error {
+	buf := bytes.Buffer{}
+	if err := mgmtd.WriteProtobufMsg(&buf, msg); err != nil {
+		return err
+	}
+	c.s.lock.Lock()
+	size := c.s.SplitFrameSize
+	c.s.lock.Unlock()
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	data := buf.Bytes()
+	if size <= 0 {
+		size = len(data)
+	}
+	for len(data) > 0 {
+		n := size
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := c.Conn.Write(data[:n]); err != nil {
+			return errors.Wrap(err, "Write")
+		}
+		data = data[n:]
+		if len(data) > 0 {
+			// Give the peer a chance to read the partial frame
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// NewServer starts the server listening on sockpath.
+func NewServer(sockpath string) (*Server, error) {
+	if err := os.RemoveAll(sockpath); err != nil {
+		return nil, errors.Wrapf(err, "os.RemoveAll(%s)", sockpath)
+	}
+	lis, err := net.Listen("unix", sockpath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "net.Listen(%s)", sockpath)
+	}
+	s := &Server{
+		BatchSize:     100,
+		lis:           lis,
+		nextSessionId: 1,
+		sessions:      map[uint64]*conn{},
+		conns:         map[*conn]struct{}{},
+		datastores: map[mgmtd.DatastoreId]map[string]string{
+			mgmtd.DatastoreId_RUNNING_DS:     {},
+			mgmtd.DatastoreId_CANDIDATE_DS:   {},
+			mgmtd.DatastoreId_OPERATIONAL_DS: {},
+		},
+		locks:  map[mgmtd.DatastoreId]uint64{},
+		faults: map[string]*Fault{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and disconnects all clients.
+func (s *Server) Close() error {
+	err := s.lis.Close()
+	s.lock.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// SetData replaces the value of xpath in the datastore. The xpath is
+// stored after normalizeXPath.
+func (s *Server) SetData(dsId mgmtd.DatastoreId, xpath, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.datastores[dsId][normalizeXPath(xpath)] = value
+}
+
+// Data returns the datastore as a map of xpath and value.
+func (s *Server) Data(dsId mgmtd.DatastoreId) map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return copyDatastore(s.datastores[dsId])
+}
+
+// Sessions returns the ids of the active sessions.
+func (s *Server) Sessions() []uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := []uint64{}
+	for id := range s.sessions {
+		ret = append(ret, id)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+// Locks returns the session ids holding the datastore locks.
+func (s *Server) Locks() map[mgmtd.DatastoreId]uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := map[mgmtd.DatastoreId]uint64{}
+	for ds, id := range s.locks {
+		ret[ds] = id
+	}
+	return ret
+}
+
+// Commits returns the received commit requests.
+func (s *Server) Commits() []*mgmtd.FeCommitConfigReq {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*mgmtd.FeCommitConfigReq{}, s.commits...)
+}
+
+// SetConfigs returns the received set-config requests.
+func (s *Server) SetConfigs() []*mgmtd.FeSetConfigReq {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*mgmtd.FeSetConfigReq{}, s.setConfigs...)
+}
+
+// InjectFault injects f to the replies of the request type.
+func (s *Server) InjectFault(reqType string, f Fault) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults[reqType] = &f
+}
+
+func (s *Server) ClearFaults() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults = map[string]*Fault{}
+}
+
+// fault returns the fault to inject to the request and counts it.
+func (s *Server) fault(reqType string) *Fault {
+	f, ok := s.faults[reqType]
+	if !ok {
+		return nil
+	}
+	if f.Count > 0 {
+		f.Count--
+		if f.Count == 0 {
+			delete(s.faults, reqType)
+		}
+	}
+	return f
+}
+
+// Notify sends datas as FeNotifyDataReq to the clients registered for
+// notifications matching the xpath of the first data.
+func (s *Server) Notify(datas []*mgmtd.YangData) error {
+	if len(datas) == 0 {
+		return errors.Errorf("no data")
+	}
+	s.lock.Lock()
+	targets := []*conn{}
+	for c := range s.conns {
+		for _, p := range c.notifyPaths {
+			if matchXPath(p, datas[0].GetXpath()) {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	s.lock.Unlock()
+	for _, c := range targets {
+		if err := c.write(&mgmtd.FeMessage{
+			Message: &mgmtd.FeMessage_NotifyDataReq{
+				NotifyDataReq: &mgmtd.FeNotifyDataReq{Data: datas},
+			},
+		}); err != nil {
+			return errors.Wrap(err, "write")
+		}
+	}
+	return nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.lis.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{Conn: nc, s: s}
+		s.lock.Lock()
+		s.conns[c] = struct{}{}
+		s.lock.Unlock()
+		s.wg.Add(1)
+		go s.serveConn(c)
+	}
+}
+
+func (s *Server) serveConn(c *conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.conns, c)
+		for id, sc := range s.sessions {
+			if sc == c {
+				s.destroySession(id)
+			}
+		}
+		c.Close()
+	}()
+	dec := mgmtd.NewDecoder(c.Conn)
+	for {
+		msg, err := dec.Decode()
+		if err != nil {
+			return
+		}
+		replies, delay := s.handle(c, msg)
+		if delay > 0 {
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				time.Sleep(delay)
+				for _, reply := range replies {
+					if err := c.write(reply); err != nil {
+						return
+					}
+				}
+			}()
+			continue
+		}
+		for _, reply := range replies {
+			if err := c.write(reply); err != nil {
+				return
+			}
+		}
+	}
+}
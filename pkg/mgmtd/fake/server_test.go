@@ -0,0 +1,202 @@
+package fake_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+	"github.com/slankdev/vtyang/pkg/mgmtd/fake"
+	"github.com/slankdev/vtyang/pkg/util"
+)
+
+func newTestServer(t *testing.T) (*fake.Server, string) {
+	sockpath := filepath.Join(t.TempDir(), "mgmtd_fe.sock")
+	s, err := fake.NewServer(sockpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, sockpath
+}
+
+func newTestClient(t *testing.T, sockpath string) *mgmtd.Client {
+	c, err := mgmtd.NewClient(sockpath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func lock(c *mgmtd.Client, ds mgmtd.DatastoreId, lock bool) error {
+	return c.LockReq(context.Background(), &mgmtd.FeLockDsReq{
+		SessionId: c.GetSessionId(),
+		DsId:      ds.Enum(),
+		Lock:      util.NewBoolPointer(lock),
+	})
+}
+
+func setConfig(c *mgmtd.Client, xpath, value string) error {
+	return c.SetConfig(context.Background(), &mgmtd.FeSetConfigReq{
+		SessionId:      c.GetSessionId(),
+		DsId:           mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+		CommitDsId:     mgmtd.DatastoreId_RUNNING_DS.Enum(),
+		ImplicitCommit: util.NewBoolPointer(false),
+		Data: []*mgmtd.YangCfgDataReq{
+			{
+				ReqType: mgmtd.CfgDataReqType_SET_DATA.Enum(),
+				Data: &mgmtd.YangData{
+					Xpath: util.NewStringPointer(xpath),
+					Value: &mgmtd.YangDataValue{
+						Value: &mgmtd.YangDataValue_EncodedStrVal{
+							EncodedStrVal: value,
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func commit(c *mgmtd.Client) error {
+	return c.CommitConfig(context.Background(), &mgmtd.FeCommitConfigReq{
+		SessionId:    c.GetSessionId(),
+		SrcDsId:      mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+		DstDsId:      mgmtd.DatastoreId_RUNNING_DS.Enum(),
+		ValidateOnly: util.NewBoolPointer(false),
+		Abort:        util.NewBoolPointer(false),
+	})
+}
+
+func TestServerLock(t *testing.T) {
+	s, sockpath := newTestServer(t)
+	c1 := newTestClient(t, sockpath)
+	c2 := newTestClient(t, sockpath)
+	defer c2.Close()
+
+	if err := lock(c1, mgmtd.DatastoreId_CANDIDATE_DS, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock(c1, mgmtd.DatastoreId_RUNNING_DS, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock(c2, mgmtd.DatastoreId_CANDIDATE_DS, true); err == nil {
+		t.Fatal("lock taken by another session is acquired")
+	}
+	if err := lock(c2, mgmtd.DatastoreId_CANDIDATE_DS, false); err == nil {
+		t.Fatal("lock taken by another session is released")
+	}
+	if err := setConfig(c2, "/a/b", "1"); err == nil {
+		t.Fatal("locked datastore is edited by another session")
+	}
+	if err := commit(c2); err == nil {
+		t.Fatal("locked datastore is committed by another session")
+	}
+	if err := setConfig(c1, "/a/b", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := commit(c1); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Data(mgmtd.DatastoreId_RUNNING_DS)["/a/b"]; got != "1" {
+		t.Fatalf("unexpected running %q", got)
+	}
+
+	// Locks are released when the session is destroyed
+	if err := c1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Locks(); len(got) != 0 {
+		t.Fatalf("locks are not released %v", got)
+	}
+	if err := lock(c2, mgmtd.DatastoreId_CANDIDATE_DS, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerFaultError(t *testing.T) {
+	s, sockpath := newTestServer(t)
+	c := newTestClient(t, sockpath)
+	defer c.Close()
+
+	s.InjectFault(fake.RequestCommit, fake.Fault{
+		Error: "validation failed",
+		Count: 1,
+	})
+	if err := setConfig(c, "/a/b", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := commit(c); err == nil ||
+		!strings.Contains(err.Error(), "validation failed") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := s.Data(mgmtd.DatastoreId_RUNNING_DS); len(got) != 0 {
+		t.Fatalf("failed commit is applied %v", got)
+	}
+	if err := commit(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerFaultDelay(t *testing.T) {
+	s, sockpath := newTestServer(t)
+	c := newTestClient(t, sockpath)
+	defer c.Close()
+	s.SetData(mgmtd.DatastoreId_OPERATIONAL_DS, "/a/b", "1")
+
+	s.InjectFault(fake.RequestGet, fake.Fault{
+		Delay: 200 * time.Millisecond,
+		Count: 1,
+	})
+	order := make(chan string, 2)
+	go func() {
+		if _, err := c.GetOperData(context.Background(), "/a"); err != nil {
+			t.Error(err)
+		}
+		order <- "delayed"
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := c.GetOperData(context.Background(), "/a"); err != nil {
+		t.Fatal(err)
+	}
+	order <- "normal"
+	if first := <-order; first != "normal" {
+		t.Fatalf("delayed reply is received first")
+	}
+	<-order
+
+	// Timeout of the client
+	s.InjectFault(fake.RequestGet, fake.Fault{
+		Delay: 200 * time.Millisecond,
+		Count: 1,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetOperData(ctx, "/a"); err == nil {
+		t.Fatal("expected timeout")
+	}
+}
+
+func TestServerSplitFrames(t *testing.T) {
+	s, sockpath := newTestServer(t)
+	c := newTestClient(t, sockpath)
+	defer c.Close()
+
+	s.SplitFrameSize = 1000
+	for i := 0; i < 1000; i++ {
+		s.SetData(mgmtd.DatastoreId_OPERATIONAL_DS,
+			fmt.Sprintf("/a/item[name='item%04d']/value", i),
+			strings.Repeat("x", 32))
+	}
+	datas, err := c.GetOperData(context.Background(), "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(datas) != 1000 {
+		t.Fatalf("unexpected data length %d", len(datas))
+	}
+}
@@ -0,0 +1,96 @@
+package mgmtd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// frameHeaderSize is the size of the marker and the length. The length
+	// in the header includes the header itself.
+	frameHeaderSize = 8
+
+	// MaxFrameSize limits the size of a frame to detect broken streams
+	// rather than allocating huge buffers.
+	MaxFrameSize = 64 * 1024 * 1024
+)
+
+// WriteProtobufMsg writes msg with the frame header of mgmtd messages.
+// The frame is written with a single Write call, so that frames written
+// from multiple goroutines are not interleaved as long as the writer is
+// safe for concurrent use.
+func WriteProtobufMsg(w io.Writer, msg *FeMessage) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "proto.Marshal")
+	}
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian,
+		MGMT_MSG_MARKER_PROTOBUF); err != nil {
+		return errors.Wrap(err, "binary.Write")
+	}
+	if err := binary.Write(buf, binary.LittleEndian,
+		uint32(frameHeaderSize+len(data))); err != nil {
+		return errors.Wrap(err, "binary.Write")
+	}
+	if _, err := buf.Write([]byte(data)); err != nil {
+		return errors.Wrap(err, "buf.Write")
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.Wrap(err, "Write")
+	}
+	if globalVerboseEnabled {
+		fmt.Println(hex.Dump(buf.Bytes()))
+	}
+	return nil
+}
+
+// Decoder reads framed messages from a stream. A frame can be split into
+// multiple reads and a read can contain multiple frames.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next message. It returns io.EOF when the stream is
+// closed at the frame boundary.
+func (d *Decoder) Decode() (*FeMessage, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "read(header)")
+	}
+	marker := binary.LittleEndian.Uint32(header[0:4])
+	if marker != MGMT_MSG_MARKER_PROTOBUF {
+		return nil, errors.Errorf("not PROTOBUF marker (0x%08x)", marker)
+	}
+	size := binary.LittleEndian.Uint32(header[4:8])
+	if size < frameHeaderSize || size > MaxFrameSize {
+		return nil, errors.Errorf("invalid frame size %d", size)
+	}
+
+	body := make([]byte, size-frameHeaderSize)
+	if _, err := io.ReadFull(d.r, body); err != nil {
+		return nil, errors.Wrap(err, "read(body)")
+	}
+	if globalVerboseEnabled {
+		fmt.Println(hex.Dump(append(header, body...)))
+	}
+	msg := &FeMessage{}
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, errors.Wrap(err, "proto.Unmarshal")
+	}
+	return msg, nil
+}
@@ -0,0 +1,95 @@
+package mgmtd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/slankdev/vtyang/pkg/util"
+)
+
+func newTestGetReply(reqId uint64, n int) *FeMessage {
+	datas := []*YangData{}
+	for i := 0; i < n; i++ {
+		datas = append(datas, &YangData{
+			Xpath: util.NewStringPointer("/frr-test:test/" + strings.Repeat("x", 64)),
+			Value: &YangDataValue{
+				Value: &YangDataValue_EncodedStrVal{EncodedStrVal: "value"},
+			},
+		})
+	}
+	return &FeMessage{
+		Message: &FeMessage_GetReply{
+			GetReply: &FeGetReply{
+				SessionId: util.NewUint64Pointer(1),
+				Config:    util.NewBoolPointer(true),
+				DsId:      DatastoreId_RUNNING_DS.Enum(),
+				ReqId:     util.NewUint64Pointer(reqId),
+				Success:   util.NewBoolPointer(true),
+				Data: &YangDataReply{
+					Data:     datas,
+					NextIndx: util.NewInt64Pointer(-1),
+				},
+			},
+		},
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	buf := bytes.Buffer{}
+	// The 2nd message is larger than the buffer of the old reader
+	sizes := []int{1, 1000, 3}
+	for i, n := range sizes {
+		if err := WriteProtobufMsg(&buf, newTestGetReply(uint64(i+1), n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.Len() < 40960 {
+		t.Fatalf("test data is too small %d", buf.Len())
+	}
+
+	for name, r := range map[string]io.Reader{
+		"bulk":     bytes.NewReader(buf.Bytes()),
+		"one-byte": iotest.OneByteReader(bytes.NewReader(buf.Bytes())),
+		"half":     iotest.HalfReader(bytes.NewReader(buf.Bytes())),
+	} {
+		dec := NewDecoder(r)
+		for i, n := range sizes {
+			msg, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			reply := msg.GetGetReply()
+			if reply.GetReqId() != uint64(i+1) || len(reply.GetData().GetData()) != n {
+				t.Fatalf("%s: unexpected message %d", name, reply.GetReqId())
+			}
+		}
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Fatalf("%s: expected EOF but %v", name, err)
+		}
+	}
+}
+
+func TestDecoderError(t *testing.T) {
+	frame := func(marker, size uint32, body []byte) []byte {
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint32(buf[0:4], marker)
+		binary.LittleEndian.PutUint32(buf[4:8], size)
+		return append(buf, body...)
+	}
+	for name, data := range map[string][]byte{
+		"marker":         frame(MGMT_MSG_MARKER_NATIVE, 8, nil),
+		"size":           frame(MGMT_MSG_MARKER_PROTOBUF, 4, nil),
+		"too-big":        frame(MGMT_MSG_MARKER_PROTOBUF, MaxFrameSize+1, nil),
+		"truncated":      frame(MGMT_MSG_MARKER_PROTOBUF, 16, []byte{1, 2}),
+		"partial-header": {0x00, 0x23},
+	} {
+		if _, err := NewDecoder(bytes.NewReader(data)).Decode(); err == nil ||
+			err == io.EOF {
+			t.Errorf("%s: expected error but %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package mgmtd
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ReconnectMinBackoff and ReconnectMaxBackoff bound the interval of
+	// reconnection attempts. The interval doubles on each failure.
+	ReconnectMinBackoff = 100 * time.Millisecond
+	ReconnectMaxBackoff = 10 * time.Second
+)
+
+// ConnectHandler is called by the supervisor with the new client after
+// each reconnection, before the client is returned by Client().
+type ConnectHandler func(c *Client) error
+
+// SupervisorStatus is the state of the supervised connection.
+type SupervisorStatus struct {
+	Connected  bool
+	SockPath   string
+	SessionId  uint64
+	Reconnects int
+	// Since is the time of the last connection or disconnection.
+	Since     time.Time
+	LastError error
+}
+
+// Supervisor keeps a client connected to mgmtd. When the connection is
+// lost, it reconnects with exponential backoff and creates a new session.
+// As the locks and the notification registrations belong to the session,
+// they have to be restored by the ConnectHandler.
+type Supervisor struct {
+	sockpath string
+	name     string
+
+	lock       sync.Mutex
+	client     *Client
+	onConnect  ConnectHandler
+	connected  bool
+	reconnects int
+	since      time.Time
+	lastErr    error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSupervisor connects to mgmtd and starts supervising the connection.
+// The first connection must succeed.
+func NewSupervisor(sockpath, name string) (*Supervisor, error) {
+	if sockpath == "" {
+		sockpath = DefaultSockPath
+	}
+	c, err := NewClient(sockpath, name)
+	if err != nil {
+		return nil, err
+	}
+	s := &Supervisor{
+		sockpath:  sockpath,
+		name:      name,
+		client:    c,
+		connected: true,
+		since:     time.Now(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.supervise()
+	return s, nil
+}
+
+// SetConnectHandler sets the handler called after reconnections.
+func (s *Supervisor) SetConnectHandler(h ConnectHandler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.onConnect = h
+}
+
+// Client returns the last connected client. Requests fail while the
+// connection is lost.
+func (s *Supervisor) Client() *Client {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.client
+}
+
+func (s *Supervisor) Status() SupervisorStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return SupervisorStatus{
+		Connected:  s.connected,
+		SockPath:   s.sockpath,
+		SessionId:  *s.client.GetSessionId(),
+		Reconnects: s.reconnects,
+		Since:      s.since,
+		LastError:  s.lastErr,
+	}
+}
+
+// Close stops the supervision and closes the client.
+func (s *Supervisor) Close() error {
+	close(s.stop)
+	<-s.done
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.connected {
+		return nil
+	}
+	s.connected = false
+	return s.client.Close()
+}
+
+func (s *Supervisor) supervise() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.Client().Done():
+		}
+
+		err := s.Client().Err()
+		log.Printf("mgmtd: connection lost: %v\n", err)
+		s.lock.Lock()
+		s.connected = false
+		s.since = time.Now()
+		s.lastErr = errors.Wrap(err, "connection lost")
+		s.lock.Unlock()
+
+		c := s.reconnect()
+		if c == nil {
+			return
+		}
+		s.lock.Lock()
+		h := s.onConnect
+		s.lock.Unlock()
+		if h != nil {
+			if err := h(c); err != nil {
+				log.Printf("mgmtd: connect handler: %s\n", err)
+				s.lock.Lock()
+				s.lastErr = errors.Wrap(err, "connect handler")
+				s.lock.Unlock()
+			}
+		}
+		s.lock.Lock()
+		s.client = c
+		s.connected = true
+		s.reconnects++
+		s.since = time.Now()
+		s.lock.Unlock()
+		log.Printf("mgmtd: reconnected session-id=%d\n", *c.GetSessionId())
+	}
+}
+
+// reconnect tries to connect until it succeeds or the supervisor is
+// stopped. It returns nil when stopped.
+func (s *Supervisor) reconnect() *Client {
+	backoff := ReconnectMinBackoff
+	for {
+		select {
+		case <-s.stop:
+			return nil
+		case <-time.After(backoff):
+		}
+		c, err := NewClient(s.sockpath, s.name)
+		if err == nil {
+			return c
+		}
+		s.lock.Lock()
+		s.lastErr = errors.Wrap(err, "reconnect")
+		s.lock.Unlock()
+		backoff *= 2
+		if backoff > ReconnectMaxBackoff {
+			backoff = ReconnectMaxBackoff
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package mgmtd_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+	"github.com/slankdev/vtyang/pkg/mgmtd/fake"
+)
+
+func TestSupervisorReconnect(t *testing.T) {
+	sockpath := filepath.Join(t.TempDir(), "mgmtd_fe.sock")
+	s, err := fake.NewServer(sockpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup, err := mgmtd.NewSupervisor(sockpath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sup.Close()
+	connected := make(chan *mgmtd.Client, 1)
+	sup.SetConnectHandler(func(c *mgmtd.Client) error {
+		// Requests can be issued from the handler
+		if _, err := c.GetOperData(context.Background(), "/"); err != nil {
+			return err
+		}
+		connected <- c
+		return nil
+	})
+
+	// Restart the server, the client reconnects after the backoff
+	s.Close()
+	time.Sleep(3 * mgmtd.ReconnectMinBackoff)
+	if sup.Status().Connected {
+		t.Fatal("disconnection is not detected")
+	}
+	s, err = fake.NewServer(sockpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var c *mgmtd.Client
+	select {
+	case c = <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not reconnected")
+	}
+	if got := s.Sessions(); !reflect.DeepEqual(got,
+		[]uint64{*c.GetSessionId()}) {
+		t.Fatalf("sessions %v", got)
+	}
+	for i := 0; !sup.Status().Connected; i++ {
+		if i > 100 {
+			t.Fatal("status is not updated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	status := sup.Status()
+	if status.Reconnects != 1 || status.LastError == nil {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if sup.Client() != c {
+		t.Fatal("client is not replaced")
+	}
+}
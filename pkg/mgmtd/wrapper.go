@@ -1,16 +1,15 @@
 package mgmtd
 
 import (
-	"bytes"
-	"encoding/binary"
-	"encoding/hex"
-	"fmt"
+	"context"
+	"log"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/k0kubun/pp"
 	"github.com/pkg/errors"
 	"github.com/slankdev/vtyang/pkg/util"
-	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -19,13 +18,44 @@ const (
 	MGMT_MSG_MARKER_NATIVE   = uint32(0x23232301)
 )
 
+// DefaultSockPath is the unix socket of mgmtd frontend interface.
+const DefaultSockPath = "/var/run/frr/mgmtd_fe.sock"
+
 var (
 	globalVerboseEnabled = false
+
+	// SessionTimeout is the time to wait for the reply of FeSessionReq.
+	SessionTimeout = 10 * time.Second
 )
 
+// sessionReqId is the key of the pending request for FeSessionReq, as
+// FeSessionReply doesn't have req_id. Request ids start from 1.
+const sessionReqId = 0
+
+// NotifyHandler is called from the receive loop for each FeNotifyDataReq.
+type NotifyHandler func(datas []*YangData)
+
+// Client is a frontend client of FRR mgmtd. Each request is sent with a
+// unique req_id and the receive loop passes the replies to the request
+// waiting for the same req_id, so requests can be issued concurrently.
+// Notifications are dispatched to the handler.
 type Client struct {
 	conn      net.Conn
 	sessionId uint64
+	nextReqId uint64
+
+	writeLock sync.Mutex
+	lock      sync.Mutex
+	pending   map[uint64]*pendingReq
+	done      chan struct{}
+	err       error
+
+	notifyHandler NotifyHandler
+}
+
+type pendingReq struct {
+	replies chan *FeMessage
+	cancel  chan struct{}
 }
 
 func SetGlobalVerbose(enabled bool) {
@@ -33,25 +63,38 @@ func SetGlobalVerbose(enabled bool) {
 }
 
 func NewClient(sockpath, name string) (*Client, error) {
+	if sockpath == "" {
+		sockpath = DefaultSockPath
+	}
+
 	// Connect to socket
-	conn, err := net.Dial("unix", "/var/run/frr/mgmtd_fe.sock")
+	conn, err := net.Dial("unix", sockpath)
 	if err != nil {
 		return nil, errors.Wrap(err, "net.Dial")
 	}
+	c := &Client{
+		conn:    conn,
+		pending: map[uint64]*pendingReq{},
+		done:    make(chan struct{}),
+	}
+	go c.receiveLoop()
 
 	// Register FE-Client
-	if err := writeProtobufMsg(conn, &FeMessage{
+	if err := c.write(&FeMessage{
 		Message: &FeMessage_RegisterReq{
 			RegisterReq: &FeRegisterReq{
 				ClientName: &name,
 			},
 		},
 	}); err != nil {
-		return nil, errors.Wrap(err, "writeProtobufMsg(RegisterReq)")
+		conn.Close()
+		return nil, errors.Wrap(err, "write(RegisterReq)")
 	}
 
 	// Create FE-Session
-	if err := writeProtobufMsg(conn, &FeMessage{
+	ctx, cancel := context.WithTimeout(context.Background(), SessionTimeout)
+	defer cancel()
+	msg, err := c.request(ctx, sessionReqId, &FeMessage{
 		Message: &FeMessage_SessionReq{
 			SessionReq: &FeSessionReq{
 				Create: util.NewBoolPointer(true),
@@ -60,25 +103,42 @@ func NewClient(sockpath, name string) (*Client, error) {
 				},
 			},
 		},
-	}); err != nil {
-		return nil, errors.Wrap(err, "writeProtobufMsg(SessionReq)")
-	}
-	msgs, err := readProtobufMsg(conn)
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "readProtobufMsg(SessionReq)")
+		conn.Close()
+		return nil, errors.Wrap(err, "request(SessionReq)")
 	}
-	msg := msgs[0]
-	sessionId := msg.GetSessionReply().SessionId
-
-	// Return client data
-	return &Client{
-		conn:      conn,
-		sessionId: *sessionId,
-	}, nil
+	reply := msg.GetSessionReply()
+	if reply == nil || !reply.GetSuccess() {
+		conn.Close()
+		return nil, errors.Errorf("SessionReq(reply-error): session not created")
+	}
+	c.sessionId = reply.GetSessionId()
+	return c, nil
 }
 
+// Close destroys the session and closes the connection.
 func (c *Client) Close() error {
-	return c.conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), SessionTimeout)
+	defer cancel()
+	_, err := c.request(ctx, sessionReqId, &FeMessage{
+		Message: &FeMessage_SessionReq{
+			SessionReq: &FeSessionReq{
+				Create: util.NewBoolPointer(false),
+				Id: &FeSessionReq_SessionId{
+					SessionId: c.sessionId,
+				},
+			},
+		},
+	})
+	if cerr := c.conn.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	<-c.done
+	if err != nil {
+		return errors.Wrap(err, "request(SessionReq)")
+	}
+	return nil
 }
 
 func (c *Client) GetRaw() (net.Conn, uint64) {
@@ -89,142 +149,322 @@ func (c *Client) GetSessionId() *uint64 {
 	return &c.sessionId
 }
 
-func (c *Client) GetReq(req *FeGetReq) ([]*YangData, error) {
-	if err := writeProtobufMsg(c.conn, &FeMessage{
-		Message: &FeMessage_GetReq{GetReq: req}}); err != nil {
-		return nil, errors.Wrap(err, "writeProtobufMsg")
-	}
-	msgs, err := readProtobufMsg(c.conn)
-	if err != nil {
-		return nil, errors.Wrap(err, "readProtobufMsg")
+// Done is closed when the connection to mgmtd is lost or closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error which closed the connection, nil while connected.
+func (c *Client) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+// newReqId returns the next request id. It is monotonically increasing.
+func (c *Client) newReqId() uint64 {
+	return atomic.AddUint64(&c.nextReqId, 1)
+}
+
+func (c *Client) write(msg *FeMessage) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return WriteProtobufMsg(c.conn, msg)
+}
+
+// replyReqId returns the req_id of the reply message.
+func replyReqId(msg *FeMessage) (uint64, bool) {
+	switch m := msg.Message.(type) {
+	case *FeMessage_SessionReply:
+		return sessionReqId, true
+	case *FeMessage_LockdsReply:
+		return m.LockdsReply.GetReqId(), true
+	case *FeMessage_SetcfgReply:
+		return m.SetcfgReply.GetReqId(), true
+	case *FeMessage_CommcfgReply:
+		return m.CommcfgReply.GetReqId(), true
+	case *FeMessage_GetReply:
+		return m.GetReply.GetReqId(), true
+	default:
+		return 0, false
 	}
-	ret := []*YangData{}
-	for _, msg := range msgs {
-		ret = append(ret, msg.GetGetReply().Data.Data...)
+}
+
+// receiveLoop reads messages until the connection is closed. When it
+// exits, all pending requests fail with the error.
+func (c *Client) receiveLoop() {
+	defer close(c.done)
+	dec := NewDecoder(c.conn)
+	for {
+		msg, err := dec.Decode()
+		if err != nil {
+			c.lock.Lock()
+			c.err = err
+			c.lock.Unlock()
+			return
+		}
+		if notify := msg.GetNotifyDataReq(); notify != nil {
+			c.lock.Lock()
+			h := c.notifyHandler
+			c.lock.Unlock()
+			if h != nil {
+				h(notify.Data)
+			}
+			continue
+		}
+		reqId, ok := replyReqId(msg)
+		if !ok {
+			log.Printf("mgmtd: unexpected message %T ignored\n", msg.Message)
+			continue
+		}
+		c.lock.Lock()
+		p := c.pending[reqId]
+		c.lock.Unlock()
+		if p == nil {
+			log.Printf("mgmtd: reply for unknown req_id %d ignored\n", reqId)
+			continue
+		}
+		select {
+		case p.replies <- msg:
+		case <-p.cancel:
+		}
 	}
-	return ret, nil
 }
 
-func (c *Client) LockReq(req *FeLockDsReq) error {
-	if err := writeProtobufMsg(c.conn, &FeMessage{
-		Message: &FeMessage_LockdsReq{LockdsReq: req}}); err != nil {
-		return errors.Wrap(err, "writeProtobufMsg")
+// send registers the pending request of reqId and sends req. The returned
+// function must be called to unregister it.
+func (c *Client) send(reqId uint64, req *FeMessage) (*pendingReq, func(), error) {
+	p := &pendingReq{
+		replies: make(chan *FeMessage, 1),
+		cancel:  make(chan struct{}),
 	}
-	msgs, err := readProtobufMsg(c.conn)
-	if err != nil {
-		return errors.Wrap(err, "readProtobufMsg")
+	c.lock.Lock()
+	if _, ok := c.pending[reqId]; ok {
+		c.lock.Unlock()
+		return nil, nil, errors.Errorf("req_id %d is in use", reqId)
 	}
-	for _, msg := range msgs {
-		if errIfAny := msg.GetLockdsReply().ErrorIfAny; errIfAny != nil {
-			return errors.Errorf("LockReq(reply-error): %s", *errIfAny)
-		}
+	c.pending[reqId] = p
+	c.lock.Unlock()
+	done := func() {
+		c.lock.Lock()
+		delete(c.pending, reqId)
+		c.lock.Unlock()
+		close(p.cancel)
 	}
-	return nil
+	if err := c.write(req); err != nil {
+		done()
+		return nil, nil, errors.Wrap(err, "write")
+	}
+	return p, done, nil
 }
 
-func (c *Client) SetConfig(req *FeSetConfigReq) error {
-	if err := writeProtobufMsg(c.conn, &FeMessage{
-		Message: &FeMessage_SetcfgReq{SetcfgReq: req}}); err != nil {
-		return errors.Wrap(err, "writeProtobufMsg")
+func (c *Client) wait(ctx context.Context, p *pendingReq) (*FeMessage, error) {
+	select {
+	case msg := <-p.replies:
+		return msg, nil
+	case <-c.done:
+		c.lock.Lock()
+		err := c.err
+		c.lock.Unlock()
+		return nil, errors.Wrap(err, "connection closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	msgs, err := readProtobufMsg(c.conn)
+}
+
+// request sends req and waits for its reply.
+func (c *Client) request(ctx context.Context, reqId uint64,
+	req *FeMessage) (*FeMessage, error) {
+	p, done, err := c.send(reqId, req)
 	if err != nil {
-		return errors.Wrap(err, "readProtobufMsg")
-	}
-	for _, msg := range msgs {
-		if errIfAny := msg.GetSetcfgReply().ErrorIfAny; errIfAny != nil {
-			return errors.Errorf("SetConfig(reply-error): %s", *errIfAny)
-		}
+		return nil, err
 	}
-	return nil
+	defer done()
+	return c.wait(ctx, p)
 }
 
-func (c *Client) CommitConfig(req *FeCommitConfigReq) error {
-	if err := writeProtobufMsg(c.conn, &FeMessage{
-		Message: &FeMessage_CommcfgReq{CommcfgReq: req}}); err != nil {
-		return errors.Wrap(err, "writeProtobufMsg")
-	}
-	msgs, err := readProtobufMsg(c.conn)
+// GetReq sends the get request and returns the data of all reply batches.
+// mgmtd sets next_indx of the last batch to -1. The req_id of req is
+// overwritten by the client.
+func (c *Client) GetReq(ctx context.Context, req *FeGetReq) ([]*YangData, error) {
+	reqId := c.newReqId()
+	req.ReqId = util.NewUint64Pointer(reqId)
+	p, done, err := c.send(reqId, &FeMessage{
+		Message: &FeMessage_GetReq{GetReq: req}})
 	if err != nil {
-		return errors.Wrap(err, "readProtobufMsg")
+		return nil, err
 	}
-	for _, msg := range msgs {
-		if errIfAny := msg.GetCommcfgReply().ErrorIfAny; errIfAny != nil {
-			return errors.Errorf("CommitConfig(reply-error): %s", *errIfAny)
+	defer done()
+	ret := []*YangData{}
+	for {
+		msg, err := c.wait(ctx, p)
+		if err != nil {
+			return nil, errors.Wrap(err, "wait")
+		}
+		reply := msg.GetGetReply()
+		if reply == nil {
+			return nil, errors.Errorf("GetReq(reply-error): unexpected reply")
+		}
+		if errIfAny := reply.GetErrorIfAny(); errIfAny != "" {
+			return nil, errors.Errorf("GetReq(reply-error): %s", errIfAny)
+		}
+		ret = append(ret, reply.GetData().GetData()...)
+		if reply.GetData().GetNextIndx() < 0 {
+			return ret, nil
 		}
 	}
-	return nil
 }
 
-func writeProtobufMsg(conn net.Conn, msg *FeMessage) error {
-	data, err := proto.Marshal(msg)
+// GetData gets the data under xpath from the datastore. Config must be
+// false for the operational datastore.
+func (c *Client) GetData(ctx context.Context, dsId DatastoreId, config bool,
+	xpath string) ([]*YangData, error) {
+	return c.GetReq(ctx, &FeGetReq{
+		SessionId: c.GetSessionId(),
+		Config:    util.NewBoolPointer(config),
+		DsId:      dsId.Enum(),
+		Data: []*YangGetDataReq{
+			{
+				Data: &YangData{
+					Xpath: util.NewStringPointer(xpath),
+				},
+				NextIndx: util.NewInt64Pointer(0),
+			},
+		},
+	})
+}
+
+// GetOperData gets the config=false data under xpath.
+func (c *Client) GetOperData(ctx context.Context,
+	xpath string) ([]*YangData, error) {
+	return c.GetData(ctx, DatastoreId_OPERATIONAL_DS, false, xpath)
+}
+
+// LockReq locks or unlocks the datastore. The req_id of req is overwritten
+// by the client.
+func (c *Client) LockReq(ctx context.Context, req *FeLockDsReq) error {
+	reqId := c.newReqId()
+	req.ReqId = util.NewUint64Pointer(reqId)
+	msg, err := c.request(ctx, reqId, &FeMessage{
+		Message: &FeMessage_LockdsReq{LockdsReq: req}})
 	if err != nil {
-		return errors.Wrap(err, "proto.Marshal")
+		return errors.Wrap(err, "request")
+	}
+	reply := msg.GetLockdsReply()
+	if reply == nil {
+		return errors.Errorf("LockReq(reply-error): unexpected reply")
 	}
-	buf := new(bytes.Buffer)
-	if err := binary.Write(buf, binary.LittleEndian,
-		MGMT_MSG_MARKER_PROTOBUF); err != nil {
-		return errors.Wrap(err, "binary.Write")
+	if errIfAny := reply.GetErrorIfAny(); errIfAny != "" {
+		return errors.Errorf("LockReq(reply-error): %s", errIfAny)
 	}
-	if err := binary.Write(buf, binary.LittleEndian,
-		uint32(8+len(data))); err != nil {
-		return errors.Wrap(err, "binary.Write")
+	if !reply.GetSuccess() {
+		return errors.Errorf("LockReq(reply-error): failed")
+	}
+	return nil
+}
+
+// SetConfig edits the datastore. The req_id of req is overwritten by the
+// client.
+func (c *Client) SetConfig(ctx context.Context, req *FeSetConfigReq) error {
+	reqId := c.newReqId()
+	req.ReqId = util.NewUint64Pointer(reqId)
+	msg, err := c.request(ctx, reqId, &FeMessage{
+		Message: &FeMessage_SetcfgReq{SetcfgReq: req}})
+	if err != nil {
+		return errors.Wrap(err, "request")
 	}
-	if _, err := buf.Write([]byte(data)); err != nil {
-		return errors.Wrap(err, "buf.Write")
+	reply := msg.GetSetcfgReply()
+	if reply == nil {
+		return errors.Errorf("SetConfig(reply-error): unexpected reply")
 	}
-	if _, err := conn.Write(buf.Bytes()); err != nil {
-		return errors.Wrap(err, "conn.Write")
+	if errIfAny := reply.GetErrorIfAny(); errIfAny != "" {
+		return errors.Errorf("SetConfig(reply-error): %s", errIfAny)
 	}
-	if globalVerboseEnabled {
-		fmt.Println(hex.Dump(buf.Bytes()))
+	if !reply.GetSuccess() {
+		return errors.Errorf("SetConfig(reply-error): failed")
 	}
 	return nil
 }
 
-func readProtobufMsg(conn net.Conn) ([]*FeMessage, error) {
-	const size = 40960
-	buf := make([]byte, size)
-	n, err := conn.Read(buf)
+// CommitConfig commits the datastore. The req_id of req is overwritten by
+// the client.
+func (c *Client) CommitConfig(ctx context.Context, req *FeCommitConfigReq) error {
+	reqId := c.newReqId()
+	req.ReqId = util.NewUint64Pointer(reqId)
+	msg, err := c.request(ctx, reqId, &FeMessage{
+		Message: &FeMessage_CommcfgReq{CommcfgReq: req}})
 	if err != nil {
-		return nil, errors.Wrap(err, "conn.Read")
+		return errors.Wrap(err, "request")
 	}
-	if n >= size {
-		return nil, errors.Errorf("msg too big (>%d)", size)
+	reply := msg.GetCommcfgReply()
+	if reply == nil {
+		return errors.Errorf("CommitConfig(reply-error): unexpected reply")
 	}
-	buff := bytes.NewBuffer(buf)
-	msgs := []*FeMessage{}
+	if errIfAny := reply.GetErrorIfAny(); errIfAny != "" {
+		return errors.Errorf("CommitConfig(reply-error): %s", errIfAny)
+	}
+	if !reply.GetSuccess() {
+		return errors.Errorf("CommitConfig(reply-error): failed")
+	}
+	return nil
+}
 
-	remain := n
-	for remain > 0 {
-		// Parse Marker
-		marker := uint32(0)
-		if err := binary.Read(buff, binary.LittleEndian, &marker); err != nil {
-			return nil, errors.Wrap(err, "binary.Read(marker)")
-		}
-		if marker != MGMT_MSG_MARKER_PROTOBUF {
-			return nil, errors.Errorf("not PROTOBUF marker")
-		}
+func (c *Client) commit(ctx context.Context, validateOnly, abort bool) error {
+	return c.CommitConfig(ctx, &FeCommitConfigReq{
+		SessionId:    c.GetSessionId(),
+		SrcDsId:      DatastoreId_CANDIDATE_DS.Enum(),
+		DstDsId:      DatastoreId_RUNNING_DS.Enum(),
+		ValidateOnly: util.NewBoolPointer(validateOnly),
+		Abort:        util.NewBoolPointer(abort),
+	})
+}
 
-		// Parse Size
-		msize := uint32(0)
-		if err := binary.Read(buff, binary.LittleEndian, &msize); err != nil {
-			return nil, errors.Wrap(err, "binary.Read(size)")
-		}
-		msize0 := msize - 8
-		remain -= int(msize)
-
-		// Parse Body
-		body := make([]byte, msize0)
-		if err := binary.Read(buff, binary.LittleEndian, &body); err != nil {
-			pp.Println(buf)
-			return nil, errors.Wrap(err, "binary.Read(body)")
-		}
-		msg := &FeMessage{}
-		if err := proto.Unmarshal(body, msg); err != nil {
-			return nil, errors.Wrap(err, "proto.Unmarshal")
-		}
-		msgs = append(msgs, msg)
+// ValidateConfig validates the candidate datastore without applying it.
+func (c *Client) ValidateConfig(ctx context.Context) error {
+	return c.commit(ctx, true, false)
+}
+
+// AbortConfig discards the changes of the candidate datastore, it is
+// restored from the running datastore.
+func (c *Client) AbortConfig(ctx context.Context) error {
+	return c.commit(ctx, false, true)
+}
+
+// RegisterNotify registers the client to receive notifications under
+// xpaths. h is called from the receive loop, so it must not wait for
+// replies from mgmtd. mgmtd doesn't reply to FeRegisterNotifyReq.
+func (c *Client) RegisterNotify(xpaths []string, h NotifyHandler) error {
+	c.lock.Lock()
+	c.notifyHandler = h
+	c.lock.Unlock()
+	return c.registerNotify(xpaths, true)
+}
+
+func (c *Client) UnregisterNotify(xpaths []string) error {
+	if err := c.registerNotify(xpaths, false); err != nil {
+		return err
 	}
-	return msgs, nil
+	c.lock.Lock()
+	c.notifyHandler = nil
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *Client) registerNotify(xpaths []string, register bool) error {
+	req := &FeRegisterNotifyReq{
+		SessionId:   c.GetSessionId(),
+		DsId:        DatastoreId_OPERATIONAL_DS.Enum(),
+		RegisterReq: util.NewBoolPointer(register),
+		ReqId:       util.NewUint64Pointer(c.newReqId()),
+	}
+	for _, xpath := range xpaths {
+		req.DataXpath = append(req.DataXpath, &YangDataXPath{
+			Xpath: util.NewStringPointer(xpath),
+		})
+	}
+	if err := c.write(&FeMessage{
+		Message: &FeMessage_RegnotifyReq{RegnotifyReq: req}}); err != nil {
+		return errors.Wrap(err, "write")
+	}
+	return nil
 }
@@ -0,0 +1,401 @@
+package mgmtd_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+	"github.com/slankdev/vtyang/pkg/mgmtd/fake"
+	"github.com/slankdev/vtyang/pkg/util"
+)
+
+func newTestClient(t *testing.T) (*fake.Server, *mgmtd.Client) {
+	sockpath := filepath.Join(t.TempDir(), "mgmtd_fe.sock")
+	s, err := fake.NewServer(sockpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	c, err := mgmtd.NewClient(sockpath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+func TestClientSession(t *testing.T) {
+	s, c := newTestClient(t)
+	if got := s.Sessions(); !reflect.DeepEqual(got, []uint64{*c.GetSessionId()}) {
+		t.Fatalf("sessions %v", got)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Sessions(); len(got) != 0 {
+		t.Fatalf("session is not destroyed %v", got)
+	}
+}
+
+func TestClientGetOperData(t *testing.T) {
+	s, c := newTestClient(t)
+	defer c.Close()
+	s.BatchSize = 2
+	s.SetData(mgmtd.DatastoreId_OPERATIONAL_DS, "/a/b/c1", "1")
+	s.SetData(mgmtd.DatastoreId_OPERATIONAL_DS, "/a/b/c2", "2")
+	s.SetData(mgmtd.DatastoreId_OPERATIONAL_DS, "/a/b/c3", "3")
+	s.SetData(mgmtd.DatastoreId_OPERATIONAL_DS, "/x/y", "4")
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS, "/a/b/c4", "5")
+
+	datas, err := c.GetOperData(context.Background(), "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, d := range datas {
+		got[d.GetXpath()] = d.GetValue().GetEncodedStrVal()
+	}
+	expected := map[string]string{"/a/b/c1": "1", "/a/b/c2": "2", "/a/b/c3": "3"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected data %v", got)
+	}
+}
+
+func TestClientCommit(t *testing.T) {
+	s, c := newTestClient(t)
+	defer c.Close()
+	set := func(xpath, value string) {
+		if err := c.SetConfig(context.Background(), &mgmtd.FeSetConfigReq{
+			SessionId:      c.GetSessionId(),
+			DsId:           mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+			CommitDsId:     mgmtd.DatastoreId_RUNNING_DS.Enum(),
+			ReqId:          util.NewUint64Pointer(0),
+			ImplicitCommit: util.NewBoolPointer(false),
+			Data: []*mgmtd.YangCfgDataReq{
+				{
+					ReqType: mgmtd.CfgDataReqType_SET_DATA.Enum(),
+					Data: &mgmtd.YangData{
+						Xpath: util.NewStringPointer(xpath),
+						Value: &mgmtd.YangDataValue{
+							Value: &mgmtd.YangDataValue_EncodedStrVal{
+								EncodedStrVal: value,
+							},
+						},
+					},
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set("/a/b", "1")
+	if err := c.ValidateConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Data(mgmtd.DatastoreId_RUNNING_DS); len(got) != 0 {
+		t.Fatalf("validate-only commit applied %v", got)
+	}
+	if err := c.AbortConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Data(mgmtd.DatastoreId_CANDIDATE_DS); len(got) != 0 {
+		t.Fatalf("candidate is not aborted %v", got)
+	}
+
+	set("/a/c", "2")
+	if err := c.CommitConfig(context.Background(), &mgmtd.FeCommitConfigReq{
+		SessionId:    c.GetSessionId(),
+		ReqId:        util.NewUint64Pointer(0),
+		SrcDsId:      mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+		DstDsId:      mgmtd.DatastoreId_RUNNING_DS.Enum(),
+		ValidateOnly: util.NewBoolPointer(false),
+		Abort:        util.NewBoolPointer(false),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got := s.Data(mgmtd.DatastoreId_RUNNING_DS)
+	if !reflect.DeepEqual(got, map[string]string{"/a/c": "2"}) {
+		t.Fatalf("unexpected running %v", got)
+	}
+	commits := s.Commits()
+	if len(commits) != 3 || !commits[0].GetValidateOnly() ||
+		!commits[1].GetAbort() {
+		t.Fatalf("unexpected commits %v", commits)
+	}
+}
+
+func TestClientNotify(t *testing.T) {
+	s, c := newTestClient(t)
+	defer c.Close()
+	ch := make(chan []*mgmtd.YangData, 1)
+	if err := c.RegisterNotify([]string{"/frr-test:event"},
+		func(datas []*mgmtd.YangData) {
+			ch <- datas
+		}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The registration is not replied, so wait for it with a get request
+	if _, err := c.GetOperData(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Notify([]*mgmtd.YangData{
+		{Xpath: util.NewStringPointer("/frr-test:other/value")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Notify([]*mgmtd.YangData{
+		{
+			Xpath: util.NewStringPointer("/frr-test:event/value"),
+			Value: &mgmtd.YangDataValue{
+				Value: &mgmtd.YangDataValue_EncodedStrVal{EncodedStrVal: "10"},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case datas := <-ch:
+		if len(datas) != 1 || datas[0].GetXpath() != "/frr-test:event/value" ||
+			datas[0].GetValue().GetEncodedStrVal() != "10" {
+			t.Fatalf("unexpected notification %v", datas)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("notification is not received")
+	}
+
+	// Requests keep working while notifications are received
+	if _, err := c.GetOperData(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestRawServer starts a server which replies to FeSessionReq and
+// passes FeGetReq to h, so that tests can control the order and
+// timing of the replies.
+func newTestRawServer(t *testing.T,
+	h func(w func(*mgmtd.FeMessage), req *mgmtd.FeGetReq)) string {
+	return newTestRawServerMsg(t, func(w func(*mgmtd.FeMessage),
+		msg *mgmtd.FeMessage) {
+		if req := msg.GetGetReq(); req != nil {
+			h(w, req)
+		}
+	})
+}
+
+// newTestRawServerMsg is same as newTestRawServer but passes all the
+// requests other than FeSessionReq to h.
+func newTestRawServerMsg(t *testing.T,
+	h func(w func(*mgmtd.FeMessage), msg *mgmtd.FeMessage)) string {
+	sockpath := filepath.Join(t.TempDir(), "mgmtd_fe.sock")
+	lis, err := net.Listen("unix", sockpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		writeLock := sync.Mutex{}
+		w := func(msg *mgmtd.FeMessage) {
+			writeLock.Lock()
+			defer writeLock.Unlock()
+			if err := mgmtd.WriteProtobufMsg(conn, msg); err != nil {
+				t.Error(err)
+			}
+		}
+		dec := mgmtd.NewDecoder(conn)
+		for {
+			msg, err := dec.Decode()
+			if err != nil {
+				return
+			}
+			if req := msg.GetSessionReq(); req != nil {
+				w(&mgmtd.FeMessage{
+					Message: &mgmtd.FeMessage_SessionReply{
+						SessionReply: &mgmtd.FeSessionReply{
+							Create:    req.Create,
+							Success:   util.NewBoolPointer(true),
+							SessionId: util.NewUint64Pointer(1),
+						},
+					},
+				})
+				continue
+			}
+			h(w, msg)
+		}
+	}()
+	return sockpath
+}
+
+func newTestGetReply(req *mgmtd.FeGetReq) *mgmtd.FeMessage {
+	return &mgmtd.FeMessage{
+		Message: &mgmtd.FeMessage_GetReply{
+			GetReply: &mgmtd.FeGetReply{
+				SessionId: req.SessionId,
+				Config:    req.Config,
+				DsId:      req.DsId,
+				ReqId:     req.ReqId,
+				Success:   util.NewBoolPointer(true),
+				Data: &mgmtd.YangDataReply{
+					Data:     []*mgmtd.YangData{req.Data[0].Data},
+					NextIndx: util.NewInt64Pointer(-1),
+				},
+			},
+		},
+	}
+}
+
+func TestClientConcurrentRequests(t *testing.T) {
+	const n = 8
+	reqs := []*mgmtd.FeGetReq{}
+	sockpath := newTestRawServer(t, func(w func(*mgmtd.FeMessage),
+		req *mgmtd.FeGetReq) {
+		// Reply in the reverse order after all requests are received
+		reqs = append(reqs, req)
+		if len(reqs) < n {
+			return
+		}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			w(newTestGetReply(reqs[i]))
+		}
+	})
+	c, err := mgmtd.NewClient(sockpath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(xpath string) {
+			defer wg.Done()
+			datas, err := c.GetOperData(context.Background(), xpath)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(datas) != 1 || datas[0].GetXpath() != xpath {
+				t.Errorf("%s: unexpected reply %v", xpath, datas)
+			}
+		}(fmt.Sprintf("/test/item%d", i))
+	}
+	wg.Wait()
+
+	reqIds := map[uint64]bool{}
+	for _, req := range reqs {
+		if req.GetReqId() == 0 || reqIds[req.GetReqId()] {
+			t.Fatalf("req_id %d is not unique", req.GetReqId())
+		}
+		reqIds[req.GetReqId()] = true
+	}
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	var dropped *mgmtd.FeGetReq
+	sockpath := newTestRawServer(t, func(w func(*mgmtd.FeMessage),
+		req *mgmtd.FeGetReq) {
+		if req.Data[0].Data.GetXpath() == "/drop" {
+			dropped = req
+			return
+		}
+		// The late reply of the timed out request must be ignored
+		w(newTestGetReply(dropped))
+		w(newTestGetReply(req))
+	})
+	c, err := mgmtd.NewClient(sockpath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetOperData(ctx, "/drop"); !errors.Is(err,
+		context.DeadlineExceeded) {
+		t.Fatalf("expected timeout but %v", err)
+	}
+	datas, err := c.GetOperData(context.Background(), "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(datas) != 1 || datas[0].GetXpath() != "/test" {
+		t.Fatalf("unexpected reply %v", datas)
+	}
+}
+
+// The reply of the unexpected type or without success is the error
+func TestClientUnexpectedReply(t *testing.T) {
+	sockpath := newTestRawServerMsg(t, func(w func(*mgmtd.FeMessage),
+		msg *mgmtd.FeMessage) {
+		switch {
+		case msg.GetLockdsReq() != nil:
+			req := msg.GetLockdsReq()
+			w(&mgmtd.FeMessage{
+				Message: &mgmtd.FeMessage_LockdsReply{
+					LockdsReply: &mgmtd.FeLockDsReply{
+						SessionId: req.SessionId,
+						ReqId:     req.ReqId,
+						DsId:      req.DsId,
+						Lock:      req.Lock,
+						Success:   util.NewBoolPointer(false),
+					},
+				},
+			})
+		case msg.GetSetcfgReq() != nil:
+			// The reply of the other type with the same req_id
+			req := msg.GetSetcfgReq()
+			w(&mgmtd.FeMessage{
+				Message: &mgmtd.FeMessage_CommcfgReply{
+					CommcfgReply: &mgmtd.FeCommitConfigReply{
+						SessionId:    req.SessionId,
+						SrcDsId:      req.DsId,
+						DstDsId:      req.CommitDsId,
+						ReqId:        req.ReqId,
+						ValidateOnly: util.NewBoolPointer(false),
+						Abort:        util.NewBoolPointer(false),
+						Success:      util.NewBoolPointer(true),
+					},
+				},
+			})
+		}
+	})
+	c, err := mgmtd.NewClient(sockpath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = c.LockReq(ctx, &mgmtd.FeLockDsReq{
+		SessionId: c.GetSessionId(),
+		DsId:      mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+		Lock:      util.NewBoolPointer(true),
+	})
+	if err == nil || err.Error() != "LockReq(reply-error): failed" {
+		t.Errorf("unexpected error %v", err)
+	}
+	err = c.SetConfig(ctx, &mgmtd.FeSetConfigReq{
+		SessionId:      c.GetSessionId(),
+		DsId:           mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+		ImplicitCommit: util.NewBoolPointer(false),
+		CommitDsId:     mgmtd.DatastoreId_RUNNING_DS.Enum(),
+	})
+	if err == nil || err.Error() != "SetConfig(reply-error): unexpected reply" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -1,15 +1,47 @@
 package vtyang
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
-	"github.com/slankdev/vtyang/pkg/mgmtd"
 )
 
 type AgentOptsBackendMgmtd struct {
 	UnixSockPath string
+	// SyncFromBackend imports the running config of mgmtd on startup
+	SyncFromBackend bool
+}
+
+type AgentOptsGrpc struct {
+	Address string
+	// TokenFile is the file of the token the agents send as
+	// "authorization: Bearer <token>" metadata. It's required unless
+	// Address is loopback.
+	TokenFile string
+}
+
+type AgentOptsSSH struct {
+	Address string
+	// HostKeyFile is generated when it doesn't exist
+	HostKeyFile string
+	// UsersFile is the json of the users allowed to login
+	UsersFile string
+}
+
+type AgentOptsConfigChange struct {
+	UnixSockPath string
+	WebhookURL   string
+}
+
+type AgentOptsDriftCheck struct {
+	Interval time.Duration
+	// Action resolves the detected drift, "adopt" or "repush". The drift
+	// is only logged when empty.
+	Action string
 }
 
 type AgentOpts struct {
@@ -18,6 +50,24 @@ type AgentOpts struct {
 	LogFile     string
 	// BackendMgmtd
 	BackendMgmtd *AgentOptsBackendMgmtd
+	// Grpc
+	Grpc *AgentOptsGrpc
+	// ConfigChange
+	ConfigChange *AgentOptsConfigChange
+	// AuditLogFile is the path of audit log, disabled when empty
+	AuditLogFile string
+	// AuditKeyFile holds the key of the audit log hash chain. It should be
+	// kept apart from the log, the default <AuditLogFile>.key is warned.
+	AuditKeyFile string
+	// RPCScripts maps rpc or action paths to the scripts handling them
+	RPCScripts map[string]string
+	// DriftCheck enables the periodic drift check
+	DriftCheck *AgentOptsDriftCheck
+	// SessionSockPath is the unix socket the clients attach to, disabled
+	// when empty
+	SessionSockPath string
+	// SSH serves the shell over ssh, disabled when nil
+	SSH *AgentOptsSSH
 }
 
 func InitAgent(opts AgentOpts) error {
@@ -36,13 +86,10 @@ func InitAgent(opts AgentOpts) error {
 		log.Printf("starting vtyang...\n")
 	}
 
+	closeMgmtd()
 	if opts.BackendMgmtd != nil {
-		var err error
-		mgmtdClient, err = mgmtd.NewClient(
-			opts.BackendMgmtd.UnixSockPath,
-			"vtyang")
-		if err != nil {
-			return errors.Wrap(err, "mgmtd.NewClient")
+		if err := connectMgmtd(opts.BackendMgmtd); err != nil {
+			return err
 		}
 	}
 
@@ -64,7 +111,70 @@ func InitAgent(opts AgentOpts) error {
 		return err
 	}
 
+	resetOperStateProviders()
+	resetDriftSources()
+	if opts.BackendMgmtd != nil {
+		RegisterOperStateProvider("mgmtd", []string{"/"}, mgmtdOperStateProvider{})
+		if _, err := RegisterDriftSource("mgmtd", mgmtdOwnedPaths(),
+			mgmtdDriftSource{}); err != nil {
+			return errors.Wrap(err, "RegisterDriftSource")
+		}
+	}
+	closeAuditLog()
+	if opts.AuditLogFile != "" {
+		keyFile := opts.AuditKeyFile
+		if keyFile == "" {
+			keyFile = opts.AuditLogFile + ".key"
+		}
+		// NOTE(slankdev): the key next to the log is taken together with
+		// it, then the records can be rewritten with the valid hashes.
+		keyAbs, _ := filepath.Abs(keyFile)
+		logAbs, _ := filepath.Abs(opts.AuditLogFile)
+		if filepath.Dir(keyAbs) == filepath.Dir(logAbs) {
+			msg := fmt.Sprintf("Warning: audit key %s is in the directory of "+
+				"the log, keep it apart with --audit-key", keyFile)
+			log.Println(msg)
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		auditLog, err = openAuditLog(opts.AuditLogFile, keyFile,
+			currentUsername(), fmt.Sprintf("cli-%d", os.Getpid()))
+		if err != nil {
+			return errors.Wrap(err, "openAuditLog")
+		}
+	}
+
+	resetRPCHandlers()
+	resetNotifications()
+	if opts.BackendMgmtd != nil {
+		if err := mgmtdRegisterNotify(); err != nil {
+			return errors.Wrap(err, "mgmtd.RegisterNotify")
+		}
+	}
+	for path, script := range opts.RPCScripts {
+		if _, err := RegisterRPCHandler(path,
+			rpcScriptHandler{path: script}); err != nil {
+			return errors.Wrap(err, "RegisterRPCHandler")
+		}
+	}
+	resetConfigChange()
+	if opts.ConfigChange != nil {
+		configChangeWebhook = opts.ConfigChange.WebhookURL
+		if opts.ConfigChange.UnixSockPath != "" {
+			if err := startConfigChangeServer(
+				opts.ConfigChange.UnixSockPath); err != nil {
+				return errors.Wrap(err, "startConfigChangeServer")
+			}
+		}
+	}
+	if opts.Grpc != nil {
+		if err := startGrpcServer(opts.Grpc); err != nil {
+			return errors.Wrap(err, "startGrpcServer")
+		}
+	}
+
 	cliMode = CliModeView
+	resetEditContext()
+	resetSessions()
 	commandnodes = nil
 	installCommandsDefault(CliModeView)
 	installCommandsDefault(CliModeConfigure)
@@ -77,5 +187,24 @@ func InitAgent(opts AgentOpts) error {
 			return err
 		}
 	}
+	if opts.BackendMgmtd != nil && opts.BackendMgmtd.SyncFromBackend {
+		if err := syncFromMgmtd(); err != nil {
+			return errors.Wrap(err, "syncFromMgmtd")
+		}
+	}
+	stopDriftCheck()
+	if opts.DriftCheck != nil {
+		startDriftCheck(opts.DriftCheck.Interval, opts.DriftCheck.Action)
+	}
+	if opts.SessionSockPath != "" {
+		if err := startSessionServer(opts.SessionSockPath); err != nil {
+			return errors.Wrap(err, "startSessionServer")
+		}
+	}
+	if opts.SSH != nil {
+		if err := startSSHServer(opts.SSH); err != nil {
+			return errors.Wrap(err, "startSSHServer")
+		}
+	}
 	return nil
 }
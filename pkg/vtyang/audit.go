@@ -0,0 +1,599 @@
+package vtyang
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	AuditTypeCommand  = "command"
+	AuditTypeEdit     = "edit"
+	AuditTypeCommit   = "commit"
+	AuditTypeRollback = "rollback"
+)
+
+// AuditRecord is one line of the audit log. Records are chained by the
+// HMAC-SHA256 of the previous record with the key kept out of the log, so
+// that modification or deletion of a record can be detected with "show log
+// audit verify".
+type AuditRecord struct {
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Session  string    `json:"session"`
+	Type     string    `json:"type"`
+	Command  string    `json:"command,omitempty"`
+	XPath    string    `json:"xpath,omitempty"`
+	Old      *string   `json:"old,omitempty"`
+	New      *string   `json:"new,omitempty"`
+	CommitId string    `json:"commit-id,omitempty"`
+	PrevHash string    `json:"prev-hash"`
+	Hash     string    `json:"hash"`
+}
+
+func (r AuditRecord) computeHash(key []byte) string {
+	r.Hash = ""
+	b, _ := json.Marshal(r)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(r.PrevHash))
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (r AuditRecord) String() string {
+	s := fmt.Sprintf("%s %d %s %s %s", r.Time.Format(time.RFC3339), r.Seq,
+		r.User, r.Session, r.Type)
+	switch r.Type {
+	case AuditTypeCommand:
+		s += fmt.Sprintf(" %q", r.Command)
+	case AuditTypeEdit:
+		old, new := "-", "-"
+		if r.Old != nil {
+			old = strconv.Quote(*r.Old)
+		}
+		if r.New != nil {
+			new = strconv.Quote(*r.New)
+		}
+		s += fmt.Sprintf(" %s %s -> %s", r.XPath, old, new)
+	case AuditTypeCommit, AuditTypeRollback:
+		s += fmt.Sprintf(" %s", r.CommitId)
+	}
+	return s
+}
+
+// auditChainEnd is the first or the last record of the chain.
+type auditChainEnd struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// auditKey is the content of the key file. The head and the tail of the
+// chain are anchored in it, so that the records removed from the both
+// ends of the log are detected too.
+type auditKey struct {
+	Key  string        `json:"key"`
+	Head auditChainEnd `json:"head"`
+	Tail auditChainEnd `json:"tail"`
+}
+
+type auditLogger struct {
+	mu      sync.Mutex
+	path    string
+	keyPath string
+	key     []byte
+	head    auditChainEnd
+	tail    auditChainEnd
+	// anchored is the seq of the tail written to the key file last
+	anchored uint64
+	file     *os.File
+	size     int64
+	user     string
+	session  string
+}
+
+var (
+	auditLog      *auditLogger
+	auditNow      = time.Now
+	auditMaxSize  = int64(10 * 1024 * 1024)
+	auditMaxFiles = 5
+	// auditAnchorInterval is the number of the records between the updates
+	// of the tail in the key file. The records after the anchored tail are
+	// found from the chain on open, only their removal after a crash isn't
+	// detected.
+	auditAnchorInterval = uint64(64)
+)
+
+// openAuditLog opens the audit log at path. The sequence number and the
+// hash chain continue from the tail anchored in the key file, which is
+// generated with the new log.
+func openAuditLog(path, keyPath, user, session string) (*auditLogger, error) {
+	l := &auditLogger{path: path, keyPath: keyPath, user: user,
+		session: session}
+	b, err := os.ReadFile(keyPath)
+	switch {
+	case os.IsNotExist(err):
+		if _, err := os.Stat(path); err == nil {
+			return nil, errors.Errorf("audit log %s exists without the key %s",
+				path, keyPath)
+		}
+		l.key = make([]byte, 32)
+		if _, err := rand.Read(l.key); err != nil {
+			return nil, errors.Wrap(err, "rand.Read")
+		}
+		if err := l.writeKey(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		k := auditKey{}
+		if err := json.Unmarshal(b, &k); err != nil {
+			return nil, errors.Wrap(err, keyPath)
+		}
+		if l.key, err = hex.DecodeString(k.Key); err != nil {
+			return nil, errors.Wrap(err, keyPath)
+		}
+		l.head = k.Head
+		l.tail = k.Tail
+		l.anchored = k.Tail.Seq
+		if err := l.followTail(); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// followTail moves the tail to the last record chained from the anchored
+// one. The tail is kept when the anchored record is missing, so that the
+// removal is reported by verify.
+func (l *auditLogger) followTail() error {
+	if l.tail.Seq == 0 {
+		return nil
+	}
+	records := []AuditRecord{}
+	for _, fn := range l.files() {
+		rs, err := readAuditLogFile(fn)
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+		records = append(records, rs...)
+	}
+	idx := 0
+	for idx < len(records) && (records[idx].Seq != l.tail.Seq ||
+		records[idx].Hash != l.tail.Hash) {
+		idx++
+	}
+	if idx == len(records) {
+		return nil
+	}
+	for _, r := range records[idx+1:] {
+		if r.Seq != l.tail.Seq+1 || r.PrevHash != l.tail.Hash ||
+			r.Hash != r.computeHash(l.key) {
+			break
+		}
+		l.tail = auditChainEnd{Seq: r.Seq, Hash: r.Hash}
+	}
+	return nil
+}
+
+// writeKey writes the key file with the current head and tail, it's
+// replaced by rename not to be broken by the crash while writing.
+func (l *auditLogger) writeKey() error {
+	l.anchored = l.tail.Seq
+	b, err := json.Marshal(auditKey{
+		Key:  hex.EncodeToString(l.key),
+		Head: l.head,
+		Tail: l.tail,
+	})
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	tmp := l.keyPath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.keyPath)
+}
+
+func (l *auditLogger) open() error {
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "os.OpenFile(%s)", l.path)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "Stat")
+	}
+	l.file = f
+	l.size = st.Size()
+	return nil
+}
+
+func (l *auditLogger) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	if l.anchored != l.tail.Seq {
+		if err := l.writeKey(); err != nil {
+			log.Printf("audit: %s\n", err)
+		}
+	}
+}
+
+// closeAuditLog closes the audit log, the tail is written to the key
+// file.
+func closeAuditLog() {
+	if auditLog != nil {
+		auditLog.close()
+		auditLog = nil
+	}
+}
+
+// setSession sets the user and the session of the following records.
+func (l *auditLogger) setSession(user, session string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.user = user
+	l.session = session
+}
+
+// rotate renames audit.log to audit.log.1, audit.log.1 to audit.log.2 and
+// so on, and removes the files exceeding auditMaxFiles.
+func (l *auditLogger) rotate() error {
+	l.file.Close()
+	l.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", l.path, auditMaxFiles))
+	for i := auditMaxFiles - 1; i >= 1; i-- {
+		src := fmt.Sprintf("%s.%d", l.path, i)
+		if _, err := os.Stat(src); err == nil {
+			if err := os.Rename(src, fmt.Sprintf("%s.%d", l.path, i+1)); err != nil {
+				return errors.Wrap(err, "os.Rename")
+			}
+		}
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return errors.Wrap(err, "os.Rename")
+	}
+	// The head moves to the first record of the oldest file left
+	records, err := readAuditLogFile(l.files()[0])
+	if err != nil {
+		return err
+	}
+	if len(records) > 0 {
+		l.head = auditChainEnd{Seq: records[0].Seq, Hash: records[0].Hash}
+	}
+	return l.open()
+}
+
+func (l *auditLogger) write(r AuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return errors.Errorf("audit log is closed")
+	}
+	r.Seq = l.tail.Seq + 1
+	r.Time = auditNow()
+	r.User = l.user
+	r.Session = l.session
+	r.PrevHash = l.tail.Hash
+	r.Hash = r.computeHash(l.key)
+	b, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	b = append(b, '\n')
+	// The key is written when the head moves, and every
+	// auditAnchorInterval records for the tail
+	anchor := l.head.Seq == 0
+	if l.size > 0 && l.size+int64(len(b)) > auditMaxSize {
+		if err := l.rotate(); err != nil {
+			return errors.Wrap(err, "rotate")
+		}
+		anchor = true
+	}
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		return errors.Wrap(err, "Write")
+	}
+	l.tail = auditChainEnd{Seq: r.Seq, Hash: r.Hash}
+	if l.head.Seq == 0 {
+		l.head = l.tail
+	}
+	if !anchor && l.tail.Seq-l.anchored < auditAnchorInterval {
+		return nil
+	}
+	return l.writeKey()
+}
+
+// files returns the log files from the oldest one.
+func (l *auditLogger) files() []string {
+	files := []string{}
+	for i := auditMaxFiles; i >= 1; i-- {
+		fn := fmt.Sprintf("%s.%d", l.path, i)
+		if _, err := os.Stat(fn); err == nil {
+			files = append(files, fn)
+		}
+	}
+	return append(files, l.path)
+}
+
+func (l *auditLogger) records() ([]AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := []AuditRecord{}
+	for _, fn := range l.files() {
+		rs, err := readAuditLogFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rs...)
+	}
+	return records, nil
+}
+
+func readAuditLogFile(path string) ([]AuditRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "os.Open(%s)", path)
+	}
+	defer f.Close()
+	records := []AuditRecord{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		r := AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, errors.Wrapf(err, "%s:%d", path, lineno)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "%s", path)
+	}
+	return records, nil
+}
+
+// verify checks the hash chain of the records, and their first and last
+// ones against the head and the tail anchored in the key file.
+func (l *auditLogger) verify(records []AuditRecord) error {
+	l.mu.Lock()
+	head, tail := l.head, l.tail
+	l.mu.Unlock()
+	if len(records) == 0 {
+		if tail.Seq != 0 {
+			return errors.Errorf("all records are missing")
+		}
+		return nil
+	}
+	if first := records[0]; first.Seq != head.Seq || first.Hash != head.Hash {
+		return errors.Errorf("seq %d: record(s) missing before it", first.Seq)
+	}
+	for idx, r := range records {
+		if r.Hash != r.computeHash(l.key) {
+			return errors.Errorf("seq %d: hash mismatch", r.Seq)
+		}
+		if idx == 0 {
+			continue
+		}
+		prev := records[idx-1]
+		if r.PrevHash != prev.Hash {
+			return errors.Errorf("seq %d: chain is broken", r.Seq)
+		}
+		if r.Seq != prev.Seq+1 {
+			return errors.Errorf("seq %d: record(s) missing after seq %d",
+				r.Seq, prev.Seq)
+		}
+	}
+	if last := records[len(records)-1]; last.Seq != tail.Seq ||
+		last.Hash != tail.Hash {
+		return errors.Errorf("seq %d: record(s) missing after it", last.Seq)
+	}
+	return nil
+}
+
+func audit(r AuditRecord) {
+	if auditLog == nil {
+		return
+	}
+	if err := auditLog.write(r); err != nil {
+		log.Printf("audit: %s\n", err)
+	}
+}
+
+func auditCommand(cli string) {
+	audit(AuditRecord{Type: AuditTypeCommand, Command: cli})
+}
+
+func auditCommit(commitId string) {
+	audit(AuditRecord{Type: AuditTypeCommit, CommitId: commitId})
+}
+
+func auditRollback(commitId string) {
+	audit(AuditRecord{Type: AuditTypeRollback, CommitId: commitId})
+}
+
+// auditReadOnlyCommands are the commands of configure mode not changing
+// the candidate, whose edits are not looked for.
+var auditReadOnlyCommands = map[string]bool{
+	"show": true,
+	"do":   true,
+	"list": true,
+	"edit": true,
+	"top":  true,
+	"up":   true,
+	"exit": true,
+	"quit": true,
+}
+
+// auditCandidateSnapshot returns the flattened candidate to compare with
+// the one after the command args, or nil when there is nothing to audit.
+func auditCandidateSnapshot(args []string) map[string]string {
+	if auditLog == nil || dbm == nil || dbm.candidateRoot == nil ||
+		len(args) == 0 || auditReadOnlyCommands[args[0]] {
+		return nil
+	}
+	m, err := flattenDBNode(dbm.candidateRoot)
+	if err != nil {
+		log.Printf("audit: %s\n", err)
+		return nil
+	}
+	return m
+}
+
+// auditCandidateEdits records the difference of the candidate from the
+// snapshot taken before the command.
+func auditCandidateEdits(before map[string]string) {
+	if before == nil || dbm.candidateRoot == nil {
+		return
+	}
+	after, err := flattenDBNode(dbm.candidateRoot)
+	if err != nil {
+		log.Printf("audit: %s\n", err)
+		return
+	}
+	xpaths := []string{}
+	for xpath, v := range after {
+		if old, ok := before[xpath]; !ok || old != v {
+			xpaths = append(xpaths, xpath)
+		}
+	}
+	for xpath := range before {
+		if _, ok := after[xpath]; !ok {
+			xpaths = append(xpaths, xpath)
+		}
+	}
+	sort.Strings(xpaths)
+	for _, xpath := range xpaths {
+		r := AuditRecord{Type: AuditTypeEdit, XPath: xpath}
+		if v, ok := before[xpath]; ok {
+			r.Old = &v
+		}
+		if v, ok := after[xpath]; ok {
+			r.New = &v
+		}
+		audit(r)
+	}
+}
+
+type auditFilter struct {
+	user    string
+	session string
+	typ     string
+	xpath   string
+	since   time.Time
+	last    int
+}
+
+func (f auditFilter) match(r AuditRecord) bool {
+	switch {
+	case f.user != "" && r.User != f.user:
+		return false
+	case f.session != "" && r.Session != f.session:
+		return false
+	case f.typ != "" && r.Type != f.typ:
+		return false
+	case f.xpath != "" && !strings.HasPrefix(r.XPath, f.xpath):
+		return false
+	case r.Time.Before(f.since):
+		return false
+	}
+	return true
+}
+
+// parseAuditFilter parses "[user <u>] [session <s>] [type <t>]
+// [xpath <prefix>] [since <time>] [last <n>]".
+func parseAuditFilter(args []string) (auditFilter, error) {
+	f := auditFilter{}
+	for ; len(args) > 0; args = args[2:] {
+		if len(args) < 2 {
+			return f, errors.Errorf("%s requires value", args[0])
+		}
+		switch args[0] {
+		case "user":
+			f.user = args[1]
+		case "session":
+			f.session = args[1]
+		case "type":
+			f.typ = args[1]
+		case "xpath":
+			f.xpath = args[1]
+		case "since":
+			t, err := parseSinceTime(args[1])
+			if err != nil {
+				return f, err
+			}
+			f.since = t
+		case "last":
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return f, errors.Wrap(err, "last")
+			}
+			f.last = n
+		default:
+			return f, errors.Errorf("unknown filter %s", args[0])
+		}
+	}
+	return f, nil
+}
+
+func ccbShowLogAudit(ctx context.Context, args []string) (*CommandResult, error) {
+	if auditLog == nil {
+		return nil, errors.Errorf("audit log is disabled")
+	}
+	records, err := auditLog.records()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) > 3 && args[3] == "verify" {
+		if err := auditLog.verify(records); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(stdout, "%d records verified\n", len(records))
+		return nil, nil
+	}
+
+	f, err := parseAuditFilter(args[3:])
+	if err != nil {
+		return nil, err
+	}
+	matched := []AuditRecord{}
+	for _, r := range records {
+		if f.match(r) {
+			matched = append(matched, r)
+		}
+	}
+	if f.last > 0 && len(matched) > f.last {
+		matched = matched[len(matched)-f.last:]
+	}
+	for _, r := range matched {
+		fmt.Fprintln(stdout, r.String())
+	}
+	return nil, nil
+}
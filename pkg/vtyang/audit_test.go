@@ -0,0 +1,247 @@
+package vtyang
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	auditTestLogFile = "/tmp/run/vtyang/audit_test.log"
+	auditTestKeyFile = "/tmp/run/vtyang/audit_test.key"
+)
+
+func removeAuditTestLogs(t *testing.T) {
+	if err := os.RemoveAll(auditTestKeyFile); err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range []string{auditTestLogFile} {
+		for i := 0; i <= auditMaxFiles+1; i++ {
+			if i > 0 {
+				fn = fmt.Sprintf("%s.%d", auditTestLogFile, i)
+			}
+			if err := os.RemoveAll(fn); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func setupAuditTestLog(t *testing.T) {
+	removeAuditTestLogs(t)
+	var err error
+	auditLog, err = openAuditLog(auditTestLogFile, auditTestKeyFile, "admin",
+		"test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	defer func(f func() time.Time) { auditNow = f }(auditNow)
+	auditNow = func() time.Time {
+		return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/basic",
+		InitConfigFile: "./testdata/config_change_config.json",
+		OutputFile:     "./testdata/output/TestAuditLog.txt",
+		Setup:          setupAuditTestLog,
+		Inputs: []string{
+			"configure",
+			"set values u08 10",
+			"set values name vtyang1",
+			"delete values items item1 foo",
+			"commit",
+			"quit",
+			"show log audit type command",
+			"show log audit type edit",
+			"show log audit xpath /main:values/main:name",
+			"show log audit type command last 2",
+			"show log audit user hoge",
+			"show log audit hoge",
+			"show log audit verify",
+		},
+	})
+}
+
+func TestAuditLogTamper(t *testing.T) {
+	setupAuditTestLog(t)
+	defer auditLog.close()
+	for i := 0; i < 3; i++ {
+		auditCommand(fmt.Sprintf("show values %d", i))
+	}
+	records, err := auditLog.records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auditLog.verify(records); err != nil {
+		t.Fatal(err)
+	}
+
+	// Modify a record
+	b, err := os.ReadFile(auditTestLogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(b), "show values 1", "show values 9", 1)
+	if err := os.WriteFile(auditTestLogFile, []byte(tampered), 0600); err != nil {
+		t.Fatal(err)
+	}
+	records, err = auditLog.records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auditLog.verify(records); err == nil {
+		t.Errorf("tampered record is not detected")
+	}
+
+	// Rewrite a record with the hash recomputed without the key
+	records, err = readAuditLogFile(auditTestLogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(b), "\n")
+	for idx := 1; idx < len(records); idx++ {
+		r := records[idx]
+		r.PrevHash = records[idx-1].Hash
+		r.Hash = r.computeHash([]byte("guessed"))
+		records[idx] = r
+		rb, _ := json.Marshal(r)
+		lines[idx] = string(rb)
+	}
+	verifyAuditTestLog(t, strings.Join(lines, "\n"), "seq 2: hash mismatch")
+
+	// Remove a record from the middle, the head and the tail
+	lines = strings.Split(string(b), "\n")
+	verifyAuditTestLog(t, strings.Join(append(lines[:1:1], lines[2:]...), "\n"),
+		"seq 3: chain is broken")
+	verifyAuditTestLog(t, strings.Join(lines[1:], "\n"),
+		"seq 2: record(s) missing before it")
+	verifyAuditTestLog(t, strings.Join(lines[:2], "\n"),
+		"seq 2: record(s) missing after it")
+	verifyAuditTestLog(t, "", "all records are missing")
+
+	// The log without the key isn't continued
+	if err := os.Remove(auditTestKeyFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openAuditLog(auditTestLogFile, auditTestKeyFile, "admin",
+		"test"); err == nil {
+		t.Errorf("audit log is opened without the key")
+	}
+}
+
+// verifyAuditTestLog verifies the log replaced with data.
+func verifyAuditTestLog(t *testing.T, data, expected string) {
+	t.Helper()
+	if err := os.WriteFile(auditTestLogFile, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	records, err := auditLog.records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = auditLog.verify(records)
+	if err == nil || err.Error() != expected {
+		t.Errorf("unexpected error %v, expected %q", err, expected)
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	defer func(n int64) { auditMaxSize = n }(auditMaxSize)
+	auditMaxSize = 1024
+	setupAuditTestLog(t)
+	for i := 0; i < 50; i++ {
+		auditCommand(fmt.Sprintf("show values %d", i))
+	}
+	auditLog.close()
+
+	// Reopen and continue the chain
+	var err error
+	auditLog, err = openAuditLog(auditTestLogFile, auditTestKeyFile, "admin",
+		"test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditLog.close()
+	auditCommand("show values 50")
+
+	for i := 1; i <= auditMaxFiles; i++ {
+		if _, err := os.Stat(fmt.Sprintf("%s.%d", auditTestLogFile, i)); err != nil {
+			t.Errorf("rotated file %d: %s", i, err)
+		}
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s.%d", auditTestLogFile,
+		auditMaxFiles+1)); err == nil {
+		t.Errorf("too many rotated files")
+	}
+	records, err := auditLog.records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auditLog.verify(records); err != nil {
+		t.Error(err)
+	}
+	last := records[len(records)-1]
+	if last.Seq != 51 || last.Command != "show values 50" {
+		t.Errorf("unexpected last record %s", last.String())
+	}
+}
+
+func TestAuditLogAnchor(t *testing.T) {
+	defer func(n uint64) { auditAnchorInterval = n }(auditAnchorInterval)
+	auditAnchorInterval = 4
+	setupAuditTestLog(t)
+	for i := 0; i < 10; i++ {
+		auditCommand(fmt.Sprintf("show values %d", i))
+	}
+
+	// The tail is anchored every 4 records from the head
+	anchoredTail := func() uint64 {
+		t.Helper()
+		b, err := os.ReadFile(auditTestKeyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := auditKey{}
+		if err := json.Unmarshal(b, &k); err != nil {
+			t.Fatal(err)
+		}
+		return k.Tail.Seq
+	}
+	if seq := anchoredTail(); seq != 9 {
+		t.Errorf("unexpected anchored tail %d", seq)
+	}
+
+	// The chain continues from the records after the anchor, as after
+	// the crash
+	auditLog.file.Close()
+	var err error
+	auditLog, err = openAuditLog(auditTestLogFile, auditTestKeyFile, "admin",
+		"test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditCommand("show values 10")
+	records, err := auditLog.records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auditLog.verify(records); err != nil {
+		t.Error(err)
+	}
+	if last := records[len(records)-1]; last.Seq != 11 {
+		t.Errorf("unexpected last record %s", last.String())
+	}
+
+	// The tail is anchored on close
+	closeAuditLog()
+	if seq := anchoredTail(); seq != 11 {
+		t.Errorf("unexpected anchored tail %d", seq)
+	}
+}
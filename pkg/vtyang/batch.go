@@ -0,0 +1,79 @@
+package vtyang
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// executeBatch executes the commands read from r, one per line. The empty
+// lines and the comments starting with '#' are skipped. It stops at the
+// first failure when exitOnError is set, and returns an error when any of
+// the commands failed.
+func executeBatch(r io.Reader, name string, exitOnError bool) error {
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	failures := []string{}
+	for !exit && scanner.Scan() {
+		lineno++
+		cli := strings.TrimSpace(scanner.Text())
+		if cli == "" || strings.HasPrefix(cli, "#") {
+			continue
+		}
+		err := consoleSession.runCommand(context.Background(), cli)
+		if err == nil {
+			continue
+		}
+		// The line is displayed with the position for the caret
+		pos := fmt.Sprintf("%s:%d: ", name, lineno)
+		fmt.Fprintf(stdout, "%s%s\n", pos, cli)
+		writeCommandError(stdout, cli, len(pos), err)
+		failures = append(failures, fmt.Sprintf("%s:%d", name, lineno))
+		if exitOnError {
+			return errors.Errorf("%s:%d: %q failed", name, lineno, cli)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, name)
+	}
+	if len(failures) > 0 {
+		return errors.Errorf("%d commands failed (%s)", len(failures),
+			strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// executeInlineCommands executes the commands of --command as the batch.
+func executeInlineCommands(commands []string, exitOnError bool) error {
+	return executeBatch(strings.NewReader(strings.Join(commands, "\n")),
+		"command", exitOnError)
+}
+
+// executeBatchFile executes the commands of the script file, or stdin when
+// the filename is "-".
+func executeBatchFile(filename string, exitOnError bool) error {
+	if filename == "-" {
+		return executeBatch(os.Stdin, "stdin", exitOnError)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return executeBatch(f, filename, exitOnError)
+}
+
+// stdinIsTerminal returns false when the commands are piped or redirected
+// to vtyang.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
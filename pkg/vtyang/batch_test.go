@@ -0,0 +1,65 @@
+package vtyang
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExecuteBatch(t *testing.T) {
+	if err := os.RemoveAll("/tmp/run/vtyang/config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/frr_mgmtd_minimal"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	buf := setStdoutWithBuffer()
+
+	script := strings.Join([]string{
+		"# comment",
+		"configure",
+		"",
+		"set lib prefix-list ipv4 hoge entry 10 action permit",
+		"set lib prefix-list ipv4 hoge entry 10 action hoge",
+		"commit",
+		"exit",
+		"show running-config | display set",
+	}, "\n")
+	err := executeBatch(strings.NewReader(script), "script.cli", false)
+	if err == nil || err.Error() != "1 commands failed (script.cli:5)" {
+		t.Errorf("unexpected error %v", err)
+	}
+	// The caret is under the invalid value
+	expected := strings.Join([]string{
+		"script.cli:5: set lib prefix-list ipv4 hoge entry 10 action hoge",
+		"                                                            ^",
+		"Error: validateValue: validateEnumValue: enum value is not valid available=[deny permit]",
+		"set lib prefix-list ipv4 hoge entry 10 action permit",
+	}, "\n") + "\n"
+	if out := buf.String(); out != expected {
+		t.Errorf("unexpected output\n%s", out)
+	}
+	buf.Reset()
+
+	// The commands after the failure are skipped
+	err = executeBatch(strings.NewReader("hoge\nconfigure\n"), "stdin", true)
+	if err == nil || err.Error() != `stdin:1: "hoge" failed` {
+		t.Errorf("unexpected error %v", err)
+	}
+	if buf.String() != "stdin:1: hoge\n         ^\nError: command hoge not found\n" {
+		t.Errorf("unexpected output\n%s", buf.String())
+	}
+	if cliMode != CliModeView {
+		t.Errorf("unexpected mode %v", cliMode)
+	}
+	buf.Reset()
+
+	if err := executeInlineCommands([]string{"show running-config lib"},
+		true); err != nil {
+		t.Error(err)
+	}
+}
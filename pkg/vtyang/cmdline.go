@@ -0,0 +1,119 @@
+package vtyang
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// splitCommandLine splits the command line into the words of the command
+// and the output pipes separated by "|". The words can be quoted by '"'
+// to have spaces and '|'. In the quotes, '"' and '\' are escaped by '\'
+// as well as "\n", "\t" and "\r", the other escapes (e.g. "\d") are kept
+// as they are.
+func splitCommandLine(s string) ([][]string, error) {
+	segments, _, err := splitCommandLineOffsets(s)
+	return segments, err
+}
+
+// commandLineOffsets returns the offsets of the words of the command
+// before "|" in the command line.
+func commandLineOffsets(s string) ([]int, error) {
+	_, offsets, err := splitCommandLineOffsets(s)
+	if err != nil {
+		return nil, err
+	}
+	return offsets[0], nil
+}
+
+func splitCommandLineOffsets(s string) ([][]string, [][]int, error) {
+	segments := [][]string{{}}
+	offsets := [][]int{{}}
+	word := strings.Builder{}
+	inWord := false
+	beginWord := func(i int) {
+		if !inWord {
+			last := len(offsets) - 1
+			offsets[last] = append(offsets[last], i)
+			inWord = true
+		}
+	}
+	endWord := func() {
+		if inWord {
+			last := len(segments) - 1
+			segments[last] = append(segments[last], word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			endWord()
+		case c == '|':
+			endWord()
+			segments = append(segments, []string{})
+			offsets = append(offsets, []int{})
+		case c == '"':
+			beginWord(i)
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+					word.WriteString(unescapeCommandChar(s[i]))
+					continue
+				}
+				word.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, nil, errors.Errorf("unterminated quoted string")
+			}
+		default:
+			beginWord(i)
+			word.WriteByte(c)
+		}
+	}
+	endWord()
+	return segments, offsets, nil
+}
+
+func unescapeCommandChar(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case '"', '\\':
+		return string(c)
+	}
+	// Keep the unknown escape (e.g. regular expression "\d")
+	return "\\" + string(c)
+}
+
+// quoteCommandArg quotes the word when it can't be in the command line as
+// is, so that splitCommandLine returns the same word.
+func quoteCommandArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n\r\"\\|") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`,
+		"\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// joinCommandLine returns the command line of the words.
+func joinCommandLine(args []string) string {
+	words := []string{}
+	for _, arg := range args {
+		words = append(words, quoteCommandArg(arg))
+	}
+	return strings.Join(words, " ")
+}
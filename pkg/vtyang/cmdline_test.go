@@ -0,0 +1,62 @@
+package vtyang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	testcases := []struct {
+		in  string
+		out [][]string
+		err bool
+	}{
+		{in: "show running-config", out: [][]string{{"show", "running-config"}}},
+		{in: "  set  values   name hoge ", out: [][]string{{"set", "values", "name", "hoge"}}},
+		{in: `set values name "hello world"`, out: [][]string{{"set", "values", "name", "hello world"}}},
+		{in: `set values name ""`, out: [][]string{{"set", "values", "name", ""}}},
+		{in: `set values name "a \"b\" \\ c\n"`, out: [][]string{{"set", "values", "name", "a \"b\" \\ c\n"}}},
+		{in: `set values name "a|b"`, out: [][]string{{"set", "values", "name", "a|b"}}},
+		{in: `set values name a"b c"d`, out: [][]string{{"set", "values", "name", "ab cd"}}},
+		{in: `show values | include "\d+ x"`, out: [][]string{{"show", "values"}, {"include", `\d+ x`}}},
+		{in: `show values | include "a\|b"`, out: [][]string{{"show", "values"}, {"include", `a\|b`}}},
+		{in: "show values|count", out: [][]string{{"show", "values"}, {"count"}}},
+		{in: "show values |", out: [][]string{{"show", "values"}, {}}},
+		{in: `set values name "hoge`, err: true},
+	}
+	for _, tc := range testcases {
+		out, err := splitCommandLine(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: error expected", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(out, tc.out) {
+			t.Errorf("%s: unexpected %q", tc.in, out)
+		}
+	}
+}
+
+func TestQuoteCommandArg(t *testing.T) {
+	for _, s := range []string{
+		"hoge", "", "hello world", `say "hi"`, `back\slash`, "a|b",
+		"tab\there", "line\nbreak", `\d+`,
+	} {
+		out, err := splitCommandLine("set " + quoteCommandArg(s))
+		if err != nil {
+			t.Errorf("%q: %s", s, err)
+			continue
+		}
+		if len(out) != 1 || len(out[0]) != 2 || out[0][1] != s {
+			t.Errorf("%q: not round-tripped %q", s, out)
+		}
+	}
+	if quoteCommandArg("hoge") != "hoge" {
+		t.Errorf("unexpected quote")
+	}
+}
@@ -1,11 +1,15 @@
 package vtyang
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/openconfig/goyang/pkg/yang"
 	"github.com/spf13/cobra"
@@ -17,15 +21,37 @@ import (
 
 var (
 	GlobalOptEnableGrpc  bool
+	GlobalOptGrpcAddr    string
+	GlobalOptGrpcToken   string
 	GlobalOptLogFile     string
 	GlobalOptRunFilePath string
 	GlobalOptYangPath    []string
 	GlobalOptDumpCliTree string
 	GlobalOptCommands    []string
+	GlobalOptScriptFile  string
+	GlobalOptExitOnError bool
 	GlobalOptMgmtdSock   string
+	GlobalOptMgmtdSync   bool
+	GlobalOptRPCScripts  []string
+	GlobalOptAuditLog    string
+	GlobalOptAuditKey    string
 
-	agentOpts   AgentOpts
-	mgmtdClient *mgmtd.Client
+	GlobalOptDriftCheckInterval time.Duration
+	GlobalOptDriftAction        string
+
+	GlobalOptConfigChangeSock    string
+	GlobalOptConfigChangeWebhook string
+
+	GlobalOptSessionSock string
+	GlobalOptDaemon      bool
+
+	GlobalOptSSHAddr    string
+	GlobalOptSSHHostKey string
+	GlobalOptSSHUsers   string
+
+	agentOpts       AgentOpts
+	mgmtdClient     *mgmtd.Client
+	mgmtdSupervisor *mgmtd.Supervisor
 
 	exit            bool      = false
 	stdout          io.Writer = os.Stdout
@@ -49,6 +75,9 @@ func getPrompt() string {
 	case CliModeView:
 		return "vtyang# "
 	case CliModeConfigure:
+		if context := editPrompt(); context != "" {
+			return fmt.Sprintf("vtyang(config-%s)# ", context)
+		}
 		return "vtyang(config)# "
 	default:
 		panic(fmt.Sprintf("CLIMODE(%v)", cliMode))
@@ -65,41 +94,137 @@ func NewCommand() *cobra.Command {
 				YangPath:    GlobalOptYangPath,
 				LogFile:     GlobalOptLogFile,
 			}
+			if GlobalOptAuditLog != "" {
+				opts.AuditLogFile = GlobalOptAuditLog
+			} else if GlobalOptRunFilePath != "" {
+				opts.AuditLogFile = GlobalOptRunFilePath + "/audit.log"
+			}
+			opts.AuditKeyFile = GlobalOptAuditKey
 			if GlobalOptMgmtdSock != "" {
 				opts.BackendMgmtd = &AgentOptsBackendMgmtd{
-					UnixSockPath: GlobalOptMgmtdSock,
+					UnixSockPath:    GlobalOptMgmtdSock,
+					SyncFromBackend: GlobalOptMgmtdSync,
+				}
+			} else if GlobalOptMgmtdSync {
+				return fmt.Errorf("--sync-from-backend requires --mgmtd-sock")
+			}
+			if len(GlobalOptRPCScripts) > 0 {
+				opts.RPCScripts = map[string]string{}
+				for _, s := range GlobalOptRPCScripts {
+					words := strings.SplitN(s, "=", 2)
+					if len(words) != 2 {
+						return fmt.Errorf("invalid rpc-script %q", s)
+					}
+					opts.RPCScripts[words[0]] = words[1]
+				}
+			}
+			if GlobalOptConfigChangeSock != "" ||
+				GlobalOptConfigChangeWebhook != "" {
+				opts.ConfigChange = &AgentOptsConfigChange{
+					UnixSockPath: GlobalOptConfigChangeSock,
+					WebhookURL:   GlobalOptConfigChangeWebhook,
+				}
+			}
+			switch GlobalOptDriftAction {
+			case "", "adopt", "repush":
+			default:
+				return fmt.Errorf("invalid drift-action %q", GlobalOptDriftAction)
+			}
+			if GlobalOptDriftCheckInterval > 0 {
+				opts.DriftCheck = &AgentOptsDriftCheck{
+					Interval: GlobalOptDriftCheckInterval,
+					Action:   GlobalOptDriftAction,
+				}
+			}
+			if GlobalOptDaemon && GlobalOptSessionSock == "" &&
+				GlobalOptSSHAddr == "" {
+				return fmt.Errorf("--daemon requires --session-sock or --ssh-addr")
+			}
+			opts.SessionSockPath = GlobalOptSessionSock
+			if GlobalOptSSHAddr != "" {
+				opts.SSH = &AgentOptsSSH{
+					Address:     GlobalOptSSHAddr,
+					HostKeyFile: GlobalOptSSHHostKey,
+					UsersFile:   GlobalOptSSHUsers,
+				}
+				if opts.SSH.HostKeyFile == "" {
+					opts.SSH.HostKeyFile = opts.RuntimePath + "/ssh_host_key"
+				}
+				if opts.SSH.UsersFile == "" {
+					opts.SSH.UsersFile = opts.RuntimePath + "/ssh_users.json"
+				}
+			}
+			if GlobalOptEnableGrpc {
+				opts.Grpc = &AgentOptsGrpc{
+					Address:   GlobalOptGrpcAddr,
+					TokenFile: GlobalOptGrpcToken,
 				}
 			}
 			if err := InitAgent(opts); err != nil {
 				return err
 			}
+			defer closeMgmtd()
+			defer closeAuditLog()
+			defer stopSessionServer()
+			defer stopSSHServer()
+
+			// Serve the attached clients only, without the shell
+			if GlobalOptDaemon {
+				cmd.SilenceUsage = true
+				sig := make(chan os.Signal, 1)
+				signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+				<-sig
+				return nil
+			}
 
-			// Execute Commands as inline mode
-			if len(GlobalOptCommands) > 0 {
-				for _, c := range GlobalOptCommands {
-					getCommandNodeCurrent().executeCommand(c)
+			// Execute Commands as batch mode, without the shell. The failure
+			// of the commands is the exit status.
+			script := GlobalOptScriptFile
+			if script == "" && len(GlobalOptCommands) == 0 && !stdinIsTerminal() {
+				script = "-"
+			}
+			if len(GlobalOptCommands) > 0 || script != "" {
+				cmd.SilenceUsage = true
+				var err error
+				if len(GlobalOptCommands) > 0 {
+					err = executeInlineCommands(GlobalOptCommands, GlobalOptExitOnError)
+					if err != nil && GlobalOptExitOnError {
+						return err
+					}
 				}
+				if script != "" {
+					if err0 := executeBatchFile(script, GlobalOptExitOnError); err0 != nil {
+						return err0
+					}
+				}
+				return err
 			}
 
 			// Prepare shell objects
 			line := liner.NewLiner()
 			defer line.Close()
 			line.SetCtrlCAborts(true)
-			line.SetWordCompleter(completer)
+			line.SetWordCompleter(consoleSession.completer)
 			line.SetTabCompletionStyle(liner.TabPrints)
-			line.SetBinder(QUESTION_MARK, completionLister)
+			line.SetBinder(QUESTION_MARK, consoleSession.completionLister)
 
 			// Start shell loop
-			for !exit {
-				if name, err := line.Prompt(getPrompt()); err == nil {
+			for !consoleSession.exited() {
+				prompt := consoleSession.prompt()
+				if name, err := line.Prompt(prompt); err == nil {
 					line.AppendHistory(name)
-					name = strings.TrimSpace(name)
-					args := strings.Fields(name)
-					if len(args) == 0 {
+					if strings.TrimSpace(name) == "" {
 						continue
 					}
-					cn := getCommandNodeCurrent()
-					cn.executeCommand(cat(args))
+					// Ctrl-C cancels the command, such as monitor
+					ctx, stop := signal.NotifyContext(context.Background(),
+						os.Interrupt)
+					err := consoleSession.runCommand(ctx, name)
+					stop()
+					if err != nil {
+						// The caret is under the line just entered
+						writeCommandError(stdout, name, len(prompt), err)
+					}
 				} else if err == liner.ErrPromptAborted {
 					log.Print("aborted")
 					break
@@ -113,12 +238,49 @@ func NewCommand() *cobra.Command {
 
 	fs := rootCmd.Flags()
 	fs.BoolVar(&GlobalOptEnableGrpc, "grpc", false, "Enable gRPC server")
+	fs.StringVar(&GlobalOptGrpcAddr, "grpc-addr", "127.0.0.1:8080", "gRPC server address")
+	fs.StringVar(&GlobalOptGrpcToken, "grpc-token-file", "",
+		"File of the token required from the agents, needed unless loopback")
 	fs.StringVarP(&GlobalOptLogFile, "logfile", "l", "/tmp/vtyang.log", "Log file")
 	fs.StringVarP(&GlobalOptRunFilePath, "run", "r", "", "Runtime file path")
 	fs.StringArrayVarP(&GlobalOptYangPath, "yang", "y", []string{}, "Yang file path")
-	fs.StringArrayVarP(&GlobalOptCommands, "command", "c", []string{}, "")
+	fs.StringArrayVarP(&GlobalOptCommands, "command", "c", []string{},
+		"Command executed without the shell")
+	fs.StringVarP(&GlobalOptScriptFile, "file", "f", "",
+		"Script file of commands executed without the shell, - for stdin")
+	fs.BoolVar(&GlobalOptExitOnError, "exit-on-error", false,
+		"Stop the commands at the first failure")
 	fs.StringVar(&GlobalOptMgmtdSock, "mgmtd-sock", "", "/var/run/frr/mgmtd_fe.sock")
+	fs.BoolVar(&GlobalOptMgmtdSync, "sync-from-backend", false,
+		"Import the running config of mgmtd on startup")
+	fs.StringArrayVar(&GlobalOptRPCScripts, "rpc-script", []string{},
+		"Script handling rpc or action (e.g. /system/reboot=/usr/bin/reboot.sh)")
+	fs.StringVar(&GlobalOptAuditLog, "audit-log", "",
+		"Audit log file (default <run>/audit.log)")
+	fs.StringVar(&GlobalOptAuditKey, "audit-key", "",
+		"Key file of audit log hash chain, kept apart from the log (default <audit-log>.key, warned)")
+	fs.StringVar(&GlobalOptConfigChangeSock, "config-change-sock", "",
+		"Unix socket streaming config change events")
+	fs.StringVar(&GlobalOptConfigChangeWebhook, "config-change-webhook", "",
+		"URL posted config change events (e.g. http://127.0.0.1:8081/events)")
+	fs.StringVar(&GlobalOptSessionSock, "session-sock", "",
+		"Unix socket the clients attach to (e.g. /var/run/vtyang.sock)")
+	fs.BoolVar(&GlobalOptDaemon, "daemon", false,
+		"Run without the shell, serving the clients of --session-sock or --ssh-addr")
+	fs.StringVar(&GlobalOptSSHAddr, "ssh-addr", "",
+		"SSH server address serving the shell (e.g. 0.0.0.0:2222)")
+	fs.StringVar(&GlobalOptSSHHostKey, "ssh-host-key", "",
+		"SSH host key, generated when missing (default <run>/ssh_host_key)")
+	fs.StringVar(&GlobalOptSSHUsers, "ssh-users", "",
+		"SSH users file (default <run>/ssh_users.json)")
+	fs.DurationVar(&GlobalOptDriftCheckInterval, "drift-check-interval", 0,
+		"Interval of config drift check, disabled when 0 (e.g. 30s)")
+	fs.StringVar(&GlobalOptDriftAction, "drift-action", "",
+		"Action on detected drift, adopt or repush (default only logged)")
 
+	rootCmd.AddCommand(newCommandGenerate())
+	rootCmd.AddCommand(newCommandAttach())
+	rootCmd.AddCommand(newCommandHashPassword())
 	rootCmd.AddCommand(util.NewCommandCompletion(rootCmd))
 	rootCmd.AddCommand(util.NewCommandVersion())
 	return rootCmd
@@ -0,0 +1,150 @@
+package vtyang
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+)
+
+// CommandError is the error of the command about one of the arguments of
+// the command line.
+type CommandError struct {
+	// XPath is the node the error is about, empty when unknown
+	XPath string
+	// ArgPos is the index of the offending argument in the words of the
+	// command line, -1 when unknown
+	ArgPos int
+	// AppTag is the error-app-tag of the YANG statement failed
+	AppTag string
+	Err    error
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Cause() error {
+	return e.Err
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// asCommandError returns the CommandError in the chain of err, nil when
+// there is none.
+func asCommandError(err error) *CommandError {
+	var cerr *CommandError
+	if errors.As(err, &cerr) {
+		return cerr
+	}
+	return nil
+}
+
+// shiftCommandError moves the argument position of the error by n, for the
+// error returned by the function given a part of the command line. The
+// position is unknown when it's out of the command line.
+func shiftCommandError(err error, n int) error {
+	if cerr := asCommandError(err); cerr != nil && cerr.ArgPos >= 0 {
+		cerr.ArgPos += n
+		if cerr.ArgPos < 0 {
+			cerr.ArgPos = -1
+		}
+	}
+	return err
+}
+
+// pinCommandError sets the argument position of the error to pos, for the
+// error about the argument as a whole (e.g. the file of load). The position
+// is unknown when pos is negative.
+func pinCommandError(err error, pos int) error {
+	if cerr := asCommandError(err); cerr != nil {
+		cerr.ArgPos = pos
+	}
+	return err
+}
+
+// moreSpecificError returns the error about the later argument, which is
+// the one parsed further.
+func moreSpecificError(err0, err1 error) error {
+	switch {
+	case err0 == nil:
+		return err1
+	case err1 == nil:
+		return err0
+	}
+	pos0, pos1 := -1, -1
+	if cerr := asCommandError(err0); cerr != nil {
+		pos0 = cerr.ArgPos
+	}
+	if cerr := asCommandError(err1); cerr != nil {
+		pos1 = cerr.ArgPos
+	}
+	if pos1 > pos0 {
+		return err1
+	}
+	return err0
+}
+
+// entryErrorAppTag returns the error-app-tag of the restrictions of the
+// type of the leaf (e.g. range and pattern), empty when none is given.
+func entryErrorAppTag(e *yang.Entry) string {
+	if e == nil {
+		return ""
+	}
+	var t *yang.Type
+	switch n := e.Node.(type) {
+	case *yang.Leaf:
+		t = n.Type
+	case *yang.LeafList:
+		t = n.Type
+	}
+	if tag := typeErrorAppTag(t); tag != "" {
+		return tag
+	}
+	// The restrictions of the typedef
+	if e.Type != nil {
+		return typeErrorAppTag(e.Type.Base)
+	}
+	return ""
+}
+
+func typeErrorAppTag(t *yang.Type) string {
+	if t == nil {
+		return ""
+	}
+	if t.Range != nil && t.Range.ErrorAppTag != nil {
+		return t.Range.ErrorAppTag.Name
+	}
+	if t.Length != nil && t.Length.ErrorAppTag != nil {
+		return t.Length.ErrorAppTag.Name
+	}
+	for _, p := range t.Pattern {
+		if p.ErrorAppTag != nil {
+			return p.ErrorAppTag.Name
+		}
+	}
+	return ""
+}
+
+// writeCommandError displays the error of the command line. When the line
+// is displayed from the column indent, the caret is put under the
+// offending argument. A negative indent means the line isn't displayed.
+func writeCommandError(w io.Writer, cli string, indent int, err error) {
+	msg := err.Error()
+	if cerr := asCommandError(err); cerr != nil {
+		if indent >= 0 && cerr.ArgPos >= 0 {
+			offsets, err := commandLineOffsets(cli)
+			if err == nil && cerr.ArgPos < len(offsets) {
+				fmt.Fprintf(w, "%s^\n", strings.Repeat(" ", indent+offsets[cerr.ArgPos]))
+			}
+		}
+		if cerr.AppTag != "" {
+			msg = fmt.Sprintf("%s (error-app-tag %s)", msg, cerr.AppTag)
+		}
+	}
+	fmt.Fprintf(w, "Error: %s\n", msg)
+}
@@ -0,0 +1,103 @@
+package vtyang
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+)
+
+func TestCommandError(t *testing.T) {
+	if err := os.RemoveAll("/tmp/run/vtyang/config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/display"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	setStdoutWithBuffer()
+
+	testcases := []struct {
+		in     string
+		xpath  string
+		argPos int
+		appTag string
+		out    string
+	}{
+		{
+			in:     "set system hoge 1",
+			xpath:  "/display:system",
+			argPos: 2,
+			out: "" +
+				"           ^\n" +
+				"Error: entry hoge is not found\n",
+		},
+		{
+			in:     "set interfaces interface eth0 vlan 5000",
+			xpath:  "/display:interfaces/display:interface[name='eth0']/display:vlan",
+			argPos: 5,
+			appTag: "invalid-vlan",
+			out: "" +
+				"                                   ^\n" +
+				"Error: validateValue: validateNumberValue: max validation failed max=4094 input=5000 (error-app-tag invalid-vlan)\n",
+		},
+		{
+			in:     `set  "system"  mtu  x`,
+			xpath:  "/display:system/display:mtu",
+			argPos: 3,
+			out: "" +
+				"                    ^\n" +
+				"Error: validateValue: validateNumberValue: SetFromString: strconv.ParseUint(s,10,16): strconv.ParseUint: parsing \"x\": invalid syntax\n",
+		},
+		{
+			in:     "do show configuration system hoge",
+			xpath:  "/display:system",
+			argPos: 4,
+			out: "" +
+				"                             ^\n" +
+				"Error: entry hoge is not found\n",
+		},
+		{
+			in:     "hoge",
+			argPos: 0,
+			out: "" +
+				"^\n" +
+				"Error: command hoge not found\n",
+		},
+	}
+	cn := getCommandNode(CliModeConfigure)
+	cliMode = CliModeConfigure
+	dbm.candidateRoot = dbm.root.DeepCopy()
+	for idx, tc := range testcases {
+		_, err := cn.execute(context.Background(), tc.in)
+		cerr := asCommandError(err)
+		if cerr == nil {
+			t.Errorf("tc[%d] %q: unexpected error %v", idx, tc.in, err)
+			continue
+		}
+		if cerr.XPath != tc.xpath || cerr.ArgPos != tc.argPos ||
+			cerr.AppTag != tc.appTag {
+			t.Errorf("tc[%d] %q: unexpected error %+v", idx, tc.in, *cerr)
+		}
+		buf := &bytes.Buffer{}
+		writeCommandError(buf, tc.in, 0, err)
+		if buf.String() != tc.out {
+			t.Errorf("tc[%d] %q: unexpected output\n%s", idx, tc.in, buf.String())
+		}
+	}
+
+	// The position is in the line typed in the edit context
+	getCommandNodeCurrent().executeCommand("edit interfaces interface eth0")
+	_, err := getCommandNodeCurrent().execute(context.Background(), "set vlan 0")
+	if cerr := asCommandError(err); cerr == nil || cerr.ArgPos != 2 {
+		t.Errorf("unexpected error %v", err)
+	}
+	_, err = getCommandNodeCurrent().execute(context.Background(), "up hoge")
+	if cerr := asCommandError(err); cerr == nil || cerr.ArgPos != 1 {
+		t.Errorf("unexpected error %v", err)
+	}
+	getCommandNodeCurrent().executeCommand("quit")
+}
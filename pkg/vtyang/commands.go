@@ -1,16 +1,18 @@
 package vtyang
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/nsf/jsondiff"
 	"github.com/openconfig/goyang/pkg/yang"
 	"github.com/pkg/errors"
 
@@ -25,9 +27,19 @@ const (
 	CliModeConfigure
 )
 
+// CommandFunc is the handler of the command. The output is written to
+// stdout, and the error is displayed by the caller.
+type CommandFunc func(ctx context.Context, args []string) (*CommandResult, error)
+
+// CommandResult is the result of the succeeded command.
+type CommandResult struct {
+	// Message is displayed after the output (e.g. "load complete")
+	Message string
+}
+
 type Command struct {
 	m string
-	f func(args []string)
+	f CommandFunc
 }
 
 type CommandNode struct {
@@ -57,143 +69,215 @@ func getCommandNodeCurrent() *CommandNode {
 	return getCommandNode(cliMode)
 }
 
-func (cn *CommandNode) executeCommand(cli string) {
-	args := strings.Fields(cli)
-	notfound := true
+// commandLock serializes the commands with the background tasks touching
+// the cli state, such as the resync after mgmtd reconnection.
+var commandLock sync.Mutex
+
+// unlockCommand releases commandLock while the command blocks, such as
+// monitor, so that it doesn't block the other sessions. The globals are
+// switched back to the console session before unlocking, so that the
+// background tasks never run on the globals of the blocking session. The
+// command must not touch the globals until the returned function takes
+// the lock and switches them to its session again.
+func unlockCommand() func() {
+	restore := consoleSession.activate()
+	commandLock.Unlock()
+	return func() {
+		commandLock.Lock()
+		restore()
+	}
+}
+
+// executeCommand executes the command line and displays the error.
+func (cn *CommandNode) executeCommand(cli string) error {
+	err := cn.runCommand(context.Background(), cli)
+	if err != nil {
+		writeCommandError(stdout, cli, -1, err)
+	}
+	return err
+}
+
+// runCommand executes the command line and displays the result. The error
+// is returned to be displayed by the caller.
+func (cn *CommandNode) runCommand(ctx context.Context, cli string) error {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	return cn.runCommandLocked(ctx, cli)
+}
+
+// runCommandLocked is runCommand called with commandLock held.
+func (cn *CommandNode) runCommandLocked(ctx context.Context, cli string) error {
+	result, err := cn.execute(ctx, cli)
+	if err != nil {
+		return err
+	}
+	if result != nil && result.Message != "" {
+		fmt.Fprintln(stdout, result.Message)
+	}
+	return nil
+}
+
+func (cn *CommandNode) execute(ctx context.Context,
+	cli string) (result *CommandResult, err error) {
+	auditCommand(cli)
+	segments, err := splitCommandLine(cli)
+	if err != nil {
+		return nil, err
+	}
+	args, pipes, err := splitOutputPipes(segments)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+	snapshot := auditCandidateSnapshot(args)
+	defer auditCandidateEdits(snapshot)
+	if len(pipes) > 0 {
+		finish, pipeErr := startOutputPipes(pipes)
+		if pipeErr != nil {
+			return nil, pipeErr
+		}
+		defer func() { finish(err != nil) }()
+	}
 	for _, cmd := range cn.commands {
 		if matchArgs(args, cmd.m) {
-			cmd.f(args)
-			notfound = false
-			break
+			return cmd.f(ctx, args)
 		}
 	}
-	if notfound {
-		fmt.Fprintf(stdout, "Error: command %s not found\n", args[0])
+	return nil, &CommandError{
+		ArgPos: 0,
+		Err:    errors.Errorf("command %s not found", args[0]),
 	}
 }
 
-func installCommandsDefault(mode CliMode) {
-	installCommand(mode, "list", []string{"List cli nodes"}, func(arg []string) {
-		cn := getCommandNodeCurrent()
-		for _, cmd := range cn.commands {
-			fmt.Fprintf(stdout, "%s\n", cmd.m)
+// quitConfigureMode discards the candidate and returns to view mode.
+func quitConfigureMode() error {
+	cliMode = CliModeView
+	dbm.candidateRoot = nil
+	dbm.candidateBase = nil
+	mgmtdPushed = nil
+	resetEditContext()
+	releaseDatastoreLocks(currentSession, true)
+
+	if agentOpts.BackendMgmtd != nil {
+		// Un-Lock mgmtd datastores
+		ctx, cancel := mgmtdContext()
+		defer cancel()
+		if err := mgmtdUnlockDatastores(ctx); err != nil {
+			return err
 		}
-	})
+	}
+	return nil
+}
 
-	installCommand(mode, "quit", []string{"Quit system"}, func(arg []string) {
-		switch cliMode {
-		case CliModeView:
-			exit = true
-		case CliModeConfigure:
-			cliMode = CliModeView
-			dbm.candidateRoot = nil
+func installCommandsDefault(mode CliMode) {
+	installCommand(mode, "list", []string{"List cli nodes"},
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			cn := getCommandNodeCurrent()
+			for _, cmd := range cn.commands {
+				fmt.Fprintf(stdout, "%s\n", cmd.m)
+			}
+			return nil, nil
+		})
 
-			if agentOpts.BackendMgmtd != nil {
-				// Un-Lock mgmtd datastores
-				for _, dsId := range []*mgmtd.DatastoreId{
-					mgmtd.DatastoreId_RUNNING_DS.Enum(),
-					mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
-				} {
-					if err := mgmtdClient.LockReq(&mgmtd.FeLockDsReq{
-						SessionId: mgmtdClient.GetSessionId(),
-						ReqId:     util.NewUint64Pointer(0),
-						DsId:      dsId,
-						Lock:      util.NewBoolPointer(false),
-					}); err != nil {
-						err := errors.Wrap(err, "LockReq(Lock=false)")
-						fmt.Fprintf(stdout, "Error %v\n", err)
-						return
-					}
-				}
+	installCommand(mode, "quit", []string{"Quit system"},
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			switch cliMode {
+			case CliModeView:
+				exit = true
+			case CliModeConfigure:
+				return nil, quitConfigureMode()
 			}
-		}
-	})
+			return nil, nil
+		})
 
 	installCommand(mode, "show cli-tree", []string{
 		"Display information",
 		"Display completion tree",
-	}, func(arg []string) {
+	}, func(ctx context.Context, args []string) (*CommandResult, error) {
 		fmt.Fprintln(stdout, dumpCompletionTreeJson(getCommandNodeCurrent().tree.Root))
+		return nil, nil
 	})
 
-	installCommandNoCompletion(mode, "hidden-command-nothing", func(arg []string) {
-		// do nothing
-	})
+	installCommandNoCompletion(mode, "hidden-command-nothing",
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			// do nothing
+			return nil, nil
+		})
 
 	installCommand(mode, "save cli-tree", []string{
 		"Save information",
 		"Save completion tree",
-	}, func(arg []string) {
-		content := dumpCompletionTreeJson(getCommandNodeCurrent().tree.Root)
-		if err := os.WriteFile("/tmp/clitree.json", []byte(content), os.ModePerm); err != nil {
-			fmt.Fprintf(stdout, "ERROR: %s", err.Error())
+	}, func(ctx context.Context, args []string) (*CommandResult, error) {
+		if err := checkFileAccess(); err != nil {
+			return nil, err
 		}
+		content := dumpCompletionTreeJson(getCommandNodeCurrent().tree.Root)
+		return nil, os.WriteFile("/tmp/clitree.json", []byte(content), os.ModePerm)
 	})
 
-	installCommandNoCompletion(mode, "show-xpath", func(args []string) {
-		xpath, _, err := ParseXPathArgs(dbm, args[1:], true)
-		if err != nil {
-			fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-			return
-		}
-		out, err := json.MarshalIndent(xpath, "", "  ")
-		if err != nil {
-			fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-			return
-		}
-		fmt.Fprintf(stdout, "%s\n", string(out))
-	})
+	installCommandNoCompletion(mode, "show-xpath",
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			xpath, _, err := ParseXPathArgs(dbm, args[1:], true)
+			if err != nil {
+				return nil, shiftCommandError(err, 1)
+			}
+			out, err := json.MarshalIndent(xpath, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(stdout, "%s\n", string(out))
+			return nil, nil
+		})
 
-	installCommandNoCompletion(mode, "eval-cli", func(args []string) {
-		xpath, val, tail, err := ParseXPathCli(dbm, args[1:], []string{}, true)
-		if err != nil {
-			fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-			return
-		}
-		out, err := json.MarshalIndent(struct {
-			XPath XPath
-			Value []DBValue `json:",omitempty"`
-			Tail  []string  `json:",omitempty"`
-		}{
-			XPath: xpath,
-			Value: val,
-			Tail:  tail,
-		}, "", "  ")
-		if err != nil {
-			fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-			return
-		}
-		fmt.Fprintf(stdout, "%s\n", string(out))
-	})
+	installCommandNoCompletion(mode, "eval-cli",
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			xpath, val, tail, err := ParseXPathCli(dbm, args[1:], []string{}, true)
+			if err != nil {
+				return nil, shiftCommandError(err, 1)
+			}
+			out, err := json.MarshalIndent(struct {
+				XPath XPath
+				Value []DBValue `json:",omitempty"`
+				Tail  []string  `json:",omitempty"`
+			}{
+				XPath: xpath,
+				Value: val,
+				Tail:  tail,
+			}, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(stdout, "%s\n", string(out))
+			return nil, nil
+		})
 
-	installCommandNoCompletion(mode, "eval-xpath", func(args []string) {
-		if len(args) != 2 {
-			err := errors.Errorf("Usage: %s <xpath>", args[0])
-			fmt.Fprintf(stdout, "Error: %s\n", err)
-			return
-		}
-		xp, err := ParseXPathString(dbm, args[1])
-		if err != nil {
-			fmt.Fprintf(stdout, "Error: %s\n", err)
-			return
-		}
-		out, err := json.MarshalIndent(xp, "", "  ")
-		if err != nil {
-			fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-			return
-		}
-		fmt.Fprintf(stdout, "%s\n", string(out))
-	})
+	installCommandNoCompletion(mode, "eval-xpath",
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			if len(args) != 2 {
+				return nil, errors.Errorf("Usage: %s <xpath>", args[0])
+			}
+			xp, err := ParseXPathString(dbm, args[1])
+			if err != nil {
+				return nil, err
+			}
+			out, err := json.MarshalIndent(xp, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(stdout, "%s\n", string(out))
+			return nil, nil
+		})
 }
 
-func installCommandNoCompletion(mode CliMode, match string,
-	f func(args []string)) {
+func installCommandNoCompletion(mode CliMode, match string, f CommandFunc) {
 	cn := getCommandNode(mode)
 	cn.commands = append(cn.commands, Command{m: match, f: f})
 }
 
 func installCommand(mode CliMode, match string, helps []string,
-	f func(args []string)) {
+	f CommandFunc) {
 	installCommandNoCompletion(mode, match, f)
 
 	cn := getCommandNode(mode)
@@ -240,34 +324,19 @@ func installCommands() {
 	installCommand(CliModeView,
 		"configure", []string{
 			"Enable configure mode",
-		},
-		func(args []string) {
-			if cliMode == CliModeConfigure {
-				fmt.Fprintf(stdout, "Already in configure mode\n")
-				return
-			}
-			cliMode = CliModeConfigure
-			dbm.candidateRoot = dbm.root.DeepCopy()
-
-			if agentOpts.BackendMgmtd != nil {
-				// Lock mgmtd datastores
-				for _, dsId := range []*mgmtd.DatastoreId{
-					mgmtd.DatastoreId_RUNNING_DS.Enum(),
-					mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
-				} {
-					if err := mgmtdClient.LockReq(&mgmtd.FeLockDsReq{
-						SessionId: mgmtdClient.GetSessionId(),
-						ReqId:     util.NewUint64Pointer(0),
-						DsId:      dsId,
-						Lock:      util.NewBoolPointer(true),
-					}); err != nil {
-						err := errors.Wrap(err, "LockReq(Lock=true)")
-						fmt.Fprintf(stdout, "Error %v\n", err)
-						return
-					}
-				}
-			}
-		})
+		}, ccbConfigure)
+
+	installCommand(CliModeView,
+		"configure exclusive", []string{
+			"Enable configure mode",
+			"Lock the configuration until leaving configure mode",
+		}, ccbConfigure)
+
+	installCommand(CliModeView,
+		"configure private", []string{
+			"Enable configure mode",
+			"Keep the other sessions from locking the configuration",
+		}, ccbConfigure)
 
 	installCommand(CliModeConfigure,
 		"show configuration running", []string{
@@ -275,34 +344,20 @@ func installCommands() {
 			"Display configuration information",
 			"Display running-configuration information",
 		},
-		func(args []string) {
-			dsId := mgmtd.DatastoreId_RUNNING_DS.Enum()
-			config, err := mgmtdClient.GetReq(&mgmtd.FeGetReq{
-				SessionId: mgmtdClient.GetSessionId(),
-				Config:    util.NewBoolPointer(true),
-				DsId:      dsId,
-				ReqId:     util.NewUint64Pointer(0),
-				Data: []*mgmtd.YangGetDataReq{
-					{
-						Data: &mgmtd.YangData{
-							Xpath: util.NewStringPointer("/"),
-						},
-						NextIndx: util.NewInt64Pointer(0),
-					},
-				},
-			})
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			ctx, cancel := mgmtdContext()
+			defer cancel()
+			config, err := mgmtdClient.GetData(ctx,
+				mgmtd.DatastoreId_RUNNING_DS, true, "/")
 			if err != nil {
-				err := errors.Wrap(err, "mgmtd.GetReq")
-				fmt.Fprintf(stdout, "Error: %v", err)
-				return
+				return nil, errors.Wrap(err, "mgmtd.GetReq")
 			}
 			configJson, err := frrConfigToJson(config)
 			if err != nil {
-				err := errors.Wrap(err, "frrConfigToJson")
-				fmt.Fprintf(stdout, "Error: %s\n", err)
-				return
+				return nil, errors.Wrap(err, "frrConfigToJson")
 			}
 			fmt.Fprintf(stdout, "%s\n", configJson)
+			return nil, nil
 		})
 
 	installCommand(CliModeConfigure,
@@ -311,34 +366,23 @@ func installCommands() {
 			"Display configuration information",
 			"Display candidate-configuration information",
 		},
-		func(args []string) {
-			dsId := mgmtd.DatastoreId_CANDIDATE_DS.Enum()
-			config, err := mgmtdClient.GetReq(&mgmtd.FeGetReq{
-				SessionId: mgmtdClient.GetSessionId(),
-				Config:    util.NewBoolPointer(true),
-				DsId:      dsId,
-				ReqId:     util.NewUint64Pointer(0),
-				Data: []*mgmtd.YangGetDataReq{
-					{
-						Data: &mgmtd.YangData{
-							Xpath: util.NewStringPointer("/"),
-						},
-						NextIndx: util.NewInt64Pointer(0),
-					},
-				},
-			})
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			ctx, cancel := mgmtdContext()
+			defer cancel()
+			if err := mgmtdPushCandidate(ctx); err != nil {
+				return nil, errors.Wrap(err, "mgmtdPushCandidate")
+			}
+			config, err := mgmtdClient.GetData(ctx,
+				mgmtd.DatastoreId_CANDIDATE_DS, true, "/")
 			if err != nil {
-				err := errors.Wrap(err, "mgmtd.GetReq")
-				fmt.Fprintf(stdout, "Error: %s\n", err)
-				return
+				return nil, errors.Wrap(err, "mgmtd.GetReq")
 			}
 			configJson, err := frrConfigToJson(config)
 			if err != nil {
-				err := errors.Wrap(err, "frrConfigToJson")
-				fmt.Fprintf(stdout, "Error: %s\n", err)
-				return
+				return nil, errors.Wrap(err, "frrConfigToJson")
 			}
 			fmt.Fprintf(stdout, "%s\n", configJson)
+			return nil, nil
 		})
 
 	installCommand(CliModeConfigure,
@@ -347,17 +391,19 @@ func installCommands() {
 			"Display configuration information",
 			"Display configuration diff",
 		},
-		func(args []string) {
+		func(ctx context.Context, args []string) (*CommandResult, error) {
 			if agentOpts.BackendMgmtd == nil {
-				diff := DBNodeDiff(&dbm.root, dbm.candidateRoot)
-				fmt.Fprintln(stdout, diff)
+				// The changes of the session, not the ones committed by
+				// the other sessions since configure
+				writeConfigDiff(dbm.candidateBase, dbm.candidateRoot)
 			} else {
-				diff, err := frrConfigDiff()
+				running, candidate, err := frrConfigDiff()
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err)
+					return nil, err
 				}
-				fmt.Fprintln(stdout, diff)
+				writeConfigDiff(running, candidate)
 			}
+			return nil, nil
 		})
 
 	installCommand(CliModeConfigure,
@@ -365,6 +411,18 @@ func installCommands() {
 			"Commit current set of changes",
 		}, ccbCommitCallback)
 
+	installCommand(CliModeConfigure,
+		"commit check", []string{
+			"Commit current set of changes",
+			"Validate current set of changes without applying",
+		}, ccbCommitCheck)
+
+	installCommand(CliModeConfigure,
+		"commit abort", []string{
+			"Commit current set of changes",
+			"Discard current set of changes",
+		}, ccbCommitAbort)
+
 	installCommand(CliModeConfigure,
 		"rollback configuration", []string{
 			"Roll back database to last committed version",
@@ -376,19 +434,40 @@ func installCommands() {
 			"Write system parameter",
 			"Write system parameter to memory",
 		},
-		func(args []string) {
-			if err := dbm.root.WriteToJsonFile(getDatabasePath()); err != nil {
-				fmt.Fprintf(stdout, "Error: %s\n", err.Error())
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			return nil, dbm.root.WriteToJsonFile(getDatabasePath())
+		})
+
+	installCommand(CliModeView,
+		"save running-config", []string{
+			"Save information",
+			"Save running configuration as set commands",
+		},
+		func(ctx context.Context, args []string) (*CommandResult, error) {
+			if len(args) != 3 {
+				fmt.Fprintf(stdout, "Usage: save running-config <file>\n")
+				return nil, nil
 			}
+			if err := checkFileAccess(); err != nil {
+				return nil, err
+			}
+			return nil, writeConfigSetFile(&dbm.root, args[2])
 		})
+	DigNodeOrDie(CliModeView, []string{"save", "running-config"}).Childs =
+		[]*CompletionNode{{
+			Name:        "FILENAME",
+			Description: "Output file name",
+			Childs:      []*CompletionNode{newCR()},
+		}}
 
 	installCommand(CliModeView,
 		"show startup-config", []string{
 			"Display information",
 			"Display startup configuration",
 		},
-		func(args []string) {
+		func(ctx context.Context, args []string) (*CommandResult, error) {
 			fmt.Fprintln(stdout, "not implemented")
+			return nil, nil
 		})
 
 	installCommand(CliModeView,
@@ -407,11 +486,134 @@ func installCommands() {
 			"Display configuration diff with history",
 		}, ccbShowConfigurationCommitDiff)
 
+	installCommand(CliModeView,
+		"show log audit", []string{
+			"Display information",
+			"Display logs",
+			"Display audit log",
+		}, ccbShowLogAudit)
+
+	installCommand(CliModeView,
+		"show notifications", []string{
+			"Display information",
+			"Display notifications in replay buffer",
+		}, ccbShowNotifications)
+
+	installCommand(CliModeView,
+		"monitor notifications", []string{
+			"Monitor events",
+			"Monitor notifications until Ctrl-C",
+		}, ccbMonitorNotifications)
+
+	installCommand(CliModeView,
+		"show configuration lock", []string{
+			"Display information",
+			"Display configuration",
+			"Display configuration locks",
+		}, ccbShowConfigurationLock)
+
+	installCommand(CliModeView,
+		"clear configuration lock", []string{
+			"Reset functions",
+			"Reset configuration",
+			"Release the configuration locks of all sessions",
+		}, ccbClearConfigurationLock)
+
+	installCommand(CliModeView,
+		"show users", []string{
+			"Display information",
+			"Display cli sessions",
+		}, ccbShowUsers)
+
+	installCommand(CliModeView,
+		"clear session", []string{
+			"Reset functions",
+			"Disconnect the cli session",
+		}, ccbClearSession)
+	DigNodeOrDie(CliModeView, []string{"clear", "session"}).Childs =
+		[]*CompletionNode{{
+			Name:        "ID",
+			Description: "Session id shown by show users",
+			Childs:      []*CompletionNode{newCR()},
+		}}
+
+	installCommand(CliModeView,
+		"show mgmtd status", []string{
+			"Display information",
+			"Display mgmtd information",
+			"Display mgmtd connection status",
+		}, ccbShowMgmtdStatus)
+
+	installCommand(CliModeView,
+		"show configuration drift", []string{
+			"Display information",
+			"Display configuration",
+			"Display config changed behind vtyang",
+		}, ccbShowConfigurationDrift)
+
+	installCommand(CliModeView,
+		"configuration drift adopt", []string{
+			"Manipulate configuration",
+			"Resolve config changed behind vtyang",
+			"Import the config of the backend",
+		}, ccbConfigurationDrift)
+
+	installCommand(CliModeView,
+		"configuration drift repush", []string{
+			"Manipulate configuration",
+			"Resolve config changed behind vtyang",
+			"Push the running config to the backend",
+		}, ccbConfigurationDrift)
+
+	for _, mode := range []struct {
+		mode loadMode
+		help string
+	}{
+		{loadModeMerge, "Merge the file into the candidate"},
+		{loadModeReplace, "Replace the top-level containers in the file"},
+		{loadModeOverride, "Replace the whole candidate with the file"},
+		{loadModeSet, "Execute the set and delete commands of the file"},
+	} {
+		installCommand(CliModeConfigure,
+			"load "+string(mode.mode), []string{
+				"Load configuration from a file",
+				mode.help,
+			}, ccbLoad)
+		DigNodeOrDie(CliModeConfigure, []string{"load", string(mode.mode)}).Childs =
+			[]*CompletionNode{{
+				Name:        "FILENAME",
+				Description: "Config file in JSON, XML or set commands",
+				Childs:      []*CompletionNode{newCR()},
+			}}
+	}
+
+	installCommand(CliModeConfigure,
+		"up", []string{
+			"Move up to the parent of the edit context",
+		}, ccbUp)
+	DigNodeOrDie(CliModeConfigure, []string{"up"}).Childs =
+		[]*CompletionNode{newCR(), {
+			Name:        "COUNT",
+			Description: "Number of levels to move up",
+			Childs:      []*CompletionNode{newCR()},
+		}}
+
+	installCommand(CliModeConfigure,
+		"top", []string{
+			"Move to the top of the configuration",
+		}, ccbTop)
+
+	installCommand(CliModeConfigure,
+		"exit", []string{
+			"Return to the previous edit context or leave configure mode",
+		}, ccbExit)
+
 	installCommand(CliModeConfigure, "do",
 		[]string{"Run an operational-mode command"},
-		func(args []string) {
+		func(ctx context.Context, args []string) (*CommandResult, error) {
 			cn := getCommandNode(CliModeView)
-			cn.executeCommand(cat(args[1:]))
+			result, err := cn.execute(ctx, joinCommandLine(args[1:]))
+			return result, shiftCommandError(err, 1)
 		})
 	viewRoot := getCommandNode(CliModeView).tree.Root
 	confRoot := getCommandNode(CliModeConfigure).tree.Root
@@ -499,6 +701,8 @@ func (t *CompletionTree) Completion(line string, pos int) []*CompletionNode {
 				result = append(result, node)
 			case "VALUE":
 				result = append(result, node)
+			case "FILENAME", "COUNT", "ID":
+				result = append(result, node)
 
 			// // TODO(slankdev)
 			// case "INTEGER":
@@ -574,9 +778,14 @@ func DigNodeOrDie(mode CliMode, query []string) *CompletionNode {
 }
 
 func completer(line string, pos int) (string, []string, string) {
+	return completeWords(line, pos, doCompletion(line, pos).Items)
+}
+
+// completeWords returns the words completing the line at pos for liner,
+// from the completion items of the line.
+func completeWords(line string, pos int, items []CompletionItem) (string, []string, string) {
 	names := []string{}
-	result := doCompletion(line, pos)
-	for _, item := range result.Items {
+	for _, item := range items {
 		names = append(names, item.Word)
 	}
 
@@ -596,11 +805,11 @@ func completer(line string, pos int) (string, []string, string) {
 		return pre, nil, line[pos:]
 	}
 
-	if len(names) > 0 && names[len(names)-1] == "NAME" {
-		return pre, nil, line[pos:]
-	}
-	if len(names) > 0 && names[len(names)-1] == "VALUE" {
-		return pre, nil, line[pos:]
+	if len(names) > 0 {
+		switch names[len(names)-1] {
+		case "NAME", "VALUE", "REGEX", "FILENAME", "COUNT", "ID":
+			return pre, nil, line[pos:]
+		}
 	}
 
 	if len(names) == 1 {
@@ -627,6 +836,11 @@ type CompletionItem struct {
 
 func doCompletion(line string, pos int) CompletionResult {
 	ret := CompletionResult{}
+	line, pos = editCompletionLine(line, pos)
+	if items, ok := completeOutputPipes(line, pos); ok {
+		ret.Items = items
+		return ret
+	}
 	nodes := getCommandNodeCurrent().tree.Completion(line, pos)
 	items := []CompletionItem{}
 
@@ -795,6 +1009,18 @@ func doCompletion(line string, pos int) CompletionResult {
 	//   dum1   configured in running-ds
 	//   dum4   configured in candidate-ds
 
+	// Output pipes of the show commands
+	showArgs := args
+	if len(showArgs) > 0 && showArgs[0] == "do" {
+		showArgs = showArgs[1:]
+	}
+	if tailSpace && len(showArgs) > 1 && showArgs[0] == "show" {
+		items = append(items, CompletionItem{
+			Word:   "|",
+			Helper: "Output modifiers",
+		})
+	}
+
 	// Return result
 	sort.Slice(items, func(i, j int) bool {
 		return items[i].Word < items[j].Word
@@ -804,14 +1030,18 @@ func doCompletion(line string, pos int) CompletionResult {
 }
 
 func completionLister(line string, pos int) {
-	result := doCompletion(line, pos)
-	if len(result.Items) == 0 {
-		fmt.Fprintf(stdout, "\n%% Invalid input detected\n")
+	writeCompletionItems(stdout, doCompletion(line, pos).Items)
+}
+
+// writeCompletionItems displays the completion items for '?'.
+func writeCompletionItems(w io.Writer, items []CompletionItem) {
+	if len(items) == 0 {
+		fmt.Fprintf(w, "\n%% Invalid input detected\n")
 		return
 	}
 
 	longestnamelen := 0
-	for _, item := range result.Items {
+	for _, item := range items {
 		if len(item.Word) > longestnamelen {
 			longestnamelen = len(item.Word)
 		}
@@ -824,9 +1054,9 @@ func completionLister(line string, pos int) {
 		return retStr
 	}
 
-	fmt.Fprintf(stdout, "\nPossible Completions:\n")
-	for _, item := range result.Items {
-		fmt.Fprintf(stdout, "  %s%s  %s\n", item.Word,
+	fmt.Fprintf(w, "\nPossible Completions:\n")
+	for _, item := range items {
+		fmt.Fprintf(w, "  %s%s  %s\n", item.Word,
 			padding(item.Word, longestnamelen),
 			item.Helper)
 	}
@@ -840,6 +1070,11 @@ type CommitHistory struct {
 	Comment   string
 }
 
+// Id returns the commit id shown in the commit list.
+func (h CommitHistory) Id() string {
+	return strconv.FormatInt(h.Timestamp.UnixNano(), 10)
+}
+
 func initCommitHistories() {
 	if GlobalOptRunFilePath != "" {
 		files, err := os.ReadDir(GlobalOptRunFilePath)
@@ -922,18 +1157,115 @@ func (h CommitHistory) WriteToFile(basepath string) error {
 	return nil
 }
 
-func ccbCommitCallback(args []string) {
+// ccbConfigure enters configure mode with the candidate of the session.
+// The exclusive and private modes lock the running datastore until leaving
+// configure mode.
+func ccbConfigure(ctx context.Context, args []string) (*CommandResult, error) {
+	if cliMode == CliModeConfigure {
+		fmt.Fprintf(stdout, "Already in configure mode\n")
+		return nil, nil
+	}
 	if agentOpts.BackendMgmtd != nil {
-		if err := mgmtdClient.CommitConfig(&mgmtd.FeCommitConfigReq{
-			SessionId:    mgmtdClient.GetSessionId(),
-			ReqId:        util.NewUint64Pointer(0),
-			SrcDsId:      mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
-			DstDsId:      mgmtd.DatastoreId_RUNNING_DS.Enum(),
-			ValidateOnly: util.NewBoolPointer(false),
-			Abort:        util.NewBoolPointer(false),
-		}); err != nil {
-			err := errors.Wrap(err, "mgmtd.WriteProtoBufMsg")
-			fmt.Fprintf(stdout, "Error: %s", err)
+		// The candidate datastore of mgmtd is shared by sessions
+		if s := configuringSession(); s != nil {
+			return nil, errors.Errorf("session %d is in configure mode", s.Id)
+		}
+	}
+	if len(args) > 1 {
+		mode := LockModeExclusive
+		switch args[1] {
+		case "exclusive":
+		case "private":
+			mode = LockModeShared
+		default:
+			return nil, &CommandError{
+				ArgPos: 1,
+				Err:    errors.Errorf("unknown configure mode %s", args[1]),
+			}
+		}
+		if len(args) > 2 {
+			return nil, &CommandError{
+				ArgPos: 2,
+				Err:    errors.Errorf("unexpected argument %s", args[2]),
+			}
+		}
+		err := lockDatastore(currentSession, DatastoreRunning, mode, true)
+		if err != nil {
+			return nil, err
+		}
+	} else if err := checkDatastoreLock(currentSession,
+		DatastoreRunning); err != nil {
+		fmt.Fprintf(stdout, "Warning: %s, commit is not allowed\n", err)
+	}
+	cliMode = CliModeConfigure
+	dbm.candidateRoot = dbm.root.DeepCopy()
+	dbm.candidateBase = dbm.root.DeepCopy()
+	mgmtdPushed = nil
+	resetEditContext()
+
+	if agentOpts.BackendMgmtd != nil {
+		// Lock mgmtd datastores
+		ctx, cancel := mgmtdContext()
+		defer cancel()
+		if err := mgmtdLockDatastores(ctx); err != nil {
+			// Stay in view mode
+			cliMode = CliModeView
+			dbm.candidateRoot = nil
+			dbm.candidateBase = nil
+			releaseDatastoreLocks(currentSession, true)
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// ccbCommitCheck validates the candidate on the backend. The candidate of
+// vtyang itself is validated on each set command.
+func ccbCommitCheck(ctx context.Context, args []string) (*CommandResult, error) {
+	if agentOpts.BackendMgmtd != nil {
+		ctx, cancel := mgmtdContext()
+		defer cancel()
+		if err := mgmtdPushCandidate(ctx); err != nil {
+			return nil, errors.Wrap(err, "mgmtdPushCandidate")
+		}
+		if err := mgmtdClient.ValidateConfig(ctx); err != nil {
+			return nil, errors.Wrap(err, "mgmtd.ValidateConfig")
+		}
+	}
+	fmt.Fprintf(stdout, "configuration check succeeds\n")
+	return nil, nil
+}
+
+// ccbCommitAbort discards the candidate and restarts it from running.
+func ccbCommitAbort(ctx context.Context, args []string) (*CommandResult, error) {
+	if agentOpts.BackendMgmtd != nil {
+		ctx, cancel := mgmtdContext()
+		defer cancel()
+		if err := mgmtdClient.AbortConfig(ctx); err != nil {
+			return nil, errors.Wrap(err, "mgmtd.AbortConfig")
+		}
+		mgmtdPushed = nil
+	}
+	dbm.candidateRoot = dbm.root.DeepCopy()
+	dbm.candidateBase = dbm.root.DeepCopy()
+	return nil, nil
+}
+
+func ccbCommitCallback(ctx context.Context, args []string) (*CommandResult, error) {
+	if err := checkDatastoreLock(currentSession, DatastoreRunning); err != nil {
+		return nil, err
+	}
+	if err := mergeCommittedChanges(); err != nil {
+		return nil, err
+	}
+	if agentOpts.BackendMgmtd != nil {
+		ctx, cancel := mgmtdContext()
+		defer cancel()
+		if err := mgmtdPushCandidate(ctx); err != nil {
+			return nil, errors.Wrap(err, "mgmtdPushCandidate")
+		}
+		if err := mgmtdCommit(ctx); err != nil {
+			return nil, errors.Wrap(err, "mgmtd.CommitConfig")
 		}
 	}
 
@@ -945,10 +1277,47 @@ func ccbCommitCallback(args []string) {
 		Before:    dbm.root.String(),
 		After:     dbm.candidateRoot.String(),
 		Client:    "cli",
+		Comment:   "-",
 		Timestamp: time.Now(),
 	}
-	if h.Comment == "" {
-		h.Comment = "-"
+	// NOTE(slankdev): mgmtd has committed already when applyCommit fails,
+	// the difference is reported as the drift of mgmtd.
+	cliMode = CliModeConfigure
+	base := dbm.candidateBase
+	dbm.candidateBase = dbm.candidateRoot.DeepCopy()
+	if err := applyCommit(h, dbm.candidateRoot.DeepCopy()); err != nil {
+		dbm.candidateBase = base
+		return nil, err
+	}
+	return nil, nil
+}
+
+// commitExternal records the config changed outside of the cli, such as
+// the config imported from a backend, and replaces the running config.
+func commitExternal(root *DBNode, client, comment string) error {
+	if err := checkDatastoreLock(currentSession, DatastoreRunning); err != nil {
+		return err
+	}
+	h := CommitHistory{
+		Before:    dbm.root.String(),
+		After:     root.String(),
+		Client:    client,
+		Comment:   comment,
+		Timestamp: time.Now(),
+	}
+	return applyCommit(h, root)
+}
+
+// applyCommit replaces the running config with root and records the commit
+// h. The config change event is emitted only after the running config is
+// written, the running config is restored and the commit is not recorded
+// when it fails.
+func applyCommit(h CommitHistory, root *DBNode) error {
+	before := dbm.root
+	dbm.root = *root
+	if err := dbm.root.WriteToJsonFile(getDatabasePath()); err != nil {
+		dbm.root = before
+		return errors.Wrap(err, "WriteToJsonFile")
 	}
 	commitHistories = append([]CommitHistory{h}, commitHistories...)
 	if GlobalOptRunFilePath != "" {
@@ -956,43 +1325,45 @@ func ccbCommitCallback(args []string) {
 			fmt.Fprintf(stdout, "Warning: %s ... ignored\n", err.Error())
 		}
 	}
-
-	cliMode = CliModeConfigure
-	dbm.root = *dbm.candidateRoot.DeepCopy()
-	if err := dbm.root.WriteToJsonFile(getDatabasePath()); err != nil {
-		fmt.Fprintf(stdout, "Error: %s\n", err.Error())
+	auditCommit(h.Id())
+	if err := emitConfigChange(h, &before, &dbm.root); err != nil {
+		fmt.Fprintf(stdout, "Warning: %s ... ignored\n", err.Error())
 	}
+	pushAgentConfig(&dbm.root)
+	return nil
 }
 
-func ccbShowConfigurationCommitList(args []string) {
+func ccbShowConfigurationCommitList(ctx context.Context, args []string) (*CommandResult, error) {
 	if len(args) > 4 {
 		idx, err := strconv.Atoi(args[4])
 		if err != nil {
-			fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-			return
+			return nil, err
 		}
 		if len(commitHistories) < idx {
-			fmt.Fprintf(stdout, "Error: Invalid commit idx\n")
-			return
+			return nil, errors.Errorf("Invalid commit idx")
 		}
 		history := commitHistories[idx]
-		na, err1 := ReadFromJsonString(history.Before)
-		nb, err2 := ReadFromJsonString(history.After)
-		if err1 != nil || err2 != nil {
-			fmt.Fprintf(stdout, "Error1: %s\n", err1.Error())
-			fmt.Fprintf(stdout, "Error2: %s\n", err2.Error())
-			return
+		na, err := ReadFromJsonString(history.Before)
+		if err != nil {
+			return nil, err
+		}
+		nb, err := ReadFromJsonString(history.After)
+		if err != nil {
+			return nil, err
 		}
-		diff := DBNodeDiff(na, nb)
-		fmt.Fprintln(stdout, diff)
+		writeConfigDiff(na, nb)
 
+	} else if useOutputFormat(outputFormatJSON) == outputFormatJSON {
+		if err := writeCommitHistoriesJSON(commitHistories); err != nil {
+			return nil, err
+		}
 	} else {
 		table := newTable()
 		table.SetHeader([]string{"Idx", "ID", "Timestamp", "Client", "Comment"})
 		for idx, history := range commitHistories {
 			table.Append([]string{
 				strconv.Itoa(idx),
-				strconv.FormatInt(history.Timestamp.UnixNano(), 10),
+				history.Id(),
 				history.Timestamp.Format("2006-01-02 15:04:05"),
 				history.Client,
 				history.Comment,
@@ -1000,59 +1371,85 @@ func ccbShowConfigurationCommitList(args []string) {
 		}
 		table.Render()
 	}
+	return nil, nil
+}
+
+// commitHistoryJSON is the commit list displayed by "| json".
+type commitHistoryJSON struct {
+	Idx       int    `json:"idx"`
+	Id        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Client    string `json:"client"`
+	Comment   string `json:"comment"`
+}
+
+func writeCommitHistoriesJSON(histories []CommitHistory) error {
+	list := []commitHistoryJSON{}
+	for idx, h := range histories {
+		list = append(list, commitHistoryJSON{
+			Idx:       idx,
+			Id:        h.Id(),
+			Timestamp: h.Timestamp.Format("2006-01-02 15:04:05"),
+			Client:    h.Client,
+			Comment:   h.Comment,
+		})
+	}
+	out, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, string(out))
+	return nil
 }
 
-func ccbShowConfigurationCommitDiff(args []string) {
+func ccbShowConfigurationCommitDiff(ctx context.Context, args []string) (*CommandResult, error) {
 	if len(args) < 4 {
 		fmt.Fprintf(stdout, "Usage\n")
-		return
+		return nil, nil
 	}
 
 	idx, err := strconv.Atoi(args[4])
 	if err != nil {
-		fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-		return
+		return nil, err
 	}
 	if len(commitHistories) < idx {
-		fmt.Fprintf(stdout, "Error: Invalid commit idx\n")
-		return
+		return nil, errors.Errorf("Invalid commit idx")
 	}
 
 	history := commitHistories[idx]
 	node, err := history.ToDBNode()
 	if err != nil {
-		fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-		return
+		return nil, err
 	}
 
-	fmt.Fprintln(stdout, DBNodeDiff(&dbm.root, node))
+	writeConfigDiff(&dbm.root, node)
+	return nil, nil
 }
 
-func ccbRollbackConfiguration(args []string) {
+func ccbRollbackConfiguration(ctx context.Context, args []string) (*CommandResult, error) {
 	if len(args) < 3 {
 		fmt.Fprintf(stdout, "Usage: rollback configuration <idx>\n")
-		return
+		return nil, nil
 	}
 	idxArg := args[2]
 
 	idx, err := strconv.Atoi(idxArg)
 	if err != nil {
-		fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-		return
+		return nil, err
 	}
 	if len(commitHistories) < idx {
-		fmt.Fprintf(stdout, "Error: Invalid commit idx\n")
-		return
+		return nil, errors.Errorf("Invalid commit idx")
 	}
 
 	history := commitHistories[idx]
 	node, err := history.ToDBNode()
 	if err != nil {
-		fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-		return
+		return nil, err
 	}
 
 	dbm.candidateRoot = node.DeepCopy()
+	auditRollback(history.Id())
+	return nil, nil
 }
 
 func dumpCompletionTreeJson(root *CompletionNode) string {
@@ -1094,7 +1491,23 @@ func yangModulesPath(paths []string) (*yang.Modules, error) {
 	return modules, nil
 }
 
+// mgmtdTimeout is the timeout of a request to mgmtd from cli.
+var mgmtdTimeout = 30 * time.Second
+
+// mgmtdContext returns the context for a request to mgmtd from cli.
+func mgmtdContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), mgmtdTimeout)
+}
+
 func frrConfigToJson(config []*mgmtd.YangData) ([]byte, error) {
+	out, err := frrConfigToDBNode(config)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out.String()), nil
+}
+
+func frrConfigToDBNode(config []*mgmtd.YangData) (*DBNode, error) {
 	yd := []YangData{}
 	for _, data := range config {
 		xp, err := ParseXPathString(dbm, *data.Xpath)
@@ -1111,50 +1524,33 @@ func frrConfigToJson(config []*mgmtd.YangData) ([]byte, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "CraftDBNode")
 	}
-	return []byte(out.String()), nil
+	return out, nil
 }
 
-func frrConfigDiff() (string, error) {
-	configs := [2][]*mgmtd.YangData{}
-	for idx, dsId := range []*mgmtd.DatastoreId{
-		mgmtd.DatastoreId_RUNNING_DS.Enum(),
-		mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+// frrConfigDiff returns the running and candidate config of mgmtd after
+// pushing the candidate of vtyang.
+func frrConfigDiff() (*DBNode, *DBNode, error) {
+	ctx, cancel := mgmtdContext()
+	defer cancel()
+	if err := mgmtdPushCandidate(ctx); err != nil {
+		return nil, nil, errors.Wrap(err, "mgmtdPushCandidate")
+	}
+	nodes := [2]*DBNode{}
+	for idx, dsId := range []mgmtd.DatastoreId{
+		mgmtd.DatastoreId_RUNNING_DS,
+		mgmtd.DatastoreId_CANDIDATE_DS,
 	} {
-		_, _ = idx, dsId
-		config, err := mgmtdClient.GetReq(&mgmtd.FeGetReq{
-			SessionId: mgmtdClient.GetSessionId(),
-			Config:    util.NewBoolPointer(true),
-			DsId:      dsId,
-			ReqId:     util.NewUint64Pointer(0),
-			Data: []*mgmtd.YangGetDataReq{
-				{
-					Data: &mgmtd.YangData{
-						Xpath: util.NewStringPointer("/"),
-					},
-					NextIndx: util.NewInt64Pointer(0),
-				},
-			},
-		})
+		config, err := mgmtdClient.GetData(ctx, dsId, true, "/")
 		if err != nil {
-			err := errors.Wrap(err, "mgmtd.GetReq")
-			return "", err
+			return nil, nil, errors.Wrap(err, "mgmtd.GetReq")
 		}
-		configs[idx] = config
-	}
-
-	jsonRunningConfig, err := frrConfigToJson(configs[0])
-	if err != nil {
-		return "", err
-	}
-	jsonCandidateConfig, err := frrConfigToJson(configs[1])
-	if err != nil {
-		return "", err
+		node, err := frrConfigToDBNode(config)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "frrConfigToDBNode")
+		}
+		nodes[idx] = node
 	}
-	opts := jsondiff.DefaultConsoleOptions()
-	opts.Indent = "  "
-	_, diff := jsondiff.Compare(jsonRunningConfig,
-		jsonCandidateConfig, &opts)
-	return diff, nil
+	return nodes[0], nodes[1], nil
 }
 
 func resolveListKeyCompletionItems(tailArg string,
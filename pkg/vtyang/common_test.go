@@ -15,13 +15,19 @@ const (
 )
 
 type TestCase struct {
-	YangPath       string
+	YangPath string
+	// ExtraYangPaths are loaded with YangPath (e.g. the vtyang only modules
+	// with the FRR ones)
+	ExtraYangPaths []string
 	RuntimePath    string
 	LogFile        string
 	Inputs         []string
 	OutputString   string
 	OutputFile     string
 	InitConfigFile string
+	BackendMgmtd   *AgentOptsBackendMgmtd
+	// Setup is called after the agent is initialized
+	Setup func(t *testing.T)
 }
 
 func executeTestCase(t *testing.T, tc *TestCase) {
@@ -50,12 +56,16 @@ func executeTestCase(t *testing.T, tc *TestCase) {
 
 	// Initializing Agent
 	if err := InitAgent(AgentOpts{
-		RuntimePath: tc.RuntimePath,
-		YangPath:    []string{tc.YangPath},
-		LogFile:     tc.LogFile,
+		RuntimePath:  tc.RuntimePath,
+		YangPath:     append([]string{tc.YangPath}, tc.ExtraYangPaths...),
+		LogFile:      tc.LogFile,
+		BackendMgmtd: tc.BackendMgmtd,
 	}); err != nil {
 		t.Fatal(err)
 	}
+	if tc.Setup != nil {
+		tc.Setup(t)
+	}
 
 	// Execute Test commands
 	buf := setStdoutWithBuffer()
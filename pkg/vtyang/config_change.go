@@ -0,0 +1,263 @@
+package vtyang
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ConfigChangeOperationCreate  = "create"
+	ConfigChangeOperationMerge   = "merge"
+	ConfigChangeOperationReplace = "replace"
+	ConfigChangeOperationDelete  = "delete"
+)
+
+// ConfigChangeEdit is one changed node of the commit.
+type ConfigChangeEdit struct {
+	Target    string `json:"target"`
+	Operation string `json:"operation"`
+}
+
+// ConfigChangeEvent is emitted on every commit. It follows the
+// netconf-config-change notification of ietf-netconf-notifications
+// (RFC6470) with the commit id in addition.
+type ConfigChangeEvent struct {
+	CommitId  string             `json:"commit-id"`
+	Username  string             `json:"username"`
+	Client    string             `json:"client"`
+	Datastore string             `json:"datastore"`
+	Timestamp time.Time          `json:"timestamp"`
+	Edits     []ConfigChangeEdit `json:"edit"`
+}
+
+var (
+	configChangeSubs     = map[int]chan ConfigChangeEvent{}
+	configChangeSubId    int
+	configChangeLock     sync.Mutex
+	configChangeListener net.Listener
+	configChangeWebhook  string
+	configChangeTimeout  = 3 * time.Second
+)
+
+// SubscribeConfigChange returns a channel receiving config change events
+// and a function to cancel the subscription. Events are dropped when the
+// subscriber doesn't read them in time.
+func SubscribeConfigChange(size int) (<-chan ConfigChangeEvent, func()) {
+	configChangeLock.Lock()
+	defer configChangeLock.Unlock()
+	configChangeSubId++
+	id := configChangeSubId
+	ch := make(chan ConfigChangeEvent, size)
+	configChangeSubs[id] = ch
+	return ch, func() {
+		configChangeLock.Lock()
+		defer configChangeLock.Unlock()
+		if _, ok := configChangeSubs[id]; ok {
+			close(ch)
+			delete(configChangeSubs, id)
+		}
+	}
+}
+
+func resetConfigChange() {
+	stopConfigChangeServer()
+	configChangeLock.Lock()
+	defer configChangeLock.Unlock()
+	for id, ch := range configChangeSubs {
+		close(ch)
+		delete(configChangeSubs, id)
+	}
+	configChangeWebhook = ""
+}
+
+// configChangeEdits returns the edits to transform before into after. The
+// targets are the leaves and leaf-lists, sorted by xpath. A changed
+// leaf-list is reported as replace since all of its items are replaced.
+func configChangeEdits(before, after *DBNode) ([]ConfigChangeEdit, error) {
+	b, err := flattenDBNode(before)
+	if err != nil {
+		return nil, errors.Wrap(err, "flattenDBNode(before)")
+	}
+	a, err := flattenDBNode(after)
+	if err != nil {
+		return nil, errors.Wrap(err, "flattenDBNode(after)")
+	}
+	leafLists := map[string]bool{}
+	if err := walkDBNode(after, func(xpath XPath, n *DBNode) error {
+		if n.Type == LeafList {
+			leafLists[xpath.String()] = true
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "walkDBNode")
+	}
+	edits := []ConfigChangeEdit{}
+	for xpath, av := range a {
+		bv, ok := b[xpath]
+		switch {
+		case !ok:
+			edits = append(edits, ConfigChangeEdit{
+				Target:    xpath,
+				Operation: ConfigChangeOperationCreate,
+			})
+		case av != bv && leafLists[xpath]:
+			edits = append(edits, ConfigChangeEdit{
+				Target:    xpath,
+				Operation: ConfigChangeOperationReplace,
+			})
+		case av != bv:
+			edits = append(edits, ConfigChangeEdit{
+				Target:    xpath,
+				Operation: ConfigChangeOperationMerge,
+			})
+		}
+	}
+	for xpath := range b {
+		if _, ok := a[xpath]; !ok {
+			edits = append(edits, ConfigChangeEdit{
+				Target:    xpath,
+				Operation: ConfigChangeOperationDelete,
+			})
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].Target < edits[j].Target
+	})
+	return edits, nil
+}
+
+func currentUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// emitConfigChange builds the event of the commit h and delivers it to the
+// subscribers, the event stream socket and the webhook.
+func emitConfigChange(h CommitHistory, before, after *DBNode) error {
+	edits, err := configChangeEdits(before, after)
+	if err != nil {
+		return err
+	}
+	ev := ConfigChangeEvent{
+		CommitId:  h.Id(),
+		Username:  sessionUsername(),
+		Client:    h.Client,
+		Datastore: "running",
+		Timestamp: h.Timestamp,
+		Edits:     edits,
+	}
+
+	configChangeLock.Lock()
+	for id, ch := range configChangeSubs {
+		select {
+		case ch <- ev:
+		default:
+			log.Printf("config change subscriber %d is slow, dropped %s\n",
+				id, ev.CommitId)
+		}
+	}
+	webhook := configChangeWebhook
+	configChangeLock.Unlock()
+
+	if webhook != "" {
+		go postConfigChange(webhook, ev)
+	}
+	return nil
+}
+
+func postConfigChange(url string, ev ConfigChangeEvent) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("config change webhook: %s\n", err)
+		return
+	}
+	client := http.Client{Timeout: configChangeTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("config change webhook: %s\n", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Printf("config change webhook: %s returned %s\n", url, resp.Status)
+	}
+}
+
+// startConfigChangeServer serves the event stream on unix socket. Each
+// client receives the events as json lines until it disconnects.
+func startConfigChangeServer(sockpath string) error {
+	stopConfigChangeServer()
+	if err := os.RemoveAll(sockpath); err != nil {
+		return errors.Wrapf(err, "os.RemoveAll(%s)", sockpath)
+	}
+	lis, err := net.Listen("unix", sockpath)
+	if err != nil {
+		return errors.Wrapf(err, "net.Listen(%s)", sockpath)
+	}
+	configChangeListener = lis
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				log.Printf("config change server stopped: %s\n", err)
+				return
+			}
+			go serveConfigChangeConn(conn)
+		}
+	}()
+	log.Printf("config change server listening on %s\n", sockpath)
+	return nil
+}
+
+func stopConfigChangeServer() {
+	if configChangeListener != nil {
+		configChangeListener.Close()
+		configChangeListener = nil
+	}
+}
+
+func serveConfigChangeConn(conn net.Conn) {
+	defer conn.Close()
+	ch, cancel := SubscribeConfigChange(64)
+	defer cancel()
+
+	// Detect disconnection of the client
+	closed := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(conn)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := enc.Encode(ev); err != nil {
+				log.Printf("config change client disconnected: %s\n", err)
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package vtyang
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfigChange(t *testing.T) {
+	sockpath := "/tmp/run/vtyang/config_change.sock"
+	webhookCh := make(chan ConfigChangeEvent, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ev := ConfigChangeEvent{}
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &ev); err != nil {
+				t.Error(err)
+			}
+			webhookCh <- ev
+		}))
+	defer webhook.Close()
+
+	var subCh <-chan ConfigChangeEvent
+	var sockReader *bufio.Reader
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/basic",
+		InitConfigFile: "./testdata/config_change_config.json",
+		OutputFile:     "./testdata/output/TestConfigChange.txt",
+		Setup: func(t *testing.T) {
+			configChangeWebhook = webhook.URL
+			if err := startConfigChangeServer(sockpath); err != nil {
+				t.Fatal(err)
+			}
+			conn, err := net.Dial("unix", sockpath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sockReader = bufio.NewReader(conn)
+			subCh, _ = SubscribeConfigChange(1)
+
+			// Wait subscription of the socket client
+			for i := 0; ; i++ {
+				configChangeLock.Lock()
+				n := len(configChangeSubs)
+				configChangeLock.Unlock()
+				if n == 2 {
+					break
+				}
+				if i > 100 {
+					t.Fatal("client not subscribed")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		},
+		Inputs: []string{
+			"configure",
+			"set values u08 10",
+			"set values name vtyang1",
+			"delete values items item1 foo",
+			"commit",
+		},
+	})
+	defer stopConfigChangeServer()
+
+	expected := []ConfigChangeEdit{
+		{Target: "/main:values/main:items/main:item1[name='foo']/main:description", Operation: "delete"},
+		{Target: "/main:values/main:items/main:item1[name='foo']/main:name", Operation: "delete"},
+		{Target: "/main:values/main:name", Operation: "merge"},
+		{Target: "/main:values/main:u08", Operation: "create"},
+	}
+	ev := <-subCh
+	if !reflect.DeepEqual(ev.Edits, expected) {
+		t.Errorf("unexpected edits %+v", ev.Edits)
+	}
+	if ev.CommitId != commitHistories[0].Id() || ev.Client != "cli" ||
+		ev.Datastore != "running" || ev.Username == "" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+
+	line, err := sockReader.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	sockEv := ConfigChangeEvent{}
+	if err := json.Unmarshal(line, &sockEv); err != nil {
+		t.Fatal(err)
+	}
+	if sockEv.CommitId != ev.CommitId || !reflect.DeepEqual(sockEv.Edits, expected) {
+		t.Errorf("unexpected event from socket %+v", sockEv)
+	}
+
+	select {
+	case webhookEv := <-webhookCh:
+		if webhookEv.CommitId != ev.CommitId ||
+			!reflect.DeepEqual(webhookEv.Edits, expected) {
+			t.Errorf("unexpected event from webhook %+v", webhookEv)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("webhook is not called")
+	}
+}
+
+// The event isn't emitted when the running config can't be written
+func TestConfigChangeCommitFailure(t *testing.T) {
+	if err := os.RemoveAll("/tmp/run/vtyang/config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitAgent(AgentOpts{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/basic"},
+		LogFile:     agentTestDefaultLogFile,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	setStdoutWithBuffer()
+	subCh, cancel := SubscribeConfigChange(1)
+	defer cancel()
+	for _, cli := range []string{"configure", "set values u08 10"} {
+		if err := getCommandNodeCurrent().runCommand(context.Background(),
+			cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(path string) { GlobalOptRunFilePath = path }(GlobalOptRunFilePath)
+	GlobalOptRunFilePath = "/tmp/run/vtyang/not-found"
+	histories := len(commitHistories)
+	running := dbm.root.String()
+	base := dbm.candidateBase.String()
+	if err := getCommandNodeCurrent().runCommand(context.Background(),
+		"commit"); err == nil {
+		t.Error("commit succeeded")
+	}
+	if len(commitHistories) != histories {
+		t.Errorf("commit is recorded")
+	}
+	if dbm.root.String() != running || dbm.candidateBase.String() != base {
+		t.Errorf("running config is changed %s", dbm.root.String())
+	}
+	select {
+	case ev := <-subCh:
+		t.Errorf("unexpected event %+v", ev)
+	default:
+	}
+}
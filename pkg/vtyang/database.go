@@ -7,6 +7,7 @@ import (
 	"math/big"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -22,6 +23,10 @@ type DatabaseManager struct {
 	root DBNode
 	// candidateRoot is the top of candidate config
 	candidateRoot *DBNode
+	// candidateBase is the running config the candidate started from. The
+	// changes committed by the other sessions since then are merged on
+	// commit.
+	candidateBase *DBNode
 }
 
 func NewDatabaseManager() *DatabaseManager {
@@ -50,12 +55,25 @@ func (m *DatabaseManager) LoadDatabaseFromFile(f string) error {
 	return nil
 }
 
+// yangModuleDumpEntries returns the top-level entries of all modules. They
+// are ordered by module and entry name, so that the module of the
+// containers defined in multiple modules is resolved consistently.
 func yangModuleDumpEntries() []*yang.Entry {
+	names := []string{}
+	for name := range yangmodules.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 	entries := []*yang.Entry{}
-	for _, m := range yangmodules.Modules {
-		ent := yang.ToEntry(m)
-		for _, e := range ent.Dir {
-			entries = append(entries, e)
+	for _, name := range names {
+		ent := yang.ToEntry(yangmodules.Modules[name])
+		dirs := []string{}
+		for dir := range ent.Dir {
+			dirs = append(dirs, dir)
+		}
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			entries = append(entries, ent.Dir[dir])
 		}
 	}
 	return entries
@@ -161,6 +179,7 @@ func (dbm *DatabaseManager) DeleteNode(xpath XPath) error {
 				default:
 					panic(fmt.Sprintf("ASSERT(%s)", child.Type))
 				}
+				break
 			}
 		}
 		if !found {
@@ -414,18 +433,8 @@ func matchChild(root *DBNode, kv map[string]XWordKey) bool {
 
 func lookupChildIdx(root *DBNode, kv map[string]XWordKey) int {
 	for idx := range root.Childs {
-		child := &root.Childs[idx]
-		for idx2 := range child.Childs {
-			child2 := &child.Childs[idx2]
-			nMatch := 0
-			for k, v := range kv {
-				if child2.Name == k && reflect.DeepEqual(child2.Value, v.Value) {
-					nMatch++
-				}
-			}
-			if len(kv) == nMatch {
-				return idx
-			}
+		if matchChild(&root.Childs[idx], kv) {
+			return idx
 		}
 	}
 	return -1
@@ -614,6 +623,8 @@ func Interface2DBNode(i interface{}) (*DBNode, error) {
 	n := &DBNode{}
 	switch g := i.(type) {
 	case map[string]interface{}:
+		// The empty object is the empty container
+		n.Type = Container
 		keys := util.GetSortedKeys(g)
 		for _, k := range keys {
 			v := g[k]
@@ -621,7 +632,6 @@ func Interface2DBNode(i interface{}) (*DBNode, error) {
 			if err != nil {
 				return nil, err
 			}
-			n.Type = Container
 			child.Name = k
 			n.Childs = append(n.Childs, *child)
 		}
@@ -0,0 +1,451 @@
+package vtyang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+)
+
+// configTextIndent is the indentation of a level of the hierarchical view.
+const configTextIndent = "    "
+
+// configTextOpts is the options of the hierarchical view.
+type configTextOpts struct {
+	// defaults displays the leaves not set but having the default value
+	defaults bool
+}
+
+// displayConfigLines returns the data under xpath of root in the
+// hierarchical view like "container { leaf value; }". The keys of the list
+// entries follow the list name, and the nodes unknown to the loaded modules
+// are marked as inactive since they're never pushed to the backends.
+func displayConfigLines(root *DBNode, xpath XPath,
+	opts configTextOpts) ([]string, error) {
+	if len(xpath.Words) == 0 {
+		return configTextRoot(root, opts), nil
+	}
+
+	// Only the data under xpath is displayed. The list given without keys
+	// displays all of its entries.
+	keyed := true
+	if xpath.TailIsList() {
+		tail := xpath.Tail()
+		for _, k := range tail.KeysIndex {
+			if kv, ok := tail.Keys[k]; !ok || kv.Value.Type == yang.Ynone {
+				keyed = false
+			}
+		}
+	}
+	lines := []string{}
+	if err := walkDBNodeEntries(root, func(xp XPath, n *DBNode,
+		e *yang.Entry) error {
+		if len(xp.Words) != len(xpath.Words) || !xpathHasPrefix(xp, xpath) {
+			return nil
+		}
+		switch {
+		case n.Type == Leaf || n.Type == LeafList:
+			lines = append(lines, configTextNode(n, e, "", "", opts)...)
+		case xp.TailIsList() && !keyed:
+			lines = append(lines, configTextListEntry(xp.Tail().Word, n, e, "",
+				"", opts)...)
+		default:
+			lines = append(lines, configTextChilds(n, e, "", opts)...)
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "walkDBNodeEntries")
+	}
+	return lines, nil
+}
+
+func configTextRoot(root *DBNode, opts configTextOpts) []string {
+	lines := []string{}
+	for idx := range root.Childs {
+		child := &root.Childs[idx]
+		e := lookupRootEntry(child.Name)
+		lines = append(lines, configTextNode(child, e, "",
+			configTextMark(e), opts)...)
+	}
+	return lines
+}
+
+// configTextMark returns the annotation of the node whose schema entry is
+// e, the node unknown to the loaded modules is inactive.
+func configTextMark(e *yang.Entry) string {
+	if e == nil {
+		return "inactive: "
+	}
+	return ""
+}
+
+// configTextNode returns the lines of the node n with its schema entry e.
+// The entry is nil for the node unknown to the loaded modules.
+func configTextNode(n *DBNode, e *yang.Entry, indent, mark string,
+	opts configTextOpts) []string {
+	switch n.Type {
+	case Leaf:
+		return []string{indent + mark + n.Name + " " +
+			quoteCommandArg(dbValueString(n.Value)) + ";"}
+	case LeafList:
+		items := []string{}
+		for _, v := range n.ArrayValue {
+			items = append(items, quoteCommandArg(dbValueString(v)))
+		}
+		if len(items) == 1 {
+			return []string{indent + mark + n.Name + " " + items[0] + ";"}
+		}
+		return []string{indent + mark + n.Name +
+			" [ " + strings.Join(items, " ") + " ];"}
+	case List:
+		lines := []string{}
+		for idx := range n.Childs {
+			lines = append(lines, configTextListEntry(n.Name, &n.Childs[idx], e,
+				indent, mark, opts)...)
+		}
+		return lines
+	default:
+		return configTextBlock(indent+mark+n.Name,
+			configTextChilds(n, e, indent+configTextIndent, opts), indent)
+	}
+}
+
+// configTextListEntry returns the lines of the entry elem of the list name
+// having the keys in the header.
+func configTextListEntry(name string, elem *DBNode, e *yang.Entry, indent,
+	mark string, opts configTextOpts) []string {
+	return configTextBlock(indent+mark+configTextListHeader(name, elem, e),
+		configTextChilds(elem, e, indent+configTextIndent, opts), indent)
+}
+
+// configTextListHeader returns the list name followed by the keys of the
+// entry elem in schema order.
+func configTextListHeader(name string, elem *DBNode, e *yang.Entry) string {
+	header := name
+	for _, k := range configTextKeys(e) {
+		for _, c := range elem.Childs {
+			if c.Name == k {
+				header += " " + quoteCommandArg(dbValueString(c.Value))
+			}
+		}
+	}
+	return header
+}
+
+func configTextKeys(e *yang.Entry) []string {
+	if e == nil || !e.IsList() {
+		return nil
+	}
+	return strings.Fields(e.Key)
+}
+
+// configTextBlock returns the block of the header and the lines of the
+// children. The node without children is closed in a line.
+func configTextBlock(header string, childs []string, indent string) []string {
+	if len(childs) == 0 {
+		return []string{header + ";"}
+	}
+	lines := []string{header + " {"}
+	lines = append(lines, childs...)
+	return append(lines, indent+"}")
+}
+
+// configTextChilds returns the lines of the children of the container or
+// the list entry n, except for the list keys displayed in the header.
+func configTextChilds(n *DBNode, e *yang.Entry, indent string,
+	opts configTextOpts) []string {
+	keys := configTextKeys(e)
+	lines := []string{}
+	for idx := range n.Childs {
+		child := &n.Childs[idx]
+		if configTextIsKey(keys, child.Name) {
+			continue
+		}
+		mark := ""
+		var ce *yang.Entry
+		if e != nil {
+			ce = lookupEntryChild(e, child.Name)
+			mark = configTextMark(ce)
+		}
+		lines = append(lines, configTextNode(child, ce, indent, mark, opts)...)
+	}
+	if opts.defaults && e != nil {
+		lines = append(lines, configTextDefaults(n, e, indent, opts)...)
+	}
+	return lines
+}
+
+func configTextIsKey(keys []string, name string) bool {
+	for _, k := range keys {
+		if k == name {
+			return true
+		}
+	}
+	return false
+}
+
+// configTextDefaults returns the lines of the leaves not set in n but
+// having the default value. The containers not set in n are displayed
+// when they have such leaves, except for the presence containers which
+// don't exist unless they are set.
+func configTextDefaults(n *DBNode, e *yang.Entry, indent string,
+	opts configTextOpts) []string {
+	names := []string{}
+	for name := range e.Dir {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		ce := e.Dir[name]
+		if ce.ReadOnly() || ce.RPC != nil || dbNodeHasChild(n, name) {
+			continue
+		}
+		switch {
+		case ce.IsLeaf():
+			value := ""
+			switch {
+			case len(ce.Default) > 0:
+				value = ce.Default[0]
+			case ce.Type != nil && ce.Type.HasDefault:
+				value = ce.Type.Default
+			default:
+				continue
+			}
+			lines = append(lines, indent+name+" "+quoteCommandArg(value)+
+				"; # default")
+		case ce.IsContainer():
+			if c, ok := ce.Node.(*yang.Container); ok && c.Presence != nil {
+				continue
+			}
+			empty := &DBNode{Name: name, Type: Container}
+			childs := configTextChilds(empty, ce, indent+configTextIndent, opts)
+			if len(childs) > 0 {
+				lines = append(lines, configTextBlock(indent+name, childs,
+					indent)...)
+			}
+		}
+	}
+	return lines
+}
+
+func dbNodeHasChild(n *DBNode, name string) bool {
+	for _, c := range n.Childs {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// displayConfigDiffLines returns the changes from a to b in the
+// hierarchical view. The added lines are marked with "+" and the removed
+// ones with "-", and the unchanged nodes are displayed only as the parents
+// of the changes.
+func displayConfigDiffLines(a, b *DBNode) []string {
+	lines := []string{}
+	for _, pair := range configDiffPairs(a, b, nil) {
+		var e *yang.Entry
+		if pair.a != nil {
+			e = lookupRootEntry(pair.a.Name)
+		} else {
+			e = lookupRootEntry(pair.b.Name)
+		}
+		lines = append(lines, configDiffNode(pair.a, pair.b, e, "",
+			configTextMark(e))...)
+	}
+	return lines
+}
+
+// configDiffPair is the children of the same name in the both nodes, one of
+// them is nil when it's added or removed.
+type configDiffPair struct {
+	a, b *DBNode
+}
+
+// configDiffPairs pairs the children of a and b in the order of a followed
+// by the ones only in b. The list keys are skipped.
+func configDiffPairs(a, b *DBNode, keys []string) []configDiffPair {
+	pairs := []configDiffPair{}
+	for idx := range a.Childs {
+		ca := &a.Childs[idx]
+		if configTextIsKey(keys, ca.Name) {
+			continue
+		}
+		pair := configDiffPair{a: ca}
+		for idx2 := range b.Childs {
+			if b.Childs[idx2].Name == ca.Name {
+				pair.b = &b.Childs[idx2]
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+	for idx := range b.Childs {
+		cb := &b.Childs[idx]
+		if configTextIsKey(keys, cb.Name) || dbNodeHasChild(a, cb.Name) {
+			continue
+		}
+		pairs = append(pairs, configDiffPair{b: cb})
+	}
+	return pairs
+}
+
+func configDiffNode(a, b *DBNode, e *yang.Entry, indent,
+	mark string) []string {
+	switch {
+	case a == nil:
+		return configDiffMarkLines("+",
+			configTextNode(b, e, indent, mark, configTextOpts{}))
+	case b == nil || a.Type != b.Type:
+		lines := configDiffMarkLines("-",
+			configTextNode(a, e, indent, mark, configTextOpts{}))
+		if b != nil {
+			lines = append(lines, configDiffMarkLines("+",
+				configTextNode(b, e, indent, mark, configTextOpts{}))...)
+		}
+		return lines
+	}
+
+	switch a.Type {
+	case Leaf, LeafList:
+		if dbNodeValueString(a) == dbNodeValueString(b) {
+			return nil
+		}
+		return append(
+			configDiffMarkLines("-",
+				configTextNode(a, e, indent, mark, configTextOpts{})),
+			configDiffMarkLines("+",
+				configTextNode(b, e, indent, mark, configTextOpts{}))...)
+	case List:
+		return configDiffList(a, b, e, indent, mark)
+	default:
+		childs := configDiffChilds(a, b, e, indent+configTextIndent)
+		return configDiffBlock(indent+mark+a.Name, childs, indent)
+	}
+}
+
+// configDiffList returns the changes of the list entries matched by their
+// keys.
+func configDiffList(a, b *DBNode, e *yang.Entry, indent,
+	mark string) []string {
+	keys := configTextKeys(e)
+	entryKey := func(elem *DBNode) string {
+		if len(keys) == 0 {
+			// The entries are compared as a whole without the schema
+			return elem.String()
+		}
+		values := []string{}
+		for _, k := range keys {
+			for _, c := range elem.Childs {
+				if c.Name == k {
+					values = append(values, dbValueString(c.Value))
+				}
+			}
+		}
+		return strings.Join(values, " ")
+	}
+	find := func(list *DBNode, key string) *DBNode {
+		for idx := range list.Childs {
+			if entryKey(&list.Childs[idx]) == key {
+				return &list.Childs[idx]
+			}
+		}
+		return nil
+	}
+
+	lines := []string{}
+	for idx := range a.Childs {
+		ea := &a.Childs[idx]
+		eb := find(b, entryKey(ea))
+		if eb == nil {
+			lines = append(lines, configDiffMarkLines("-",
+				configTextListEntry(a.Name, ea, e, indent, mark,
+					configTextOpts{}))...)
+			continue
+		}
+		header := indent + mark + configTextListHeader(a.Name, ea, e)
+		lines = append(lines, configDiffBlock(header,
+			configDiffChilds(ea, eb, e, indent+configTextIndent), indent)...)
+	}
+	for idx := range b.Childs {
+		eb := &b.Childs[idx]
+		if find(a, entryKey(eb)) != nil {
+			continue
+		}
+		lines = append(lines, configDiffMarkLines("+",
+			configTextListEntry(b.Name, eb, e, indent, mark,
+				configTextOpts{}))...)
+	}
+	return lines
+}
+
+func configDiffChilds(a, b *DBNode, e *yang.Entry, indent string) []string {
+	lines := []string{}
+	for _, pair := range configDiffPairs(a, b, configTextKeys(e)) {
+		name := ""
+		if pair.a != nil {
+			name = pair.a.Name
+		} else {
+			name = pair.b.Name
+		}
+		mark := ""
+		var ce *yang.Entry
+		if e != nil {
+			ce = lookupEntryChild(e, name)
+			mark = configTextMark(ce)
+		}
+		lines = append(lines, configDiffNode(pair.a, pair.b, ce, indent,
+			mark)...)
+	}
+	return lines
+}
+
+// configDiffBlock returns the unchanged node having the changes of the
+// children, nothing when the children aren't changed.
+func configDiffBlock(header string, childs []string, indent string) []string {
+	if len(childs) == 0 {
+		return nil
+	}
+	lines := []string{" " + header + " {"}
+	lines = append(lines, childs...)
+	return append(lines, " "+indent+"}")
+}
+
+func configDiffMarkLines(marker string, lines []string) []string {
+	ret := []string{}
+	for _, line := range lines {
+		ret = append(ret, marker+line)
+	}
+	return ret
+}
+
+// writeConfigText displays the node at xpath of the data tree root in the
+// hierarchical view, or in the output format selected by the pipe.
+func writeConfigText(root *DBNode, xpath XPath, node *DBNode) error {
+	format := useOutputFormat(outputFormatJSON, outputFormatSet,
+		outputFormatDefaults)
+	if format == outputFormatJSON || format == outputFormatSet {
+		return writeDBNode(root, xpath, node)
+	}
+	lines, err := displayConfigLines(root, xpath, configTextOpts{
+		defaults: format == outputFormatDefaults,
+	})
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Fprintln(stdout, line)
+	}
+	return nil
+}
+
+// writeConfigDiff displays the changes from a to b in the hierarchical
+// view.
+func writeConfigDiff(a, b *DBNode) {
+	for _, line := range displayConfigDiffLines(a, b) {
+		fmt.Fprintln(stdout, line)
+	}
+}
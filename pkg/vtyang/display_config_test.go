@@ -0,0 +1,36 @@
+package vtyang
+
+import (
+	"testing"
+)
+
+func TestDisplayConfig(t *testing.T) {
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/display",
+		OutputFile:     "./testdata/output/TestDisplayConfig.txt",
+		InitConfigFile: "./testdata/display_config.json",
+		Inputs: []string{
+			"show configuration",
+			"show configuration | display defaults",
+			"show configuration interfaces interface",
+			"show configuration interfaces interface eth0",
+			"show configuration system hostname",
+			"show configuration system | display set",
+			"configure",
+			"set system ntp server 10.0.0.100",
+			"set interfaces interface eth1 description downlink",
+			"set routes route 10.1.0.0/16 254 nexthop 10.0.0.2",
+			"show configuration diff",
+			"commit",
+			"set system hostname vtyang",
+			"set routes route 10.1.0.0/16 254 nexthop 10.0.0.3",
+			"delete interfaces interface eth1",
+			"show configuration diff",
+			"commit",
+			"quit",
+			"show configuration commit list 0",
+			"show configuration commit diff 1",
+		},
+	})
+}
@@ -0,0 +1,121 @@
+package vtyang
+
+import (
+	"os"
+	"strings"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+)
+
+// displaySetLines returns the set commands recreating the data under
+// xpath of root, in the order of the data tree. The words of the list
+// keys follow the list in schema order, and the values are quoted when
+// needed, so that each line is parsed back by ParseXPathArgs.
+func displaySetLines(root *DBNode, xpath XPath) ([]string, error) {
+	lines := []string{}
+	if err := walkDBNodeEntries(root, func(xp XPath, n *DBNode,
+		e *yang.Entry) error {
+		if !xpathHasPrefix(xp, xpath) {
+			return nil
+		}
+		words := displaySetWords(xp)
+		switch n.Type {
+		case Container:
+			// The list entry having only the keys and the empty container
+			// are created by themselves
+			if xp.TailIsList() {
+				for _, child := range n.Childs {
+					if _, ok := xp.Tail().Keys[child.Name]; !ok {
+						return nil
+					}
+				}
+			} else if len(n.Childs) > 0 {
+				return nil
+			}
+		case Leaf:
+			if parent := len(xp.Words) - 2; parent >= 0 &&
+				xp.Words[parent].Dbtype == List {
+				if _, ok := xp.Words[parent].Keys[n.Name]; ok {
+					return nil
+				}
+			}
+			words = append(words, quoteCommandArg(dbValueString(n.Value)))
+		case LeafList:
+			for _, v := range n.ArrayValue {
+				words = append(words, quoteCommandArg(dbValueString(v)))
+			}
+		}
+		lines = append(lines, strings.Join(words, " "))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// displaySetWords returns the set command of the node at xpath without
+// its value.
+func displaySetWords(xpath XPath) []string {
+	words := []string{"set"}
+	for _, arg := range xpathArgs(xpath) {
+		words = append(words, quoteCommandArg(arg))
+	}
+	return words
+}
+
+// xpathArgs returns the args of the node at xpath, in which the values of
+// the list keys follow the list in schema order.
+func xpathArgs(xpath XPath) []string {
+	args := []string{}
+	for _, xword := range xpath.Words {
+		args = append(args, xword.Word)
+		if xword.Dbtype == List {
+			for _, k := range xword.keysOrder() {
+				args = append(args, dbValueString(xword.Keys[k].Value))
+			}
+		}
+	}
+	return args
+}
+
+// xpathHasPrefix reports whether xpath is under the prefix. The list keys
+// not specified in the prefix match any entries.
+func xpathHasPrefix(xpath, prefix XPath) bool {
+	if len(xpath.Words) < len(prefix.Words) {
+		return false
+	}
+	for idx, pw := range prefix.Words {
+		xw := xpath.Words[idx]
+		if xw.Word != pw.Word {
+			return false
+		}
+		for k, pv := range pw.Keys {
+			if pv.Value.Type == yang.Ynone {
+				continue
+			}
+			xv, ok := xw.Keys[k]
+			if !ok || dbValueString(xv.Value) != dbValueString(pv.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// writeConfigSetFile exports the data tree root to the file as set
+// commands, one per line.
+func writeConfigSetFile(root *DBNode, filename string) error {
+	lines, err := displaySetLines(root, XPath{})
+	if err != nil {
+		return errors.Wrap(err, "displaySetLines")
+	}
+	content := ""
+	for _, line := range lines {
+		content += line + "\n"
+	}
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		return err
+	}
+	return nil
+}
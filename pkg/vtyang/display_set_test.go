@@ -0,0 +1,125 @@
+package vtyang
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDisplaySet(t *testing.T) {
+	// Start from the empty config regardless of the other tests
+	if err := os.RemoveAll("/tmp/run/vtyang/config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/basic"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	buf := setStdoutWithBuffer()
+	for _, cli := range []string{
+		"configure",
+		"set values u08 10",
+		"set values decimal -0.5",
+		`set values name "hello world"`,
+		"set values crypto main:aes",
+		"set values month-union 1",
+		"set values items item1 foo description foo-desc",
+		"set values items item1 bar",
+		`set values items item2 "a b" t1 description "say \"hi\" | bye"`,
+		"set values items item9 hoge 100 1 description baz",
+		"set values items item4 main:des3",
+		"commit",
+	} {
+		getCommandNodeCurrent().executeCommand(cli)
+	}
+	if buf.String() != "" {
+		t.Fatalf("unexpected output\n%s", buf.String())
+	}
+
+	lines, err := displaySetLines(&dbm.root, XPath{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"set values crypto main:aes",
+		"set values decimal -0.5",
+		"set values items item1 foo description foo-desc",
+		"set values items item1 bar",
+		`set values items item2 "a b" t1 description "say \"hi\" | bye"`,
+		"set values items item4 main:des3",
+		"set values items item9 hoge 100 1 description baz",
+		"set values month-union 1",
+		`set values name "hello world"`,
+		"set values u08 10",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("unexpected lines\n%s", strings.Join(lines, "\n"))
+	}
+
+	// The lines are parsed back to the same xpaths and values
+	for _, line := range lines {
+		segments, err := splitCommandLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(segments) != 1 {
+			t.Fatalf("%s: unexpected pipe", line)
+		}
+		if _, _, err := ParseXPathArgs(dbm, segments[0][1:], true); err != nil {
+			t.Errorf("%s: %s", line, err)
+		}
+	}
+
+	// Replaying the lines recreates the config
+	before, err := flattenDBNode(&dbm.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getCommandNodeCurrent().executeCommand("delete values")
+	for _, line := range lines {
+		getCommandNodeCurrent().executeCommand(line)
+	}
+	getCommandNodeCurrent().executeCommand("commit")
+	getCommandNodeCurrent().executeCommand("quit")
+	if buf.String() != "" {
+		t.Fatalf("unexpected output\n%s", buf.String())
+	}
+	after, err := flattenDBNode(&dbm.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("not round-tripped\nbefore: %v\nafter:  %v", before, after)
+	}
+
+	// Subtree
+	items, _, err := ParseXPathArgs(dbm, []string{"values", "items", "item1"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err = displaySetLines(&dbm.root, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lines, expected[2:4]) {
+		t.Errorf("unexpected lines\n%s", strings.Join(lines, "\n"))
+	}
+
+	// Export
+	filename := "/tmp/run/vtyang/config.set"
+	getCommandNodeCurrent().executeCommand("save running-config " + filename)
+	if buf.String() != "" {
+		t.Fatalf("unexpected output\n%s", buf.String())
+	}
+	out, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != strings.Join(expected, "\n")+"\n" {
+		t.Errorf("unexpected export\n%s", out)
+	}
+}
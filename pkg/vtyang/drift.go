@@ -0,0 +1,471 @@
+package vtyang
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+)
+
+// DriftSource is a backend applying the config of vtyang. Its config is
+// compared with the running config of vtyang to detect the changes made
+// behind vtyang.
+type DriftSource interface {
+	// GetConfig returns the config applied by the backend.
+	GetConfig(ctx context.Context) ([]YangData, error)
+	// PushConfig applies root, the running config of vtyang, to the
+	// backend.
+	PushConfig(ctx context.Context, root *DBNode) error
+}
+
+// DriftHandle identifies the registration of the source.
+type DriftHandle int
+
+type driftRegistration struct {
+	handle DriftHandle
+	name   string
+	paths  []string
+	source DriftSource
+}
+
+var (
+	driftTimeout     = 3 * time.Second
+	driftSources     []driftRegistration
+	driftLastHandle  DriftHandle
+	driftSourcesLock sync.Mutex
+	driftCheckStop   chan struct{}
+	driftCheckDone   chan struct{}
+)
+
+// RegisterDriftSource registers source s managing the subtrees at paths.
+// The paths are schema paths as in RegisterOperStateProvider. The name
+// selects the source in the drift commands, the registration of the name
+// already registered is rejected.
+func RegisterDriftSource(name string, paths []string,
+	s DriftSource) (DriftHandle, error) {
+	driftSourcesLock.Lock()
+	defer driftSourcesLock.Unlock()
+	for _, reg := range driftSources {
+		if reg.name == name {
+			return 0, errors.Errorf("drift source %s already registered", name)
+		}
+	}
+	driftLastHandle++
+	driftSources = append(driftSources, driftRegistration{
+		handle: driftLastHandle,
+		name:   name,
+		paths:  paths,
+		source: s,
+	})
+	log.Printf("drift source %s registered for %v\n", name, paths)
+	return driftLastHandle, nil
+}
+
+func UnregisterDriftSource(handle DriftHandle) {
+	driftSourcesLock.Lock()
+	defer driftSourcesLock.Unlock()
+	regs := []driftRegistration{}
+	for _, reg := range driftSources {
+		if reg.handle != handle {
+			regs = append(regs, reg)
+			continue
+		}
+		log.Printf("drift source %s unregistered\n", reg.name)
+	}
+	driftSources = regs
+}
+
+func resetDriftSources() {
+	driftSourcesLock.Lock()
+	defer driftSourcesLock.Unlock()
+	driftSources = nil
+}
+
+// lookupDriftSources returns the sources named name, or all of them when
+// name is empty.
+func lookupDriftSources(name string) []driftRegistration {
+	driftSourcesLock.Lock()
+	defer driftSourcesLock.Unlock()
+	ret := []driftRegistration{}
+	for _, reg := range driftSources {
+		if name == "" || reg.name == name {
+			ret = append(ret, reg)
+		}
+	}
+	return ret
+}
+
+// driftResult is the config of a source compared with vtyang. The leaves
+// are indexed by xpath, list keys are part of the xpath of the other
+// leaves and not included.
+type driftResult struct {
+	reg     driftRegistration
+	vtyang  map[string]YangData
+	backend map[string]YangData
+}
+
+func (r driftResult) drifted() bool {
+	if len(r.vtyang) != len(r.backend) {
+		return true
+	}
+	for k, v := range r.vtyang {
+		if b, ok := r.backend[k]; !ok || b.Value != v.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// diff returns the delta from vtyang to the backend.
+func (r driftResult) diff() (string, error) {
+	na, err := CraftDBNode(sortedYangData(r.vtyang))
+	if err != nil {
+		return "", errors.Wrap(err, "CraftDBNode")
+	}
+	nb, err := CraftDBNode(sortedYangData(r.backend))
+	if err != nil {
+		return "", errors.Wrap(err, "CraftDBNode")
+	}
+	return DBNodeDiff(na, nb), nil
+}
+
+func sortedYangData(m map[string]YangData) []YangData {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := []YangData{}
+	for _, k := range keys {
+		ret = append(ret, m[k])
+	}
+	return ret
+}
+
+// driftLeaves returns the leaves of root in the subtrees at paths.
+func driftLeaves(root *DBNode, paths []string) (map[string]YangData, error) {
+	ret := map[string]YangData{}
+	if err := walkDBNode(root, func(xpath XPath, n *DBNode) error {
+		if isListKey(xpath) || !matchSchemaPaths(xpath, paths) {
+			return nil
+		}
+		ret[xpath.String()] = YangData{
+			XPath: xpath,
+			Value: dbNodeValueString(n),
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func matchSchemaPaths(xpath XPath, paths []string) bool {
+	for _, path := range paths {
+		words := splitSchemaPath(path)
+		if len(words) > len(xpath.Words) {
+			continue
+		}
+		match := true
+		for i := range words {
+			if words[i] != xpath.Words[i].Word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// removeSchemaSubtree removes the nodes at the schema path words from n.
+// All entries of the lists on the path are traversed. The empty path is
+// refused, as it would remove the whole config.
+func removeSchemaSubtree(n *DBNode, words []string) error {
+	if len(words) == 0 {
+		return errors.Errorf("empty schema path")
+	}
+	childs := []DBNode{}
+	for _, child := range n.Childs {
+		if child.Name != words[0] {
+			childs = append(childs, child)
+			continue
+		}
+		if len(words) == 1 {
+			continue
+		}
+		switch child.Type {
+		case Container:
+			if err := removeSchemaSubtree(&child, words[1:]); err != nil {
+				return err
+			}
+		case List:
+			for idx := range child.Childs {
+				err := removeSchemaSubtree(&child.Childs[idx], words[1:])
+				if err != nil {
+					return err
+				}
+			}
+		}
+		childs = append(childs, child)
+	}
+	n.Childs = childs
+	return nil
+}
+
+func checkDrift(reg driftRegistration) (driftResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), driftTimeout)
+	defer cancel()
+	datas, err := reg.source.GetConfig(ctx)
+	if err != nil {
+		return driftResult{}, errors.Wrap(err, "GetConfig")
+	}
+
+	// The backend config is crafted and walked as the running config, so
+	// that both sides are indexed by the same xpath.
+	filtered := []YangData{}
+	for _, data := range datas {
+		if !isListKey(data.XPath) {
+			filtered = append(filtered, data)
+		}
+	}
+	backendRoot, err := CraftDBNode(filtered)
+	if err != nil {
+		return driftResult{}, errors.Wrap(err, "CraftDBNode")
+	}
+	backend, err := driftLeaves(backendRoot, reg.paths)
+	if err != nil {
+		return driftResult{}, errors.Wrap(err, "driftLeaves")
+	}
+	vtyang, err := driftLeaves(&dbm.root, reg.paths)
+	if err != nil {
+		return driftResult{}, errors.Wrap(err, "driftLeaves")
+	}
+	return driftResult{reg: reg, vtyang: vtyang, backend: backend}, nil
+}
+
+// adoptDrift replaces the subtrees of the source in the running config
+// with the config of the backend.
+func adoptDrift(r driftResult) error {
+	root := dbm.root.DeepCopy()
+	for _, path := range r.reg.paths {
+		if err := removeSchemaSubtree(root, splitSchemaPath(path)); err != nil {
+			return errors.Wrapf(err, "removeSchemaSubtree(%s)", path)
+		}
+	}
+	dbm0 := NewDatabaseManager()
+	dbm0.candidateRoot = root
+	for _, data := range sortedYangData(r.backend) {
+		if _, err := dbm0.SetNode(data.XPath, data.Value); err != nil {
+			return errors.Wrapf(err, "SetNode(%s)", data.XPath.String())
+		}
+	}
+	return commitExternal(root, r.reg.name, "drift-adopt")
+}
+
+func repushDrift(r driftResult) error {
+	ctx, cancel := context.WithTimeout(context.Background(), driftTimeout)
+	defer cancel()
+	return r.reg.source.PushConfig(ctx, &dbm.root)
+}
+
+// resolveDrift checks the sources named name and, when action is
+// "adopt" or "repush", resolves the detected drift. The sources are
+// processed regardless of the failure of the others.
+func resolveDrift(name, action string) error {
+	regs := lookupDriftSources(name)
+	if len(regs) == 0 {
+		return errors.Errorf("drift source %s not found", name)
+	}
+	failures := []string{}
+	for _, reg := range regs {
+		r, err := checkDrift(reg)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", reg.name, err))
+			continue
+		}
+		if !r.drifted() {
+			fmt.Fprintf(stdout, "%s: no drift\n", reg.name)
+			continue
+		}
+		switch action {
+		case "adopt":
+			err = adoptDrift(r)
+		case "repush":
+			err = repushDrift(r)
+		default:
+			diff, err := r.diff()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %s", reg.name, err))
+				continue
+			}
+			fmt.Fprintf(stdout, "%s: drift detected\n", reg.name)
+			fmt.Fprintln(stdout, diff)
+			continue
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", reg.name, err))
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: %s done\n", reg.name, action)
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+func ccbShowConfigurationDrift(ctx context.Context, args []string) (*CommandResult, error) {
+	name := ""
+	if len(args) > 3 {
+		name = args[3]
+	}
+	return nil, resolveDrift(name, "")
+}
+
+func ccbConfigurationDrift(ctx context.Context, args []string) (*CommandResult, error) {
+	if cliMode == CliModeConfigure {
+		return nil, errors.Errorf("not allowed in configure mode")
+	}
+	name := ""
+	if len(args) > 3 {
+		name = args[3]
+	}
+	return nil, resolveDrift(name, args[2])
+}
+
+// startDriftCheck checks the drift of all sources every interval. The
+// drift is logged and resolved by action when it's not empty. Actions
+// are postponed while any session is in configure mode.
+func startDriftCheck(interval time.Duration, action string) {
+	stopDriftCheck()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	driftCheckStop = stop
+	driftCheckDone = done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			commandLock.Lock()
+			driftCheckOnce(action)
+			commandLock.Unlock()
+		}
+	}()
+}
+
+func stopDriftCheck() {
+	if driftCheckStop != nil {
+		close(driftCheckStop)
+		<-driftCheckDone
+		driftCheckStop = nil
+		driftCheckDone = nil
+	}
+}
+
+func driftCheckOnce(action string) {
+	for _, reg := range lookupDriftSources("") {
+		r, err := checkDrift(reg)
+		if err != nil {
+			log.Printf("drift: %s: %s\n", reg.name, err)
+			continue
+		}
+		if !r.drifted() {
+			continue
+		}
+		log.Printf("drift: %s: drift detected\n", reg.name)
+		if action == "" || anySessionConfiguring() {
+			continue
+		}
+		switch action {
+		case "adopt":
+			err = adoptDrift(r)
+		case "repush":
+			err = repushDrift(r)
+		}
+		if err != nil {
+			log.Printf("drift: %s: %s: %s\n", reg.name, action, err)
+			continue
+		}
+		log.Printf("drift: %s: %s done\n", reg.name, action)
+	}
+}
+
+// mgmtdDriftSource compares the running datastore of mgmtd.
+type mgmtdDriftSource struct{}
+
+func (mgmtdDriftSource) GetConfig(ctx context.Context) ([]YangData, error) {
+	root, err := mgmtdRunningConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	leaves, err := driftLeaves(root, mgmtdOwnedPaths())
+	if err != nil {
+		return nil, errors.Wrap(err, "driftLeaves")
+	}
+	return sortedYangData(leaves), nil
+}
+
+// PushConfig commits the change set from the running datastore of mgmtd
+// to root.
+func (mgmtdDriftSource) PushConfig(ctx context.Context, root *DBNode) error {
+	if err := mgmtdLockDatastores(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := mgmtdUnlockDatastores(ctx); err != nil {
+			log.Printf("mgmtdUnlockDatastores: %s\n", err)
+		}
+	}()
+	// The change set is made from the running datastore, so the candidate
+	// is reset to it first
+	if err := mgmtdClient.AbortConfig(ctx); err != nil {
+		return errors.Wrap(err, "mgmtd.AbortConfig")
+	}
+	running, err := mgmtdRunningConfig(ctx)
+	if err != nil {
+		return err
+	}
+	reqs, err := mgmtdChangeSet(running, root)
+	if err != nil {
+		return errors.Wrap(err, "mgmtdChangeSet")
+	}
+	if err := mgmtdSetConfig(ctx, reqs); err != nil {
+		return err
+	}
+	if err := mgmtdCommit(ctx); err != nil {
+		return errors.Wrap(err, "mgmtd.CommitConfig")
+	}
+	return nil
+}
+
+func mgmtdRunningConfig(ctx context.Context) (*DBNode, error) {
+	config, err := mgmtdClient.GetData(ctx, mgmtd.DatastoreId_RUNNING_DS,
+		true, "/")
+	if err != nil {
+		return nil, errors.Wrap(err, "mgmtd.GetData")
+	}
+	root, skipped, err := frrConfigImport(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "frrConfigImport")
+	}
+	for _, s := range skipped {
+		log.Printf("mgmtd: %s: %s ... ignored\n", s.XPath, s.Err)
+	}
+	return root, nil
+}
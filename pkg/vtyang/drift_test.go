@@ -0,0 +1,263 @@
+package vtyang
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	vtyangapi "github.com/slankdev/vtyang/pkg/grpc/api"
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+)
+
+func TestDriftMgmtd(t *testing.T) {
+	const action = "/frr-filter:lib/prefix-list[type='ipv4'][name='hoge']/entry[sequence='10']/action"
+	s, backend := newTestMgmtdServer(t)
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS, action, "deny")
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS,
+		"/frr-filter:lib/prefix-list[type='ipv4'][name='fuga']/entry[sequence='10']/action",
+		"permit")
+
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/frr_mgmtd_minimal",
+		InitConfigFile: "./testdata/frr_mgmtd_minimal_config.json",
+		OutputFile:     "./testdata/output/TestDriftMgmtd.txt",
+		BackendMgmtd:   backend,
+		Inputs: []string{
+			"show configuration drift",
+			"configuration drift adopt",
+			"show configuration drift",
+			"show running-config",
+		},
+	})
+	if len(commitHistories) == 0 || commitHistories[0].Client != "mgmtd" ||
+		commitHistories[0].Comment != "drift-adopt" {
+		t.Fatalf("unexpected commit histories %v", commitHistories)
+	}
+
+	// The change made behind vtyang is reverted by repush
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS, action, "permit")
+	buf := setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("configuration drift repush mgmtd")
+	if buf.String() != "mgmtd: repush done\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+	if v := s.Data(mgmtd.DatastoreId_RUNNING_DS)[action]; v != "deny" {
+		t.Errorf("unexpected running action %q", v)
+	}
+	if locks := s.Locks(); len(locks) != 0 {
+		t.Errorf("datastores are left locked %v", locks)
+	}
+}
+
+// The config of the vtyang only modules isn't compared with mgmtd, and
+// kept by adopt and not pushed by repush
+func TestDriftMgmtdMixed(t *testing.T) {
+	const action = "/frr-filter:lib/prefix-list[type='ipv4'][name='hoge']/entry[sequence='10']/action"
+	s, backend := newTestMgmtdServer(t)
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS, action, "permit")
+
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/frr_mgmtd_minimal",
+		ExtraYangPaths: []string{"./testdata/yang/operstate"},
+		InitConfigFile: "./testdata/frr_mgmtd_mixed_config.json",
+		OutputFile:     "./testdata/output/TestDriftMgmtdMixed.txt",
+		BackendMgmtd:   backend,
+		Inputs: []string{
+			"show configuration drift",
+		},
+	})
+
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS, action, "deny")
+	buf := setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("configuration drift adopt mgmtd")
+	if buf.String() != "mgmtd: adopt done\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+	running := dbm.root.String()
+	if !strings.Contains(running, "vtyang0") ||
+		!strings.Contains(running, "deny") {
+		t.Fatalf("unexpected running %s", running)
+	}
+
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS, action, "permit")
+	buf = setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("configuration drift repush mgmtd")
+	if buf.String() != "mgmtd: repush done\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+	for xpath := range s.Data(mgmtd.DatastoreId_RUNNING_DS) {
+		if strings.Contains(xpath, "system") {
+			t.Errorf("vtyang only config is pushed %s", xpath)
+		}
+	}
+}
+
+func TestDriftGrpcAgent(t *testing.T) {
+	if err := InitAgent(AgentOpts{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/operstate"},
+		LogFile:     agentTestDefaultLogFile,
+		Grpc:        &AgentOptsGrpc{Address: "127.0.0.1:0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer stopGrpcServer()
+	for _, cli := range []string{
+		"configure",
+		"set system hostname vtyang0",
+		"commit",
+		"quit",
+	} {
+		getCommandNodeCurrent().executeCommand(cli)
+	}
+
+	// Connect agent reporting its applied config
+	conn, err := grpc.Dial(grpcListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := vtyangapi.NewAgentServiceClient(conn).
+		Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []*vtyangapi.AgentMessage{
+		{
+			Message: &vtyangapi.AgentMessage_Register{
+				Register: &vtyangapi.AgentRegister{
+					Name:        "agent0",
+					ConfigPaths: []string{"/operstate:system"},
+				},
+			},
+		},
+		{
+			Message: &vtyangapi.AgentMessage_ConfigState{
+				ConfigState: &vtyangapi.ConfigState{
+					Data: []*vtyangapi.YangData{
+						{
+							Xpath: "/operstate:system/hostname",
+							Value: "agent0",
+						},
+						{
+							Xpath: "/operstate:system/interface[name='eth0']/description",
+							Value: "uplink",
+						},
+					},
+				},
+			},
+		},
+	} {
+		if err := stream.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	applied := make(chan string, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			applied <- req.GetConfigApplyReq().Config
+			stream.Send(&vtyangapi.AgentMessage{
+				Message: &vtyangapi.AgentMessage_ConfigApplyReply{
+					ConfigApplyReply: &vtyangapi.ConfigApplyReply{
+						ReqId: req.GetConfigApplyReq().ReqId,
+					},
+				},
+			})
+		}
+	}()
+
+	// The running config is pushed on connect
+	if config := <-applied; !strings.Contains(config, "vtyang0") {
+		t.Errorf("unexpected config %q", config)
+	}
+
+	// Wait the config state
+	for i := 0; ; i++ {
+		buf := setStdoutWithBuffer()
+		getCommandNodeCurrent().executeCommand("show configuration drift")
+		if strings.HasPrefix(buf.String(), "agent:agent0: drift detected\n") {
+			break
+		}
+		if i > 100 {
+			t.Fatalf("drift not detected %q", buf.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Repush sends the running config of vtyang
+	buf := setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("configuration drift repush")
+	if buf.String() != "agent:agent0: repush done\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+	if config := <-applied; !strings.Contains(config, "vtyang0") {
+		t.Errorf("unexpected config %q", config)
+	}
+
+	// Adopt replaces the subtree with the config state
+	buf = setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("configuration drift adopt agent:agent0")
+	if buf.String() != "agent:agent0: adopt done\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+	if config := <-applied; !strings.Contains(config, "agent0") {
+		t.Errorf("unexpected config %q", config)
+	}
+	leaves, err := flattenDBNode(&dbm.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaves["/operstate:system/operstate:hostname"] != "agent0" ||
+		leaves["/operstate:system/operstate:interface[name='eth0']/operstate:description"] != "uplink" {
+		t.Errorf("unexpected running config %v", leaves)
+	}
+	buf = setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("show configuration drift")
+	if buf.String() != "agent:agent0: no drift\n" {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+func TestDriftSourceDuplicate(t *testing.T) {
+	resetDriftSources()
+	defer resetDriftSources()
+
+	handle, err := RegisterDriftSource("agent:agent0",
+		[]string{"/operstate:system"}, mgmtdDriftSource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = RegisterDriftSource("agent:agent0",
+		[]string{"/operstate:system"}, mgmtdDriftSource{})
+	if err == nil ||
+		err.Error() != "drift source agent:agent0 already registered" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The stale handle doesn't unregister the current source
+	UnregisterDriftSource(handle)
+	handle2, err := RegisterDriftSource("agent:agent0",
+		[]string{"/operstate:system"}, mgmtdDriftSource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	UnregisterDriftSource(handle)
+	if len(lookupDriftSources("agent:agent0")) != 1 {
+		t.Fatal("source unregistered by the stale handle")
+	}
+	UnregisterDriftSource(handle2)
+	if len(lookupDriftSources("agent:agent0")) != 0 {
+		t.Fatal("source not unregistered")
+	}
+}
@@ -1,16 +1,13 @@
 package vtyang
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
 
 	"github.com/openconfig/goyang/pkg/yang"
-	"github.com/pkg/errors"
-
-	"github.com/slankdev/vtyang/pkg/mgmtd"
-	"github.com/slankdev/vtyang/pkg/util"
 )
 
 func getCommandConfig(modules *yang.Modules) *CompletionNode {
@@ -58,6 +55,11 @@ func getCommandConfig(modules *yang.Modules) *CompletionNode {
 				Name:   "delete",
 				Childs: child,
 			},
+			{
+				Name:        "edit",
+				Description: "Edit the configuration under the path",
+				Childs:      child,
+			},
 		},
 	}
 }
@@ -101,7 +103,7 @@ func resolveCompletionNodeConfig(e *yang.Entry, depth int, modName string, chain
 				case ee.IsChoice():
 					for _, ee2 := range ee.Dir {
 						for _, ee3 := range ee2.Dir {
-							if !ee3.ReadOnly() && ee3.RPC == nil {
+							if !ee3.ReadOnly() && !isRPCEntry(ee3) {
 								tail.Childs = append(tail.Childs,
 									resolveCompletionNodeConfig(ee3, depth+1, modName, chains))
 								sort.Slice(tail.Childs,
@@ -111,6 +113,8 @@ func resolveCompletionNodeConfig(e *yang.Entry, depth int, modName string, chain
 							}
 						}
 					}
+				case isRPCEntry(ee):
+					// actions are executed with action command
 				default:
 					nn := resolveCompletionNodeConfig(ee, depth+1, modName, chains)
 					if nn != nil {
@@ -134,12 +138,12 @@ func resolveCompletionNodeConfig(e *yang.Entry, depth int, modName string, chain
 	default:
 		childs := []*CompletionNode{}
 		for _, ee := range e.Dir {
-			if !ee.ReadOnly() && ee.RPC == nil {
+			if !ee.ReadOnly() && !isRPCEntry(ee) {
 				switch {
 				case ee.IsChoice():
 					for _, ee2 := range ee.Dir {
 						for _, ee3 := range ee2.Dir {
-							if !ee3.ReadOnly() && ee3.RPC == nil {
+							if !ee3.ReadOnly() && !isRPCEntry(ee3) {
 								childs = append(childs, resolveCompletionNodeConfig(ee3, depth+1, modName, chains))
 							}
 						}
@@ -160,27 +164,24 @@ func getCommandCallbackConfig(_ *yang.Modules) []Command {
 	return []Command{
 		{
 			m: "show",
-			f: func(args []string) {
-				xpath, _, err := ParseXPathArgs(dbm, args[1:], false)
+			f: func(ctx context.Context, args []string) (*CommandResult, error) {
+				xpath, _, err := parseEditArgs(args, false)
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, err
 				}
 				node, err := dbm.GetNode(xpath)
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, err
 				}
-				fmt.Fprintln(stdout, stdout, stdout, node.String())
+				return nil, writeDBNode(&dbm.root, xpath, node)
 			},
 		},
 		{
 			m: "set",
-			f: func(args []string) {
-				xpath, value, err := ParseXPathArgs(dbm, args[1:], true)
+			f: func(ctx context.Context, args []string) (*CommandResult, error) {
+				xpath, value, err := parseEditArgs(args, true)
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, err
 				}
 				vals := []string{}
 				for _, v := range value {
@@ -188,49 +189,26 @@ func getCommandCallbackConfig(_ *yang.Modules) []Command {
 				}
 				valueStr := strings.Join(vals, " ")
 				if _, err := dbm.SetNode(xpath, valueStr); err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
-				}
-
-				if agentOpts.BackendMgmtd != nil {
-					if err := mgmtdClient.SetConfig(&mgmtd.FeSetConfigReq{
-						SessionId:      mgmtdClient.GetSessionId(),
-						DsId:           mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
-						CommitDsId:     mgmtd.DatastoreId_RUNNING_DS.Enum(),
-						ReqId:          util.NewUint64Pointer(0),
-						ImplicitCommit: util.NewBoolPointer(false),
-						Data: []*mgmtd.YangCfgDataReq{
-							{
-								ReqType: mgmtd.CfgDataReqType_SET_DATA.Enum(),
-								Data: &mgmtd.YangData{
-									Xpath: util.NewStringPointer(xpath.String()),
-									Value: &mgmtd.YangDataValue{
-										Value: &mgmtd.YangDataValue_EncodedStrVal{
-											EncodedStrVal: valueStr,
-										},
-									},
-								},
-							},
-						},
-					}); err != nil {
-						err := errors.Wrap(err, "SetConfig")
-						fmt.Fprintf(stdout, "Error: %v\n", err)
-					}
+					return nil, err
 				}
+				return nil, nil
 			},
 		},
+		{
+			m: "edit",
+			f: ccbEdit,
+		},
 		{
 			m: "delete",
-			f: func(args []string) {
-				xpath, _, err := ParseXPathArgs(dbm, args[1:], true)
+			f: func(ctx context.Context, args []string) (*CommandResult, error) {
+				xpath, _, err := parseEditArgs(args, true)
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, err
 				}
 				if err := dbm.DeleteNode(xpath); err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, err
 				}
+				return nil, nil
 			},
 		},
 	}
@@ -320,6 +298,11 @@ func getViewCommandConfig(modules *yang.Modules) *CompletionNode {
 			{
 				Name: "show",
 				Childs: []*CompletionNode{
+					{
+						Name:        "configuration",
+						Description: "Display configuration",
+						Childs:      append([]*CompletionNode{newCR()}, child...),
+					},
 					{
 						Name:   "running-config",
 						Childs: child,
@@ -342,42 +325,54 @@ func getViewCommandCallbackConfig(_ *yang.Modules) []Command {
 	return []Command{
 		{
 			m: "show running-config",
-			f: func(args []string) {
+			f: func(ctx context.Context, args []string) (*CommandResult, error) {
+				xpath, _, err := ParseXPathArgs(dbm, args[2:], false)
+				if err != nil {
+					return nil, shiftCommandError(err, 2)
+				}
+				node, err := dbm.GetNode(xpath)
+				if err != nil {
+					return nil, err
+				}
+				return nil, writeDBNode(&dbm.root, xpath, node)
+			},
+		},
+		{
+			m: "show configuration",
+			f: func(ctx context.Context, args []string) (*CommandResult, error) {
 				xpath, _, err := ParseXPathArgs(dbm, args[2:], false)
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, shiftCommandError(err, 2)
 				}
 				node, err := dbm.GetNode(xpath)
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, err
 				}
-				fmt.Fprintln(stdout, node.String())
+				return nil, writeConfigText(&dbm.root, xpath, node)
 			},
 		},
 		{
 			m: "show running-config-frr",
-			f: func(args []string) {
+			f: func(ctx context.Context, args []string) (*CommandResult, error) {
 				node := &dbm.root
 				filteredNode, err := filterDbWithModule(node, "frr-isisd")
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, err
 				}
 				fmt.Fprintln(stdout, filteredNode.String())
+				return nil, nil
 			},
 		},
 		{
 			m: "show running-config-raw",
-			f: func(args []string) {
+			f: func(ctx context.Context, args []string) (*CommandResult, error) {
 				node := &dbm.root
 				out, err := json.MarshalIndent(node, "", "  ")
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, err
 				}
 				fmt.Fprintln(stdout, string(out))
+				return nil, nil
 			},
 		},
 	}
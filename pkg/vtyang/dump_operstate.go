@@ -1,6 +1,7 @@
 package vtyang
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -26,6 +27,9 @@ func getCommandOperState(modules *yang.Modules) *CompletionNode {
 		sort.Strings(entnames)
 		for _, entname := range entnames {
 			e := yang.ToEntry(m).Dir[entname]
+			if isRPCEntry(e) {
+				continue
+			}
 			child = append(child, resolveCompletionNodeOperState(e, 0))
 		}
 	}
@@ -55,7 +59,7 @@ func resolveCompletionNodeOperState(e *yang.Entry, depth int) *CompletionNode {
 				case ee.IsChoice():
 					for _, ee2 := range ee.Dir {
 						for _, ee3 := range ee2.Dir {
-							if !ee3.ReadOnly() && ee3.RPC == nil {
+							if !ee3.ReadOnly() && !isRPCEntry(ee3) {
 								wildcardNode.Childs = append(wildcardNode.Childs,
 									resolveCompletionNodeOperState(ee3, depth+1))
 								sort.Slice(wildcardNode.Childs,
@@ -65,6 +69,8 @@ func resolveCompletionNodeOperState(e *yang.Entry, depth int) *CompletionNode {
 							}
 						}
 					}
+				case isRPCEntry(ee):
+					// actions are executed with action command
 				default:
 					if nn := resolveCompletionNodeOperState(ee, depth+1); nn != nil {
 						wildcardNode.Childs = append(wildcardNode.Childs, nn)
@@ -90,11 +96,13 @@ func resolveCompletionNodeOperState(e *yang.Entry, depth int) *CompletionNode {
 			case ee.IsChoice():
 				for _, ee2 := range ee.Dir {
 					for _, ee3 := range ee2.Dir {
-						if !ee3.ReadOnly() && ee3.RPC == nil {
+						if !ee3.ReadOnly() && !isRPCEntry(ee3) {
 							childs = append(childs, resolveCompletionNodeOperState(ee3, depth+1))
 						}
 					}
 				}
+			case isRPCEntry(ee):
+				// actions are executed with action command
 			default:
 				childs = append(childs, resolveCompletionNodeOperState(ee, depth+1))
 			}
@@ -109,26 +117,24 @@ func getCommandCallbackOperState(_ *yang.Modules) []Command {
 	return []Command{
 		{
 			m: "show",
-			f: func(args []string) {
+			f: func(ctx context.Context, args []string) (*CommandResult, error) {
 				if len(args) < 2 {
 					fmt.Fprintf(stdout, "usage:\n")
-					return
+					return nil, nil
 				}
 				xpath, _, err := ParseXPathArgs(dbm, args[1:], false)
 				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+					return nil, shiftCommandError(err, 1)
 				}
-				node, err := dbm.GetNode(xpath)
-				if err != nil {
-					fmt.Fprintf(stdout, "Error: %s\n", err.Error())
-					return
+				node, root, errs := getNodeWithOperState(xpath)
+				for _, err := range errs {
+					fmt.Fprintf(stdout, "Warning: %s\n", err.Error())
 				}
 				if node == nil {
 					fmt.Fprintf(stdout, "Not Found\n")
-					return
+					return nil, nil
 				}
-				fmt.Fprintln(stdout, node.String())
+				return nil, writeDBNode(root, xpath, node)
 			},
 		},
 	}
@@ -1,7 +1,9 @@
 package vtyang
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/openconfig/goyang/pkg/yang"
@@ -14,12 +16,32 @@ func getCommandRPC(modules *yang.Modules) *CompletionNode {
 			continue
 		}
 		for _, e := range yang.ToEntry(m).Dir {
-			if e.RPC != nil {
+			if e.RPC != nil && e.Node.Kind() == "rpc" {
 				child = append(child, resolveCompletionNodeRPC(e, 0))
 			}
 		}
 	}
-	return &CompletionNode{
+	sort.Slice(child, func(i, j int) bool { return child[i].Name < child[j].Name })
+
+	actionChild := []*CompletionNode{}
+	for fullname, m := range modules.Modules {
+		if strings.Contains(fullname, "@") {
+			continue
+		}
+		for _, e := range yang.ToEntry(m).Dir {
+			if e.RPC != nil {
+				continue
+			}
+			if nn := resolveCompletionNodeAction(e); nn != nil {
+				actionChild = append(actionChild, nn)
+			}
+		}
+	}
+	sort.Slice(actionChild, func(i, j int) bool {
+		return actionChild[i].Name < actionChild[j].Name
+	})
+
+	n := &CompletionNode{
 		Childs: []*CompletionNode{
 			{
 				Name:   "rpc",
@@ -27,6 +49,72 @@ func getCommandRPC(modules *yang.Modules) *CompletionNode {
 			},
 		},
 	}
+	if len(actionChild) > 0 {
+		n.Childs = append(n.Childs, &CompletionNode{
+			Name:   "action",
+			Childs: actionChild,
+		})
+	}
+	return n
+}
+
+// resolveCompletionNodeAction returns the completion tree of the data
+// nodes which lead to actions, or nil when e has no action under it.
+func resolveCompletionNodeAction(e *yang.Entry) *CompletionNode {
+	if isRPCEntry(e) {
+		return resolveCompletionNodeRPC(e, 0)
+	}
+	if e.IsLeaf() || e.IsLeafList() {
+		return nil
+	}
+
+	childs := []*CompletionNode{}
+	for _, ee := range e.Dir {
+		switch {
+		case ee.IsChoice():
+			for _, ee2 := range ee.Dir {
+				for _, ee3 := range ee2.Dir {
+					if nn := resolveCompletionNodeAction(ee3); nn != nil {
+						childs = append(childs, nn)
+					}
+				}
+			}
+		default:
+			if nn := resolveCompletionNodeAction(ee); nn != nil {
+				childs = append(childs, nn)
+			}
+		}
+	}
+	if len(childs) == 0 {
+		return nil
+	}
+	sort.Slice(childs, func(i, j int) bool { return childs[i].Name < childs[j].Name })
+
+	n := CompletionNode{
+		Name:        e.Name,
+		Description: e.Description,
+	}
+	if e.IsList() {
+		var top *CompletionNode = nil
+		var tail *CompletionNode = nil
+		for _, word := range strings.Fields(e.Key) {
+			newTail := &CompletionNode{
+				Name:        "NAME",
+				Description: word,
+			}
+			if top == nil {
+				top = newTail
+			} else {
+				tail.Childs = append(tail.Childs, newTail)
+			}
+			tail = newTail
+		}
+		tail.Childs = childs
+		n.Childs = []*CompletionNode{top}
+	} else {
+		n.Childs = childs
+	}
+	return &n
 }
 
 func resolveCompletionNodeRPC(e *yang.Entry, depth int) *CompletionNode {
@@ -80,9 +168,25 @@ func getCommandCallbackRPC(_ *yang.Modules) []Command {
 	return []Command{
 		{
 			m: "rpc",
-			f: func(args []string) {
-				fmt.Fprintln(stdout, "NOT IMPLEMENTED")
-			},
+			f: ccbRPC,
+		},
+		{
+			m: "action",
+			f: ccbRPC,
 		},
 	}
 }
+
+func ccbRPC(ctx context.Context, args []string) (*CommandResult, error) {
+	if len(args) < 2 {
+		fmt.Fprintf(stdout, "usage: %s <name> [<input>...]\n", args[0])
+		return nil, nil
+	}
+	output, err := callRPC(args[1:])
+	if err != nil {
+		// The input is parsed in pieces, so the position is unknown
+		return nil, pinCommandError(err, -1)
+	}
+	fmt.Fprintln(stdout, output.String())
+	return nil, nil
+}
@@ -0,0 +1,151 @@
+package vtyang
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+)
+
+// editStack is the stack of the edit contexts of configure mode. The top
+// is the current context, which set, delete and show are relative to.
+var editStack []XPath
+
+// editContext returns the current edit context, the empty xpath at the top
+// of the configuration.
+func editContext() XPath {
+	if len(editStack) == 0 {
+		return XPath{}
+	}
+	return editStack[len(editStack)-1]
+}
+
+// editArgs returns the args of the path relative to the edit context
+// prefixed with the words of the context.
+func editArgs(args []string) []string {
+	return append(xpathArgs(editContext()), args...)
+}
+
+// parseEditArgs parses the path of the command relative to the edit
+// context. The argument position of the error is in the command line.
+func parseEditArgs(args []string, setmode bool) (XPath, []DBValue, error) {
+	xpath, value, err := ParseXPathArgs(dbm, editArgs(args[1:]), setmode)
+	return xpath, value, shiftCommandError(err, 1-len(xpathArgs(editContext())))
+}
+
+// editContextCommands are the commands taking the path relative to the
+// edit context.
+var editContextCommands = []string{"delete", "edit", "set", "show"}
+
+// editCompletionLine returns the line with the words of the edit context
+// inserted after the command, so that the completion is rooted at the
+// context node.
+func editCompletionLine(line string, pos int) (string, int) {
+	if cliMode != CliModeConfigure || len(editContext().Words) == 0 {
+		return line, pos
+	}
+	idx := strings.IndexByte(line, ' ')
+	if idx < 0 || idx >= pos {
+		return line, pos
+	}
+	found := false
+	for _, c := range editContextCommands {
+		if line[:idx] == c {
+			found = true
+		}
+	}
+	if !found {
+		return line, pos
+	}
+	context := " " + joinCommandLine(xpathArgs(editContext()))
+	return line[:idx] + context + line[idx:], pos + len(context)
+}
+
+func editPrompt() string {
+	return strings.Join(xpathArgs(editContext()), "-")
+}
+
+func resetEditContext() {
+	editStack = nil
+}
+
+func ccbEdit(ctx context.Context, args []string) (*CommandResult, error) {
+	if len(args) < 2 {
+		fmt.Fprintf(stdout, "Usage: edit <path>\n")
+		return nil, nil
+	}
+	xpath, _, err := parseEditArgs(args, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateEditContext(xpath); err != nil {
+		return nil, &CommandError{
+			XPath:  xpath.String(),
+			ArgPos: len(args) - 1,
+			Err:    err,
+		}
+	}
+	editStack = append(editStack, xpath)
+	return nil, nil
+}
+
+// validateEditContext checks that the xpath is a container or a list entry
+// with all of its keys.
+func validateEditContext(xpath XPath) error {
+	tail := xpath.Tail()
+	switch tail.Dbtype {
+	case Container:
+		return nil
+	case List:
+		for _, k := range tail.KeysIndex {
+			if tail.Keys[k].Value.Type == yang.Ynone {
+				return errors.Errorf("key %s of %s is missing", k, tail.Word)
+			}
+		}
+		return nil
+	default:
+		return errors.Errorf("%s is not a container or a list", tail.Word)
+	}
+}
+
+func ccbUp(ctx context.Context, args []string) (*CommandResult, error) {
+	count := 1
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return nil, &CommandError{
+				ArgPos: 1,
+				Err:    errors.Errorf("invalid count %s", args[1]),
+			}
+		}
+		count = n
+	}
+	current := editContext()
+	if len(current.Words) == 0 {
+		return nil, errors.Errorf("already at the top of the configuration")
+	}
+	if count > len(current.Words) {
+		count = len(current.Words)
+	}
+	current.Words = current.Words[:len(current.Words)-count]
+	editStack = append(editStack, current)
+	return nil, nil
+}
+
+func ccbTop(ctx context.Context, args []string) (*CommandResult, error) {
+	resetEditContext()
+	return nil, nil
+}
+
+// ccbExit returns to the previous edit context, or leaves configure mode
+// at the top of the configuration.
+func ccbExit(ctx context.Context, args []string) (*CommandResult, error) {
+	if len(editStack) == 0 {
+		return nil, quitConfigureMode()
+	}
+	editStack = editStack[:len(editStack)-1]
+	return nil, nil
+}
@@ -0,0 +1,162 @@
+package vtyang
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEditContext(t *testing.T) {
+	if err := os.RemoveAll("/tmp/run/vtyang/config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/frr_mgmtd_minimal"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	buf := setStdoutWithBuffer()
+
+	testcases := []struct {
+		in     string
+		prompt string
+		out    string
+	}{
+		{
+			in:     "configure",
+			prompt: "vtyang(config)# ",
+		},
+		{
+			in:     "up",
+			prompt: "vtyang(config)# ",
+			out:    "Error: already at the top of the configuration\n",
+		},
+		{
+			in:     "edit lib prefix-list ipv4",
+			prompt: "vtyang(config)# ",
+			out:    "Error: key name of prefix-list is missing\n",
+		},
+		{
+			in:     "edit lib prefix-list ipv4 hoge",
+			prompt: "vtyang(config-lib-prefix-list-ipv4-hoge)# ",
+		},
+		{
+			in:     "set entry 10 action permit",
+			prompt: "vtyang(config-lib-prefix-list-ipv4-hoge)# ",
+		},
+		{
+			in:     "commit",
+			prompt: "vtyang(config-lib-prefix-list-ipv4-hoge)# ",
+		},
+		{
+			in:     "edit entry 10 action",
+			prompt: "vtyang(config-lib-prefix-list-ipv4-hoge)# ",
+			out:    "Error: action is not a container or a list\n",
+		},
+		{
+			in:     "edit entry 10",
+			prompt: "vtyang(config-lib-prefix-list-ipv4-hoge-entry-10)# ",
+		},
+		{
+			in:     "set ipv4-prefix 10.0.0.0/8",
+			prompt: "vtyang(config-lib-prefix-list-ipv4-hoge-entry-10)# ",
+		},
+		{
+			in:     "show action",
+			prompt: "vtyang(config-lib-prefix-list-ipv4-hoge-entry-10)# ",
+			out:    "\"permit\"\n",
+		},
+		{
+			in:     "up 2",
+			prompt: "vtyang(config-lib)# ",
+		},
+		{
+			in:     "exit",
+			prompt: "vtyang(config-lib-prefix-list-ipv4-hoge-entry-10)# ",
+		},
+		{
+			in:     "delete ipv4-prefix",
+			prompt: "vtyang(config-lib-prefix-list-ipv4-hoge-entry-10)# ",
+		},
+		{
+			in:     "top",
+			prompt: "vtyang(config)# ",
+		},
+		{
+			in:     "commit",
+			prompt: "vtyang(config)# ",
+		},
+		{
+			in:     "exit",
+			prompt: "vtyang# ",
+		},
+		{
+			in:     "show running-config lib prefix-list ipv4 hoge entry 10 | display set",
+			prompt: "vtyang# ",
+			out:    "set lib prefix-list ipv4 hoge entry 10 action permit\n",
+		},
+	}
+	for idx, tc := range testcases {
+		getCommandNodeCurrent().executeCommand(tc.in)
+		if out := buf.String(); out != tc.out {
+			t.Errorf("tc[%d] %q: unexpected output %q", idx, tc.in, out)
+		}
+		if prompt := getPrompt(); prompt != tc.prompt {
+			t.Errorf("tc[%d] %q: unexpected prompt %q", idx, tc.in, prompt)
+		}
+		buf.Reset()
+	}
+}
+
+func TestDoCompletionEditContext(t *testing.T) {
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/frr_mgmtd_minimal"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	getCommandNodeCurrent().executeCommand("configure")
+	getCommandNodeCurrent().executeCommand("edit lib prefix-list ipv4 hoge")
+
+	testcases := []TestDoCompletionTestCase{
+		{
+			in: "set ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "<cr>"},
+					{Word: "entry"},
+					{Word: "name"},
+					{Word: "remark"},
+					{Word: "type"},
+				},
+			},
+		},
+		{
+			in: "set entry 10 action ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "deny"},
+					{Word: "permit"},
+				},
+			},
+		},
+		{
+			in: "up ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "<cr>"},
+					{Word: "COUNT"},
+				},
+			},
+		},
+	}
+	for idx := range testcases {
+		t.Logf("execute tc[%d] \"%s\"", idx, testcases[idx].in)
+		if err := executeDoCompletionTestCase(testcases, idx); err != nil {
+			t.Errorf("fail tc[%d] err=\"%s\"\n", idx, err)
+		}
+	}
+	getCommandNodeCurrent().executeCommand("quit")
+}
@@ -0,0 +1,893 @@
+package vtyang
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// GenerateGoOpts are the options of GenerateGo.
+type GenerateGoOpts struct {
+	// Package is the package name of the generated code.
+	Package string
+	// Root is the name of the struct holding the top-level nodes of all
+	// the modules.
+	Root string
+	// Modules are the names of the modules generated. The modules only
+	// imported by them are loaded but not generated. All the modules are
+	// generated when empty.
+	Modules []string
+}
+
+// GenerateGo generates the Go structs of the data nodes of modules. Each
+// struct is encoded in the JSON of RFC 7951 by MarshalJSON, decoded by
+// UnmarshalJSON and checked against the constraints of its schema by
+// Validate. The generated code only depends on the standard library.
+func GenerateGo(modules *yang.Modules, opts GenerateGoOpts) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "yang"
+	}
+	if opts.Root == "" {
+		opts.Root = "Device"
+	}
+	g := &goGenerator{
+		opts:    opts,
+		names:   map[string]bool{opts.Root: true},
+		imports: map[string]bool{"fmt": true},
+	}
+
+	names := []string{}
+	for fullname, m := range modules.Modules {
+		if strings.Contains(fullname, "@") {
+			continue
+		}
+		if len(opts.Modules) > 0 && !containsString(opts.Modules, m.Name) {
+			continue
+		}
+		names = append(names, fullname)
+	}
+	sort.Strings(names)
+	for _, name := range opts.Modules {
+		if !containsString(names, name) {
+			return nil, errors.Errorf("module %s not found", name)
+		}
+	}
+
+	root := &goStruct{
+		name:   opts.Root,
+		schema: "/" + strings.ToLower(opts.Root),
+		root:   true,
+	}
+	for _, name := range names {
+		m := modules.Modules[name]
+		fields, err := g.fields(yang.ToEntry(m), root, m.Name, false)
+		if err != nil {
+			return nil, err
+		}
+		root.fields = append(root.fields, fields...)
+	}
+	sortFields(root.fields)
+	g.structs = append([]*goStruct{root}, g.structs...)
+
+	out, err := g.generate(names)
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := format.Source(out)
+	if err != nil {
+		return nil, errors.Wrap(err, "format.Source")
+	}
+	return formatted, nil
+}
+
+type goGenerator struct {
+	opts     GenerateGoOpts
+	structs  []*goStruct
+	names    map[string]bool
+	patterns []string
+	imports  map[string]bool
+}
+
+type goStruct struct {
+	name   string
+	schema string
+	module string
+	root   bool
+	fields []*goField
+}
+
+type goFieldKind int
+
+const (
+	goFieldLeaf goFieldKind = iota
+	goFieldLeafList
+	goFieldContainer
+	goFieldList
+)
+
+type goField struct {
+	name      string
+	yname     string
+	module    string
+	kind      goFieldKind
+	entry     *yang.Entry
+	elem      string
+	child     *goStruct
+	mandatory bool
+}
+
+func (f *goField) goType(parent *goStruct) string {
+	switch f.kind {
+	case goFieldLeaf:
+		if f.elem == "Binary" || f.elem == "YANGEmpty" {
+			return f.elem
+		}
+		return "*" + f.elem
+	case goFieldLeafList:
+		return "[]" + f.elem
+	case goFieldContainer:
+		// The top-level containers always exist in the root
+		if parent.root {
+			return f.elem
+		}
+		return "*" + f.elem
+	default:
+		return "[]" + f.elem
+	}
+}
+
+// fields returns the fields of the data nodes under e. The nodes of
+// choices and cases are flattened into the struct of e.
+func (g *goGenerator) fields(e *yang.Entry, parent *goStruct,
+	module string, inChoice bool) ([]*goField, error) {
+	names := []string{}
+	for name := range e.Dir {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := []*goField{}
+	for _, name := range names {
+		ce := e.Dir[name]
+		if ce.RPC != nil || ce.Kind == yang.NotificationEntry ||
+			ce.Kind == yang.AnyDataEntry || ce.Kind == yang.AnyXMLEntry {
+			continue
+		}
+		if ce.IsChoice() || ce.IsCase() {
+			cfields, err := g.fields(ce, parent, module, true)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, cfields...)
+			continue
+		}
+
+		cmodule := module
+		if m, err := ce.InstantiatingModule(); err == nil && m != "" {
+			cmodule = m
+		}
+		f := &goField{
+			name:      goCamelCase(ce.Name),
+			yname:     ce.Name,
+			module:    cmodule,
+			entry:     ce,
+			mandatory: ce.Mandatory == yang.TSTrue && !inChoice,
+		}
+		switch {
+		case ce.IsLeaf():
+			f.kind = goFieldLeaf
+			f.elem = goLeafType(ce.Type)
+		case ce.IsLeafList():
+			f.kind = goFieldLeafList
+			f.elem = goLeafType(ce.Type)
+		case ce.IsDir():
+			f.kind = goFieldContainer
+			if ce.IsList() {
+				f.kind = goFieldList
+			}
+			prefix := parent.name
+			if parent.root {
+				prefix = goCamelCase(cmodule)
+			}
+			child := &goStruct{
+				name:   g.structName(prefix + "_" + goCamelCase(ce.Name)),
+				module: cmodule,
+			}
+			if parent.root {
+				child.schema = "/" + cmodule + "/" + ce.Name
+			} else {
+				child.schema = parent.schema + "/" + ce.Name
+			}
+			g.structs = append(g.structs, child)
+			cfields, err := g.fields(ce, child, cmodule, false)
+			if err != nil {
+				return nil, err
+			}
+			child.fields = cfields
+			sortFields(child.fields)
+			f.child = child
+			f.elem = child.name
+		default:
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func (g *goGenerator) structName(name string) string {
+	ret := name
+	for i := 2; g.names[ret]; i++ {
+		ret = fmt.Sprintf("%s%d", name, i)
+	}
+	g.names[ret] = true
+	return ret
+}
+
+// sortFields sorts the fields by name. The fields with the same name,
+// such as the nodes of different modules, are renamed with the module.
+func sortFields(fields []*goField) {
+	count := map[string]int{}
+	for _, f := range fields {
+		count[f.name]++
+	}
+	for _, f := range fields {
+		if count[f.name] > 1 {
+			f.name = goCamelCase(f.module) + "_" + f.name
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+}
+
+func goCamelCase(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+	ret := ""
+	for _, word := range words {
+		ret += strings.ToUpper(word[:1]) + word[1:]
+	}
+	if ret == "" || (ret[0] >= '0' && ret[0] <= '9') {
+		ret = "Y" + ret
+	}
+	return ret
+}
+
+func goLeafType(t *yang.YangType) string {
+	switch t.Kind {
+	case yang.Yint8:
+		return "int8"
+	case yang.Yint16:
+		return "int16"
+	case yang.Yint32:
+		return "int32"
+	case yang.Yint64:
+		return "int64"
+	case yang.Yuint8:
+		return "uint8"
+	case yang.Yuint16:
+		return "uint16"
+	case yang.Yuint32:
+		return "uint32"
+	case yang.Yuint64:
+		return "uint64"
+	case yang.Ybool:
+		return "bool"
+	case yang.Ydecimal64:
+		return "float64"
+	case yang.Ybinary:
+		return "Binary"
+	case yang.Yempty:
+		return "YANGEmpty"
+	default:
+		// enumeration, identityref, bits, leafref, union, etc.
+		return "string"
+	}
+}
+
+func (g *goGenerator) generate(modules []string) ([]byte, error) {
+	body := &bytes.Buffer{}
+	for _, s := range g.structs {
+		g.writeStruct(body, s)
+	}
+	for _, s := range g.structs {
+		g.writeMethods(body, s)
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "// Code generated by \"vtyang generate go\". DO NOT EDIT.\n\n")
+	fmt.Fprintf(out, "// Package %s contains the structs of the YANG modules:\n", g.opts.Package)
+	for _, m := range modules {
+		fmt.Fprintf(out, "//   - %s\n", m)
+	}
+	fmt.Fprintf(out, "package %s\n\n", g.opts.Package)
+	for _, imp := range goGenerateRuntimeImports {
+		g.imports[imp] = true
+	}
+	imports := []string{}
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	fmt.Fprintf(out, "import (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(out, "\t%q\n", imp)
+	}
+	fmt.Fprintf(out, ")\n\n")
+	if len(g.patterns) > 0 {
+		fmt.Fprintf(out, "var (\n")
+		for idx, p := range g.patterns {
+			fmt.Fprintf(out, "\tygenPattern%d = regexp.MustCompile(%s)\n",
+				idx, strconv.Quote(p))
+		}
+		fmt.Fprintf(out, ")\n\n")
+	}
+	out.Write(body.Bytes())
+	out.WriteString(goGenerateRuntime)
+	return out.Bytes(), nil
+}
+
+func (g *goGenerator) writeStruct(w *bytes.Buffer, s *goStruct) {
+	fmt.Fprintf(w, "// %s represents the %s YANG schema element.\n", s.name, s.schema)
+	fmt.Fprintf(w, "type %s struct {\n", s.name)
+	for _, f := range s.fields {
+		fmt.Fprintf(w, "\t%s %s `path:%q module:%q`\n",
+			f.name, f.goType(s), f.yname, f.module)
+	}
+	fmt.Fprintf(w, "}\n\n")
+}
+
+func (g *goGenerator) writeMethods(w *bytes.Buffer, s *goStruct) {
+	fmt.Fprintf(w, "// MarshalJSON encodes s in the JSON encoding of RFC 7951.\n")
+	fmt.Fprintf(w, "func (s %s) MarshalJSON() ([]byte, error) {\n", s.name)
+	fmt.Fprintf(w, "\treturn ygenMarshal(&s)\n}\n\n")
+	fmt.Fprintf(w, "// UnmarshalJSON decodes s from the JSON encoding of RFC 7951. The\n")
+	fmt.Fprintf(w, "// member names are also accepted without their module name.\n")
+	if s.root {
+		fmt.Fprintf(w, "// The members of the modules not generated are ignored.\n")
+	}
+	fmt.Fprintf(w, "func (s *%s) UnmarshalJSON(b []byte) error {\n", s.name)
+	fmt.Fprintf(w, "\treturn ygenUnmarshal(b, s, %t)\n}\n\n", s.root)
+
+	path := ""
+	if !s.root {
+		words := strings.Split(strings.TrimPrefix(s.schema, "/"), "/")
+		path = "/" + words[0] + ":" + strings.Join(words[1:], "/")
+	}
+	fmt.Fprintf(w, "// Validate checks the constraints of the YANG schema of s.\n")
+	fmt.Fprintf(w, "func (s *%s) Validate() error {\n", s.name)
+	fmt.Fprintf(w, "\treturn s.validate(%q)\n}\n\n", path)
+
+	fmt.Fprintf(w, "func (s *%s) validate(path string) error {\n", s.name)
+	for _, f := range s.fields {
+		g.writeFieldValidation(w, s, f)
+	}
+	fmt.Fprintf(w, "\treturn nil\n}\n\n")
+}
+
+// childPath returns the expression of the path of field f. The module
+// name is added when it differs from the parent as in RFC 7951.
+func childPath(s *goStruct, f *goField) string {
+	if s.root || f.module != s.module {
+		return fmt.Sprintf("path + %q", "/"+f.module+":"+f.yname)
+	}
+	return fmt.Sprintf("path + %q", "/"+f.yname)
+}
+
+func (g *goGenerator) writeFieldValidation(w *bytes.Buffer, s *goStruct, f *goField) {
+	path := childPath(s, f)
+	switch f.kind {
+	case goFieldLeaf:
+		pointer := f.elem != "Binary" && f.elem != "YANGEmpty"
+		if f.mandatory && pointer {
+			fmt.Fprintf(w, "\tif s.%s == nil {\n", f.name)
+			fmt.Fprintf(w, "\t\treturn fmt.Errorf(\"%%s: mandatory leaf is missing\", %s)\n", path)
+			fmt.Fprintf(w, "\t}\n")
+		}
+		checks := g.typeChecks(f.entry.Type, "v", path)
+		if checks == "" {
+			return
+		}
+		if pointer {
+			fmt.Fprintf(w, "\tif s.%s != nil {\n\t\tv := *s.%s\n%s\t}\n", f.name, f.name, checks)
+		} else {
+			fmt.Fprintf(w, "\t{\n\t\tv := s.%s\n%s\t}\n", f.name, checks)
+		}
+	case goFieldLeafList:
+		g.writeElements(w, f, path)
+		if checks := g.typeChecks(f.entry.Type, "v", path); checks != "" {
+			fmt.Fprintf(w, "\tfor _, v := range s.%s {\n%s\t}\n", f.name, checks)
+		}
+	case goFieldContainer:
+		if s.root {
+			fmt.Fprintf(w, "\tif err := s.%s.validate(%s); err != nil {\n", f.name, path)
+			fmt.Fprintf(w, "\t\treturn err\n\t}\n")
+			return
+		}
+		fmt.Fprintf(w, "\tif s.%s != nil {\n", f.name)
+		fmt.Fprintf(w, "\t\tif err := s.%s.validate(%s); err != nil {\n", f.name, path)
+		fmt.Fprintf(w, "\t\t\treturn err\n\t\t}\n\t}\n")
+	case goFieldList:
+		g.writeElements(w, f, path)
+		keys := strings.Fields(f.entry.Key)
+		fmt.Fprintf(w, "\tif len(s.%s) > 0 {\n", f.name)
+		fmt.Fprintf(w, "\t\tkeys := map[string]bool{}\n")
+		fmt.Fprintf(w, "\t\tfor i := range s.%s {\n", f.name)
+		fmt.Fprintf(w, "\t\t\te := &s.%s[i]\n", f.name)
+		if len(keys) == 0 {
+			fmt.Fprintf(w, "\t\t\tkey := fmt.Sprintf(\"[%%d]\", i+1)\n")
+		} else {
+			format, args := "", []string{}
+			for _, key := range keys {
+				kf := fieldByYangName(f.child, key)
+				if kf == nil {
+					continue
+				}
+				format += "[" + key + "='%v']"
+				if kf.elem == "Binary" || kf.elem == "YANGEmpty" {
+					args = append(args, "e."+kf.name)
+					continue
+				}
+				fmt.Fprintf(w, "\t\t\tif e.%s == nil {\n", kf.name)
+				fmt.Fprintf(w, "\t\t\t\treturn fmt.Errorf(\"%%s: key %s is missing\", %s)\n", key, path)
+				fmt.Fprintf(w, "\t\t\t}\n")
+				args = append(args, "*e."+kf.name)
+			}
+			fmt.Fprintf(w, "\t\t\tkey := fmt.Sprintf(%q, %s)\n", format, strings.Join(args, ", "))
+		}
+		fmt.Fprintf(w, "\t\t\tif keys[key] {\n")
+		fmt.Fprintf(w, "\t\t\t\treturn fmt.Errorf(\"%%s%%s: duplicated\", %s, key)\n", path)
+		fmt.Fprintf(w, "\t\t\t}\n")
+		fmt.Fprintf(w, "\t\t\tkeys[key] = true\n")
+		fmt.Fprintf(w, "\t\t\tif err := e.validate(%s + key); err != nil {\n", path)
+		fmt.Fprintf(w, "\t\t\t\treturn err\n\t\t\t}\n\t\t}\n\t}\n")
+	}
+}
+
+func (g *goGenerator) writeElements(w *bytes.Buffer, f *goField, path string) {
+	attr := f.entry.ListAttr
+	if attr == nil {
+		return
+	}
+	if attr.MinElements > 0 {
+		fmt.Fprintf(w, "\tif len(s.%s) < %d {\n", f.name, attr.MinElements)
+		fmt.Fprintf(w, "\t\treturn fmt.Errorf(\"%%s: %%d entries are less than min-elements %d\", %s, len(s.%s))\n",
+			attr.MinElements, path, f.name)
+		fmt.Fprintf(w, "\t}\n")
+	}
+	if attr.MaxElements != 0 && attr.MaxElements < 1<<31 {
+		fmt.Fprintf(w, "\tif len(s.%s) > %d {\n", f.name, attr.MaxElements)
+		fmt.Fprintf(w, "\t\treturn fmt.Errorf(\"%%s: %%d entries are more than max-elements %d\", %s, len(s.%s))\n",
+			attr.MaxElements, path, f.name)
+		fmt.Fprintf(w, "\t}\n")
+	}
+}
+
+func fieldByYangName(s *goStruct, name string) *goField {
+	for _, f := range s.fields {
+		if f.yname == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// typeChecks returns the code checking the value v of type t. The
+// restrictions of unions, leafrefs and identityrefs aren't checked.
+func (g *goGenerator) typeChecks(t *yang.YangType, v, path string) string {
+	w := &bytes.Buffer{}
+	switch t.Kind {
+	case yang.Yint8, yang.Yint16, yang.Yint32, yang.Yint64,
+		yang.Yuint8, yang.Yuint16, yang.Yuint32, yang.Yuint64,
+		yang.Ydecimal64:
+		if cond := rangeCondition(t.Range, t.Kind, v); cond != "" {
+			fmt.Fprintf(w, "\t\tif !(%s) {\n", cond)
+			fmt.Fprintf(w, "\t\t\treturn fmt.Errorf(\"%%s: %%v is out of range %s\", %s, %s)\n",
+				t.Range, path, v)
+			fmt.Fprintf(w, "\t\t}\n")
+		}
+	case yang.Ystring:
+		if cond := rangeCondition(t.Length, yang.Yuint64, "n"); cond != "" {
+			g.imports["unicode/utf8"] = true
+			fmt.Fprintf(w, "\t\tif n := uint64(utf8.RuneCountInString(%s)); !(%s) {\n", v, cond)
+			fmt.Fprintf(w, "\t\t\treturn fmt.Errorf(\"%%s: length of %%q is out of range %s\", %s, %s)\n",
+				t.Length, path, v)
+			fmt.Fprintf(w, "\t\t}\n")
+		}
+		for _, p := range append(append([]string{}, t.Pattern...), t.POSIXPattern...) {
+			idx, ok := g.pattern(p)
+			if !ok {
+				fmt.Fprintf(w, "\t\t// pattern %q isn't supported by regexp\n", p)
+				continue
+			}
+			fmt.Fprintf(w, "\t\tif !ygenPattern%d.MatchString(%s) {\n", idx, v)
+			fmt.Fprintf(w, "\t\t\treturn fmt.Errorf(\"%%s: %%q doesn't match pattern %%q\", %s, %s, %q)\n",
+				path, v, p)
+			fmt.Fprintf(w, "\t\t}\n")
+		}
+	case yang.Ybinary:
+		if cond := rangeCondition(t.Length, yang.Yuint64, "n"); cond != "" {
+			fmt.Fprintf(w, "\t\tif n := uint64(len(%s)); !(%s) {\n", v, cond)
+			fmt.Fprintf(w, "\t\t\treturn fmt.Errorf(\"%%s: length %%d is out of range %s\", %s, len(%s))\n",
+				t.Length, path, v)
+			fmt.Fprintf(w, "\t\t}\n")
+		}
+	case yang.Yenum:
+		if t.Enum == nil {
+			break
+		}
+		names := t.Enum.Names()
+		sort.Strings(names)
+		quoted := []string{}
+		for _, name := range names {
+			quoted = append(quoted, strconv.Quote(name))
+		}
+		fmt.Fprintf(w, "\t\tswitch %s {\n\t\tcase %s:\n\t\tdefault:\n", v,
+			strings.Join(quoted, ", "))
+		fmt.Fprintf(w, "\t\t\treturn fmt.Errorf(\"%%s: %%q is not one of %s\", %s, %s)\n",
+			strings.Join(names, ", "), path, v)
+		fmt.Fprintf(w, "\t\t}\n")
+	}
+	return w.String()
+}
+
+func (g *goGenerator) pattern(p string) (int, bool) {
+	expr := "^(?:" + p + ")$"
+	if _, err := regexp.Compile(expr); err != nil {
+		return 0, false
+	}
+	for idx, q := range g.patterns {
+		if q == expr {
+			return idx, true
+		}
+	}
+	g.imports["regexp"] = true
+	g.patterns = append(g.patterns, expr)
+	return len(g.patterns) - 1, true
+}
+
+var goKindRanges = map[yang.TypeKind]yang.YangRange{
+	yang.Yint8:   yang.Int8Range,
+	yang.Yint16:  yang.Int16Range,
+	yang.Yint32:  yang.Int32Range,
+	yang.Yint64:  yang.Int64Range,
+	yang.Yuint8:  yang.Uint8Range,
+	yang.Yuint16: yang.Uint16Range,
+	yang.Yuint32: yang.Uint32Range,
+	yang.Yuint64: yang.Uint64Range,
+}
+
+// rangeCondition returns the condition of v in r. The bounds of the type
+// of kind are omitted, and "" is returned when nothing is checked.
+func rangeCondition(r yang.YangRange, kind yang.TypeKind, v string) string {
+	isTypeBound := func(n yang.Number, min bool) bool {
+		if kind == yang.Ydecimal64 {
+			return n.Value >= 1<<63-1
+		}
+		bounds, ok := goKindRanges[kind]
+		if !ok || len(bounds) == 0 {
+			return false
+		}
+		if min {
+			return n.Equal(bounds[0].Min)
+		}
+		return n.Equal(bounds[0].Max)
+	}
+	conds := []string{}
+	for _, yr := range r {
+		words := []string{}
+		if !isTypeBound(yr.Min, true) {
+			words = append(words, fmt.Sprintf("%s >= %s", v, yr.Min))
+		}
+		if !isTypeBound(yr.Max, false) {
+			words = append(words, fmt.Sprintf("%s <= %s", v, yr.Max))
+		}
+		if len(words) == 0 {
+			return ""
+		}
+		conds = append(conds, "("+strings.Join(words, " && ")+")")
+	}
+	return strings.Join(conds, " || ")
+}
+
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+var goGenerateRuntimeImports = []string{
+	"bytes",
+	"encoding/base64",
+	"encoding/json",
+	"reflect",
+	"strconv",
+}
+
+// goGenerateRuntime is the code of the generated file encoding and
+// decoding the structs with reflection through their tags.
+const goGenerateRuntime = `
+// Binary is a type that is used for fields that have a YANG type of
+// binary. It is used such that binary fields can be distinguished from
+// leaf-lists of uint8s (which are mapped to []uint8, equivalent to
+// []byte in reflection).
+type Binary []byte
+
+// YANGEmpty is a type that is used for fields that have a YANG type of
+// empty. It is used such that empty fields can be distinguished from boolean fields
+// in the generated code.
+type YANGEmpty bool
+
+var (
+	ygenBinaryType = reflect.TypeOf(Binary(nil))
+	ygenEmptyType  = reflect.TypeOf(YANGEmpty(false))
+)
+
+func ygenMarshal(s interface{}) ([]byte, error) {
+	return json.Marshal(ygenEncodeStruct(reflect.ValueOf(s).Elem(), ""))
+}
+
+func ygenEncodeStruct(v reflect.Value, module string) map[string]interface{} {
+	m := map[string]interface{}{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, fmodule := t.Field(i).Tag.Get("path"), t.Field(i).Tag.Get("module")
+		if fmodule != module {
+			name = fmodule + ":" + name
+		}
+		if value, ok := ygenEncodeValue(v.Field(i), fmodule); ok {
+			m[name] = value
+		}
+	}
+	return m
+}
+
+// ygenEncodeValue returns the value of v in JSON. The 64-bit numbers are
+// encoded as strings as in RFC 7951.
+func ygenEncodeValue(v reflect.Value, module string) (interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, false
+		}
+		return ygenEncodeValue(v.Elem(), module)
+	case reflect.Struct:
+		m := ygenEncodeStruct(v, module)
+		return m, len(m) > 0
+	case reflect.Slice:
+		if v.Type() == ygenBinaryType {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), v.Len() > 0
+		}
+		a := []interface{}{}
+		for i := 0; i < v.Len(); i++ {
+			e, _ := ygenEncodeValue(v.Index(i), module)
+			a = append(a, e)
+		}
+		return a, len(a) > 0
+	case reflect.Bool:
+		if v.Type() == ygenEmptyType {
+			return []interface{}{nil}, v.Bool()
+		}
+		return v.Bool(), true
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return v.Int(), true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return v.Uint(), true
+	case reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	case reflect.String:
+		return v.String(), true
+	}
+	return nil, false
+}
+
+func ygenUnmarshal(b []byte, s interface{}, root bool) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	m := map[string]interface{}{}
+	if err := d.Decode(&m); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(s).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return ygenDecodeStruct(m, v, "", root)
+}
+
+func ygenDecodeStruct(m map[string]interface{}, v reflect.Value,
+	path string, root bool) error {
+	t := v.Type()
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		name, module := t.Field(i).Tag.Get("path"), t.Field(i).Tag.Get("module")
+		fields[name] = i
+		fields[module+":"+name] = i
+	}
+	for name, value := range m {
+		i, ok := fields[name]
+		if !ok {
+			if root {
+				continue
+			}
+			return fmt.Errorf("%s/%s: unknown member", path, name)
+		}
+		if err := ygenDecodeValue(value, v.Field(i), path+"/"+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ygenDecodeValue sets value to v. The numbers and booleans are also
+// accepted as strings, and the strings as numbers and booleans.
+func ygenDecodeValue(value interface{}, v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		n := reflect.New(v.Type().Elem())
+		if err := ygenDecodeValue(value, n.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(n)
+		return nil
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: object is expected", path)
+		}
+		return ygenDecodeStruct(m, v, path, false)
+	case reflect.Slice:
+		if v.Type() == ygenBinaryType {
+			s, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s: base64 string is expected", path)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		a, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: array is expected", path)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(a), len(a))
+		for i, e := range a {
+			if err := ygenDecodeValue(e, slice.Index(i), path); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Bool:
+		if v.Type() == ygenEmptyType {
+			v.SetBool(true)
+			return nil
+		}
+		if b, ok := value.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+		b, err := strconv.ParseBool(ygenScalarString(value))
+		if err != nil {
+			return fmt.Errorf("%s: boolean is expected", path)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		if b, ok := value.(bool); ok {
+			v.SetString(strconv.FormatBool(b))
+			return nil
+		}
+		switch value.(type) {
+		case string, json.Number:
+			v.SetString(ygenScalarString(value))
+			return nil
+		}
+		return fmt.Errorf("%s: string is expected", path)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(ygenScalarString(value), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(ygenScalarString(value), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(ygenScalarString(value), 64)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("%s: unsupported type %s", path, v.Type())
+}
+
+func ygenScalarString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+`
+
+var (
+	GlobalOptGenerateOutput  string
+	GlobalOptGeneratePackage string
+	GlobalOptGenerateRoot    string
+	GlobalOptGenerateModules []string
+)
+
+func newCommandGenerate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate code from the yang modules",
+	}
+	goCmd := &cobra.Command{
+		Use:   "go",
+		Short: "Generate Go structs of the yang modules",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			modules, err := yangModulesPath(GlobalOptYangPath)
+			if err != nil {
+				return err
+			}
+			out, err := GenerateGo(modules, GenerateGoOpts{
+				Package: GlobalOptGeneratePackage,
+				Root:    GlobalOptGenerateRoot,
+				Modules: GlobalOptGenerateModules,
+			})
+			if err != nil {
+				return err
+			}
+			if GlobalOptGenerateOutput == "" || GlobalOptGenerateOutput == "-" {
+				_, err := cmd.OutOrStdout().Write(out)
+				return err
+			}
+			return os.WriteFile(GlobalOptGenerateOutput, out, 0644)
+		},
+	}
+	fs := goCmd.Flags()
+	fs.StringArrayVarP(&GlobalOptYangPath, "yang", "y", []string{}, "Yang file path")
+	fs.StringVarP(&GlobalOptGenerateOutput, "output", "o", "",
+		"Output file (default stdout)")
+	fs.StringVar(&GlobalOptGeneratePackage, "package", "yang",
+		"Package name of the generated code")
+	fs.StringVar(&GlobalOptGenerateRoot, "root", "Device",
+		"Name of the struct holding the top-level nodes")
+	fs.StringArrayVarP(&GlobalOptGenerateModules, "module", "m", []string{},
+		"Module to generate, all the modules when not specified")
+	cmd.AddCommand(goCmd)
+	return cmd
+}
@@ -0,0 +1,79 @@
+package vtyang
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestGenerateGoLinuxAgent checks that the structs of linux-agent are
+// generated from the latest yang/linux-agent.yang.
+func TestGenerateGoLinuxAgent(t *testing.T) {
+	modules, err := yangModulesPath([]string{"../../yang"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := GenerateGo(modules, GenerateGoOpts{
+		Package: "yang",
+		Modules: []string{"linux-agent"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile("../linux-agent/yang/generated.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(expected) {
+		t.Errorf("generated.go is outdated, run go generate ./cmd/linux-agent")
+	}
+
+	if _, err := GenerateGo(modules, GenerateGoOpts{
+		Modules: []string{"no-such-module"},
+	}); err == nil || err.Error() != "module no-such-module not found" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// TestGenerateGoTypeCheck checks that the code generated from the yang
+// modules of the tests compiles.
+func TestGenerateGoTypeCheck(t *testing.T) {
+	imp := importer.ForCompiler(token.NewFileSet(), "source", nil)
+	for _, dir := range []string{
+		"basic",
+		"choice_case",
+		"display",
+		"frr_mgmtd_minimal",
+		"multi_module",
+		"operstate",
+		"same_container_name_in_different_modules",
+	} {
+		t.Run(dir, func(t *testing.T) {
+			modules, err := yangModulesPath([]string{"./testdata/yang/" + dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := GenerateGo(modules, GenerateGoOpts{Package: "gen"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "generated.go", out, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := types.Config{Importer: imp}
+			if _, err := conf.Check("gen", fset, []*ast.File{f}, nil); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(out), "type Device struct") {
+				t.Errorf("root struct not generated")
+			}
+		})
+	}
+}
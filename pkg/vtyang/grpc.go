@@ -0,0 +1,410 @@
+package vtyang
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	vtyangapi "github.com/slankdev/vtyang/pkg/grpc/api"
+)
+
+var (
+	grpcServer   *grpc.Server
+	grpcListener net.Listener
+)
+
+// startGrpcServer serves AgentService. The server listening on the
+// address other than loopback requires the token, as the agents can read
+// and change the config through the service.
+func startGrpcServer(opts *AgentOptsGrpc) error {
+	stopGrpcServer()
+	token := ""
+	if opts.TokenFile != "" {
+		b, err := os.ReadFile(opts.TokenFile)
+		if err != nil {
+			return errors.Wrap(err, "os.ReadFile")
+		}
+		token = strings.TrimSpace(string(b))
+		if token == "" {
+			return errors.Errorf("token file %s is empty", opts.TokenFile)
+		}
+	} else if !loopbackAddress(opts.Address) {
+		return errors.Errorf("grpc address %s isn't loopback, token file "+
+			"is required", opts.Address)
+	}
+	lis, err := net.Listen("tcp", opts.Address)
+	if err != nil {
+		return errors.Wrapf(err, "net.Listen(%s)", opts.Address)
+	}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{},
+			info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := grpcAuthorize(ctx, token); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream,
+			info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := grpcAuthorize(ss.Context(), token); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	vtyangapi.RegisterAgentServiceServer(s, &agentServiceServer{})
+	grpcServer = s
+	grpcListener = lis
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			log.Printf("grpc server stopped: %s\n", err)
+		}
+	}()
+	log.Printf("grpc server listening on %s\n", lis.Addr())
+	return nil
+}
+
+// loopbackAddress reports whether the host of address is "localhost" or
+// a loopback IP address.
+func loopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// grpcAuthorize checks the token in the authorization metadata. Any
+// request is authorized when token is empty.
+func grpcAuthorize(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(value),
+			[]byte("Bearer "+token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid token")
+}
+
+func stopGrpcServer() {
+	if grpcServer != nil {
+		grpcServer.Stop()
+		grpcServer = nil
+		grpcListener = nil
+	}
+}
+
+type agentServiceServer struct {
+	vtyangapi.UnimplementedAgentServiceServer
+}
+
+// Connect serves one agent. The first message must be a registration,
+// then the agent answers requests sent by vtyang over the same stream.
+func (s *agentServiceServer) Connect(stream vtyangapi.AgentService_ConnectServer) error {
+	msg, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	reg := msg.GetRegister()
+	if reg == nil {
+		return errors.Errorf("first message must be register")
+	}
+
+	agent := newAgentConn(reg.Name, stream)
+	providerName := agent.providerName()
+	if len(reg.OperStatePaths) > 0 {
+		handle := RegisterOperStateProvider(providerName, reg.OperStatePaths,
+			agent)
+		defer UnregisterOperStateProvider(handle)
+	}
+	rpcHandles := []RPCHandle{}
+	defer func() {
+		for _, handle := range rpcHandles {
+			UnregisterRPCHandler(handle)
+		}
+	}()
+	for _, path := range reg.Rpcs {
+		handle, err := RegisterRPCHandler(path, agent)
+		if err != nil {
+			log.Printf("agent %s rejected: %s\n", reg.Name, err)
+			return err
+		}
+		rpcHandles = append(rpcHandles, handle)
+	}
+	if len(reg.ConfigPaths) > 0 {
+		handle, err := RegisterDriftSource(providerName, reg.ConfigPaths, agent)
+		if err != nil {
+			log.Printf("agent %s rejected: %s\n", reg.Name, err)
+			return err
+		}
+		defer UnregisterDriftSource(handle)
+		go agent.pushRunningConfig(stream.Context())
+	}
+	log.Printf("agent %s connected\n", reg.Name)
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			log.Printf("agent %s disconnected: %s\n", reg.Name, err)
+			return nil
+		}
+		switch m := msg.Message.(type) {
+		case *vtyangapi.AgentMessage_OperStateReply:
+			agent.deliver(m.OperStateReply.ReqId, msg)
+		case *vtyangapi.AgentMessage_RpcReply:
+			agent.deliver(m.RpcReply.ReqId, msg)
+		case *vtyangapi.AgentMessage_ConfigApplyReply:
+			agent.deliver(m.ConfigApplyReply.ReqId, msg)
+		case *vtyangapi.AgentMessage_ConfigState:
+			agent.setConfigState(m.ConfigState)
+		case *vtyangapi.AgentMessage_Notification:
+			if err := agent.publish(m.Notification); err != nil {
+				log.Printf("agent %s notification ignored: %s\n", reg.Name, err)
+			}
+		default:
+			log.Printf("agent %s sent unexpected message %T\n", reg.Name, m)
+		}
+	}
+}
+
+// agentConn is a connected agent. Requests are correlated with their
+// replies by req_id.
+type agentConn struct {
+	name    string
+	stream  vtyangapi.AgentService_ConnectServer
+	sendMu  sync.Mutex
+	mu      sync.Mutex
+	reqId   uint64
+	pending map[uint64]chan *vtyangapi.AgentMessage
+	// configState is the config last reported as applied by the agent
+	configState []YangData
+}
+
+func newAgentConn(name string,
+	stream vtyangapi.AgentService_ConnectServer) *agentConn {
+	return &agentConn{
+		name:    name,
+		stream:  stream,
+		pending: map[uint64]chan *vtyangapi.AgentMessage{},
+	}
+}
+
+func (a *agentConn) providerName() string {
+	return "agent:" + a.name
+}
+
+func (a *agentConn) deliver(reqId uint64, msg *vtyangapi.AgentMessage) {
+	a.mu.Lock()
+	ch, ok := a.pending[reqId]
+	delete(a.pending, reqId)
+	a.mu.Unlock()
+	if !ok {
+		log.Printf("agent %s sent reply for unknown req_id %d\n", a.name, reqId)
+		return
+	}
+	ch <- msg
+}
+
+// request sends the request built by f and waits for its reply.
+func (a *agentConn) request(ctx context.Context,
+	f func(reqId uint64) *vtyangapi.AgentRequest) (*vtyangapi.AgentMessage, error) {
+	a.mu.Lock()
+	a.reqId++
+	reqId := a.reqId
+	ch := make(chan *vtyangapi.AgentMessage, 1)
+	a.pending[reqId] = ch
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.pending, reqId)
+		a.mu.Unlock()
+	}()
+
+	a.sendMu.Lock()
+	err := a.stream.Send(f(reqId))
+	a.sendMu.Unlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "stream.Send")
+	}
+
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (a *agentConn) GetOperState(ctx context.Context,
+	xpath XPath) ([]YangData, error) {
+	msg, err := a.request(ctx, func(reqId uint64) *vtyangapi.AgentRequest {
+		return &vtyangapi.AgentRequest{
+			Request: &vtyangapi.AgentRequest_OperStateReq{
+				OperStateReq: &vtyangapi.OperStateRequest{
+					ReqId: reqId,
+					Xpath: xpath.String(),
+				},
+			},
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	reply := msg.GetOperStateReply()
+	if reply == nil {
+		return nil, errors.Errorf("agent %s: unexpected reply %T", a.name, msg.Message)
+	}
+	if reply.Error != "" {
+		return nil, errors.Errorf("agent %s: %s", a.name, reply.Error)
+	}
+	datas := []YangData{}
+	for _, data := range reply.Data {
+		xp, err := ParseXPathString(dbm, data.Xpath)
+		if err != nil {
+			log.Printf("agent %s operstate %s ignored: %s\n",
+				a.name, data.Xpath, err)
+			continue
+		}
+		datas = append(datas, YangData{XPath: xp, Value: data.Value})
+	}
+	return datas, nil
+}
+
+func (a *agentConn) CallRPC(ctx context.Context, xpath XPath,
+	input *DBNode) (*DBNode, error) {
+	msg, err := a.request(ctx, func(reqId uint64) *vtyangapi.AgentRequest {
+		return &vtyangapi.AgentRequest{
+			Request: &vtyangapi.AgentRequest_RpcReq{
+				RpcReq: &vtyangapi.RPCRequest{
+					ReqId: reqId,
+					Xpath: xpath.String(),
+					Input: input.String(),
+				},
+			},
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	reply := msg.GetRpcReply()
+	if reply == nil {
+		return nil, errors.Errorf("agent %s: unexpected reply %T", a.name, msg.Message)
+	}
+	if reply.Error != "" {
+		return nil, errors.Errorf("agent %s: %s", a.name, reply.Error)
+	}
+	if reply.Output == "" {
+		return &DBNode{Type: Container}, nil
+	}
+	return ReadFromJsonString(reply.Output)
+}
+
+func (a *agentConn) publish(n *vtyangapi.Notification) error {
+	eventTime := time.Time{}
+	if n.EventTime != "" {
+		t, err := time.Parse(time.RFC3339, n.EventTime)
+		if err != nil {
+			return errors.Wrap(err, "time.Parse")
+		}
+		eventTime = t
+	}
+	return PublishNotificationJson(n.Stream, n.Xpath, eventTime, n.Data)
+}
+
+func (a *agentConn) setConfigState(state *vtyangapi.ConfigState) {
+	datas := []YangData{}
+	for _, data := range state.Data {
+		xp, err := ParseXPathString(dbm, data.Xpath)
+		if err != nil {
+			log.Printf("agent %s config state %s ignored: %s\n",
+				a.name, data.Xpath, err)
+			continue
+		}
+		datas = append(datas, YangData{XPath: xp, Value: data.Value})
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configState = datas
+}
+
+func (a *agentConn) GetConfig(ctx context.Context) ([]YangData, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]YangData{}, a.configState...), nil
+}
+
+// PushConfig sends the whole config to the agent, which is expected to
+// report the applied config again.
+func (a *agentConn) PushConfig(ctx context.Context, root *DBNode) error {
+	msg, err := a.request(ctx, func(reqId uint64) *vtyangapi.AgentRequest {
+		return &vtyangapi.AgentRequest{
+			Request: &vtyangapi.AgentRequest_ConfigApplyReq{
+				ConfigApplyReq: &vtyangapi.ConfigApplyRequest{
+					ReqId:  reqId,
+					Config: root.String(),
+				},
+			},
+		}
+	})
+	if err != nil {
+		return err
+	}
+	reply := msg.GetConfigApplyReply()
+	if reply == nil {
+		return errors.Errorf("agent %s: unexpected reply %T", a.name, msg.Message)
+	}
+	if reply.Error != "" {
+		return errors.Errorf("agent %s: %s", a.name, reply.Error)
+	}
+	return nil
+}
+
+// pushRunningConfig sends the running config to the agent connected.
+func (a *agentConn) pushRunningConfig(ctx context.Context) {
+	commandLock.Lock()
+	root := dbm.root.DeepCopy()
+	commandLock.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, driftTimeout)
+	defer cancel()
+	if err := a.PushConfig(ctx, root); err != nil {
+		log.Printf("agent %s config push failed: %s\n", a.name, err)
+	}
+}
+
+// pushAgentConfig sends the committed config to the agents managing
+// config. The failures are warned as the config is already committed,
+// they are left to the drift check.
+func pushAgentConfig(root *DBNode) {
+	for _, reg := range lookupDriftSources("") {
+		agent, ok := reg.source.(*agentConn)
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), driftTimeout)
+		err := agent.PushConfig(ctx, root)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(stdout, "Warning: %s ... ignored\n", err.Error())
+		}
+	}
+}
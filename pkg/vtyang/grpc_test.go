@@ -0,0 +1,86 @@
+package vtyang
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	vtyangapi "github.com/slankdev/vtyang/pkg/grpc/api"
+)
+
+func TestGrpcToken(t *testing.T) {
+	resetRPCHandlers()
+	defer resetRPCHandlers()
+	defer stopGrpcServer()
+
+	err := startGrpcServer(&AgentOptsGrpc{Address: "0.0.0.0:0"})
+	if err == nil || err.Error() !=
+		"grpc address 0.0.0.0:0 isn't loopback, token file is required" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := startGrpcServer(&AgentOptsGrpc{
+		Address:   "127.0.0.1:0",
+		TokenFile: tokenFile,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.Dial(grpcListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	connect := func(token string) vtyangapi.AgentService_ConnectClient {
+		ctx := context.Background()
+		if token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization",
+				"Bearer "+token)
+		}
+		stream, err := vtyangapi.NewAgentServiceClient(conn).Connect(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&vtyangapi.AgentMessage{
+			Message: &vtyangapi.AgentMessage_Register{
+				Register: &vtyangapi.AgentRegister{
+					Name: "agent0",
+					Rpcs: []string{"/ping"},
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return stream
+	}
+
+	// The agent without the valid token is rejected
+	for _, token := range []string{"", "hoge"} {
+		if _, err := connect(token).Recv(); status.Code(err) !=
+			codes.Unauthenticated {
+			t.Fatalf("token %q: unexpected error %v", token, err)
+		}
+	}
+	if lookupRPCHandler(XPath{Words: []XWord{{Word: "ping"}}}) != nil {
+		t.Fatal("agent registered without the token")
+	}
+
+	connect("secret")
+	for i := 0; lookupRPCHandler(XPath{Words: []XWord{{Word: "ping"}}}) == nil; i++ {
+		if i > 100 {
+			t.Fatal("agent not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,405 @@
+package vtyang
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+)
+
+// loadMode is how "load" applies the file to the candidate.
+type loadMode string
+
+const (
+	// loadModeMerge merges the file into the candidate
+	loadModeMerge loadMode = "merge"
+	// loadModeReplace replaces the top-level containers in the file
+	loadModeReplace loadMode = "replace"
+	// loadModeOverride replaces the whole candidate with the file
+	loadModeOverride loadMode = "override"
+	// loadModeSet executes the set and delete commands of the file
+	loadModeSet loadMode = "set"
+)
+
+func ccbLoad(ctx context.Context, args []string) (*CommandResult, error) {
+	if len(args) != 3 {
+		fmt.Fprintf(stdout, "Usage: load merge|replace|override|set <file>\n")
+		return nil, nil
+	}
+	if err := checkFileAccess(); err != nil {
+		return nil, err
+	}
+	if err := loadConfigFile(loadMode(args[1]), args[2]); err != nil {
+		// The position in the file is in the message
+		return nil, pinCommandError(err, 2)
+	}
+	return &CommandResult{Message: "load complete"}, nil
+}
+
+// loadConfigFile updates the candidate with the config file in JSON, XML
+// or set commands. The candidate isn't changed when any of the config is
+// invalid, or mgmtd rejects it.
+func loadConfigFile(mode loadMode, filename string) error {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	cmds, err := readConfigCommands(raw, mode == loadModeSet)
+	if err != nil {
+		return errors.Wrap(err, filename)
+	}
+
+	candidate := dbm.candidateRoot.DeepCopy()
+	switch mode {
+	case loadModeMerge, loadModeSet:
+	case loadModeOverride:
+		candidate = &DBNode{Type: Container}
+	case loadModeReplace:
+		for _, cmd := range cmds {
+			if len(cmd.args) > 1 && cmd.args[0] == "set" {
+				deleteDBNodeChild(candidate, cmd.args[1])
+			}
+		}
+	default:
+		return errors.Errorf("unknown load mode %s", mode)
+	}
+
+	dbm0 := NewDatabaseManager()
+	dbm0.candidateRoot = candidate
+	for _, cmd := range cmds {
+		if err := applyConfigCommand(dbm0, cmd.args); err != nil {
+			return errors.Wrapf(err, "%s: %s", filename, cmd.pos)
+		}
+	}
+	// The candidate is restored when mgmtd rejects it
+	prev := dbm.candidateRoot
+	dbm.candidateRoot = dbm0.candidateRoot
+
+	if agentOpts.BackendMgmtd != nil {
+		ctx, cancel := mgmtdContext()
+		defer cancel()
+		if err := mgmtdPushCandidate(ctx); err != nil {
+			dbm.candidateRoot = prev
+			return errors.Wrap(err, "mgmtdPushCandidate")
+		}
+	}
+	return nil
+}
+
+// configCommand is a set or delete command read from the config file with
+// its position for the error message.
+type configCommand struct {
+	pos  string
+	args []string
+}
+
+// readConfigCommands returns the commands recreating the config of the
+// file. The format is detected by the first character, '{' for JSON, '<'
+// for XML and set commands otherwise.
+func readConfigCommands(raw []byte, setOnly bool) ([]configCommand, error) {
+	trimmed := bytes.TrimSpace(raw)
+	var root *DBNode
+	var err error
+	switch {
+	case setOnly || len(trimmed) == 0:
+		return readConfigSetCommands(raw)
+	case trimmed[0] == '{':
+		root, err = readConfigJSON(trimmed)
+	case trimmed[0] == '<':
+		root, err = readConfigXML(trimmed)
+	default:
+		return readConfigSetCommands(raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := displaySetLines(root, XPath{})
+	if err != nil {
+		return nil, errors.Wrap(err, "displaySetLines")
+	}
+	cmds := []configCommand{}
+	for _, line := range lines {
+		segments, err := splitCommandLine(line)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, configCommand{pos: line, args: segments[0]})
+	}
+	return cmds, nil
+}
+
+// readConfigSetCommands reads the set and delete commands, one per line.
+// The empty lines and the comments starting with '#' are skipped.
+func readConfigSetCommands(raw []byte) ([]configCommand, error) {
+	cmds := []configCommand{}
+	for idx, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pos := fmt.Sprintf("line %d", idx+1)
+		segments, err := splitCommandLine(line)
+		if err != nil {
+			return nil, errors.Wrap(err, pos)
+		}
+		if len(segments) != 1 {
+			return nil, errors.Errorf("%s: unexpected |", pos)
+		}
+		args := segments[0]
+		if args[0] != "set" && args[0] != "delete" {
+			return nil, errors.Errorf("%s: %s is not set or delete", pos, args[0])
+		}
+		cmds = append(cmds, configCommand{pos: pos, args: args})
+	}
+	return cmds, nil
+}
+
+// applyConfigCommand executes the set or delete command on the candidate of
+// dbm0.
+func applyConfigCommand(dbm0 *DatabaseManager, args []string) error {
+	switch args[0] {
+	case "set":
+		xpath, value, err := ParseXPathArgs(dbm0, args[1:], true)
+		if err != nil {
+			return err
+		}
+		vals := []string{}
+		for _, v := range value {
+			vals = append(vals, v.ToString())
+		}
+		if _, err := dbm0.SetNode(xpath, strings.Join(vals, " ")); err != nil {
+			return err
+		}
+	case "delete":
+		xpath, _, err := ParseXPathArgs(dbm0, args[1:], true)
+		if err != nil {
+			return err
+		}
+		if err := dbm0.DeleteNode(xpath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteDBNodeChild(n *DBNode, name string) {
+	childs := []DBNode{}
+	for _, c := range n.Childs {
+		if c.Name != name {
+			childs = append(childs, c)
+		}
+	}
+	n.Childs = childs
+}
+
+// readConfigJSON reads the config in JSON. The member names can be
+// qualified by the module name as RFC 7951.
+func readConfigJSON(raw []byte) (*DBNode, error) {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+	root, err := Interface2DBNode(unqualifyJSONMembers(m))
+	if err != nil {
+		return nil, errors.Wrap(err, "Interface2DBNode")
+	}
+	for idx := range root.Childs {
+		child := &root.Childs[idx]
+		if err := checkConfigSchema(child, lookupRootEntry(child.Name),
+			"/"+child.Name); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+func unqualifyJSONMembers(i interface{}) interface{} {
+	switch g := i.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, v := range g {
+			if idx := strings.Index(k, ":"); idx >= 0 {
+				k = k[idx+1:]
+			}
+			m[k] = unqualifyJSONMembers(v)
+		}
+		return m
+	case []interface{}:
+		items := []interface{}{}
+		for _, v := range g {
+			items = append(items, unqualifyJSONMembers(v))
+		}
+		return items
+	}
+	return i
+}
+
+// checkConfigSchema checks that the node n and its children are the
+// config defined in the loaded modules.
+func checkConfigSchema(n *DBNode, e *yang.Entry, path string) error {
+	switch {
+	case e == nil:
+		return errors.Errorf("%s: entry is not found", path)
+	case e.ReadOnly():
+		return errors.Errorf("%s: entry is not config", path)
+	case e.IsList():
+		if n.Type != List {
+			return errors.Errorf("%s: entry is not list", path)
+		}
+		for idx := range n.Childs {
+			elem := &n.Childs[idx]
+			for _, k := range strings.Fields(e.Key) {
+				if !dbNodeHasChild(elem, k) {
+					return errors.Errorf("%s: key %s is missing", path, k)
+				}
+			}
+			if err := checkConfigSchemaChilds(elem, e, path); err != nil {
+				return err
+			}
+		}
+	case e.IsLeafList():
+		if n.Type != LeafList {
+			return errors.Errorf("%s: entry is not leaf-list", path)
+		}
+	case e.IsLeaf():
+		if n.Type != Leaf {
+			return errors.Errorf("%s: entry is not leaf", path)
+		}
+	default:
+		if n.Type != Container {
+			return errors.Errorf("%s: entry is not container", path)
+		}
+		return checkConfigSchemaChilds(n, e, path)
+	}
+	return nil
+}
+
+func checkConfigSchemaChilds(n *DBNode, e *yang.Entry, path string) error {
+	for idx := range n.Childs {
+		child := &n.Childs[idx]
+		if err := checkConfigSchema(child, lookupEntryChild(e, child.Name),
+			path+"/"+child.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xmlElement is an element of the config in XML.
+type xmlElement struct {
+	name   string
+	text   string
+	childs []*xmlElement
+}
+
+// readConfigXML reads the config in XML as NETCONF. The top-level nodes
+// are the children of <config> or <data>, or the root element itself. The
+// namespaces are ignored and the nodes are resolved by name.
+func readConfigXML(raw []byte) (*DBNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	stack := []*xmlElement{{}}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "xml")
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			elem := &xmlElement{name: t.Name.Local}
+			parent := stack[len(stack)-1]
+			parent.childs = append(parent.childs, elem)
+			stack = append(stack, elem)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			elem := stack[len(stack)-1]
+			elem.text += string(t)
+		}
+	}
+
+	tops := stack[0].childs
+	if len(tops) == 1 && (tops[0].name == "config" || tops[0].name == "data") {
+		tops = tops[0].childs
+	}
+	root := &DBNode{Type: Container}
+	for _, elem := range tops {
+		if err := xmlElementToDBNode(root, elem, lookupRootEntry(elem.name),
+			"/"+elem.name); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// xmlElementToDBNode adds the element to the children of parent. The
+// repeated elements are gathered to the list or the leaf-list.
+func xmlElementToDBNode(parent *DBNode, elem *xmlElement, e *yang.Entry,
+	path string) error {
+	switch {
+	case e == nil:
+		return errors.Errorf("%s: entry is not found", path)
+	case e.ReadOnly():
+		return errors.Errorf("%s: entry is not config", path)
+	}
+
+	var child *DBNode
+	for idx := range parent.Childs {
+		if parent.Childs[idx].Name == elem.name {
+			child = &parent.Childs[idx]
+		}
+	}
+	if child == nil {
+		parent.Childs = append(parent.Childs, DBNode{Name: elem.name})
+		child = &parent.Childs[len(parent.Childs)-1]
+	}
+
+	value := DBValue{
+		Type:   yang.Ystring,
+		String: strings.TrimSpace(elem.text),
+	}
+	switch {
+	case e.IsList():
+		child.Type = List
+		entry := DBNode{Type: Container}
+		for _, c := range elem.childs {
+			if err := xmlElementToDBNode(&entry, c, lookupEntryChild(e, c.name),
+				path+"/"+c.name); err != nil {
+				return err
+			}
+		}
+		for _, k := range strings.Fields(e.Key) {
+			if !dbNodeHasChild(&entry, k) {
+				return errors.Errorf("%s: key %s is missing", path, k)
+			}
+		}
+		child.Childs = append(child.Childs, entry)
+	case e.IsLeafList():
+		child.Type = LeafList
+		child.ArrayValue = append(child.ArrayValue, value)
+	case e.IsLeaf():
+		child.Type = Leaf
+		child.Value = value
+	default:
+		// The container defined in multiple modules appears for each
+		// namespace (e.g. <lib> of frr-filter and frr-route-map)
+		child.Type = Container
+		for _, c := range elem.childs {
+			if err := xmlElementToDBNode(child, c, lookupEntryChild(e, c.name),
+				path+"/"+c.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package vtyang
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+	"github.com/slankdev/vtyang/pkg/mgmtd/fake"
+)
+
+func TestLoad(t *testing.T) {
+	executeTestCase(t, &TestCase{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    "./testdata/yang/display",
+		OutputFile:  "./testdata/output/TestLoad.txt",
+		Inputs: []string{
+			"configure",
+			"set system hostname r1",
+			"set interfaces interface eth0 description uplink",
+			"commit",
+			"load merge ./testdata/load/merge.json",
+			"show configuration diff",
+			"load replace ./testdata/load/replace.xml",
+			"show configuration diff",
+			"load override ./testdata/load/override.set",
+			"show configuration diff",
+			"load set ./testdata/load/edit.set",
+			"show configuration diff",
+			"load merge ./testdata/load/unknown.json",
+			"load set ./testdata/load/invalid.set",
+			"load merge ./testdata/load/not-found.json",
+			"load merge",
+			"show configuration diff",
+			"commit",
+			"quit",
+			"show configuration",
+		},
+	})
+}
+
+func TestLoadMgmtd(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	executeTestCase(t, &TestCase{
+		RuntimePath:  "/tmp/run/vtyang",
+		YangPath:     "./testdata/yang/frr_mgmtd_minimal",
+		OutputFile:   "./testdata/output/TestLoadMgmtd.txt",
+		BackendMgmtd: backend,
+		Inputs: []string{
+			"configure",
+			"load merge ./testdata/load/prefix_list.set",
+		},
+	})
+	// The loaded config is pushed to the candidate of mgmtd before commit
+	xpath := "/frr-filter:lib/prefix-list[type='ipv4'][name='hoge']/entry[sequence='10']/action"
+	if v := s.Data(mgmtd.DatastoreId_CANDIDATE_DS)[xpath]; v != "permit" {
+		t.Errorf("unexpected candidate %v", s.Data(mgmtd.DatastoreId_CANDIDATE_DS))
+	}
+	if v, ok := s.Data(mgmtd.DatastoreId_RUNNING_DS)[xpath]; ok {
+		t.Errorf("unexpected running %s", v)
+	}
+	getCommandNodeCurrent().executeCommand("quit")
+}
+
+func TestLoadMgmtdRejected(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	s.InjectFault(fake.RequestSetConfig, fake.Fault{
+		Error: "Validation failed",
+		Count: 1,
+	})
+	executeTestCase(t, &TestCase{
+		RuntimePath:  "/tmp/run/vtyang",
+		YangPath:     "./testdata/yang/frr_mgmtd_minimal",
+		OutputFile:   "./testdata/output/TestLoadMgmtdRejected.txt",
+		BackendMgmtd: backend,
+		Inputs: []string{
+			"configure",
+			"load merge ./testdata/load/prefix_list.set",
+		},
+	})
+	// The candidate isn't changed by the load rejected by mgmtd
+	if strings.Contains(dbm.candidateRoot.String(), "hoge") {
+		t.Errorf("unexpected candidate %s", dbm.candidateRoot.String())
+	}
+	getCommandNodeCurrent().executeCommand("load merge ./testdata/load/prefix_list.set")
+	if !strings.Contains(dbm.candidateRoot.String(), "hoge") {
+		t.Errorf("unexpected candidate %s", dbm.candidateRoot.String())
+	}
+	getCommandNodeCurrent().executeCommand("quit")
+}
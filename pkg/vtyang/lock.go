@@ -0,0 +1,212 @@
+package vtyang
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DatastoreRunning = "running"
+)
+
+type LockMode string
+
+const (
+	// LockModeExclusive allows only the owner to commit
+	LockModeExclusive LockMode = "exclusive"
+	// LockModeShared is held by the sessions of configure private, it
+	// keeps the other sessions from taking the exclusive lock
+	LockModeShared LockMode = "shared"
+)
+
+// DatastoreLock is the lock of the datastore held by the session. The
+// locks are shared by the cli and the other northbound interfaces, each of
+// them locks on its own session.
+type DatastoreLock struct {
+	Datastore string
+	Mode      LockMode
+	Session   int
+	User      string
+	Since     time.Time
+
+	session *Session
+	// configure is true for the lock taken by configure, which is
+	// released on leaving configure mode
+	configure bool
+}
+
+var datastoreLocks []*DatastoreLock
+
+func resetDatastoreLocks() {
+	datastoreLocks = nil
+}
+
+// lockDatastore takes the lock of the datastore for the session. The
+// exclusive lock is denied while the other sessions hold any lock or have
+// uncommitted changes, the shared one while they hold the exclusive lock.
+func lockDatastore(s *Session, datastore string, mode LockMode,
+	configure bool) error {
+	if datastore != DatastoreRunning {
+		return errors.Errorf("unknown datastore %s", datastore)
+	}
+	for _, l := range datastoreLocks {
+		if l.Datastore != datastore {
+			continue
+		}
+		if l.session == s {
+			return errors.Errorf("%s datastore is already locked by the session",
+				datastore)
+		}
+		if mode == LockModeExclusive || l.Mode == LockModeExclusive {
+			return lockedError(l)
+		}
+	}
+	if mode == LockModeExclusive {
+		for _, other := range sessions {
+			if other != s && other.hasChanges() {
+				return errors.Errorf("session %d has uncommitted changes",
+					other.Id)
+			}
+		}
+	}
+	datastoreLocks = append(datastoreLocks, &DatastoreLock{
+		Datastore: datastore,
+		Mode:      mode,
+		Session:   s.Id,
+		User:      s.User,
+		Since:     time.Now(),
+		session:   s,
+		configure: configure,
+	})
+	return nil
+}
+
+// unlockDatastore releases the lock of the datastore held by the session.
+func unlockDatastore(s *Session, datastore string) error {
+	for idx, l := range datastoreLocks {
+		if l.Datastore == datastore && l.session == s {
+			datastoreLocks = append(datastoreLocks[:idx],
+				datastoreLocks[idx+1:]...)
+			return nil
+		}
+	}
+	return errors.Errorf("%s datastore is not locked by the session", datastore)
+}
+
+// releaseDatastoreLocks releases the locks of the session, only the ones
+// taken by configure when configureOnly is set.
+func releaseDatastoreLocks(s *Session, configureOnly bool) {
+	locks := []*DatastoreLock{}
+	for _, l := range datastoreLocks {
+		if l.session != s || (configureOnly && !l.configure) {
+			locks = append(locks, l)
+		}
+	}
+	datastoreLocks = locks
+}
+
+// checkDatastoreLock returns the error when the datastore is locked
+// exclusively by the other session.
+func checkDatastoreLock(s *Session, datastore string) error {
+	for _, l := range datastoreLocks {
+		if l.Datastore == datastore && l.Mode == LockModeExclusive &&
+			l.session != s {
+			return lockedError(l)
+		}
+	}
+	return nil
+}
+
+func lockedError(l *DatastoreLock) error {
+	return errors.Errorf("%s datastore is locked by session %d (%s)",
+		l.Datastore, l.Session, l.User)
+}
+
+// hasChanges returns true when the candidate of the session has the
+// changes not committed yet.
+func (s *Session) hasChanges() bool {
+	candidate, base := s.candidate, s.base
+	if s == currentSession {
+		candidate, base = dbm.candidateRoot, dbm.candidateBase
+	}
+	return candidate != nil && !dbNodeEqual(base, candidate)
+}
+
+// OpenSession opens the session of the northbound interface other than
+// the cli. The locks of the session are released by Close.
+func OpenSession(user, client string) *Session {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	return openSession(user, client, io.Discard, nil)
+}
+
+// Close releases the locks of the session and unregisters it.
+func (s *Session) Close() {
+	closeSession(s)
+}
+
+// LockDatastore takes the lock of the datastore for the session, as the
+// lock operation of NETCONF.
+func LockDatastore(s *Session, datastore string, mode LockMode) error {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	return lockDatastore(s, datastore, mode, false)
+}
+
+// UnlockDatastore releases the lock of the datastore held by the session.
+func UnlockDatastore(s *Session, datastore string) error {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	return unlockDatastore(s, datastore)
+}
+
+// DatastoreLocks returns the locks held by the sessions.
+func DatastoreLocks() []DatastoreLock {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	locks := []DatastoreLock{}
+	for _, l := range datastoreLocks {
+		locks = append(locks, *l)
+	}
+	return locks
+}
+
+func ccbShowConfigurationLock(ctx context.Context, args []string) (*CommandResult, error) {
+	table := newTable()
+	table.SetHeader([]string{"Datastore", "Mode", "Session", "User", "Since"})
+	for _, l := range datastoreLocks {
+		table.Append([]string{
+			l.Datastore,
+			string(l.Mode),
+			strconv.Itoa(l.Session),
+			l.User,
+			l.Since.Format("2006-01-02 15:04:05"),
+		})
+	}
+	table.Render()
+	return nil, nil
+}
+
+// ccbClearConfigurationLock releases the locks of all sessions. The
+// sessions stay in configure mode. It's allowed only for the privileged
+// sessions, as it overrides the locks of the others.
+func ccbClearConfigurationLock(ctx context.Context, args []string) (*CommandResult, error) {
+	if currentSession != nil && !currentSession.privileged {
+		return nil, errors.Errorf("permission denied, " +
+			"only the console and the privileged users can clear the locks")
+	}
+	if len(datastoreLocks) == 0 {
+		return nil, errors.Errorf("configuration is not locked")
+	}
+	for _, l := range datastoreLocks {
+		fmt.Fprintf(stdout, "%s lock of session %d released\n",
+			l.Datastore, l.Session)
+	}
+	datastoreLocks = nil
+	return nil, nil
+}
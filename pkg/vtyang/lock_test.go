@@ -0,0 +1,140 @@
+package vtyang
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDatastoreLock(t *testing.T) {
+	if err := os.RemoveAll("/tmp/run/vtyang/config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/frr_mgmtd_minimal"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	buf0 := setStdoutWithBuffer()
+	buf1 := bytes.NewBufferString("")
+	commandLock.Lock()
+	s0 := consoleSession
+	s1 := openSession("alice", "test", buf1, nil)
+	commandLock.Unlock()
+
+	run := func(s *Session, cli string) error {
+		t.Helper()
+		err := s.runCommand(context.Background(), cli)
+		t.Logf("session %d: %s: %v", s.Id, cli, err)
+		return err
+	}
+	expectError := func(err error, msg string) {
+		t.Helper()
+		if err == nil || err.Error() != msg {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+
+	// The unknown modes are rejected rather than taken as exclusive
+	expectError(run(s0, "configure privat"), "unknown configure mode privat")
+	expectError(run(s0, "configure exclusive hoge"), "unexpected argument hoge")
+	if len(DatastoreLocks()) != 0 {
+		t.Errorf("unexpected locks %+v", DatastoreLocks())
+	}
+
+	// The exclusive lock keeps the others from locking and committing
+	if err := run(s0, "configure exclusive"); err != nil {
+		t.Fatal(err)
+	}
+	locked := "running datastore is locked by session 1 (" +
+		currentUsername() + ")"
+	expectError(run(s1, "configure private"), locked)
+	if err := run(s1, "configure"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf1.String(), "Warning: "+locked) {
+		t.Errorf("unexpected output\n%s", buf1.String())
+	}
+	if err := run(s1, "set lib prefix-list ipv4 hoge entry 10 action permit"); err != nil {
+		t.Fatal(err)
+	}
+	expectError(run(s1, "commit"), locked)
+
+	buf1.Reset()
+	if err := run(s1, "do show configuration lock"); err != nil {
+		t.Fatal(err)
+	}
+	if !hasTableRow(buf1.String(), "running exclusive 1 "+currentUsername()) {
+		t.Errorf("unexpected output\n%s", buf1.String())
+	}
+	// Only the console clears the locks of the others
+	expectError(run(s1, "do clear configuration lock"), "permission denied, "+
+		"only the console and the privileged users can clear the locks")
+	buf0.Reset()
+	if err := run(s0, "do clear configuration lock"); err != nil {
+		t.Fatal(err)
+	}
+	if buf0.String() != "running lock of session 1 released\n" {
+		t.Errorf("unexpected output\n%s", buf0.String())
+	}
+	if err := run(s1, "commit"); err != nil {
+		t.Error(err)
+	}
+	expectError(run(s0, "do clear configuration lock"),
+		"configuration is not locked")
+
+	// The exclusive lock is denied while the others have changes
+	if err := run(s0, "quit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(s1, "set lib prefix-list ipv4 hoge entry 20 action deny"); err != nil {
+		t.Fatal(err)
+	}
+	expectError(run(s0, "configure exclusive"),
+		"session 2 has uncommitted changes")
+	if err := run(s0, "configure private"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(s1, "quit"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The locks are shared by the other northbound interfaces
+	n := OpenSession("bob", "netconf")
+	expectError(LockDatastore(n, DatastoreRunning, LockModeExclusive),
+		locked)
+	if err := run(s0, "quit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := LockDatastore(n, DatastoreRunning, LockModeExclusive); err != nil {
+		t.Fatal(err)
+	}
+	if locks := DatastoreLocks(); len(locks) != 1 || locks[0].Session != n.Id {
+		t.Errorf("unexpected locks %+v", locks)
+	}
+	for _, cli := range []string{
+		"configure",
+		"set lib prefix-list ipv4 fuga entry 10 action deny",
+	} {
+		if err := run(s0, cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectError(run(s0, "commit"),
+		"running datastore is locked by session 3 (bob)")
+
+	// The locks are released on close
+	n.Close()
+	if locks := DatastoreLocks(); len(locks) != 0 {
+		t.Errorf("unexpected locks %+v", locks)
+	}
+	if err := run(s0, "commit"); err != nil {
+		t.Error(err)
+	}
+	expectError(UnlockDatastore(s1, DatastoreRunning),
+		"running datastore is not locked by the session")
+}
@@ -0,0 +1,488 @@
+package vtyang
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+	"github.com/slankdev/vtyang/pkg/util"
+)
+
+// mgmtdDatastores are locked while the cli is in configure mode.
+var mgmtdDatastores = []mgmtd.DatastoreId{
+	mgmtd.DatastoreId_RUNNING_DS,
+	mgmtd.DatastoreId_CANDIDATE_DS,
+}
+
+// connectMgmtd connects to mgmtd under the supervisor, which reconnects
+// when the connection is lost.
+func connectMgmtd(opts *AgentOptsBackendMgmtd) error {
+	sup, err := mgmtd.NewSupervisor(opts.UnixSockPath, "vtyang")
+	if err != nil {
+		return errors.Wrap(err, "mgmtd.NewSupervisor")
+	}
+	mgmtdSupervisor = sup
+	mgmtdClient = sup.Client()
+	sup.SetConnectHandler(mgmtdReconnected)
+	return nil
+}
+
+func closeMgmtd() {
+	if mgmtdSupervisor == nil {
+		return
+	}
+	if err := mgmtdSupervisor.Close(); err != nil {
+		log.Printf("mgmtd client close: %s\n", err)
+	}
+	mgmtdSupervisor = nil
+	mgmtdClient = nil
+}
+
+// mgmtdReconnected restores the state of the previous session on the new
+// client. It is called from the supervisor, so it waits for the running
+// command to finish.
+func mgmtdReconnected(c *mgmtd.Client) error {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	mgmtdClient = c
+
+	if err := mgmtdRegisterNotify(); err != nil {
+		return errors.Wrap(err, "mgmtdRegisterNotify")
+	}
+	ctx, cancel := mgmtdContext()
+	defer cancel()
+	if anySessionConfiguring() {
+		if err := mgmtdLockDatastores(ctx); err != nil {
+			return errors.Wrap(err, "mgmtdLockDatastores")
+		}
+	}
+	if err := mgmtdResyncRunning(ctx); err != nil {
+		return errors.Wrap(err, "mgmtdResyncRunning")
+	}
+	return nil
+}
+
+func mgmtdRegisterNotify() error {
+	return mgmtdClient.RegisterNotify([]string{"/"},
+		func(datas []*mgmtd.YangData) {
+			if err := PublishMgmtdNotification(datas); err != nil {
+				log.Printf("mgmtd notification ignored: %s\n", err)
+			}
+		})
+}
+
+func mgmtdLockReq(ctx context.Context, dsId mgmtd.DatastoreId,
+	lock bool) error {
+	return mgmtdClient.LockReq(ctx, &mgmtd.FeLockDsReq{
+		SessionId: mgmtdClient.GetSessionId(),
+		ReqId:     util.NewUint64Pointer(0),
+		DsId:      dsId.Enum(),
+		Lock:      util.NewBoolPointer(lock),
+	})
+}
+
+// mgmtdLockDatastores locks the datastores. When one of them can't be
+// locked, the locks already taken are released.
+func mgmtdLockDatastores(ctx context.Context) error {
+	for idx, dsId := range mgmtdDatastores {
+		if err := mgmtdLockReq(ctx, dsId, true); err != nil {
+			for _, dsId := range mgmtdDatastores[:idx] {
+				if err := mgmtdLockReq(ctx, dsId, false); err != nil {
+					log.Printf("LockReq(Lock=false): %s\n", err)
+				}
+			}
+			return errors.Wrap(err, "LockReq(Lock=true)")
+		}
+	}
+	return nil
+}
+
+func mgmtdUnlockDatastores(ctx context.Context) error {
+	for _, dsId := range mgmtdDatastores {
+		if err := mgmtdLockReq(ctx, dsId, false); err != nil {
+			return errors.Wrap(err, "LockReq(Lock=false)")
+		}
+	}
+	return nil
+}
+
+// mgmtdResyncRunning merges the running datastore of mgmtd into the
+// running config of vtyang, as it may be changed while disconnected. Only
+// the subtrees of mgmtdOwnedNames are replaced, the ones of the vtyang
+// only modules are kept. The change is recorded as a commit. The
+// resync is refused when mgmtd has the data vtyang can't parse, as the
+// subtrees of them would be lost. The candidate config in configure mode
+// is kept.
+func mgmtdResyncRunning(ctx context.Context) error {
+	config, err := mgmtdClient.GetData(ctx, mgmtd.DatastoreId_RUNNING_DS,
+		true, "/")
+	if err != nil {
+		return errors.Wrap(err, "mgmtd.GetData")
+	}
+	root, skipped, err := frrConfigImport(config)
+	if err != nil {
+		return errors.Wrap(err, "frrConfigImport")
+	}
+	if len(skipped) > 0 {
+		for _, s := range skipped {
+			log.Printf("mgmtd: %s: %s\n", s.XPath, s.Err)
+		}
+		return errors.Errorf("%d data of mgmtd can't be parsed, "+
+			"running config drifts from mgmtd", len(skipped))
+	}
+	mgmtdPushed = nil
+
+	owned := mgmtdOwnedNames()
+	merged := DBNode{Name: dbm.root.Name, Type: Container}
+	for _, child := range dbm.root.Childs {
+		if !owned[child.Name] {
+			merged.Childs = append(merged.Childs, child)
+		}
+	}
+	for _, child := range root.Childs {
+		if owned[child.Name] {
+			merged.Childs = append(merged.Childs, child)
+		}
+	}
+	if merged.String() == dbm.root.String() {
+		return nil
+	}
+	h := CommitHistory{
+		Before:    dbm.root.String(),
+		After:     merged.String(),
+		Client:    "mgmtd",
+		Comment:   "resync",
+		Timestamp: time.Now(),
+	}
+	if err := applyCommit(h, &merged); err != nil {
+		return err
+	}
+	log.Printf("mgmtd: running config resynced\n")
+	return nil
+}
+
+// mgmtdOwnedNames returns the names of the top-level nodes of the FRR
+// modules, which are the subtrees managed by mgmtd. The others are of the
+// vtyang only modules (e.g. linux-agent) and never sent to mgmtd.
+func mgmtdOwnedNames() map[string]bool {
+	names := map[string]bool{}
+	for name, m := range yangmodules.Modules {
+		if !strings.HasPrefix(name, "frr-") {
+			continue
+		}
+		for dir := range yang.ToEntry(m).Dir {
+			names[dir] = true
+		}
+	}
+	return names
+}
+
+// mgmtdOwnedPaths returns mgmtdOwnedNames as the schema paths.
+func mgmtdOwnedPaths() []string {
+	paths := []string{}
+	for name := range mgmtdOwnedNames() {
+		paths = append(paths, "/"+name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// mgmtdOwnedConfig returns the subtrees of root managed by mgmtd.
+func mgmtdOwnedConfig(root *DBNode) *DBNode {
+	owned := mgmtdOwnedNames()
+	ret := &DBNode{Name: root.Name, Type: root.Type}
+	for _, child := range root.Childs {
+		if owned[child.Name] {
+			ret.Childs = append(ret.Childs, child)
+		}
+	}
+	return ret
+}
+
+// mgmtdSkippedData is the data of mgmtd which vtyang can't parse.
+type mgmtdSkippedData struct {
+	XPath string
+	Err   error
+}
+
+// frrConfigImport converts the data of mgmtd into the config tree. Unlike
+// frrConfigToDBNode, the data which vtyang can't parse are skipped and
+// returned instead of failing the whole import.
+func frrConfigImport(config []*mgmtd.YangData) (*DBNode, []mgmtdSkippedData,
+	error) {
+	yd := []YangData{}
+	skipped := []mgmtdSkippedData{}
+	for _, data := range config {
+		xp, err := ParseXPathString(dbm, data.GetXpath())
+		if err != nil {
+			skipped = append(skipped, mgmtdSkippedData{
+				XPath: data.GetXpath(),
+				Err:   err,
+			})
+			continue
+		}
+		yd = append(yd, YangData{
+			XPath: xp,
+			Value: data.GetValue().GetEncodedStrVal(),
+		})
+	}
+	root, err := CraftDBNode(yd)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "CraftDBNode")
+	}
+	return root, skipped, nil
+}
+
+// syncFromMgmtd replaces the running config with the running datastore of
+// mgmtd and records it as a commit. The data which can't be parsed are
+// reported as warnings.
+func syncFromMgmtd() error {
+	ctx, cancel := mgmtdContext()
+	defer cancel()
+	config, err := mgmtdClient.GetData(ctx, mgmtd.DatastoreId_RUNNING_DS,
+		true, "/")
+	if err != nil {
+		return errors.Wrap(err, "mgmtd.GetData")
+	}
+	root, skipped, err := frrConfigImport(config)
+	if err != nil {
+		return errors.Wrap(err, "frrConfigImport")
+	}
+	for _, s := range skipped {
+		fmt.Fprintf(stdout, "Warning: %s: %s ... ignored\n", s.XPath, s.Err)
+	}
+	log.Printf("mgmtd: %d data synced, %d data skipped\n",
+		len(config)-len(skipped), len(skipped))
+	if root.String() == dbm.root.String() {
+		return nil
+	}
+
+	return commitExternal(root, "mgmtd", "sync-from-backend")
+}
+
+func ccbShowMgmtdStatus(ctx context.Context, args []string) (*CommandResult, error) {
+	if mgmtdSupervisor == nil {
+		return nil, errors.Errorf("mgmtd backend is not enabled")
+	}
+	status := mgmtdSupervisor.Status()
+	state := "disconnected"
+	if status.Connected {
+		state = "connected"
+	}
+	lastErr := "-"
+	if status.LastError != nil {
+		lastErr = status.LastError.Error()
+	}
+	fmt.Fprintf(stdout, "State:       %s\n", state)
+	fmt.Fprintf(stdout, "Socket:      %s\n", status.SockPath)
+	fmt.Fprintf(stdout, "Session-Id:  %d\n", status.SessionId)
+	fmt.Fprintf(stdout, "Reconnects:  %d\n", status.Reconnects)
+	fmt.Fprintf(stdout, "Since:       %s\n",
+		status.Since.Format(time.RFC3339))
+	fmt.Fprintf(stdout, "Last-Error:  %s\n", lastErr)
+	return nil, nil
+}
+
+// mgmtdBatchSize is the max number of edits in one FeSetConfigReq.
+var mgmtdBatchSize = 100
+
+// mgmtdPushed is the candidate config last pushed to the candidate
+// datastore of mgmtd, nil when it's same as running. The edits in
+// configure mode are accumulated in the candidate config of vtyang and the
+// difference from mgmtdPushed is sent before mgmtd needs them.
+var mgmtdPushed *DBNode
+
+// mgmtdPushCandidate sends the changes of the candidate config since the
+// last push. When it fails on the way, the candidate datastore is restored
+// from running, so that the next push sends all the changes again.
+func mgmtdPushCandidate(ctx context.Context) error {
+	if mgmtdPushed == nil {
+		mgmtdPushed = dbm.root.DeepCopy()
+	}
+	reqs, err := mgmtdChangeSet(mgmtdPushed, dbm.candidateRoot)
+	if err != nil {
+		return errors.Wrap(err, "mgmtdChangeSet")
+	}
+	if err := mgmtdSetConfig(ctx, reqs); err != nil {
+		mgmtdPushed = nil
+		return err
+	}
+	mgmtdPushed = dbm.candidateRoot.DeepCopy()
+	return nil
+}
+
+// mgmtdSetConfig sends reqs to the candidate datastore in batches of
+// mgmtdBatchSize. The candidate is aborted when a batch is rejected.
+func mgmtdSetConfig(ctx context.Context, reqs []*mgmtd.YangCfgDataReq) error {
+	for len(reqs) > 0 {
+		n := len(reqs)
+		if n > mgmtdBatchSize {
+			n = mgmtdBatchSize
+		}
+		if err := mgmtdClient.SetConfig(ctx, &mgmtd.FeSetConfigReq{
+			SessionId:      mgmtdClient.GetSessionId(),
+			DsId:           mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+			CommitDsId:     mgmtd.DatastoreId_RUNNING_DS.Enum(),
+			ReqId:          util.NewUint64Pointer(0),
+			ImplicitCommit: util.NewBoolPointer(false),
+			Data:           reqs[:n],
+		}); err != nil {
+			if err := mgmtdClient.AbortConfig(ctx); err != nil {
+				log.Printf("mgmtd.AbortConfig: %s\n", err)
+			}
+			return errors.Wrap(err, "SetConfig")
+		}
+		reqs = reqs[n:]
+	}
+	return nil
+}
+
+func mgmtdCommit(ctx context.Context) error {
+	return mgmtdClient.CommitConfig(ctx, &mgmtd.FeCommitConfigReq{
+		SessionId:    mgmtdClient.GetSessionId(),
+		ReqId:        util.NewUint64Pointer(0),
+		SrcDsId:      mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+		DstDsId:      mgmtd.DatastoreId_RUNNING_DS.Enum(),
+		ValidateOnly: util.NewBoolPointer(false),
+		Abort:        util.NewBoolPointer(false),
+	})
+}
+
+type mgmtdLeaf struct {
+	xpath XPath
+	value string
+}
+
+// mgmtdLeaves returns the leaves and leaf-lists of root indexed by xpath,
+// and the xpaths of all the nodes including containers and list entries.
+func mgmtdLeaves(root *DBNode) (map[string]mgmtdLeaf, map[string]bool,
+	error) {
+	leaves := map[string]mgmtdLeaf{}
+	nodes := map[string]bool{}
+	if err := walkDBNode(root, func(xpath XPath, n *DBNode) error {
+		leaves[xpath.String()] = mgmtdLeaf{
+			xpath: xpath,
+			value: dbNodeValueString(n),
+		}
+		for i := 1; i <= len(xpath.Words); i++ {
+			nodes[XPath{Words: xpath.Words[:i]}.String()] = true
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+	return leaves, nodes, nil
+}
+
+// isListKey returns true when the tail of xpath is a key of the list
+// entry.
+func isListKey(xpath XPath) bool {
+	if len(xpath.Words) < 2 {
+		return false
+	}
+	parent := xpath.Words[len(xpath.Words)-2]
+	_, ok := parent.Keys[xpath.Words[len(xpath.Words)-1].Word]
+	return ok
+}
+
+// mgmtdChangeSet returns the minimal edits to transform before into after.
+// A removed subtree is deleted at its top node, a changed leaf-list is
+// deleted and set again, and list entries are created implicitly by
+// setting their leaves. Deletions are ordered before settings.
+func mgmtdChangeSet(before, after *DBNode) ([]*mgmtd.YangCfgDataReq, error) {
+	before = mgmtdOwnedConfig(before)
+	after = mgmtdOwnedConfig(after)
+	b, bNodes, err := mgmtdLeaves(before)
+	if err != nil {
+		return nil, errors.Wrap(err, "mgmtdLeaves(before)")
+	}
+	a, aNodes, err := mgmtdLeaves(after)
+	if err != nil {
+		return nil, errors.Wrap(err, "mgmtdLeaves(after)")
+	}
+
+	deletes := map[string]bool{}
+	for xpath, bl := range b {
+		al, ok := a[xpath]
+		switch {
+		case !ok:
+			for i := 1; i <= len(bl.xpath.Words); i++ {
+				top := XPath{Words: bl.xpath.Words[:i]}.String()
+				if !aNodes[top] {
+					deletes[top] = true
+					break
+				}
+			}
+		case al.value != bl.value &&
+			al.xpath.Words[len(al.xpath.Words)-1].Dbtype == LeafList:
+			deletes[xpath] = true
+		}
+	}
+
+	sets := map[string]string{}
+	covered := map[string]bool{}
+	newEntries := map[string]bool{}
+	for xpath, al := range a {
+		if bl, ok := b[xpath]; ok && bl.value == al.value {
+			continue
+		}
+		if isListKey(al.xpath) {
+			entry := XPath{Words: al.xpath.Words[:len(al.xpath.Words)-1]}.String()
+			if !bNodes[entry] {
+				newEntries[entry] = true
+			}
+			continue
+		}
+		sets[xpath] = al.value
+		for i := 1; i < len(al.xpath.Words); i++ {
+			covered[XPath{Words: al.xpath.Words[:i]}.String()] = true
+		}
+	}
+	// List entries having only keys are created by themselves
+	for entry := range newEntries {
+		if !covered[entry] {
+			sets[entry] = ""
+		}
+	}
+
+	deleteXPaths := []string{}
+	for xpath := range deletes {
+		deleteXPaths = append(deleteXPaths, xpath)
+	}
+	sort.Strings(deleteXPaths)
+	setXPaths := []string{}
+	for xpath := range sets {
+		setXPaths = append(setXPaths, xpath)
+	}
+	sort.Strings(setXPaths)
+
+	ret := []*mgmtd.YangCfgDataReq{}
+	for _, xpath := range deleteXPaths {
+		ret = append(ret, &mgmtd.YangCfgDataReq{
+			ReqType: mgmtd.CfgDataReqType_DELETE_DATA.Enum(),
+			Data: &mgmtd.YangData{
+				Xpath: util.NewStringPointer(xpath),
+			},
+		})
+	}
+	for _, xpath := range setXPaths {
+		ret = append(ret, &mgmtd.YangCfgDataReq{
+			ReqType: mgmtd.CfgDataReqType_SET_DATA.Enum(),
+			Data: &mgmtd.YangData{
+				Xpath: util.NewStringPointer(xpath),
+				Value: &mgmtd.YangDataValue{
+					Value: &mgmtd.YangDataValue_EncodedStrVal{
+						EncodedStrVal: sets[xpath],
+					},
+				},
+			},
+		})
+	}
+	return ret, nil
+}
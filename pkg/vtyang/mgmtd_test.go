@@ -0,0 +1,400 @@
+package vtyang
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+	"github.com/slankdev/vtyang/pkg/mgmtd/fake"
+	"github.com/slankdev/vtyang/pkg/util"
+)
+
+// newTestMgmtdServer starts the fake mgmtd. The client connected by
+// InitAgent is closed before the server at the end of the test.
+func newTestMgmtdServer(t *testing.T) (*fake.Server, *AgentOptsBackendMgmtd) {
+	sockpath := filepath.Join(t.TempDir(), "mgmtd_fe.sock")
+	s, err := fake.NewServer(sockpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	t.Cleanup(closeMgmtd)
+	return s, &AgentOptsBackendMgmtd{UnixSockPath: sockpath}
+}
+
+func TestMgmtdOperState(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	s.SetData(mgmtd.DatastoreId_OPERATIONAL_DS,
+		"/operstate:system/state/uptime", "3600")
+	s.SetData(mgmtd.DatastoreId_OPERATIONAL_DS,
+		"/operstate:system/interface[name='eth0']/counters/in-octets", "100")
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/operstate",
+		InitConfigFile: "./testdata/operstate_config.json",
+		OutputFile:     "./testdata/output/TestMgmtdOperState.txt",
+		BackendMgmtd:   backend,
+		Inputs: []string{
+			"show system state",
+			"show system interface eth0",
+		},
+	})
+}
+
+func TestMgmtdCommitCheckAbort(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/operstate",
+		InitConfigFile: "./testdata/operstate_config.json",
+		OutputFile:     "./testdata/output/TestMgmtdCommitCheckAbort.txt",
+		BackendMgmtd:   backend,
+		Inputs: []string{
+			"configure",
+			"set system hostname vtyang1",
+			"commit check",
+			"commit abort",
+			"commit",
+			"quit",
+			"show running-config",
+		},
+	})
+	commits := s.Commits()
+	if len(commits) != 3 || !commits[0].GetValidateOnly() ||
+		!commits[1].GetAbort() {
+		t.Fatalf("unexpected commits %v", commits)
+	}
+	if got := s.Data(mgmtd.DatastoreId_CANDIDATE_DS); len(got) != 0 {
+		t.Fatalf("mgmtd candidate is not aborted %v", got)
+	}
+}
+
+func TestMgmtdNotification(t *testing.T) {
+	defer func(f func() time.Time) { notificationNow = f }(notificationNow)
+	notificationNow = func() time.Time {
+		return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	s, backend := newTestMgmtdServer(t)
+	executeTestCase(t, &TestCase{
+		RuntimePath:  "/tmp/run/vtyang",
+		YangPath:     "./testdata/yang/notification",
+		OutputFile:   "./testdata/output/TestMgmtdNotification.txt",
+		BackendMgmtd: backend,
+		Setup: func(t *testing.T) {
+			ch, cancel := SubscribeNotifications(1)
+			defer cancel()
+			// Wait for the registration with a get request
+			if _, err := mgmtdClient.GetOperData(context.Background(), "/"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Notify([]*mgmtd.YangData{
+				newTestMgmtdData("/notification:link-down/if-name", "eth0"),
+				newTestMgmtdData("/notification:link-down/reason", "carrier-lost"),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-ch:
+			case <-time.After(3 * time.Second):
+				t.Fatal("notification is not received")
+			}
+		},
+		Inputs: []string{
+			"show notifications",
+		},
+	})
+}
+
+func newTestMgmtdData(xpath, value string) *mgmtd.YangData {
+	return &mgmtd.YangData{
+		Xpath: util.NewStringPointer(xpath),
+		Value: &mgmtd.YangDataValue{
+			Value: &mgmtd.YangDataValue_EncodedStrVal{EncodedStrVal: value},
+		},
+	}
+}
+
+func TestMgmtdConfigure(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS,
+		"/frr-filter:lib/prefix-list[type='ipv4'][name='fuga']/entry[sequence='10']/action",
+		"deny")
+	s.SetData(mgmtd.DatastoreId_CANDIDATE_DS,
+		"/frr-filter:lib/prefix-list[type='ipv4'][name='fuga']/entry[sequence='10']/action",
+		"deny")
+	executeTestCase(t, &TestCase{
+		RuntimePath:  "/tmp/run/vtyang",
+		YangPath:     "./testdata/yang/frr_mgmtd_minimal",
+		OutputFile:   "./testdata/output/TestMgmtdConfigure.txt",
+		BackendMgmtd: backend,
+		Setup: func(t *testing.T) {
+			// The split frames are reassembled by the client
+			s.SplitFrameSize = 16
+		},
+		Inputs: []string{
+			"configure",
+			"set lib prefix-list ipv4 hoge entry 10 action permit",
+			"show configuration candidate",
+			"show configuration running",
+			"show configuration diff",
+			"commit",
+			"show configuration running",
+			"quit",
+		},
+	})
+	if got := s.Locks(); len(got) != 0 {
+		t.Fatalf("locks are not released %v", got)
+	}
+	running := s.Data(mgmtd.DatastoreId_RUNNING_DS)
+	if running["/frr-filter:lib/prefix-list[type='ipv4'][name='hoge']/entry[sequence='10']/action"] != "permit" {
+		t.Fatalf("unexpected running %v", running)
+	}
+}
+
+func TestMgmtdConfigureLocked(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	var other *mgmtd.Client
+	t.Cleanup(func() {
+		if other != nil {
+			other.Close()
+		}
+	})
+	executeTestCase(t, &TestCase{
+		RuntimePath:  "/tmp/run/vtyang",
+		YangPath:     "./testdata/yang/frr_mgmtd_minimal",
+		OutputFile:   "./testdata/output/TestMgmtdConfigureLocked.txt",
+		BackendMgmtd: backend,
+		Setup: func(t *testing.T) {
+			// Another frontend session holds the candidate lock
+			var err error
+			other, err = mgmtd.NewClient(backend.UnixSockPath, "other")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := other.LockReq(context.Background(), &mgmtd.FeLockDsReq{
+				SessionId: other.GetSessionId(),
+				DsId:      mgmtd.DatastoreId_CANDIDATE_DS.Enum(),
+				Lock:      util.NewBoolPointer(true),
+			}); err != nil {
+				t.Fatal(err)
+			}
+		},
+		Inputs: []string{
+			"configure",
+			"show running-config",
+		},
+	})
+	if cliMode != CliModeView {
+		t.Fatalf("entered configure mode without locks")
+	}
+	locks := s.Locks()
+	if len(locks) != 1 ||
+		locks[mgmtd.DatastoreId_CANDIDATE_DS] != *other.GetSessionId() {
+		t.Fatalf("unexpected locks %v", locks)
+	}
+}
+
+func TestMgmtdCommitError(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	s.InjectFault(fake.RequestCommit, fake.Fault{
+		Error: "Validation failed",
+		Count: 1,
+	})
+	executeTestCase(t, &TestCase{
+		RuntimePath:  "/tmp/run/vtyang",
+		YangPath:     "./testdata/yang/frr_mgmtd_minimal",
+		OutputFile:   "./testdata/output/TestMgmtdCommitError.txt",
+		BackendMgmtd: backend,
+		Inputs: []string{
+			"configure",
+			"set lib prefix-list ipv4 hoge entry 10 action permit",
+			"commit",
+			"show configuration running",
+			"commit",
+			"show configuration running",
+			"quit",
+			"show running-config",
+		},
+	})
+}
+
+func TestMgmtdChangeSet(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	defer func(n int) { mgmtdBatchSize = n }(mgmtdBatchSize)
+	mgmtdBatchSize = 2
+	executeTestCase(t, &TestCase{
+		RuntimePath:  "/tmp/run/vtyang",
+		YangPath:     "./testdata/yang/frr_mgmtd_minimal",
+		OutputFile:   "./testdata/output/TestMgmtdChangeSet.txt",
+		BackendMgmtd: backend,
+		Inputs: []string{
+			"configure",
+			"set lib prefix-list ipv4 hoge entry 10 action permit",
+			"set lib prefix-list ipv4 hoge entry 20 action deny",
+			"set lib prefix-list ipv4 fuga entry 10 action deny",
+			"commit",
+			"delete lib prefix-list ipv4 hoge entry 20",
+			"delete lib prefix-list ipv4 fuga",
+			"set lib prefix-list ipv4 hoge entry 10 action deny",
+			"show configuration diff",
+			"commit",
+			"show configuration running",
+			"quit",
+		},
+	})
+
+	// The deletions reach mgmtd
+	running := s.Data(mgmtd.DatastoreId_RUNNING_DS)
+	if len(running) != 1 || running["/frr-filter:lib/prefix-list[type='ipv4'][name='hoge']/entry[sequence='10']/action"] != "deny" {
+		t.Fatalf("unexpected running %v", running)
+	}
+
+	// 3 settings are chunked into 2 requests, then 2 deletions and 1
+	// setting are chunked into 2 requests
+	expected := [][]string{
+		{
+			"SET_DATA /frr-filter:lib/frr-filter:prefix-list[type='ipv4'][name='fuga']/frr-filter:entry[sequence='10']/frr-filter:action",
+			"SET_DATA /frr-filter:lib/frr-filter:prefix-list[type='ipv4'][name='hoge']/frr-filter:entry[sequence='10']/frr-filter:action",
+		},
+		{
+			"SET_DATA /frr-filter:lib/frr-filter:prefix-list[type='ipv4'][name='hoge']/frr-filter:entry[sequence='20']/frr-filter:action",
+		},
+		{
+			"DELETE_DATA /frr-filter:lib/frr-filter:prefix-list[type='ipv4'][name='fuga']",
+			"DELETE_DATA /frr-filter:lib/frr-filter:prefix-list[type='ipv4'][name='hoge']/frr-filter:entry[sequence='20']",
+		},
+		{
+			"SET_DATA /frr-filter:lib/frr-filter:prefix-list[type='ipv4'][name='hoge']/frr-filter:entry[sequence='10']/frr-filter:action",
+		},
+	}
+	got := [][]string{}
+	for _, req := range s.SetConfigs() {
+		edits := []string{}
+		for _, d := range req.Data {
+			edits = append(edits, fmt.Sprintf("%s %s", d.GetReqType(),
+				d.GetData().GetXpath()))
+		}
+		got = append(got, edits)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected requests %v", got)
+	}
+}
+
+func TestMgmtdReconnect(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/frr_mgmtd_minimal",
+		ExtraYangPaths: []string{"./testdata/yang/operstate"},
+		OutputFile:     "./testdata/output/TestMgmtdReconnect.txt",
+		BackendMgmtd:   backend,
+		Inputs: []string{
+			"configure",
+			"set system hostname vtyang0",
+			"set ripd instance default default-metric 2",
+			"commit",
+			"set lib prefix-list ipv4 hoge entry 10 action permit",
+		},
+	})
+
+	// Restart mgmtd without ripd, which is removed by the resync with the running config changed while disconnected
+	s.Close()
+	s, err := fake.NewServer(backend.UnixSockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS,
+		"/frr-filter:lib/prefix-list[type='ipv4'][name='fuga']/entry[sequence='10']/action",
+		"deny")
+	for i := 0; mgmtdSupervisor.Status().Reconnects == 0; i++ {
+		if i > 500 {
+			t.Fatal("not reconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The locks of configure mode are taken by the new session
+	locks := s.Locks()
+	if len(locks) != 2 ||
+		locks[mgmtd.DatastoreId_RUNNING_DS] != *mgmtdClient.GetSessionId() ||
+		locks[mgmtd.DatastoreId_CANDIDATE_DS] != *mgmtdClient.GetSessionId() {
+		t.Fatalf("unexpected locks %v", locks)
+	}
+
+	buf := setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("do show mgmtd status")
+	getCommandNodeCurrent().executeCommand("do show running-config")
+	out := buf.String()
+	for _, s := range []string{
+		"State:       connected\n",
+		"Reconnects:  1\n",
+		"Last-Error:  connection lost: EOF\n",
+		// The running config is resynced
+		"fuga",
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("%q is not in the output:\n%s", s, out)
+		}
+	}
+	if !strings.Contains(dbm.candidateRoot.String(), "hoge") {
+		t.Fatalf("candidate is not kept %s", dbm.candidateRoot.String())
+	}
+
+	// The subtree of the vtyang only module is kept, and the resync is
+	// committed
+	if !strings.Contains(dbm.root.String(), "vtyang0") ||
+		strings.Contains(dbm.root.String(), "default-metric") {
+		t.Fatalf("unexpected running %s", dbm.root.String())
+	}
+	if len(commitHistories) == 0 || commitHistories[0].Client != "mgmtd" ||
+		commitHistories[0].Comment != "resync" {
+		t.Fatalf("resync is not recorded %v", commitHistories)
+	}
+	b, err := os.ReadFile(getDatabasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "fuga") {
+		t.Fatalf("resync is not persisted %s", b)
+	}
+}
+
+func TestMgmtdSyncFromBackend(t *testing.T) {
+	s, backend := newTestMgmtdServer(t)
+	backend.SyncFromBackend = true
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS,
+		"/frr-filter:lib/prefix-list[type='ipv4'][name='fuga']/entry[sequence='10']/action",
+		"deny")
+	s.SetData(mgmtd.DatastoreId_RUNNING_DS,
+		"/frr-unknown:unknown/value", "1")
+
+	// The warnings are printed on startup
+	buf := setStdoutWithBuffer()
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/frr_mgmtd_minimal",
+		InitConfigFile: "./testdata/frr_mgmtd_minimal_config.json",
+		OutputFile:     "./testdata/output/TestMgmtdSyncFromBackend.txt",
+		BackendMgmtd:   backend,
+		Inputs: []string{
+			"show running-config",
+		},
+	})
+	if !strings.HasPrefix(buf.String(),
+		"Warning: /frr-unknown:unknown/value: ") {
+		t.Fatalf("unexpected warnings %q", buf.String())
+	}
+	if len(commitHistories) == 0 || commitHistories[0].Client != "mgmtd" ||
+		!strings.Contains(commitHistories[0].Before, "hoge") ||
+		!strings.Contains(commitHistories[0].After, "fuga") {
+		t.Fatalf("unexpected commit histories %v", commitHistories)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
 	"github.com/olekukonko/tablewriter"
@@ -60,14 +59,6 @@ func jsonstring2map(s string) (interface{}, error) {
 	return m, nil
 }
 
-func cat(args []string) string {
-	s := ""
-	for _, a := range args {
-		s += fmt.Sprintf("%s ", a)
-	}
-	return s
-}
-
 func matchArgs(args []string, matchStr string) bool {
 	matchArgs := strings.Fields(matchStr)
 	if len(matchArgs) > len(args) {
@@ -87,7 +78,7 @@ func matchArgs(args []string, matchStr string) bool {
 }
 
 func newTable() *tablewriter.Table {
-	table := tablewriter.NewWriter(os.Stdout)
+	table := tablewriter.NewWriter(stdout)
 	table.SetAutoWrapText(false)
 	table.SetAutoFormatHeaders(true)
 	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
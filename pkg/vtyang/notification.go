@@ -0,0 +1,300 @@
+package vtyang
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+	"github.com/slankdev/vtyang/pkg/mgmtd"
+)
+
+// NotificationStreamDefault is the stream used when a backend doesn't
+// specify one, as same as the default stream of RFC5277.
+const NotificationStreamDefault = "NETCONF"
+
+// Notification is a YANG notification received from backends. Path is the
+// xpath of the notification statement, it can point a nested notification
+// of YANG 1.1 including list keys.
+type Notification struct {
+	Stream    string
+	EventTime time.Time
+	Path      string
+	Data      *DBNode
+}
+
+func (n Notification) String() string {
+	return fmt.Sprintf("%s %s %s\n%s", n.EventTime.Format(time.RFC3339),
+		n.Stream, n.Path, n.Data.String())
+}
+
+var (
+	notificationReplaySize = 1000
+	notificationNow        = time.Now
+	notifications          []Notification
+	notificationSubs       = map[int]chan Notification{}
+	notificationSubId      int
+	notificationLock       sync.Mutex
+)
+
+func resetNotifications() {
+	notificationLock.Lock()
+	defer notificationLock.Unlock()
+	notifications = nil
+	for id, ch := range notificationSubs {
+		close(ch)
+		delete(notificationSubs, id)
+	}
+}
+
+// lookupNotificationEntry returns the notification statement at path.
+func lookupNotificationEntry(path string) (*yang.Entry, error) {
+	words := splitSchemaPath(path)
+	if len(words) == 0 {
+		return nil, errors.Errorf("notification is not specified")
+	}
+	e := yangRootEntry()
+	for _, w := range words {
+		e = lookupEntryChild(e, w)
+		if e == nil {
+			return nil, errors.Errorf("entry %s is not found", w)
+		}
+	}
+	if e.Kind != yang.NotificationEntry {
+		return nil, errors.Errorf("%s is not a notification", path)
+	}
+	return e, nil
+}
+
+// PublishNotification validates data against the notification statement
+// at path, stores it to the replay buffer and delivers it to subscribers.
+// The event time is set to now when it is zero.
+func PublishNotification(stream, path string, eventTime time.Time,
+	data *DBNode) error {
+	e, err := lookupNotificationEntry(path)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		data = &DBNode{Type: Container}
+	}
+	if err := typeDBNode(data, e); err != nil {
+		return errors.Wrapf(err, "notification %s", path)
+	}
+	if stream == "" {
+		stream = NotificationStreamDefault
+	}
+	if eventTime.IsZero() {
+		eventTime = notificationNow()
+	}
+	n := Notification{
+		Stream:    stream,
+		EventTime: eventTime,
+		Path:      path,
+		Data:      data,
+	}
+
+	notificationLock.Lock()
+	defer notificationLock.Unlock()
+	notifications = append(notifications, n)
+	if len(notifications) > notificationReplaySize {
+		notifications = notifications[len(notifications)-notificationReplaySize:]
+	}
+	for id, ch := range notificationSubs {
+		select {
+		case ch <- n:
+		default:
+			log.Printf("notification subscriber %d is slow, dropped %s\n", id, path)
+		}
+	}
+	return nil
+}
+
+// PublishNotificationJson is same as PublishNotification but takes the
+// data encoded in json.
+func PublishNotificationJson(stream, path string, eventTime time.Time,
+	data string) error {
+	if data == "" {
+		return PublishNotification(stream, path, eventTime, nil)
+	}
+	node, err := ReadFromJsonString(data)
+	if err != nil {
+		return errors.Wrap(err, "ReadFromJsonString")
+	}
+	return PublishNotification(stream, path, eventTime, node)
+}
+
+var xpathSegmentRegexp = regexp.MustCompile(
+	`[a-zA-Z0-9-:]+(\[[a-zA-Z0-9-:]+='[a-zA-Z0-9-\.:/]*'\])*`)
+
+// PublishMgmtdNotification publishes the data of FeNotifyDataReq. mgmtd
+// sends the leaves of one notification with absolute xpaths, so the
+// notification statement is resolved from the prefix of them.
+func PublishMgmtdNotification(datas []*mgmtd.YangData) error {
+	path := ""
+	var e *yang.Entry
+	leaves := []YangData{}
+	for _, data := range datas {
+		xpathStr := data.GetXpath()
+		if e == nil {
+			segs := xpathSegmentRegexp.FindAllString(xpathStr, -1)
+			for i := 1; i <= len(segs) && e == nil; i++ {
+				p := "/" + strings.Join(segs[:i], "/")
+				if ee, err := lookupNotificationEntry(p); err == nil {
+					path, e = p, ee
+				}
+			}
+			if e == nil {
+				return errors.Errorf("notification of %s is not found", xpathStr)
+			}
+		}
+		if !strings.HasPrefix(xpathStr, path) {
+			return errors.Errorf("%s is not in notification %s", xpathStr, path)
+		}
+		rel := strings.TrimPrefix(xpathStr, path)
+		if strings.Trim(rel, "/") == "" {
+			continue
+		}
+		xpath, err := ParseXPathStringImpl(e, rel)
+		if err != nil {
+			return errors.Wrap(err, "ParseXPathStringImpl")
+		}
+		leaves = append(leaves, YangData{
+			XPath: xpath,
+			Value: data.GetValue().GetEncodedStrVal(),
+		})
+	}
+	if e == nil {
+		return errors.Errorf("notification has no data")
+	}
+	node, err := CraftDBNode(leaves)
+	if err != nil {
+		return errors.Wrap(err, "CraftDBNode")
+	}
+	return PublishNotification(NotificationStreamDefault, path, time.Time{}, node)
+}
+
+// SubscribeNotifications returns a channel receiving the notifications
+// published after the subscription and a function to cancel it. This is
+// the hook for northbound interfaces like NETCONF or gNMI to forward the
+// notifications to their subscribers.
+func SubscribeNotifications(size int) (<-chan Notification, func()) {
+	notificationLock.Lock()
+	defer notificationLock.Unlock()
+	notificationSubId++
+	id := notificationSubId
+	ch := make(chan Notification, size)
+	notificationSubs[id] = ch
+	return ch, func() {
+		notificationLock.Lock()
+		defer notificationLock.Unlock()
+		if _, ok := notificationSubs[id]; ok {
+			close(ch)
+			delete(notificationSubs, id)
+		}
+	}
+}
+
+// ReplayNotifications returns the buffered notifications of stream which
+// occurred at or after since. An empty stream matches all streams.
+func ReplayNotifications(stream string, since time.Time) []Notification {
+	notificationLock.Lock()
+	defer notificationLock.Unlock()
+	ret := []Notification{}
+	for _, n := range notifications {
+		if stream != "" && n.Stream != stream {
+			continue
+		}
+		if n.EventTime.Before(since) {
+			continue
+		}
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+// parseSinceTime accepts RFC3339 time or a duration meaning the time
+// before now (e.g. 10m).
+func parseSinceTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time %s", s)
+	}
+	return notificationNow().Add(-d), nil
+}
+
+// parseNotificationArgs parses "[<stream>] [since <time>]".
+func parseNotificationArgs(args []string) (string, time.Time, error) {
+	stream := ""
+	since := time.Time{}
+	for len(args) > 0 {
+		switch {
+		case args[0] == "since":
+			if len(args) < 2 {
+				return "", time.Time{}, errors.Errorf("since requires time")
+			}
+			t, err := parseSinceTime(args[1])
+			if err != nil {
+				return "", time.Time{}, err
+			}
+			since = t
+			args = args[2:]
+		case stream == "":
+			stream = args[0]
+			args = args[1:]
+		default:
+			return "", time.Time{}, errors.Errorf("unexpected argument %s", args[0])
+		}
+	}
+	return stream, since, nil
+}
+
+func ccbShowNotifications(ctx context.Context, args []string) (*CommandResult, error) {
+	stream, since, err := parseNotificationArgs(args[2:])
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range ReplayNotifications(stream, since) {
+		fmt.Fprintln(stdout, n.String())
+	}
+	return nil, nil
+}
+
+// ccbMonitorNotifications displays the notifications until ctx is cancelled,
+// which the caller does on Ctrl-C or on the disconnection of the session.
+func ccbMonitorNotifications(ctx context.Context, args []string) (*CommandResult, error) {
+	stream := ""
+	if len(args) > 2 {
+		stream = strings.Join(args[2:], " ")
+	}
+	ch, cancel := SubscribeNotifications(64)
+	defer cancel()
+
+	fmt.Fprintf(stdout, "Monitoring notifications, press Ctrl-C to stop\n")
+	// The other sessions run the commands while monitoring
+	out := stdout
+	defer unlockCommand()()
+	for {
+		select {
+		case n, ok := <-ch:
+			if !ok {
+				return nil, nil
+			}
+			if stream != "" && n.Stream != stream {
+				continue
+			}
+			fmt.Fprintln(out, n.String())
+		case <-ctx.Done():
+			// Stopped by Ctrl-C or the disconnection of the session
+			return nil, nil
+		}
+	}
+}
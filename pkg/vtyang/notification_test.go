@@ -0,0 +1,239 @@
+package vtyang
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	vtyangapi "github.com/slankdev/vtyang/pkg/grpc/api"
+)
+
+func publishTestNotifications(t *testing.T) {
+	for _, item := range []struct {
+		stream string
+		path   string
+		time   string
+		data   string
+	}{
+		{"", "/notification:link-down", "2023-01-01T00:00:00Z",
+			`{"if-name": "eth0", "reason": "carrier-lost"}`},
+		{"interface", "/notification:system/interface[name='eth0']/flap",
+			"2023-01-01T00:10:00Z", `{"count": 3}`},
+		{"", "/notification:link-down", "2023-01-01T00:20:00Z",
+			`{"if-name": "eth1", "reason": "admin-down"}`},
+	} {
+		eventTime, err := time.Parse(time.RFC3339, item.time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := PublishNotificationJson(item.stream, item.path,
+			eventTime, item.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNotification01(t *testing.T) {
+	defer func(f func() time.Time) { notificationNow = f }(notificationNow)
+	notificationNow = func() time.Time {
+		return time.Date(2023, 1, 1, 0, 30, 0, 0, time.UTC)
+	}
+	executeTestCase(t, &TestCase{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    "./testdata/yang/notification",
+		OutputFile:  "./testdata/output/TestNotification01.txt",
+		Setup:       publishTestNotifications,
+		Inputs: []string{
+			"show notifications",
+			"show notifications NETCONF",
+			"show notifications interface",
+			"show notifications since 2023-01-01T00:05:00Z",
+			"show notifications NETCONF since 15m",
+			"show notifications since hoge",
+		},
+	})
+}
+
+func TestNotificationValidation(t *testing.T) {
+	if err := InitAgent(AgentOpts{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/notification"},
+		LogFile:     agentTestDefaultLogFile,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []struct {
+		path string
+		data string
+	}{
+		{"/notification:link-up", `{}`},
+		{"/notification:system", `{}`},
+		{"/notification:link-down", `{"hoge": "eth0"}`},
+		{"/notification:link-down", `{"reason": "hoge"}`},
+		{"/notification:system/interface[name='eth0']/flap", `{"count": -1}`},
+		{"/notification:link-down", `{"lanes": ["1", "256"]}`},
+		{"/notification:link-down", `{"if-name": ["eth0"]}`},
+	} {
+		if err := PublishNotificationJson("", item.path, time.Time{},
+			item.data); err == nil {
+			t.Errorf("%s %s: expected error", item.path, item.data)
+		}
+	}
+	if n := ReplayNotifications("", time.Time{}); len(n) != 0 {
+		t.Errorf("unexpected notifications %v", n)
+	}
+	if err := PublishNotificationJson("", "/notification:link-down",
+		time.Time{}, `{"lanes": ["1", "2"]}`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNotificationReplaySize(t *testing.T) {
+	defer func(n int) { notificationReplaySize = n }(notificationReplaySize)
+	notificationReplaySize = 2
+	if err := InitAgent(AgentOpts{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/notification"},
+		LogFile:     agentTestDefaultLogFile,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	publishTestNotifications(t)
+	n := ReplayNotifications("", time.Time{})
+	if len(n) != 2 {
+		t.Fatalf("unexpected length %d", len(n))
+	}
+	if n[0].Stream != "interface" {
+		t.Errorf("oldest notification is not dropped")
+	}
+}
+
+func TestNotificationMonitor(t *testing.T) {
+	if err := InitAgent(AgentOpts{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/notification"},
+		LogFile:     agentTestDefaultLogFile,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	buf := setStdoutWithBuffer()
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- getCommandNodeCurrent().runCommand(ctx,
+			"monitor notifications NETCONF")
+	}()
+
+	// Wait subscription
+	for i := 0; ; i++ {
+		notificationLock.Lock()
+		n := len(notificationSubs)
+		notificationLock.Unlock()
+		if n > 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("monitor not started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	publishTestNotifications(t)
+	for i := 0; ; i++ {
+		notificationLock.Lock()
+		n := 0
+		for _, ch := range notificationSubs {
+			n += len(ch)
+		}
+		notificationLock.Unlock()
+		if n == 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("notifications not consumed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+
+	expected := strings.Join([]string{
+		"Monitoring notifications, press Ctrl-C to stop",
+		"2023-01-01T00:00:00Z NETCONF /notification:link-down",
+		"{",
+		"  \"if-name\": \"eth0\",",
+		"  \"reason\": \"carrier-lost\"",
+		"}",
+		"2023-01-01T00:20:00Z NETCONF /notification:link-down",
+		"{",
+		"  \"if-name\": \"eth1\",",
+		"  \"reason\": \"admin-down\"",
+		"}",
+		"",
+	}, "\n")
+	if buf.String() != expected {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+func TestNotificationGrpcAgent(t *testing.T) {
+	if err := InitAgent(AgentOpts{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/notification"},
+		LogFile:     agentTestDefaultLogFile,
+		Grpc:        &AgentOptsGrpc{Address: "127.0.0.1:0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer stopGrpcServer()
+
+	conn, err := grpc.Dial(grpcListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := vtyangapi.NewAgentServiceClient(conn).
+		Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []*vtyangapi.AgentMessage{
+		{
+			Message: &vtyangapi.AgentMessage_Register{
+				Register: &vtyangapi.AgentRegister{Name: "agent0"},
+			},
+		},
+		{
+			Message: &vtyangapi.AgentMessage_Notification{
+				Notification: &vtyangapi.Notification{
+					Xpath:     "/notification:link-down",
+					Data:      `{"if-name": "eth0"}`,
+					EventTime: "2023-01-01T00:00:00Z",
+				},
+			},
+		},
+	} {
+		if err := stream.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; len(ReplayNotifications("", time.Time{})) == 0; i++ {
+		if i > 100 {
+			t.Fatal("notification not received")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	n := ReplayNotifications("", time.Time{})[0]
+	if n.Stream != NotificationStreamDefault ||
+		n.Path != "/notification:link-down" ||
+		n.Data.String() != "{\n  \"if-name\": \"eth0\"\n}" {
+		t.Errorf("unexpected notification %s", n.String())
+	}
+}
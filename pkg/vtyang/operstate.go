@@ -0,0 +1,346 @@
+package vtyang
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+)
+
+// OperStateProvider supplies config-false data for the subtree it is
+// registered on. It is queried at request time by the show command.
+type OperStateProvider interface {
+	GetOperState(ctx context.Context, xpath XPath) ([]YangData, error)
+}
+
+type OperStateProviderFunc func(ctx context.Context, xpath XPath) ([]YangData, error)
+
+func (f OperStateProviderFunc) GetOperState(ctx context.Context,
+	xpath XPath) ([]YangData, error) {
+	return f(ctx, xpath)
+}
+
+// OperStateHandle identifies the registration of the provider, the name
+// is only for the messages and may be shared by the registrations.
+type OperStateHandle int
+
+type operStateRegistration struct {
+	handle   OperStateHandle
+	name     string
+	paths    [][]string
+	provider OperStateProvider
+}
+
+var (
+	operStateTimeout       = 3 * time.Second
+	operStateProviders     []operStateRegistration
+	operStateLastHandle    OperStateHandle
+	operStateProvidersLock sync.Mutex
+)
+
+// RegisterOperStateProvider registers provider p for the subtrees at paths.
+// The path is a slash separated list of schema node names such as
+// "/frr-interface:lib/interface/state", module prefixes and list keys
+// are ignored. The provider is queried once even when the request
+// overlaps with several of the paths.
+func RegisterOperStateProvider(name string, paths []string,
+	p OperStateProvider) OperStateHandle {
+	operStateProvidersLock.Lock()
+	defer operStateProvidersLock.Unlock()
+	operStateLastHandle++
+	reg := operStateRegistration{
+		handle:   operStateLastHandle,
+		name:     name,
+		provider: p,
+	}
+	for _, path := range paths {
+		reg.paths = append(reg.paths, splitSchemaPath(path))
+	}
+	operStateProviders = append(operStateProviders, reg)
+	log.Printf("operstate provider %s registered for %s\n", name,
+		strings.Join(paths, ", "))
+	return reg.handle
+}
+
+func UnregisterOperStateProvider(handle OperStateHandle) {
+	operStateProvidersLock.Lock()
+	defer operStateProvidersLock.Unlock()
+	regs := []operStateRegistration{}
+	for _, reg := range operStateProviders {
+		if reg.handle != handle {
+			regs = append(regs, reg)
+			continue
+		}
+		log.Printf("operstate provider %s unregistered\n", reg.name)
+	}
+	operStateProviders = regs
+}
+
+func resetOperStateProviders() {
+	operStateProvidersLock.Lock()
+	defer operStateProvidersLock.Unlock()
+	operStateProviders = nil
+}
+
+// splitSchemaPath splits path into schema node names, dropping module
+// prefixes and list key predicates.
+func splitSchemaPath(path string) []string {
+	words := []string{}
+	for _, w := range strings.Split(path, "/") {
+		if w == "" {
+			continue
+		}
+		if idx := strings.Index(w, "["); idx >= 0 {
+			w = w[:idx]
+		}
+		if idx := strings.Index(w, ":"); idx >= 0 {
+			w = w[idx+1:]
+		}
+		words = append(words, w)
+	}
+	return words
+}
+
+// lookupOperStateProviders returns providers whose registered subtree
+// overlaps with xpath, i.e. one of them is a prefix of the other.
+func lookupOperStateProviders(xpath XPath) []operStateRegistration {
+	operStateProvidersLock.Lock()
+	defer operStateProvidersLock.Unlock()
+	ret := []operStateRegistration{}
+	for _, reg := range operStateProviders {
+		for _, path := range reg.paths {
+			if schemaPathOverlaps(path, xpath) {
+				ret = append(ret, reg)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+func schemaPathOverlaps(path []string, xpath XPath) bool {
+	for i := 0; i < len(path) && i < len(xpath.Words); i++ {
+		if path[i] != xpath.Words[i].Word {
+			return false
+		}
+	}
+	return true
+}
+
+// collectOperState queries all relevant providers in parallel. Providers
+// which fail or don't answer within operStateTimeout are reported as
+// warnings and the result of the other providers is still returned.
+func collectOperState(xpath XPath) (*DBNode, []error) {
+	regs := lookupOperStateProviders(xpath)
+	if len(regs) == 0 {
+		return nil, nil
+	}
+
+	type result struct {
+		reg   operStateRegistration
+		datas []YangData
+		err   error
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), operStateTimeout)
+	defer cancel()
+	results := make(chan result, len(regs))
+	for _, reg := range regs {
+		go func(reg operStateRegistration) {
+			datas, err := reg.provider.GetOperState(ctx, xpath)
+			results <- result{reg: reg, datas: datas, err: err}
+		}(reg)
+	}
+
+	datas := []YangData{}
+	errs := []error{}
+	pending := map[OperStateHandle]string{}
+	for _, reg := range regs {
+		pending[reg.handle] = reg.name
+	}
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.reg.handle)
+			if r.err != nil {
+				errs = append(errs, errors.Wrapf(r.err, "provider %s", r.reg.name))
+				continue
+			}
+			datas = append(datas, r.datas...)
+		case <-ctx.Done():
+			for _, reg := range regs {
+				if _, ok := pending[reg.handle]; ok {
+					errs = append(errs, errors.Errorf(
+						"provider %s timed out, partial result", reg.name))
+				}
+			}
+			pending = nil
+		}
+	}
+
+	node, err := CraftDBNode(datas)
+	if err != nil {
+		return nil, append(errs, errors.Wrap(err, "CraftDBNode"))
+	}
+	return node, errs
+}
+
+// getNodeWithOperState merges the live operational state into a copy of
+// the running config and returns the node at xpath and the merged root.
+func getNodeWithOperState(xpath XPath) (*DBNode, *DBNode, []error) {
+	root := dbm.root.DeepCopy()
+	root.Type = Container
+	oper, errs := collectOperState(xpath)
+	if oper != nil {
+		mergeDBNode(root, oper, yangRootEntry())
+	}
+	m := NewDatabaseManager()
+	if err := m.LoadDatabaseFromData(root); err != nil {
+		return nil, nil, append(errs, err)
+	}
+	node, err := m.GetNode(xpath)
+	if err != nil {
+		return nil, nil, append(errs, err)
+	}
+	return node, &m.root, errs
+}
+
+func yangRootEntry() *yang.Entry {
+	root := &yang.Entry{Dir: map[string]*yang.Entry{}}
+	for _, e := range yangModuleDumpEntries() {
+		if _, ok := root.Dir[e.Name]; !ok {
+			root.Dir[e.Name] = e
+		}
+	}
+	return root
+}
+
+func lookupEntryChild(e *yang.Entry, name string) *yang.Entry {
+	if e == nil {
+		return nil
+	}
+	for n, ee := range e.Dir {
+		switch {
+		case ee.IsChoice():
+			for _, ee2 := range ee.Dir {
+				if ee3 := lookupEntryChild(ee2, name); ee3 != nil {
+					return ee3
+				}
+			}
+		default:
+			if n == name {
+				return ee
+			}
+		}
+	}
+	return nil
+}
+
+// mergeDBNode merges src into dst. List elements are matched by their
+// keys, leaves of src overwrite the ones of dst.
+func mergeDBNode(dst, src *DBNode, e *yang.Entry) {
+	for idx := range src.Childs {
+		schild := src.Childs[idx]
+		ce := lookupEntryChild(e, schild.Name)
+		var dchild *DBNode
+		for idx2 := range dst.Childs {
+			if dst.Childs[idx2].Name == schild.Name {
+				dchild = &dst.Childs[idx2]
+				break
+			}
+		}
+		if dchild == nil {
+			dst.Childs = append(dst.Childs, schild)
+			continue
+		}
+		switch schild.Type {
+		case Container:
+			mergeDBNode(dchild, &schild, ce)
+		case List:
+			mergeDBNodeList(dchild, &schild, ce)
+		default:
+			*dchild = schild
+		}
+	}
+}
+
+func mergeDBNodeList(dst, src *DBNode, e *yang.Entry) {
+	keys := []string{}
+	if e != nil {
+		keys = strings.Fields(e.Key)
+	}
+	for idx := range src.Childs {
+		selem := src.Childs[idx]
+		var delem *DBNode
+		if len(keys) > 0 {
+			for idx2 := range dst.Childs {
+				if listElementKeyEqual(&dst.Childs[idx2], &selem, keys) {
+					delem = &dst.Childs[idx2]
+					break
+				}
+			}
+		}
+		if delem == nil {
+			dst.Childs = append(dst.Childs, selem)
+			continue
+		}
+		mergeDBNode(delem, &selem, e)
+	}
+}
+
+func listElementKeyEqual(a, b *DBNode, keys []string) bool {
+	for _, k := range keys {
+		var va, vb *DBValue
+		for idx := range a.Childs {
+			if a.Childs[idx].Name == k {
+				va = &a.Childs[idx].Value
+			}
+		}
+		for idx := range b.Childs {
+			if b.Childs[idx].Name == k {
+				vb = &b.Childs[idx].Value
+			}
+		}
+		if va == nil || vb == nil {
+			return false
+		}
+		// NOTE(slankdev): values loaded from json file don't hold the yang
+		// type (e.g. uint8 is loaded as decimal64), so compare as string.
+		if fmt.Sprintf("%v", va.ToValue()) != fmt.Sprintf("%v", vb.ToValue()) {
+			return false
+		}
+	}
+	return true
+}
+
+// mgmtdOperStateProvider fetches config-false data from FRR mgmtd.
+type mgmtdOperStateProvider struct{}
+
+func (mgmtdOperStateProvider) GetOperState(ctx context.Context,
+	xpath XPath) ([]YangData, error) {
+	xpathStr := xpath.String()
+	if xpathStr == "" {
+		xpathStr = "/"
+	}
+	config, err := mgmtdClient.GetOperData(ctx, xpathStr)
+	if err != nil {
+		return nil, errors.Wrap(err, "mgmtd.GetOperData")
+	}
+	datas := []YangData{}
+	for _, data := range config {
+		xp, err := ParseXPathString(dbm, data.GetXpath())
+		if err != nil {
+			log.Printf("mgmtd operstate %s ignored: %s\n", data.GetXpath(), err)
+			continue
+		}
+		datas = append(datas, YangData{
+			XPath: xp,
+			Value: data.GetValue().GetEncodedStrVal(),
+		})
+	}
+	return datas, nil
+}
@@ -0,0 +1,189 @@
+package vtyang
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	vtyangapi "github.com/slankdev/vtyang/pkg/grpc/api"
+)
+
+func newTestOperStateProvider(t *testing.T, kv [][2]string) OperStateProvider {
+	return OperStateProviderFunc(func(ctx context.Context,
+		xpath XPath) ([]YangData, error) {
+		datas := []YangData{}
+		for _, item := range kv {
+			xp, err := ParseXPathString(dbm, item[0])
+			if err != nil {
+				t.Error(err)
+				return nil, err
+			}
+			datas = append(datas, YangData{XPath: xp, Value: item[1]})
+		}
+		return datas, nil
+	})
+}
+
+func TestOperStateProvider01(t *testing.T) {
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/operstate",
+		InitConfigFile: "./testdata/operstate_config.json",
+		OutputFile:     "./testdata/output/TestOperStateProvider01.txt",
+		Setup: func(t *testing.T) {
+			RegisterOperStateProvider("system", []string{"/operstate:system/state"},
+				newTestOperStateProvider(t, [][2]string{
+					{"/operstate:system/state/uptime", "3600"},
+					{"/operstate:system/state/version", "v0.0.1"},
+				}))
+			RegisterOperStateProvider("counters", []string{"/system/interface/counters"},
+				newTestOperStateProvider(t, [][2]string{
+					{"/operstate:system/interface[name='eth0']/counters/in-octets", "100"},
+					{"/operstate:system/interface[name='eth0']/counters/out-octets", "200"},
+					{"/operstate:system/interface[name='eth2']/counters/in-octets", "300"},
+				}))
+		},
+		Inputs: []string{
+			"show system state",
+			"show system interface eth0",
+			"show system",
+			"show running-config",
+		},
+	})
+}
+
+// The providers registered with the same name are queried individually
+func TestOperStateProviderSameName(t *testing.T) {
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/operstate",
+		InitConfigFile: "./testdata/operstate_config.json",
+		OutputFile:     "./testdata/output/TestOperStateProviderSameName.txt",
+		Setup: func(t *testing.T) {
+			RegisterOperStateProvider("agent:x", []string{"/system/state"},
+				newTestOperStateProvider(t, [][2]string{
+					{"/operstate:system/state/uptime", "3600"},
+				}))
+			handle := RegisterOperStateProvider("agent:x",
+				[]string{"/system/state", "/system/interface/counters"},
+				newTestOperStateProvider(t, [][2]string{
+					{"/operstate:system/state/version", "v0.0.1"},
+				}))
+			RegisterOperStateProvider("agent:x", []string{"/system/state"},
+				newTestOperStateProvider(t, [][2]string{
+					{"/operstate:system/state/version", "v0.0.2"},
+				}))
+			UnregisterOperStateProvider(handle)
+		},
+		Inputs: []string{
+			"show system state",
+		},
+	})
+}
+
+func TestOperStateProviderTimeout(t *testing.T) {
+	defer func(d time.Duration) { operStateTimeout = d }(operStateTimeout)
+	operStateTimeout = 100 * time.Millisecond
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/operstate",
+		InitConfigFile: "./testdata/operstate_config.json",
+		OutputFile:     "./testdata/output/TestOperStateProviderTimeout.txt",
+		Setup: func(t *testing.T) {
+			RegisterOperStateProvider("system", []string{"/system/state"},
+				newTestOperStateProvider(t, [][2]string{
+					{"/operstate:system/state/uptime", "3600"},
+				}))
+			RegisterOperStateProvider("slow", []string{"/system/state"},
+				OperStateProviderFunc(func(ctx context.Context,
+					xpath XPath) ([]YangData, error) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}))
+		},
+		Inputs: []string{
+			"show system state",
+		},
+	})
+}
+
+func TestOperStateGrpcAgent(t *testing.T) {
+	if err := InitAgent(AgentOpts{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/operstate"},
+		LogFile:     agentTestDefaultLogFile,
+		Grpc:        &AgentOptsGrpc{Address: "127.0.0.1:0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer stopGrpcServer()
+
+	// Connect agent
+	conn, err := grpc.Dial(grpcListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := vtyangapi.NewAgentServiceClient(conn).
+		Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&vtyangapi.AgentMessage{
+		Message: &vtyangapi.AgentMessage_Register{
+			Register: &vtyangapi.AgentRegister{
+				Name:           "agent0",
+				OperStatePaths: []string{"/system/state"},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			stream.Send(&vtyangapi.AgentMessage{
+				Message: &vtyangapi.AgentMessage_OperStateReply{
+					OperStateReply: &vtyangapi.OperStateReply{
+						ReqId: req.GetOperStateReq().ReqId,
+						Data: []*vtyangapi.YangData{
+							{
+								Xpath: "/operstate:system/state/version",
+								Value: "agent0-v1",
+							},
+						},
+					},
+				},
+			})
+		}
+	}()
+
+	// Wait registration
+	for i := 0; ; i++ {
+		xpath, _, err := ParseXPathArgs(dbm, []string{"system", "state"}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lookupOperStateProviders(xpath)) > 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("agent not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	buf := setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("show system state")
+	expected := "{\n  \"version\": \"agent0-v1\"\n}\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
@@ -0,0 +1,485 @@
+package vtyang
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// outputPipe is the output modifier following "|" in the command line
+// (e.g. "show running-config | include hoge").
+type outputPipe struct {
+	name string
+	args []string
+}
+
+type outputPipeSpec struct {
+	name string
+	help string
+	// arg is the placeholder of the argument, no argument when empty
+	arg     string
+	argHelp string
+	// choices are the valid arguments, any argument when empty
+	choices []CompletionItem
+}
+
+var outputPipeSpecs = []outputPipeSpec{
+	{
+		name:    "begin",
+		help:    "Show the output from the line matching the pattern",
+		arg:     "REGEX",
+		argHelp: "Regular expression",
+	},
+	{
+		name: "count",
+		help: "Count the lines of the output",
+	},
+	{
+		name:    "display",
+		help:    "Display the data in the format",
+		arg:     "FORMAT",
+		argHelp: "Output format",
+		choices: []CompletionItem{
+			{Word: "defaults", Helper: "Display the data with the default values"},
+			{Word: "set", Helper: "Display the data as set commands"},
+		},
+	},
+	{
+		name:    "exclude",
+		help:    "Show the lines not matching the pattern",
+		arg:     "REGEX",
+		argHelp: "Regular expression",
+	},
+	{
+		name:    "include",
+		help:    "Show the lines matching the pattern",
+		arg:     "REGEX",
+		argHelp: "Regular expression",
+	},
+	{
+		name: "json",
+		help: "Display the data in JSON",
+	},
+	{
+		name: "no-more",
+		help: "Don't paginate the output",
+	},
+	{
+		name:    "save",
+		help:    "Save the output to the file",
+		arg:     "FILENAME",
+		argHelp: "Output file name",
+	},
+}
+
+func lookupOutputPipeSpec(name string) *outputPipeSpec {
+	for idx := range outputPipeSpecs {
+		if outputPipeSpecs[idx].name == name {
+			return &outputPipeSpecs[idx]
+		}
+	}
+	return nil
+}
+
+// splitOutputPipes splits the segments of the command line into the
+// command and the output pipes.
+func splitOutputPipes(segments [][]string) ([]string, []outputPipe, error) {
+	if len(segments) == 1 {
+		return segments[0], nil, nil
+	}
+	if len(segments[0]) == 0 {
+		return nil, nil, errors.Errorf("command is missing before |")
+	}
+	pipes := []outputPipe{}
+	for _, words := range segments[1:] {
+		pipe, err := parseOutputPipe(words)
+		if err != nil {
+			return nil, nil, err
+		}
+		pipes = append(pipes, pipe)
+	}
+	return segments[0], pipes, nil
+}
+
+func parseOutputPipe(words []string) (outputPipe, error) {
+	if len(words) == 0 {
+		return outputPipe{}, errors.Errorf("pipe is missing after |")
+	}
+	spec := lookupOutputPipeSpec(words[0])
+	if spec == nil {
+		return outputPipe{}, errors.Errorf("pipe %s not found", words[0])
+	}
+	pipe := outputPipe{name: words[0], args: words[1:]}
+	switch {
+	case spec.arg == "" && len(pipe.args) > 0:
+		return outputPipe{}, errors.Errorf("| %s takes no argument", pipe.name)
+	case spec.arg != "" && len(pipe.args) == 0:
+		return outputPipe{}, errors.Errorf("| %s requires %s", pipe.name, spec.arg)
+	case spec.arg == "FILENAME" && len(pipe.args) > 1:
+		return outputPipe{}, errors.Errorf("| %s takes one %s", pipe.name, spec.arg)
+	}
+	if len(spec.choices) > 0 {
+		valid := false
+		for _, c := range spec.choices {
+			if len(pipe.args) == 1 && pipe.args[0] == c.Word {
+				valid = true
+			}
+		}
+		if !valid {
+			return outputPipe{}, errors.Errorf("| %s: invalid %s %s", pipe.name,
+				spec.arg, strings.Join(pipe.args, " "))
+		}
+	}
+	if spec.arg == "REGEX" {
+		if _, err := regexp.Compile(strings.Join(pipe.args, " ")); err != nil {
+			return outputPipe{}, errors.Wrapf(err, "| %s", pipe.name)
+		}
+	}
+	return pipe, nil
+}
+
+type outputFormat int
+
+const (
+	outputFormatDefault outputFormat = iota
+	outputFormatJSON
+	outputFormatSet
+	outputFormatDefaults
+)
+
+func (f outputFormat) String() string {
+	switch f {
+	case outputFormatJSON:
+		return "json"
+	case outputFormatSet:
+		return "display set"
+	case outputFormatDefaults:
+		return "display defaults"
+	default:
+		return "default"
+	}
+}
+
+var (
+	// outputFmt is the format selected by the pipe of the executing
+	// command.
+	outputFmt = outputFormatDefault
+	// outputFmtUsed is set when the command displays the data in
+	// outputFmt.
+	outputFmtUsed = false
+)
+
+// useOutputFormat returns the output format selected by the pipe when
+// it's one of the formats supported by the command.
+func useOutputFormat(supported ...outputFormat) outputFormat {
+	for _, f := range supported {
+		if f == outputFmt {
+			outputFmtUsed = true
+			return f
+		}
+	}
+	return outputFormatDefault
+}
+
+// lineWriter passes the output to the function line by line.
+type lineWriter struct {
+	buf  []byte
+	line func(line []byte) error
+	// end is called on close after the last line
+	end func() error
+	// abort is called instead of close when the command failed
+	abort func()
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := w.buf[:idx+1]
+		w.buf = w.buf[idx+1:]
+		if err := w.line(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) Close() error {
+	if len(w.buf) > 0 {
+		line := w.buf
+		w.buf = nil
+		if err := w.line(line); err != nil {
+			return err
+		}
+	}
+	if w.end != nil {
+		return w.end()
+	}
+	return nil
+}
+
+func newOutputPipeWriter(pipe outputPipe, next io.Writer) (*lineWriter, error) {
+	switch pipe.name {
+	case "include", "exclude":
+		re := regexp.MustCompile(strings.Join(pipe.args, " "))
+		include := pipe.name == "include"
+		return &lineWriter{line: func(line []byte) error {
+			if re.Match(bytes.TrimRight(line, "\n")) == include {
+				_, err := next.Write(line)
+				return err
+			}
+			return nil
+		}}, nil
+	case "begin":
+		re := regexp.MustCompile(strings.Join(pipe.args, " "))
+		begun := false
+		return &lineWriter{line: func(line []byte) error {
+			if !begun && !re.Match(bytes.TrimRight(line, "\n")) {
+				return nil
+			}
+			begun = true
+			_, err := next.Write(line)
+			return err
+		}}, nil
+	case "count":
+		count := 0
+		return &lineWriter{
+			line: func(line []byte) error {
+				count++
+				return nil
+			},
+			end: func() error {
+				_, err := fmt.Fprintf(next, "Count: %d lines\n", count)
+				return err
+			},
+		}, nil
+	case "save":
+		// The output is written to the temporary file, so that the file
+		// isn't truncated when the command fails
+		if err := checkFileAccess(); err != nil {
+			return nil, err
+		}
+		filename := pipe.args[0]
+		f, err := os.CreateTemp(filepath.Dir(filename),
+			"."+filepath.Base(filename)+".*")
+		if err != nil {
+			return nil, err
+		}
+		count := 0
+		return &lineWriter{
+			line: func(line []byte) error {
+				count++
+				_, err := f.Write(line)
+				return err
+			},
+			end: func() error {
+				if err := f.Close(); err != nil {
+					os.Remove(f.Name())
+					return err
+				}
+				if err := os.Rename(f.Name(), filename); err != nil {
+					os.Remove(f.Name())
+					return err
+				}
+				_, err := fmt.Fprintf(next, "Wrote %d lines of output to '%s'\n",
+					count, filename)
+				return err
+			},
+			abort: func() {
+				f.Close()
+				os.Remove(f.Name())
+			},
+		}, nil
+	}
+	// The formats are applied by the command. The output isn't paged, so
+	// no-more does nothing.
+	return nil, nil
+}
+
+// startOutputPipes redirects stdout to the pipes. The returned function
+// flushes the output and restores stdout. The output isn't flushed but
+// discarded when the command failed.
+func startOutputPipes(pipes []outputPipe) (func(failed bool), error) {
+	out := stdout
+	writers := []*lineWriter{}
+	format := outputFormatDefault
+	for idx := len(pipes) - 1; idx >= 0; idx-- {
+		switch pipes[idx].name {
+		case "json":
+			format = outputFormatJSON
+		case "display":
+			format = outputFormatSet
+			if pipes[idx].args[0] == "defaults" {
+				format = outputFormatDefaults
+			}
+		}
+		var next io.Writer = out
+		if len(writers) > 0 {
+			next = writers[0]
+		}
+		w, err := newOutputPipeWriter(pipes[idx], next)
+		if err != nil {
+			for _, w := range writers {
+				if w.abort != nil {
+					w.abort()
+				}
+			}
+			return nil, err
+		}
+		if w != nil {
+			writers = append([]*lineWriter{w}, writers...)
+		}
+	}
+
+	// The output is held until the command turns out to support the
+	// format
+	var head io.Writer = out
+	if len(writers) > 0 {
+		head = writers[0]
+	}
+	var held *bytes.Buffer
+	if format != outputFormatDefault {
+		held = &bytes.Buffer{}
+		stdout = held
+	} else {
+		stdout = head
+	}
+	outputFmt = format
+	outputFmtUsed = false
+
+	return func(failed bool) {
+		stdout = out
+		if failed {
+			for _, w := range writers {
+				if w.abort != nil {
+					w.abort()
+				}
+			}
+			outputFmt = outputFormatDefault
+			outputFmtUsed = false
+			return
+		}
+		if held != nil {
+			if outputFmtUsed {
+				if _, err := held.WriteTo(head); err != nil {
+					fmt.Fprintf(out, "Error: %s\n", err)
+				}
+			} else {
+				fmt.Fprintf(out, "Error: | %s is not supported by the command\n",
+					format)
+			}
+		}
+		for _, w := range writers {
+			if err := w.Close(); err != nil {
+				fmt.Fprintf(out, "Error: %s\n", err)
+			}
+		}
+		outputFmt = outputFormatDefault
+		outputFmtUsed = false
+	}, nil
+}
+
+// writeDBNode displays the node at xpath of the data tree root in the
+// output format selected by the pipe.
+func writeDBNode(root *DBNode, xpath XPath, node *DBNode) error {
+	switch useOutputFormat(outputFormatJSON, outputFormatSet) {
+	case outputFormatSet:
+		lines, err := displaySetLines(root, xpath)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			fmt.Fprintln(stdout, line)
+		}
+	default:
+		fmt.Fprintln(stdout, node.String())
+	}
+	return nil
+}
+
+// completeOutputPipes returns the completion items of the output pipes
+// when the line has "|".
+func completeOutputPipes(line string, pos int) ([]CompletionItem, bool) {
+	line = line[:pos]
+	args := strings.Fields(line)
+	if len(line) > 0 && line[len(line)-1] == ' ' {
+		args = append(args, "")
+	}
+	last := -1
+	for idx, arg := range args {
+		if arg == "|" {
+			last = idx
+		}
+	}
+	if last < 0 {
+		return nil, false
+	}
+
+	words := args[last+1:]
+	items := []CompletionItem{}
+	if len(words) == 0 {
+		// The cursor is just after "|"
+		return []CompletionItem{{Word: "|", Helper: "Output modifiers"}}, true
+	}
+	if len(words) == 1 {
+		for _, spec := range outputPipeSpecs {
+			if strings.HasPrefix(spec.name, words[0]) {
+				items = append(items, CompletionItem{
+					Word:   spec.name,
+					Helper: spec.help,
+				})
+			}
+		}
+		return items, true
+	}
+
+	spec := lookupOutputPipeSpec(words[0])
+	if spec == nil {
+		return items, true
+	}
+	typing := words[len(words)-1]
+	given := words[1 : len(words)-1]
+	switch {
+	case spec.arg == "":
+		if len(given) == 0 && typing == "" {
+			items = append(items, outputPipeEndItems()...)
+		}
+	case len(given) == 0 && len(spec.choices) > 0:
+		for _, c := range spec.choices {
+			if strings.HasPrefix(c.Word, typing) {
+				items = append(items, c)
+			}
+		}
+	case len(given) == 0:
+		items = append(items, CompletionItem{Word: spec.arg, Helper: spec.argHelp})
+	case spec.arg == "REGEX":
+		// The regular expression can have spaces
+		items = append(items, CompletionItem{Word: spec.arg, Helper: spec.argHelp})
+		if typing == "" {
+			items = append(items, outputPipeEndItems()...)
+		}
+	case len(given) == 1 && typing == "":
+		items = append(items, outputPipeEndItems()...)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Word < items[j].Word
+	})
+	return items, true
+}
+
+func outputPipeEndItems() []CompletionItem {
+	return []CompletionItem{
+		{Word: "<cr>"},
+		{Word: "|", Helper: "Output modifiers"},
+	}
+}
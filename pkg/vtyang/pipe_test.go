@@ -0,0 +1,189 @@
+package vtyang
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputPipe(t *testing.T) {
+	saveFile := "/tmp/run/vtyang/pipe_save.txt"
+	executeTestCase(t, &TestCase{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    "./testdata/yang/basic",
+		OutputFile:  "./testdata/output/TestOutputPipe.txt",
+		Inputs: []string{
+			"configure",
+			"set values u08 10",
+			"set values name hoge",
+			"set values items item1 foo description foo-desc",
+			"set values items item1 bar",
+			"set values items item2 baz t1 description baz-desc",
+			"commit",
+			"show values | display set",
+			"quit",
+			"show running-config | include desc",
+			`show running-config | exclude "\""`,
+			"show running-config | begin items | count",
+			"show running-config | display set",
+			"show running-config values items item1 | display set",
+			"show running-config | display set | exclude item | include name",
+			"show running-config | json | count",
+			"show running-config | no-more | count",
+			"show running-config | display set | save " + saveFile,
+			"show running-config hoge | save " + saveFile,
+			"show configuration commit list | display set",
+			"show running-config | include (",
+			"show running-config | display json",
+			"show running-config | count foo",
+			"show running-config |",
+			"| count",
+			"show running-config | grep foo",
+		},
+	})
+
+	out, err := os.ReadFile(saveFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "set values items item1 foo description foo-desc\n" +
+		"set values items item1 bar\n" +
+		"set values items item2 baz t1 description baz-desc\n" +
+		"set values name hoge\n" +
+		"set values u08 10\n"
+	if string(out) != expected {
+		t.Errorf("unexpected saved output\n%s", out)
+	}
+	tmps, err := filepath.Glob("/tmp/run/vtyang/.pipe_save.txt.*")
+	if err != nil || len(tmps) > 0 {
+		t.Errorf("temporary files are left %v %v", tmps, err)
+	}
+
+	buf := setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("show configuration commit list | json")
+	list := []map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(commitHistories) || list[0]["client"] != "cli" {
+		t.Errorf("unexpected commit list\n%s", buf.String())
+	}
+}
+
+func TestDoCompletionOutputPipe(t *testing.T) {
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/basic"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []TestDoCompletionTestCase{
+		{
+			in: "show running-config ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "values"},
+					{Word: "|"},
+				},
+			},
+		},
+		{
+			in: "show configuration commit list ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "<cr>"},
+					{Word: "|"},
+				},
+			},
+		},
+		{
+			in: "show running-config | ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "begin"},
+					{Word: "count"},
+					{Word: "display"},
+					{Word: "exclude"},
+					{Word: "include"},
+					{Word: "json"},
+					{Word: "no-more"},
+					{Word: "save"},
+				},
+			},
+		},
+		{
+			in: "show running-config | ex",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "exclude"},
+				},
+			},
+		},
+		{
+			in: "show running-config | include ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "REGEX"},
+				},
+			},
+		},
+		{
+			in: "show running-config | include foo ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "<cr>"},
+					{Word: "REGEX"},
+					{Word: "|"},
+				},
+			},
+		},
+		{
+			in: "show running-config | display ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "defaults"},
+					{Word: "set"},
+				},
+			},
+		},
+		{
+			in: "show running-config | display set ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "<cr>"},
+					{Word: "|"},
+				},
+			},
+		},
+		{
+			in: "show running-config | count ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "<cr>"},
+					{Word: "|"},
+				},
+			},
+		},
+		{
+			in: "show running-config | save ",
+			out: CompletionResult{
+				Items: []CompletionItem{
+					{Word: "FILENAME"},
+				},
+			},
+		},
+		{
+			in:  "show running-config | grep ",
+			out: CompletionResult{Items: []CompletionItem{}},
+		},
+	}
+	for idx := range testcases {
+		t.Logf("execute tc[%d] \"%s\"", idx, testcases[idx].in)
+		if err := executeDoCompletionTestCase(testcases, idx); err != nil {
+			t.Errorf("fail tc[%d] err=\"%s\"\n", idx, err)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package vtyang
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+)
+
+// RPCHandler executes a YANG rpc or action. xpath points the rpc, or the
+// action including the keys of the data node it is invoked on. input is
+// already validated against the input statement of the rpc.
+type RPCHandler interface {
+	CallRPC(ctx context.Context, xpath XPath, input *DBNode) (*DBNode, error)
+}
+
+type RPCHandlerFunc func(ctx context.Context, xpath XPath, input *DBNode) (*DBNode, error)
+
+func (f RPCHandlerFunc) CallRPC(ctx context.Context, xpath XPath,
+	input *DBNode) (*DBNode, error) {
+	return f(ctx, xpath, input)
+}
+
+// RPCHandle identifies the registration of the handler.
+type RPCHandle int
+
+type rpcRegistration struct {
+	handle  RPCHandle
+	path    string
+	handler RPCHandler
+}
+
+var (
+	rpcTimeout      = 10 * time.Second
+	rpcHandlers     = map[string]rpcRegistration{}
+	rpcLastHandle   RPCHandle
+	rpcHandlersLock sync.Mutex
+)
+
+// RegisterRPCHandler registers h for the rpc or action at path. The path is
+// a slash separated list of schema node names such as "/reboot" or
+// "/system/interface/reset", module prefixes and list keys are ignored.
+// A path has at most one handler, the registration for the path already
+// registered is rejected.
+func RegisterRPCHandler(path string, h RPCHandler) (RPCHandle, error) {
+	rpcHandlersLock.Lock()
+	defer rpcHandlersLock.Unlock()
+	key := rpcHandlerKey(splitSchemaPath(path))
+	if _, ok := rpcHandlers[key]; ok {
+		return 0, errors.Errorf("rpc handler for %s already registered", key)
+	}
+	rpcLastHandle++
+	rpcHandlers[key] = rpcRegistration{
+		handle:  rpcLastHandle,
+		path:    path,
+		handler: h,
+	}
+	log.Printf("rpc handler registered for %s\n", path)
+	return rpcLastHandle, nil
+}
+
+func UnregisterRPCHandler(handle RPCHandle) {
+	rpcHandlersLock.Lock()
+	defer rpcHandlersLock.Unlock()
+	for key, reg := range rpcHandlers {
+		if reg.handle == handle {
+			delete(rpcHandlers, key)
+			log.Printf("rpc handler unregistered for %s\n", reg.path)
+		}
+	}
+}
+
+func resetRPCHandlers() {
+	rpcHandlersLock.Lock()
+	defer rpcHandlersLock.Unlock()
+	rpcHandlers = map[string]rpcRegistration{}
+}
+
+func rpcHandlerKey(words []string) string {
+	return "/" + strings.Join(words, "/")
+}
+
+func lookupRPCHandler(xpath XPath) RPCHandler {
+	words := []string{}
+	for _, w := range xpath.Words {
+		words = append(words, w.Word)
+	}
+	rpcHandlersLock.Lock()
+	defer rpcHandlersLock.Unlock()
+	return rpcHandlers[rpcHandlerKey(words)].handler
+}
+
+// rpcScriptHandler runs an external program. The input is given as json
+// on stdin and the output is read as json from stdout. The xpath of the
+// invoked rpc is given with VTYANG_RPC_XPATH environment variable.
+type rpcScriptHandler struct {
+	path string
+}
+
+func (h rpcScriptHandler) CallRPC(ctx context.Context, xpath XPath,
+	input *DBNode) (*DBNode, error) {
+	stderr := bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, h.path)
+	cmd.Stdin = strings.NewReader(input.String())
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "VTYANG_RPC_XPATH="+xpath.String())
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.Errorf("%s: %s", h.path, msg)
+		}
+		return nil, errors.Wrap(err, h.path)
+	}
+	if strings.TrimSpace(string(out)) == "" {
+		return &DBNode{Type: Container}, nil
+	}
+	return ReadFromJsonString(string(out))
+}
+
+// isRPCEntry returns true for rpc and action statements. Goyang only sets
+// the RPC field of an action when it has input or output statements.
+func isRPCEntry(e *yang.Entry) bool {
+	if e.RPC != nil {
+		return true
+	}
+	return e.Node != nil && e.Node.Kind() == "action"
+}
+
+// parseRPCPath resolves args into the xpath of the rpc or action and the
+// remaining input arguments. An action is addressed by the data path
+// followed by the action name like "system interface eth0 reset".
+func parseRPCPath(args []string) (XPath, *yang.Entry, []string, error) {
+	if len(args) == 0 {
+		return XPath{}, nil, nil, errors.Errorf("rpc name is not specified")
+	}
+
+	// Top-level rpc
+	for _, e := range yangModuleDumpEntries() {
+		if e.Name == args[0] && e.RPC != nil && e.Node.Kind() == "rpc" {
+			mod, err := e.InstantiatingModule()
+			if err != nil {
+				return XPath{}, nil, nil, errors.Wrap(err, "InstantiatingModule")
+			}
+			xpath := XPath{Words: []XWord{{Module: mod, Word: e.Name}}}
+			return xpath, e, args[1:], nil
+		}
+	}
+
+	// Action nested in data nodes
+	e := yangRootEntry()
+	for i := 0; i < len(args); i++ {
+		ee := lookupEntryChild(e, args[i])
+		if ee == nil {
+			return XPath{}, nil, nil, errors.Errorf("entry %s is not found", args[i])
+		}
+		if isRPCEntry(ee) && i > 0 {
+			xpath, _, err := ParseXPathArgs(dbm, args[:i], false)
+			if err != nil {
+				return XPath{}, nil, nil, errors.Wrap(err, "ParseXPathArgs")
+			}
+			mod, err := ee.InstantiatingModule()
+			if err != nil {
+				return XPath{}, nil, nil, errors.Wrap(err, "InstantiatingModule")
+			}
+			xpath.Words = append(xpath.Words, XWord{Module: mod, Word: ee.Name})
+			return xpath, ee, args[i+1:], nil
+		}
+		if ee.IsList() {
+			i += len(strings.Fields(ee.Key))
+		}
+		e = ee
+	}
+	return XPath{}, nil, nil, errors.Errorf("rpc %s is not found", strings.Join(args, " "))
+}
+
+// parseRPCInput parses the cli arguments as a sequence of "<path> <value>"
+// and crafts the input node validated against the input statement.
+func parseRPCInput(input *yang.Entry, args []string) (*DBNode, error) {
+	datas := []YangData{}
+	for len(args) > 0 {
+		if input == nil {
+			return nil, errors.Errorf("unexpected input %s", args[0])
+		}
+		consumed := 0
+		for i := 1; i <= len(args); i++ {
+			xpath, vals, err := ParseXPathArgsImpl(input, args[:i], true)
+			if err != nil {
+				return nil, errors.Wrapf(err, "input %s",
+					strings.Join(args[:i], " "))
+			}
+			if len(vals) == 0 {
+				continue
+			}
+			switch xpath.Tail().Dbtype {
+			case Leaf:
+				datas = append(datas, YangData{XPath: xpath, Value: args[i-1]})
+				consumed = i
+			case LeafList:
+				// NOTE(slankdev): leaf-list takes all remaining arguments as
+				// same as set command in configure mode.
+				datas = append(datas, YangData{
+					XPath: xpath,
+					Value: strings.Join(args[i-1:], " "),
+				})
+				consumed = len(args)
+			}
+			if consumed > 0 {
+				break
+			}
+		}
+		if consumed == 0 {
+			return nil, errors.Errorf("input %s: value is not specified",
+				strings.Join(args, " "))
+		}
+		args = args[consumed:]
+	}
+
+	if input != nil {
+		for _, e := range input.Dir {
+			if e.IsLeaf() && e.Mandatory == yang.TSTrue {
+				found := false
+				for _, data := range datas {
+					if data.XPath.Words[0].Word == e.Name {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return nil, errors.Errorf("input %s is mandatory", e.Name)
+				}
+			}
+		}
+	}
+	return CraftDBNode(datas)
+}
+
+// typeDBNode validates n against the schema entry e and converts the leaf
+// values into their yang types. Values given by external handlers as json
+// don't hold the yang type.
+func typeDBNode(n *DBNode, e *yang.Entry) error {
+	for idx := range n.Childs {
+		child := &n.Childs[idx]
+		ce := lookupEntryChild(e, child.Name)
+		if ce == nil {
+			return errors.Errorf("%s is not defined", child.Name)
+		}
+		switch child.Type {
+		case Container:
+			if err := typeDBNode(child, ce); err != nil {
+				return err
+			}
+		case List:
+			for idx2 := range child.Childs {
+				if err := typeDBNode(&child.Childs[idx2], ce); err != nil {
+					return err
+				}
+			}
+		case Leaf:
+			if ce.Type == nil {
+				return errors.Errorf("%s is not a leaf", child.Name)
+			}
+			v, err := validateValue(dbValueString(child.Value), ce.Type)
+			if err != nil {
+				return errors.Wrapf(err, "%s", child.Name)
+			}
+			child.Value = v
+		case LeafList:
+			if ce.Type == nil || !ce.IsLeafList() {
+				return errors.Errorf("%s is not a leaf-list", child.Name)
+			}
+			// NOTE(slankdev): the items of leaf-list are held as string in
+			// DBNode, so they're only validated here.
+			for idx2, item := range child.ArrayValue {
+				v, err := validateValue(dbValueString(item), ce.Type)
+				if err != nil {
+					return errors.Wrapf(err, "%s", child.Name)
+				}
+				child.ArrayValue[idx2] = DBValue{
+					Type:   yang.Ystring,
+					String: dbValueString(v),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// callRPC executes the rpc or action addressed by args and returns its
+// output.
+//
+// NOTE(slankdev): mgmtd frontend protocol doesn't have rpc messages yet,
+// so rpcs are dispatched to go handlers, scripts and grpc agents only.
+func callRPC(args []string) (*DBNode, error) {
+	xpath, e, inputArgs, err := parseRPCPath(args)
+	if err != nil {
+		return nil, err
+	}
+	var inputEntry, outputEntry *yang.Entry
+	if e.RPC != nil {
+		inputEntry = e.RPC.Input
+		outputEntry = e.RPC.Output
+	}
+	input, err := parseRPCInput(inputEntry, inputArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	h := lookupRPCHandler(xpath)
+	if h == nil {
+		return nil, errors.Errorf("rpc %s is not implemented", xpath.String())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+	output, err := h.CallRPC(ctx, xpath, input)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rpc %s", xpath.String())
+	}
+	if output == nil {
+		output = &DBNode{Type: Container}
+	}
+	if len(output.Childs) > 0 && outputEntry == nil {
+		return nil, errors.Errorf("rpc %s has no output statement",
+			xpath.String())
+	}
+	if err := typeDBNode(output, outputEntry); err != nil {
+		return nil, errors.Wrap(err, "output")
+	}
+	return output, nil
+}
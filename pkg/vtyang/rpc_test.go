@@ -0,0 +1,204 @@
+package vtyang
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	vtyangapi "github.com/slankdev/vtyang/pkg/grpc/api"
+)
+
+func TestRPCHandler(t *testing.T) {
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/rpc",
+		InitConfigFile: "./testdata/rpc_config.json",
+		OutputFile:     "./testdata/output/TestRPCHandler.txt",
+		Setup: func(t *testing.T) {
+			if _, err := RegisterRPCHandler("/rpc:reboot", RPCHandlerFunc(func(
+				ctx context.Context, xpath XPath, input *DBNode) (*DBNode, error) {
+				return nil, nil
+			})); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := RegisterRPCHandler("/system/interface/reset", RPCHandlerFunc(func(
+				ctx context.Context, xpath XPath, input *DBNode) (*DBNode, error) {
+				if xpath.String() != "/rpc:system/rpc:interface[name='eth0']/rpc:reset" {
+					return nil, errors.Errorf("unexpected xpath %s", xpath.String())
+				}
+				result := "reset"
+				for _, child := range input.Childs {
+					if child.Name == "force" && child.Value.Boolean {
+						result = "force reset"
+					}
+				}
+				return ReadFromJsonString(`{"result": "` + result + `"}`)
+			})); err != nil {
+				t.Fatal(err)
+			}
+		},
+		Inputs: []string{
+			"rpc reboot",
+			"rpc reboot delay 10",
+			"rpc reboot delay hoge",
+			"rpc reboot unknown 10",
+			"rpc ping destination 10.0.0.1",
+			"action system interface eth0 reset",
+			"action system interface eth0 reset force true",
+			"action system interface eth0 hoge",
+		},
+	})
+}
+
+func TestRPCScriptHandler(t *testing.T) {
+	executeTestCase(t, &TestCase{
+		RuntimePath:    "/tmp/run/vtyang",
+		YangPath:       "./testdata/yang/rpc",
+		InitConfigFile: "./testdata/rpc_config.json",
+		OutputFile:     "./testdata/output/TestRPCScriptHandler.txt",
+		Setup: func(t *testing.T) {
+			if _, err := RegisterRPCHandler("/ping",
+				rpcScriptHandler{path: "./testdata/rpc/ping.sh"}); err != nil {
+				t.Fatal(err)
+			}
+		},
+		Inputs: []string{
+			"rpc ping",
+			"rpc ping destination 10.0.0.1 count 3",
+			"rpc ping destination 10.0.0.1 count 30",
+			"rpc ping destination unreachable",
+		},
+	})
+}
+
+func TestRPCHandlerDuplicate(t *testing.T) {
+	resetRPCHandlers()
+	defer resetRPCHandlers()
+	h := RPCHandlerFunc(func(ctx context.Context, xpath XPath,
+		input *DBNode) (*DBNode, error) {
+		return nil, nil
+	})
+	xpath := XPath{Words: []XWord{{Word: "ping"}}}
+
+	handle, err := RegisterRPCHandler("/rpc:ping", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = RegisterRPCHandler("/ping", h)
+	if err == nil || err.Error() != "rpc handler for /ping already registered" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The stale handle doesn't unregister the current handler
+	UnregisterRPCHandler(handle)
+	handle2, err := RegisterRPCHandler("/ping", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	UnregisterRPCHandler(handle)
+	if lookupRPCHandler(xpath) == nil {
+		t.Fatal("handler unregistered by the stale handle")
+	}
+	UnregisterRPCHandler(handle2)
+	if lookupRPCHandler(xpath) != nil {
+		t.Fatal("handler not unregistered")
+	}
+}
+
+func TestRPCGrpcAgent(t *testing.T) {
+	if err := InitAgent(AgentOpts{
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/rpc"},
+		LogFile:     agentTestDefaultLogFile,
+		Grpc:        &AgentOptsGrpc{Address: "127.0.0.1:0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer stopGrpcServer()
+
+	// Connect agent
+	conn, err := grpc.Dial(grpcListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := vtyangapi.NewAgentServiceClient(conn).
+		Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&vtyangapi.AgentMessage{
+		Message: &vtyangapi.AgentMessage_Register{
+			Register: &vtyangapi.AgentRegister{
+				Name: "agent0",
+				Rpcs: []string{"/rpc:ping"},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			stream.Send(&vtyangapi.AgentMessage{
+				Message: &vtyangapi.AgentMessage_RpcReply{
+					RpcReply: &vtyangapi.RPCReply{
+						ReqId:  req.GetRpcReq().ReqId,
+						Output: `{"sent": 1, "received": 0}`,
+					},
+				},
+			})
+		}
+	}()
+
+	// Wait registration
+	for i := 0; lookupRPCHandler(XPath{Words: []XWord{{Word: "ping"}}}) == nil; i++ {
+		if i > 100 {
+			t.Fatal("agent not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	buf := setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("rpc ping destination 10.0.0.1")
+	expected := "{\n  \"received\": 0,\n  \"sent\": 1\n}\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+
+	// The agent registering the same rpc is rejected and the handler of
+	// the first agent is kept
+	stream2, err := vtyangapi.NewAgentServiceClient(conn).
+		Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream2.Send(&vtyangapi.AgentMessage{
+		Message: &vtyangapi.AgentMessage_Register{
+			Register: &vtyangapi.AgentRegister{
+				Name: "agent1",
+				Rpcs: []string{"/ping"},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream2.Recv(); err == nil ||
+		!strings.Contains(err.Error(), "rpc handler for /ping already registered") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	buf = setStdoutWithBuffer()
+	getCommandNodeCurrent().executeCommand("rpc ping destination 10.0.0.1")
+	if buf.String() != expected {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
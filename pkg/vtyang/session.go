@@ -0,0 +1,487 @@
+package vtyang
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+)
+
+// Session is a cli session of the shell or the clients attached to the
+// daemon. Each session has its own mode, private candidate and edit
+// context, the running config is shared.
+//
+// The state of the session running the command is held by the globals
+// (e.g. cliMode, stdout and dbm.candidateRoot), which are those of the
+// console session while no other session runs a command. All of them are
+// guarded by commandLock, so the commands of all sessions are serialized
+// and a slow command (e.g. commit waiting for mgmtd) blocks the others.
+// This is a known limit until the state is moved into Session.
+//
+// The code running without commandLock must not touch the globals:
+//   - the background tasks (drift check, mgmtd resync, agent config push)
+//     take commandLock and run on the globals of the console session
+//   - the blocking commands (monitor) release it with unlockCommand and
+//     keep the writer of the session taken before unlocking
+//   - the goroutines of the servers (session, ssh, grpc, config change)
+//     take commandLock or work on the values copied under it, such as the
+//     config change events and the running config pushed to the agents
+type Session struct {
+	Id     int
+	User   string
+	Client string
+	Since  time.Time
+
+	mode      CliMode
+	candidate *DBNode
+	base      *DBNode
+	pushed    *DBNode
+	editStack []XPath
+	stdout    io.Writer
+	exit      bool
+
+	// closeFunc disconnects the client, nil for the console session
+	closeFunc func()
+	// privileged allows the commands overriding the other sessions, such
+	// as clear configuration lock. It's set for the console session and
+	// the attached sessions of root or the user running the daemon.
+	privileged bool
+}
+
+var (
+	sessions       []*Session
+	sessionLastId  int
+	consoleSession *Session
+	currentSession *Session
+)
+
+// resetSessions drops the sessions and starts the console session on the
+// current globals.
+func resetSessions() {
+	stopSessionServer()
+	stopSSHServer()
+	resetDatastoreLocks()
+	sessions = nil
+	sessionLastId = 0
+	consoleSession = nil
+	currentSession = nil
+	consoleSession = openSession(currentUsername(), "console", stdout, nil)
+	consoleSession.privileged = true
+	currentSession = consoleSession
+}
+
+// openSession registers the new session in view mode. The output of the
+// commands is written to w.
+func openSession(user, client string, w io.Writer, closeFunc func()) *Session {
+	sessionLastId++
+	s := &Session{
+		Id:        sessionLastId,
+		User:      user,
+		Client:    client,
+		Since:     time.Now(),
+		mode:      CliModeView,
+		stdout:    w,
+		closeFunc: closeFunc,
+	}
+	sessions = append(sessions, s)
+	log.Printf("session %d opened by %s (%s)\n", s.Id, user, client)
+	return s
+}
+
+// closeSession leaves configure mode of the session, releases its locks
+// and unregisters it.
+func closeSession(s *Session) {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	restore := s.activate()
+	if cliMode == CliModeConfigure {
+		if err := quitConfigureMode(); err != nil {
+			log.Printf("session %d: %s\n", s.Id, err)
+		}
+	}
+	restore()
+	releaseDatastoreLocks(s, false)
+	for idx := range sessions {
+		if sessions[idx] == s {
+			sessions = append(sessions[:idx], sessions[idx+1:]...)
+			break
+		}
+	}
+	log.Printf("session %d closed\n", s.Id)
+}
+
+func lookupSession(id int) *Session {
+	for _, s := range sessions {
+		if s.Id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// save stores the globals to the session.
+func (s *Session) save() {
+	s.mode = cliMode
+	s.candidate = dbm.candidateRoot
+	s.base = dbm.candidateBase
+	s.pushed = mgmtdPushed
+	s.editStack = editStack
+	s.stdout = stdout
+	s.exit = exit
+}
+
+// load restores the globals from the session.
+func (s *Session) load() {
+	cliMode = s.mode
+	dbm.candidateRoot = s.candidate
+	dbm.candidateBase = s.base
+	mgmtdPushed = s.pushed
+	editStack = s.editStack
+	stdout = s.stdout
+	exit = s.exit
+	if auditLog != nil {
+		auditLog.setSession(s.User, s.auditId())
+	}
+}
+
+// activate switches the globals to the session. The returned function
+// switches them back to the console session.
+func (s *Session) activate() func() {
+	if s == currentSession {
+		return func() {}
+	}
+	prev := currentSession
+	prev.save()
+	s.load()
+	currentSession = s
+	return func() {
+		s.save()
+		prev.load()
+		currentSession = prev
+	}
+}
+
+func (s *Session) auditId() string {
+	if s == consoleSession {
+		return fmt.Sprintf("cli-%d", os.Getpid())
+	}
+	return fmt.Sprintf("session-%d", s.Id)
+}
+
+// Mode returns the mode of the session, which is in the globals while the
+// session is running the command.
+func (s *Session) Mode() CliMode {
+	if s == currentSession {
+		return cliMode
+	}
+	return s.mode
+}
+
+// runCommand executes the command line in the session.
+func (s *Session) runCommand(ctx context.Context, cli string) error {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	defer s.activate()()
+	return getCommandNodeCurrent().runCommandLocked(ctx, cli)
+}
+
+func (s *Session) completion(line string, pos int) CompletionResult {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	defer s.activate()()
+	return doCompletion(line, pos)
+}
+
+func (s *Session) completer(line string, pos int) (string, []string, string) {
+	return completeWords(line, pos, s.completion(line, pos).Items)
+}
+
+func (s *Session) completionLister(line string, pos int) {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	defer s.activate()()
+	writeCompletionItems(stdout, doCompletion(line, pos).Items)
+}
+
+// prompt returns the prompt of the session's mode and edit context.
+func (s *Session) prompt() string {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	defer s.activate()()
+	return getPrompt()
+}
+
+// exited returns true after quit in view mode.
+func (s *Session) exited() bool {
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	if s == currentSession {
+		return exit
+	}
+	return s.exit
+}
+
+// configuringSession returns the other session in configure mode, nil when
+// there is none.
+func configuringSession() *Session {
+	for _, s := range sessions {
+		if s != currentSession && s.Mode() == CliModeConfigure {
+			return s
+		}
+	}
+	return nil
+}
+
+// anySessionConfiguring returns true when one of the sessions is in
+// configure mode.
+func anySessionConfiguring() bool {
+	if cliMode == CliModeConfigure {
+		return true
+	}
+	return configuringSession() != nil
+}
+
+func ccbShowUsers(ctx context.Context, args []string) (*CommandResult, error) {
+	table := newTable()
+	table.SetHeader([]string{"Session", "User", "Client", "Mode", "Since"})
+	list := append([]*Session{}, sessions...)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+	for _, s := range list {
+		id := strconv.Itoa(s.Id)
+		if s == currentSession {
+			id = "*" + id
+		}
+		mode := "view"
+		if s.Mode() == CliModeConfigure {
+			mode = "configure"
+		}
+		table.Append([]string{
+			id,
+			s.User,
+			s.Client,
+			mode,
+			s.Since.Format("2006-01-02 15:04:05"),
+		})
+	}
+	table.Render()
+	return nil, nil
+}
+
+// ccbClearSession disconnects the client of the session. The session is
+// closed by its server, which discards the candidate.
+func ccbClearSession(ctx context.Context, args []string) (*CommandResult, error) {
+	if len(args) != 3 {
+		fmt.Fprintf(stdout, "Usage: clear session <id>\n")
+		return nil, nil
+	}
+	id, err := strconv.Atoi(args[2])
+	if err != nil {
+		return nil, &CommandError{
+			ArgPos: 2,
+			Err:    errors.Errorf("invalid session id %s", args[2]),
+		}
+	}
+	s := lookupSession(id)
+	switch {
+	case s == nil:
+		return nil, &CommandError{
+			ArgPos: 2,
+			Err:    errors.Errorf("session %d is not found", id),
+		}
+	case s == currentSession:
+		return nil, errors.Errorf("can't clear the own session, use quit")
+	case s.closeFunc == nil:
+		return nil, errors.Errorf("session %d can't be cleared", id)
+	}
+	s.closeFunc()
+	return &CommandResult{
+		Message: fmt.Sprintf("session %d cleared", id),
+	}, nil
+}
+
+// mergeCommittedChanges merges the changes committed by the other sessions
+// since the candidate started into the candidate. It fails when both of
+// them changed the same node.
+func mergeCommittedChanges() error {
+	base := dbm.candidateBase
+	if base == nil || dbNodeEqual(base, &dbm.root) {
+		return nil
+	}
+	conflicts := []string{}
+	merged := mergeChanges(base, dbm.candidateRoot, &dbm.root, nil, "",
+		&conflicts)
+	if len(conflicts) > 0 {
+		return errors.Errorf("conflict with the commit of other session "+
+			"at %s, commit abort to restart from running",
+			strings.Join(conflicts, ", "))
+	}
+	if merged == nil {
+		merged = &DBNode{Type: Container}
+	}
+	dbm.candidateRoot = merged
+	dbm.candidateBase = dbm.root.DeepCopy()
+	return nil
+}
+
+// mergeChanges returns the node merging the changes from b (base) to c
+// (candidate) and the ones from b to r (running). The paths changed on both
+// sides differently are appended to conflicts.
+func mergeChanges(b, c, r *DBNode, e *yang.Entry, path string,
+	conflicts *[]string) *DBNode {
+	switch {
+	case dbNodeEqual(b, c):
+		return r
+	case dbNodeEqual(b, r), dbNodeEqual(c, r):
+		return c
+	case c == nil || r == nil:
+		*conflicts = append(*conflicts, path)
+		return r
+	case b == nil && c.Type == r.Type:
+		// The node created by both sides is merged from the empty one
+		b = &DBNode{Name: r.Name, Type: r.Type}
+	}
+	switch {
+	case b.Type == Container && c.Type == Container && r.Type == Container:
+		return mergeContainerChanges(b, c, r, e, path, conflicts)
+	case b.Type == List && c.Type == List && r.Type == List:
+		return mergeListChanges(b, c, r, e, path, conflicts)
+	default:
+		*conflicts = append(*conflicts, path)
+		return r
+	}
+}
+
+func mergeContainerChanges(b, c, r *DBNode, e *yang.Entry, path string,
+	conflicts *[]string) *DBNode {
+	names := []string{}
+	found := map[string]bool{}
+	for _, n := range []*DBNode{r, c, b} {
+		for _, child := range n.Childs {
+			if !found[child.Name] {
+				found[child.Name] = true
+				names = append(names, child.Name)
+			}
+		}
+	}
+	ret := &DBNode{Name: r.Name, Type: r.Type}
+	for _, name := range names {
+		var ce *yang.Entry
+		if path == "" {
+			ce = lookupRootEntry(name)
+		} else {
+			ce = lookupEntryChild(e, name)
+		}
+		child := mergeChanges(lookupDBNodeChild(b, name),
+			lookupDBNodeChild(c, name), lookupDBNodeChild(r, name), ce,
+			path+"/"+name, conflicts)
+		if child != nil {
+			ret.Childs = append(ret.Childs, *child)
+		}
+	}
+	return ret
+}
+
+// mergeListChanges merges the list elements matched by their keys. The
+// elements of the list without keys are added or deleted as a whole.
+func mergeListChanges(b, c, r *DBNode, e *yang.Entry, path string,
+	conflicts *[]string) *DBNode {
+	keys := []string{}
+	if e != nil {
+		keys = strings.Fields(e.Key)
+	}
+	index := func(n *DBNode) ([]string, map[string]*DBNode) {
+		ids := []string{}
+		m := map[string]*DBNode{}
+		for idx := range n.Childs {
+			id := listElementId(&n.Childs[idx], keys)
+			ids = append(ids, id)
+			m[id] = &n.Childs[idx]
+		}
+		return ids, m
+	}
+	bids, bm := index(b)
+	cids, cm := index(c)
+	rids, rm := index(r)
+	ids := []string{}
+	found := map[string]bool{}
+	for _, list := range [][]string{rids, cids, bids} {
+		for _, id := range list {
+			if !found[id] {
+				found[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	ret := &DBNode{Name: r.Name, Type: r.Type}
+	for _, id := range ids {
+		elem := mergeChanges(bm[id], cm[id], rm[id], e, path+id, conflicts)
+		if elem != nil {
+			ret.Childs = append(ret.Childs, *elem)
+		}
+	}
+	return ret
+}
+
+// listElementId returns the keys of the list element as in xpath (e.g.
+// [name='eth0']).
+func listElementId(n *DBNode, keys []string) string {
+	if len(keys) == 0 {
+		return fmt.Sprintf("[%s]", n.String())
+	}
+	id := ""
+	for _, k := range keys {
+		v := ""
+		if child := lookupDBNodeChild(n, k); child != nil {
+			v = fmt.Sprintf("%v", child.Value.ToValue())
+		}
+		id += fmt.Sprintf("[%s='%s']", k, v)
+	}
+	return id
+}
+
+func lookupDBNodeChild(n *DBNode, name string) *DBNode {
+	if n == nil {
+		return nil
+	}
+	for idx := range n.Childs {
+		if n.Childs[idx].Name == name {
+			return &n.Childs[idx]
+		}
+	}
+	return nil
+}
+
+func dbNodeEqual(a, b *DBNode) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Type == b.Type && a.String() == b.String()
+}
+
+// checkFileAccess denies the commands reading or writing the files (e.g.
+// load and | save) in the sessions other than the console, as the files
+// are accessed with the permission of the daemon.
+func checkFileAccess() error {
+	if currentSession != nil && currentSession != consoleSession {
+		return errors.Errorf("file access is allowed only on the console")
+	}
+	return nil
+}
+
+// sessionUsername returns the user of the session running the command.
+func sessionUsername() string {
+	if currentSession == nil {
+		return currentUsername()
+	}
+	return currentSession.User
+}
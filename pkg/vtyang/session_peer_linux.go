@@ -0,0 +1,44 @@
+package vtyang
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// sessionPeerUser returns the user of the process connecting on the unix
+// socket by SO_PEERCRED, which the client can't forge. The user is
+// privileged when it's root or the one running the daemon.
+func sessionPeerUser(conn net.Conn) (string, bool, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return "", false, errors.Errorf("not unix socket")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return "", false, errors.Wrap(err, "SyscallConn")
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET,
+			syscall.SO_PEERCRED)
+	}); err != nil {
+		return "", false, errors.Wrap(err, "Control")
+	}
+	if credErr != nil {
+		return "", false, errors.Wrap(credErr, "GetsockoptUcred")
+	}
+	privileged := cred.Uid == 0 || int(cred.Uid) == os.Getuid()
+	uid := strconv.Itoa(int(cred.Uid))
+	u, err := user.LookupId(uid)
+	if err != nil {
+		// The user without the name is shown by the uid
+		return "uid:" + uid, privileged, nil
+	}
+	return u.Username, privileged, nil
+}
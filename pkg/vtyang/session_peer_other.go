@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package vtyang
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// sessionPeerUser returns the user of the process connecting on the unix
+// socket, which is supported only on linux.
+func sessionPeerUser(conn net.Conn) (string, bool, error) {
+	return "", false, errors.Errorf("peer credentials are not supported")
+}
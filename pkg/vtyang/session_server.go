@@ -0,0 +1,389 @@
+package vtyang
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/slankdev/vtyang/pkg/liner"
+)
+
+const (
+	sessionRequestHello    = "hello"
+	sessionRequestExec     = "exec"
+	sessionRequestComplete = "complete"
+)
+
+// sessionRequest is the message from the attached client. The client
+// starts with hello, and then sends the command lines to exec and the
+// lines to complete.
+type sessionRequest struct {
+	Type string `json:"type"`
+	Line string `json:"line,omitempty"`
+	Pos  int    `json:"pos,omitempty"`
+}
+
+// sessionResponse is the message to the attached client. The output of
+// the command is streamed in the messages with only output, and the last
+// message of the request is done.
+type sessionResponse struct {
+	Output  string           `json:"output,omitempty"`
+	Done    bool             `json:"done,omitempty"`
+	Session int              `json:"session,omitempty"`
+	Prompt  string           `json:"prompt,omitempty"`
+	Error   string           `json:"error,omitempty"`
+	Exit    bool             `json:"exit,omitempty"`
+	Items   []CompletionItem `json:"items,omitempty"`
+}
+
+var sessionListener net.Listener
+
+// startSessionServer accepts the clients attaching on unix socket. Each
+// client has its own session until it disconnects.
+func startSessionServer(sockpath string) error {
+	stopSessionServer()
+	if err := os.RemoveAll(sockpath); err != nil {
+		return errors.Wrapf(err, "os.RemoveAll(%s)", sockpath)
+	}
+	lis, err := net.Listen("unix", sockpath)
+	if err != nil {
+		return errors.Wrapf(err, "net.Listen(%s)", sockpath)
+	}
+	sessionListener = lis
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				log.Printf("session server stopped: %s\n", err)
+				return
+			}
+			go serveSessionConn(conn)
+		}
+	}()
+	log.Printf("session server listening on %s\n", sockpath)
+	return nil
+}
+
+func stopSessionServer() {
+	if sessionListener != nil {
+		sessionListener.Close()
+		sessionListener = nil
+	}
+}
+
+// sessionConn is the connection of the attached client.
+type sessionConn struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (c *sessionConn) send(resp sessionResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc.Encode(resp)
+}
+
+// Write streams the output of the command to the client.
+func (c *sessionConn) Write(p []byte) (int, error) {
+	if err := c.send(sessionResponse{Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// serveSessionConn runs the session of the client. The user of the session
+// is the owner of the client process. The requests are read in the
+// background, so that the disconnection cancels the running command.
+func serveSessionConn(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	c := &sessionConn{enc: json.NewEncoder(conn)}
+
+	user, privileged, err := sessionPeerUser(conn)
+	if err != nil {
+		log.Printf("session client rejected: %s\n", err)
+		c.send(sessionResponse{
+			Done:  true,
+			Error: "can't identify the user of the client",
+		})
+		return
+	}
+	hello := sessionRequest{}
+	if err := dec.Decode(&hello); err != nil {
+		log.Printf("session client disconnected: %s\n", err)
+		return
+	}
+	if hello.Type != sessionRequestHello {
+		c.send(sessionResponse{
+			Done:  true,
+			Error: fmt.Sprintf("unexpected request %q", hello.Type),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	commandLock.Lock()
+	s := openSession(user, "attach", c, func() {
+		cancel()
+		conn.Close()
+	})
+	s.privileged = privileged
+	commandLock.Unlock()
+	defer closeSession(s)
+	if err := c.send(sessionResponse{
+		Done:    true,
+		Session: s.Id,
+		Prompt:  s.prompt(),
+	}); err != nil {
+		return
+	}
+
+	reqs := make(chan sessionRequest)
+	go func() {
+		defer close(reqs)
+		defer cancel()
+		for {
+			req := sessionRequest{}
+			if err := dec.Decode(&req); err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					log.Printf("session %d: %s\n", s.Id, err)
+				}
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for req := range reqs {
+		resp := sessionResponse{Done: true}
+		switch req.Type {
+		case sessionRequestExec:
+			prompt := s.prompt()
+			if err := s.runCommand(ctx, req.Line); err != nil {
+				// The caret is under the line after the prompt
+				buf := bytes.Buffer{}
+				writeCommandError(&buf, req.Line, len(prompt), err)
+				resp.Error = buf.String()
+			}
+			resp.Prompt = s.prompt()
+			resp.Exit = s.exited()
+		case sessionRequestComplete:
+			if req.Pos < 0 || req.Pos > len(req.Line) {
+				resp.Error = fmt.Sprintf("invalid position %d", req.Pos)
+				break
+			}
+			resp.Items = s.completion(req.Line, req.Pos).Items
+		default:
+			resp.Error = fmt.Sprintf("unexpected request %q", req.Type)
+		}
+		if err := c.send(resp); err != nil {
+			log.Printf("session %d: %s\n", s.Id, err)
+			return
+		}
+		if resp.Exit {
+			return
+		}
+	}
+}
+
+// sessionClient is the client attaching to the session server.
+type sessionClient struct {
+	conn   net.Conn
+	dec    *json.Decoder
+	enc    *json.Encoder
+	out    io.Writer
+	prompt string
+}
+
+func dialSession(sockpath string, out io.Writer) (*sessionClient, error) {
+	conn, err := net.Dial("unix", sockpath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "net.Dial(%s)", sockpath)
+	}
+	c := &sessionClient{
+		conn: conn,
+		dec:  json.NewDecoder(conn),
+		enc:  json.NewEncoder(conn),
+		out:  out,
+	}
+	resp, err := c.request(sessionRequest{Type: sessionRequestHello})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.Error != "" {
+		conn.Close()
+		return nil, errors.New(strings.TrimSpace(resp.Error))
+	}
+	return c, nil
+}
+
+func (c *sessionClient) Close() error {
+	return c.conn.Close()
+}
+
+// request sends the request and returns the last response of it. The
+// output streamed before is written to the output of the client.
+func (c *sessionClient) request(req sessionRequest) (sessionResponse, error) {
+	if err := c.enc.Encode(req); err != nil {
+		return sessionResponse{}, errors.Wrap(err, "send")
+	}
+	for {
+		resp := sessionResponse{}
+		if err := c.dec.Decode(&resp); err != nil {
+			if err == io.EOF {
+				return resp, errors.New("session closed by server")
+			}
+			return resp, errors.Wrap(err, "receive")
+		}
+		if !resp.Done {
+			fmt.Fprint(c.out, resp.Output)
+			continue
+		}
+		if resp.Prompt != "" {
+			c.prompt = resp.Prompt
+		}
+		return resp, nil
+	}
+}
+
+// exec executes the command line in the session. The error of the command
+// is displayed and kept in the response, the returned error is the one of
+// the connection.
+func (c *sessionClient) exec(line string) (sessionResponse, error) {
+	resp, err := c.request(sessionRequest{
+		Type: sessionRequestExec,
+		Line: line,
+	})
+	if err != nil {
+		return resp, err
+	}
+	fmt.Fprint(c.out, resp.Error)
+	return resp, nil
+}
+
+func (c *sessionClient) completion(line string, pos int) []CompletionItem {
+	resp, err := c.request(sessionRequest{
+		Type: sessionRequestComplete,
+		Line: line,
+		Pos:  pos,
+	})
+	if err != nil {
+		log.Printf("completion: %s\n", err)
+		return nil
+	}
+	return resp.Items
+}
+
+func (c *sessionClient) completer(line string, pos int) (string, []string, string) {
+	return completeWords(line, pos, c.completion(line, pos))
+}
+
+func (c *sessionClient) completionLister(line string, pos int) {
+	writeCompletionItems(c.out, c.completion(line, pos))
+}
+
+// attachSession runs the shell of the session on the daemon. The commands
+// are executed as batch when stdin isn't a terminal.
+func attachSession(sockpath string) error {
+	c, err := dialSession(sockpath, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if !stdinIsTerminal() {
+		return c.execBatch(os.Stdin)
+	}
+
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(c.completer)
+	line.SetTabCompletionStyle(liner.TabPrints)
+	line.SetBinder(QUESTION_MARK, c.completionLister)
+	for {
+		name, err := line.Prompt(c.prompt)
+		if err == liner.ErrPromptAborted {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		line.AppendHistory(name)
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		resp, err := c.exec(name)
+		if err != nil {
+			return err
+		}
+		if resp.Exit {
+			return nil
+		}
+	}
+}
+
+// execBatch executes the commands read from r. The commands are displayed
+// after the prompt, so that the caret of the error is under the line.
+func (c *sessionClient) execBatch(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	failed := 0
+	for scanner.Scan() {
+		cli := strings.TrimSpace(scanner.Text())
+		if cli == "" || strings.HasPrefix(cli, "#") {
+			continue
+		}
+		fmt.Fprintf(c.out, "%s%s\n", c.prompt, cli)
+		resp, err := c.exec(cli)
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			failed++
+		}
+		if resp.Exit {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errors.Errorf("%d commands failed", failed)
+	}
+	return nil
+}
+
+func newCommandAttach() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attach",
+		Short: "Attach a session to the vtyang daemon",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if GlobalOptSessionSock == "" {
+				return errors.Errorf("--session-sock is required")
+			}
+			cmd.SilenceUsage = true
+			return attachSession(GlobalOptSessionSock)
+		},
+	}
+	fs := cmd.Flags()
+	fs.StringVarP(&GlobalOptSessionSock, "session-sock", "s", "",
+		"Unix socket of the daemon (e.g. /var/run/vtyang.sock)")
+	return cmd
+}
@@ -0,0 +1,333 @@
+package vtyang
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	if err := os.RemoveAll("/tmp/run/vtyang/config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/frr_mgmtd_minimal"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	buf0 := setStdoutWithBuffer()
+	buf1 := bytes.NewBufferString("")
+	commandLock.Lock()
+	s0 := consoleSession
+	s1 := openSession("alice", "test", buf1, nil)
+	commandLock.Unlock()
+
+	run := func(s *Session, cli string) error {
+		t.Helper()
+		err := s.runCommand(context.Background(), cli)
+		t.Logf("session %d: %s: %v", s.Id, cli, err)
+		return err
+	}
+	for _, cli := range []string{
+		"configure",
+		"set lib prefix-list ipv4 hoge entry 10 action permit",
+	} {
+		if err := run(s0, cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cliMode != CliModeConfigure || s1.Mode() != CliModeView {
+		t.Errorf("unexpected mode %v %v", cliMode, s1.Mode())
+	}
+
+	// The candidate is private to the session
+	for _, cli := range []string{
+		"configure",
+		"set lib prefix-list ipv4 fuga entry 10 action deny",
+		"show configuration diff",
+		"commit",
+	} {
+		if err := run(s1, cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Contains(buf1.String(), "hoge") {
+		t.Errorf("unexpected output\n%s", buf1.String())
+	}
+	buf1.Reset()
+
+	// The commit of the other session is merged
+	if err := run(s0, "commit"); err != nil {
+		t.Fatal(err)
+	}
+	buf0.Reset()
+	if err := run(s0, "do show running-config | display set"); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		"set lib prefix-list ipv4 fuga entry 10 action deny",
+		"set lib prefix-list ipv4 hoge entry 10 action permit",
+	}, "\n") + "\n"
+	if buf0.String() != expected {
+		t.Errorf("unexpected output\n%s", buf0.String())
+	}
+
+	// The node changed by both sessions conflicts
+	if err := run(s1, "commit abort"); err != nil {
+		t.Fatal(err)
+	}
+	for _, cli := range []string{
+		"set lib prefix-list ipv4 hoge entry 10 action deny",
+		"commit",
+	} {
+		if err := run(s1, cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := run(s0, "delete lib prefix-list ipv4 hoge entry 10"); err != nil {
+		t.Fatal(err)
+	}
+	err := run(s0, "commit")
+	if err == nil || !strings.Contains(err.Error(), "conflict") ||
+		!strings.Contains(err.Error(), "hoge") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := run(s0, "commit abort"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(s0, "commit"); err != nil {
+		t.Error(err)
+	}
+
+	buf1.Reset()
+	if err := run(s1, "do show users"); err != nil {
+		t.Fatal(err)
+	}
+	if !hasTableRow(buf1.String(), "1 "+currentUsername()+" console configure") ||
+		!hasTableRow(buf1.String(), "*2 alice test configure") {
+		t.Errorf("unexpected output\n%s", buf1.String())
+	}
+	err = run(s1, "do clear session 1")
+	if err == nil || err.Error() != "session 1 can't be cleared" {
+		t.Errorf("unexpected error %v", err)
+	}
+	err = run(s1, "do clear session 2")
+	if err == nil || err.Error() != "can't clear the own session, use quit" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// The candidate is discarded on close
+	closeSession(s1)
+	if lookupSession(s1.Id) != nil {
+		t.Errorf("session %d is not closed", s1.Id)
+	}
+	if cliMode != CliModeConfigure || s1.mode != CliModeView {
+		t.Errorf("unexpected mode %v %v", cliMode, s1.mode)
+	}
+}
+
+func TestSessionServer(t *testing.T) {
+	if err := os.RemoveAll("/tmp/run/vtyang/config.json"); err != nil {
+		t.Fatal(err)
+	}
+	sockpath := "/tmp/run/vtyang/session.sock"
+	if err := InitAgent(AgentOpts{
+		LogFile:         agentTestDefaultLogFile,
+		RuntimePath:     "/tmp/run/vtyang",
+		YangPath:        []string{"./testdata/yang/frr_mgmtd_minimal"},
+		SessionSockPath: sockpath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer stopSessionServer()
+	setStdoutWithBuffer()
+
+	out := bytes.NewBufferString("")
+	c, err := dialSession(sockpath, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.prompt != "vtyang# " {
+		t.Errorf("unexpected prompt %q", c.prompt)
+	}
+
+	script := strings.Join([]string{
+		"configure",
+		"set lib prefix-list ipv4 hoge entry 10 action hoge",
+		"do show users",
+		"quit",
+		"quit",
+	}, "\n")
+	if err := c.execBatch(strings.NewReader(script)); err == nil ||
+		err.Error() != "1 commands failed" {
+		t.Errorf("unexpected error %v", err)
+	}
+	// The caret is under the invalid value after the prompt
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) < 3 || lines[2] != strings.Repeat(" ", 62)+"^" {
+		t.Errorf("unexpected output\n%s", out.String())
+	}
+	// The user is the owner of the client process
+	if !hasTableRow(out.String(), "*2 "+currentUsername()+" attach configure") {
+		t.Errorf("unexpected output\n%s", out.String())
+	}
+
+	// The connection is closed after quit
+	items := c.completion("configure", 9)
+	if items != nil {
+		t.Errorf("unexpected items %v", items)
+	}
+}
+
+// The monitor doesn't block the other sessions, and it's stopped by the
+// disconnection and clear session
+func TestSessionServerMonitor(t *testing.T) {
+	sockpath := "/tmp/run/vtyang/session.sock"
+	if err := InitAgent(AgentOpts{
+		LogFile:         agentTestDefaultLogFile,
+		RuntimePath:     "/tmp/run/vtyang",
+		YangPath:        []string{"./testdata/yang/notification"},
+		SessionSockPath: sockpath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer stopSessionServer()
+	setStdoutWithBuffer()
+	monitors := func() int {
+		notificationLock.Lock()
+		defer notificationLock.Unlock()
+		return len(notificationSubs)
+	}
+	wait := func(cond func() bool, msg string) {
+		t.Helper()
+		for i := 0; !cond(); i++ {
+			if i > 100 {
+				t.Fatal(msg)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	monitor := func(expected int) *sessionClient {
+		t.Helper()
+		c, err := dialSession(sockpath, bytes.NewBufferString(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.enc.Encode(sessionRequest{
+			Type: sessionRequestExec,
+			Line: "monitor notifications",
+		}); err != nil {
+			t.Fatal(err)
+		}
+		wait(func() bool { return monitors() == expected }, "monitor not started")
+		return c
+	}
+
+	// Session 2 and 3 are monitoring
+	c2 := monitor(1)
+	defer c2.Close()
+	c3 := monitor(2)
+	defer c3.Close()
+	out := bytes.NewBufferString("")
+	c4, err := dialSession(sockpath, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c4.Close()
+	if _, err := c4.exec("clear session 2"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "session 2 cleared") {
+		t.Errorf("unexpected output\n%s", out.String())
+	}
+	wait(func() bool { return monitors() == 1 }, "monitor not stopped by clear")
+	resp, err := c4.exec("save cli-tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Error, "file access is allowed only on the console") {
+		t.Errorf("unexpected error %q", resp.Error)
+	}
+
+	c3.Close()
+	wait(func() bool { return monitors() == 0 }, "monitor not stopped by close")
+	wait(func() bool {
+		commandLock.Lock()
+		defer commandLock.Unlock()
+		return lookupSession(2) == nil && lookupSession(3) == nil
+	}, "session not closed")
+}
+
+func TestSessionUnlockCommand(t *testing.T) {
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/notification"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	buf0 := setStdoutWithBuffer()
+	buf1 := bytes.NewBufferString("")
+	commandLock.Lock()
+	s1 := openSession("alice", "test", buf1, nil)
+	commandLock.Unlock()
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s1.runCommand(ctx, "monitor notifications")
+	}()
+	for i := 0; ; i++ {
+		notificationLock.Lock()
+		n := len(notificationSubs)
+		notificationLock.Unlock()
+		if n > 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("monitor not started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The background tasks run on the globals of the console session
+	// while the session is monitoring
+	commandLock.Lock()
+	if currentSession != consoleSession || stdout != buf0 {
+		t.Errorf("globals of session %d while monitoring", currentSession.Id)
+	}
+	commandLock.Unlock()
+
+	stop()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	commandLock.Lock()
+	defer commandLock.Unlock()
+	if currentSession != consoleSession || stdout != buf0 ||
+		s1.stdout != buf1 {
+		t.Errorf("globals not restored, session %d", currentSession.Id)
+	}
+	if !strings.Contains(buf1.String(), "Monitoring notifications") ||
+		buf0.Len() != 0 {
+		t.Errorf("unexpected output %q %q", buf0.String(), buf1.String())
+	}
+}
+
+// hasTableRow returns true when a row of the table starts with the
+// columns of row separated by a space.
+func hasTableRow(table, row string) bool {
+	for _, line := range strings.Split(table, "\n") {
+		if strings.HasPrefix(strings.Join(strings.Fields(line), " ")+" ",
+			row+" ") {
+			return true
+		}
+	}
+	return false
+}
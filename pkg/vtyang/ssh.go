@@ -0,0 +1,471 @@
+package vtyang
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/slankdev/vtyang/pkg/liner"
+)
+
+// SSHUser is the user of the local user store authenticated by ssh. The
+// password is the bcrypt hash (e.g. by vtyang hash-password), the password
+// authentication is disabled when it's empty.
+type SSHUser struct {
+	Name           string   `json:"name"`
+	Password       string   `json:"password,omitempty"`
+	AuthorizedKeys []string `json:"authorized-keys,omitempty"`
+}
+
+type SSHUsers struct {
+	Users []SSHUser `json:"users"`
+}
+
+var sshListener net.Listener
+
+// readSSHUsers reads the user store. It's read on each authentication, so
+// that the changes are effective without restart.
+func readSSHUsers(filename string) (*SSHUsers, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	users := SSHUsers{}
+	if err := json.Unmarshal(b, &users); err != nil {
+		return nil, errors.Wrap(err, filename)
+	}
+	return &users, nil
+}
+
+func lookupSSHUser(filename, name string) (*SSHUser, error) {
+	users, err := readSSHUsers(filename)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range users.Users {
+		if users.Users[idx].Name == name {
+			return &users.Users[idx], nil
+		}
+	}
+	return nil, errors.Errorf("user %s is not found", name)
+}
+
+// readSSHHostKey reads the host key, which is generated as ed25519 key when
+// the file doesn't exist.
+func readSSHHostKey(filename string) (ssh.Signer, error) {
+	b, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "ed25519.GenerateKey")
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "x509.MarshalPKCS8PrivateKey")
+		}
+		b = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(filename, b, 0600); err != nil {
+			return nil, err
+		}
+		log.Printf("ssh host key generated in %s\n", filename)
+	} else if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(b)
+	if err != nil {
+		return nil, errors.Wrap(err, filename)
+	}
+	return signer, nil
+}
+
+func newSSHServerConfig(opts *AgentOptsSSH) (*ssh.ServerConfig, error) {
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata,
+			password []byte) (*ssh.Permissions, error) {
+			u, err := lookupSSHUser(opts.UsersFile, c.User())
+			if err != nil {
+				return nil, err
+			}
+			if u.Password == "" {
+				return nil, errors.Errorf("password of %s is not set", u.Name)
+			}
+			err = bcrypt.CompareHashAndPassword([]byte(u.Password), password)
+			if err != nil {
+				return nil, errors.Errorf("password of %s mismatch", u.Name)
+			}
+			return nil, nil
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata,
+			key ssh.PublicKey) (*ssh.Permissions, error) {
+			u, err := lookupSSHUser(opts.UsersFile, c.User())
+			if err != nil {
+				return nil, err
+			}
+			for _, authorized := range u.AuthorizedKeys {
+				k, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorized))
+				if err != nil {
+					log.Printf("ssh: user %s: %s\n", u.Name, err)
+					continue
+				}
+				if bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, errors.Errorf("key of %s is not authorized", u.Name)
+		},
+	}
+	signer, err := readSSHHostKey(opts.HostKeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "readSSHHostKey")
+	}
+	config.AddHostKey(signer)
+	return config, nil
+}
+
+// startSSHServer accepts the ssh connections. Each channel of them has its
+// own session with the shell, or runs the commands of exec.
+func startSSHServer(opts *AgentOptsSSH) error {
+	stopSSHServer()
+	config, err := newSSHServerConfig(opts)
+	if err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", opts.Address)
+	if err != nil {
+		return errors.Wrapf(err, "net.Listen(%s)", opts.Address)
+	}
+	sshListener = lis
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				log.Printf("ssh server stopped: %s\n", err)
+				return
+			}
+			go serveSSHConn(conn, config)
+		}
+	}()
+	log.Printf("ssh server listening on %s\n", lis.Addr())
+	return nil
+}
+
+func stopSSHServer() {
+	if sshListener != nil {
+		sshListener.Close()
+		sshListener = nil
+	}
+}
+
+func serveSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		log.Printf("ssh %s: %s\n", conn.RemoteAddr(), err)
+		return
+	}
+	log.Printf("ssh %s: user %s logged in\n", conn.RemoteAddr(), sconn.User())
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		if nch.ChannelType() != "session" {
+			nch.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, requests, err := nch.Accept()
+		if err != nil {
+			log.Printf("ssh %s: %s\n", conn.RemoteAddr(), err)
+			continue
+		}
+		go serveSSHChannel(sconn, ch, requests)
+	}
+}
+
+// sshPtyRequest is the payload of pty-req (RFC4254 6.2).
+type sshPtyRequest struct {
+	Term    string
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+	Modes   string
+}
+
+// sshWindowChange is the payload of window-change (RFC4254 6.7).
+type sshWindowChange struct {
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+}
+
+// serveSSHChannel handles the requests of the session channel. The shell
+// uses liner when the pty is requested, and the commands are read as the
+// batch otherwise. The requests end on the close of the channel, which
+// cancels the running command.
+func serveSSHChannel(sconn *ssh.ServerConn, ch ssh.Channel,
+	requests <-chan *ssh.Request) {
+	defer ch.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var pty *sshPtyRequest
+	var line *liner.State
+	started := false
+	for req := range requests {
+		ok := false
+		switch req.Type {
+		case "pty-req":
+			p := sshPtyRequest{}
+			if err := ssh.Unmarshal(req.Payload, &p); err == nil {
+				pty = &p
+				ok = true
+			}
+		case "window-change":
+			w := sshWindowChange{}
+			if err := ssh.Unmarshal(req.Payload, &w); err == nil && line != nil {
+				line.SetColumns(int(w.Columns))
+				ok = true
+			}
+		case "env":
+			ok = true
+		case "shell", "exec":
+			if started {
+				break
+			}
+			started = true
+			ok = true
+			command := ""
+			if req.Type == "exec" {
+				payload := struct{ Command string }{}
+				if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+					ok = false
+					break
+				}
+				command = payload.Command
+			}
+			var out io.Writer = ch
+			if pty != nil {
+				out = crlfWriter{w: ch}
+			}
+			client := fmt.Sprintf("ssh %s", sconn.RemoteAddr())
+			commandLock.Lock()
+			s := openSession(sconn.User(), client, out, func() {
+				sconn.Close()
+			})
+			commandLock.Unlock()
+			in := newSSHInput(ch)
+			if req.Type == "shell" && pty != nil {
+				line = liner.NewLinerTerminal(in, out, pty.Term,
+					int(pty.Columns))
+			}
+			go func() {
+				status := runSSHSession(ctx, s, in, out, line, command)
+				closeSession(s)
+				ch.SendRequest("exit-status", false,
+					ssh.Marshal(struct{ Status uint32 }{status}))
+				ch.Close()
+			}()
+		}
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+}
+
+// runSSHSession runs the shell or the commands of exec in the session, and
+// returns the exit status.
+func runSSHSession(ctx context.Context, s *Session, in *sshInput,
+	out io.Writer, line *liner.State, command string) uint32 {
+	switch {
+	case command != "":
+		if runSessionBatch(ctx, s, in, strings.NewReader(command), out,
+			false) > 0 {
+			return 1
+		}
+		return 0
+	case line == nil:
+		if runSessionBatch(ctx, s, in, in, out, true) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(s.completer)
+	line.SetTabCompletionStyle(liner.TabPrints)
+	line.SetBinder(QUESTION_MARK, s.completionLister)
+	defer line.Close()
+	for !s.exited() {
+		prompt := s.prompt()
+		name, err := line.Prompt(prompt)
+		if err == liner.ErrPromptAborted {
+			continue
+		} else if err != nil {
+			if err != io.EOF {
+				log.Printf("session %d: %s\n", s.Id, err)
+			}
+			break
+		}
+		line.AppendHistory(name)
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		if err := in.runCommand(ctx, s, name); err != nil {
+			// The caret is under the line just entered
+			writeCommandError(out, name, len(prompt), err)
+		}
+	}
+	return 0
+}
+
+// runSessionBatch executes the commands read from r in the session, and
+// returns the number of the failed commands. The commands are displayed
+// after the prompt when echo is set.
+func runSessionBatch(ctx context.Context, s *Session, in *sshInput,
+	r io.Reader, out io.Writer, echo bool) int {
+	scanner := bufio.NewScanner(r)
+	failed := 0
+	for !s.exited() && scanner.Scan() {
+		cli := strings.TrimSpace(scanner.Text())
+		if cli == "" || strings.HasPrefix(cli, "#") {
+			continue
+		}
+		indent := -1
+		if echo {
+			prompt := s.prompt()
+			fmt.Fprintf(out, "%s%s\n", prompt, cli)
+			indent = len(prompt)
+		}
+		if err := in.runCommand(ctx, s, cli); err != nil {
+			writeCommandError(out, cli, indent, err)
+			failed++
+		}
+	}
+	return failed
+}
+
+// sshInput is the input of the channel read in the background, so that
+// Ctrl-C (0x03) is seen while the command runs. It cancels the command
+// instead of being read by the shell.
+type sshInput struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte
+	err    error
+	cancel context.CancelFunc
+}
+
+func newSSHInput(r io.Reader) *sshInput {
+	in := &sshInput{}
+	in.cond = sync.NewCond(&in.mu)
+	go in.readLoop(r)
+	return in
+}
+
+func (in *sshInput) readLoop(r io.Reader) {
+	b := make([]byte, 1024)
+	for {
+		n, err := r.Read(b)
+		in.mu.Lock()
+		for _, c := range b[:n] {
+			if c == 0x03 && in.cancel != nil {
+				in.cancel()
+				continue
+			}
+			in.buf = append(in.buf, c)
+		}
+		if err != nil {
+			in.err = err
+		}
+		in.cond.Broadcast()
+		in.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (in *sshInput) Read(p []byte) (int, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for len(in.buf) == 0 && in.err == nil {
+		in.cond.Wait()
+	}
+	if len(in.buf) == 0 {
+		return 0, in.err
+	}
+	n := copy(p, in.buf)
+	in.buf = in.buf[n:]
+	return n, nil
+}
+
+// runCommand executes the command line in the session with ctx, which is
+// cancelled by Ctrl-C.
+func (in *sshInput) runCommand(ctx context.Context, s *Session,
+	cli string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	in.mu.Lock()
+	in.cancel = cancel
+	in.mu.Unlock()
+	defer func() {
+		in.mu.Lock()
+		in.cancel = nil
+		in.mu.Unlock()
+	}()
+	return s.runCommand(ctx, cli)
+}
+
+// crlfWriter translates the newlines for the pty in raw mode.
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(p, []byte("\n"),
+		[]byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func newCommandHashPassword() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hash-password",
+		Short: "Print the bcrypt hash of the password read from stdin for --ssh-users",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && err != io.EOF {
+				return err
+			}
+			password = strings.TrimRight(password, "\r\n")
+			if password == "" {
+				return errors.Errorf("password is empty")
+			}
+			hash, err := bcrypt.GenerateFromPassword([]byte(password),
+				bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(hash))
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,244 @@
+package vtyang
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHServer(t *testing.T) {
+	if err := os.RemoveAll("/tmp/run/vtyang/config.json"); err != nil {
+		t.Fatal(err)
+	}
+	hostKeyFile := "/tmp/run/vtyang/ssh_host_key"
+	usersFile := "/tmp/run/vtyang/ssh_users.json"
+	if err := os.RemoveAll(hostKeyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user store of alice with password and bob with public key
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := json.Marshal(SSHUsers{Users: []SSHUser{
+		{Name: "alice", Password: string(hash)},
+		{Name: "bob", AuthorizedKeys: []string{
+			string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+		}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(usersFile, users, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitAgent(AgentOpts{
+		LogFile:     agentTestDefaultLogFile,
+		RuntimePath: "/tmp/run/vtyang",
+		YangPath:    []string{"./testdata/yang/frr_mgmtd_minimal"},
+		SSH: &AgentOptsSSH{
+			Address:     "127.0.0.1:0",
+			HostKeyFile: hostKeyFile,
+			UsersFile:   usersFile,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer stopSSHServer()
+	setStdoutWithBuffer()
+	if _, err := os.Stat(hostKeyFile); err != nil {
+		t.Fatal(err)
+	}
+	addr := sshListener.Addr().String()
+	dial := func(user string, auth ssh.AuthMethod) (*ssh.Client, error) {
+		return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			Timeout:         5 * time.Second,
+		})
+	}
+
+	// The authentication failures
+	if _, err := dial("alice", ssh.Password("hoge")); err == nil {
+		t.Errorf("login with wrong password")
+	}
+	if _, err := dial("bob", ssh.Password("secret")); err == nil {
+		t.Errorf("login with password not set")
+	}
+	if _, err := dial("carol", ssh.PublicKeys(signer)); err == nil {
+		t.Errorf("login with unknown user")
+	}
+
+	// The commands of exec are executed in the own session
+	c1, err := dial("alice", ssh.Password("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	sess, err := c1.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := sess.CombinedOutput("show users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasTableRow(string(out), "*2 alice ssh") {
+		t.Errorf("unexpected output\n%s", out)
+	}
+	sess, err = c1.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.Run("show hoge")
+	if exitErr, ok := err.(*ssh.ExitError); !ok || exitErr.ExitStatus() != 1 {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// The files of the daemon aren't accessed by the remote users
+	saveFile := "/tmp/run/vtyang/ssh_save.txt"
+	if err := os.RemoveAll(saveFile); err != nil {
+		t.Fatal(err)
+	}
+	for _, cli := range []string{
+		"save running-config " + saveFile,
+		"show running-config | save " + saveFile,
+	} {
+		sess, err = c1.NewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := sess.CombinedOutput(cli)
+		if !strings.Contains(string(out), "file access is allowed only on the console") {
+			t.Errorf("unexpected output %s: %v\n%s", cli, err, out)
+		}
+	}
+	if _, err := os.Stat(saveFile); !os.IsNotExist(err) {
+		t.Errorf("file is saved %v", err)
+	}
+
+	// The close of the channel stops the command
+	monitors := func() int {
+		notificationLock.Lock()
+		defer notificationLock.Unlock()
+		return len(notificationSubs)
+	}
+	waitMonitors := func(n int) {
+		t.Helper()
+		for i := 0; monitors() != n; i++ {
+			if i > 100 {
+				t.Fatalf("monitors %d != %d", monitors(), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	sess, err = c1.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Start("monitor notifications"); err != nil {
+		t.Fatal(err)
+	}
+	waitMonitors(1)
+	sess.Close()
+	waitMonitors(0)
+
+	// The shell on the pty is driven by liner
+	c2, err := dial("bob", ssh.PublicKeys(signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	sess, err = c2.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.RequestPty("xterm", 24, 80, ssh.TerminalModes{}); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &syncBuffer{}
+	sess.Stdout = buf
+	if err := sess.Shell(); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput := func(s string) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if strings.Contains(buf.String(), s) {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("%q is not found in output\n%q", s, buf.String())
+	}
+	waitOutput("vtyang# ")
+	if _, err := stdin.Write([]byte("show us?")); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput("users")
+	if _, err := stdin.Write([]byte("\t\r")); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput("bob")
+	if !strings.Contains(buf.String(), "\r\n") {
+		t.Errorf("unexpected output\n%q", buf.String())
+	}
+
+	// Ctrl-C stops the command
+	if _, err := stdin.Write([]byte("monitor notifications\r")); err != nil {
+		t.Fatal(err)
+	}
+	waitMonitors(1)
+	if _, err := stdin.Write([]byte{0x03}); err != nil {
+		t.Fatal(err)
+	}
+	waitMonitors(0)
+	if _, err := stdin.Write([]byte("quit\r")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
+// syncBuffer is the buffer written by the goroutine of ssh session.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,10 @@
+#!/bin/sh
+# Reply the rpc input as the ping result
+INPUT=$(cat)
+case "$INPUT" in
+*unreachable*)
+	echo "destination unreachable" >&2
+	exit 1
+	;;
+esac
+echo '{"sent": 3, "received": 3, "rtt": 1000000}'
@@ -0,0 +1,231 @@
+package vtyang
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/pkg/errors"
+)
+
+// walkDBNode calls f for each leaf and leaf-list of the data tree root with
+// the xpath resolved by the schema. List keys are set in schema order and
+// nodes unknown to the loaded modules are skipped.
+func walkDBNode(root *DBNode, f func(xpath XPath, n *DBNode) error) error {
+	return walkDBNodeEntries(root, func(xpath XPath, n *DBNode,
+		_ *yang.Entry) error {
+		if n.Type == Leaf || n.Type == LeafList {
+			return f(xpath, n)
+		}
+		return nil
+	})
+}
+
+// walkDBNodeEntries is walkDBNode calling f also for the containers and
+// the list entries with their schema entry. The xpath of a list entry
+// ends with the list having the keys of the entry.
+func walkDBNodeEntries(root *DBNode,
+	f func(xpath XPath, n *DBNode, e *yang.Entry) error) error {
+	for idx := range root.Childs {
+		child := &root.Childs[idx]
+		if child.Type == Container && len(lookupRootEntries(child.Name)) > 1 {
+			if err := walkDBNodeMerged(child, f); err != nil {
+				return err
+			}
+			continue
+		}
+		e := lookupRootEntry(child.Name)
+		if e == nil {
+			log.Printf("walk: entry %s is not found ... ignored\n", child.Name)
+			continue
+		}
+		if err := walkDBNodeImpl(child, e, XPath{}, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lookupRootEntries(name string) []*yang.Entry {
+	ret := []*yang.Entry{}
+	for _, e := range yangModuleDumpEntries() {
+		if e.Name == name {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// walkDBNodeMerged walks the top-level container defined in multiple
+// modules. Each child is walked under the container of the module
+// defining it (e.g. /frr-filter:lib/prefix-list and
+// /frr-route-map:lib/route-map).
+func walkDBNodeMerged(n *DBNode,
+	f func(xpath XPath, n *DBNode, e *yang.Entry) error) error {
+	entries := lookupRootEntries(n.Name)
+	for idx := range n.Childs {
+		child := &n.Childs[idx]
+		found := false
+		for _, e := range entries {
+			ce := lookupEntryChild(e, child.Name)
+			if ce == nil {
+				continue
+			}
+			mod, err := e.InstantiatingModule()
+			if err != nil {
+				return errors.Wrap(err, "InstantiatingModule")
+			}
+			xpath := XPath{Words: []XWord{{
+				Module: mod,
+				Word:   n.Name,
+				Dbtype: Container,
+			}}}
+			if err := walkDBNodeImpl(child, ce, xpath, f); err != nil {
+				return err
+			}
+			found = true
+			break
+		}
+		if !found {
+			log.Printf("walk: entry %s/%s is not found ... ignored\n",
+				n.Name, child.Name)
+		}
+	}
+	return nil
+}
+
+// lookupRootEntry returns the top-level entry. As containers of the same
+// name can be defined in different modules, the entry merges them.
+func lookupRootEntry(name string) *yang.Entry {
+	var ret *yang.Entry
+	for _, e := range yangModuleDumpEntries() {
+		if e.Name != name {
+			continue
+		}
+		if ret == nil {
+			ret = e
+			continue
+		}
+		if ret.Dir == nil || e.Dir == nil {
+			continue
+		}
+		merged := *ret
+		merged.Dir = map[string]*yang.Entry{}
+		for k, v := range ret.Dir {
+			merged.Dir[k] = v
+		}
+		for k, v := range e.Dir {
+			if _, ok := merged.Dir[k]; !ok {
+				merged.Dir[k] = v
+			}
+		}
+		ret = &merged
+	}
+	return ret
+}
+
+func walkDBNodeImpl(n *DBNode, e *yang.Entry, parent XPath,
+	f func(xpath XPath, n *DBNode, e *yang.Entry) error) error {
+	mod, err := e.InstantiatingModule()
+	if err != nil {
+		return errors.Wrap(err, "InstantiatingModule")
+	}
+	xword := XWord{Module: mod, Word: n.Name, Dbtype: n.Type}
+	if e.Type != nil {
+		xword.Dbvaluetype = e.Type.Kind
+		xword.ytype = *e.Type
+	}
+
+	switch n.Type {
+	case Container:
+		xpath := XPath{Words: append(append([]XWord{}, parent.Words...), xword)}
+		if err := f(xpath, n, e); err != nil {
+			return err
+		}
+		return walkDBNodeChilds(n, e, xpath, f)
+	case List:
+		keys := strings.Fields(e.Key)
+		for idx := range n.Childs {
+			elem := &n.Childs[idx]
+			xw := xword
+			xw.Keys = map[string]XWordKey{}
+			xw.KeysIndex = keys
+			for _, k := range keys {
+				for _, c := range elem.Childs {
+					if c.Name == k {
+						xw.Keys[k] = XWordKey{Value: c.Value}
+					}
+				}
+			}
+			xpath := XPath{Words: append(append([]XWord{}, parent.Words...), xw)}
+			if err := f(xpath, elem, e); err != nil {
+				return err
+			}
+			if err := walkDBNodeChilds(elem, e, xpath, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Leaf, LeafList:
+		xpath := XPath{Words: append(append([]XWord{}, parent.Words...), xword)}
+		return f(xpath, n, e)
+	default:
+		return errors.Errorf("%s: unsupported node type %s", n.Name, n.Type)
+	}
+}
+
+func walkDBNodeChilds(n *DBNode, e *yang.Entry, xpath XPath,
+	f func(xpath XPath, n *DBNode, e *yang.Entry) error) error {
+	for idx := range n.Childs {
+		child := &n.Childs[idx]
+		ce := lookupEntryChild(e, child.Name)
+		if ce == nil {
+			log.Printf("walk: entry %s/%s is not found ... ignored\n",
+				xpath.String(), child.Name)
+			continue
+		}
+		if err := walkDBNodeImpl(child, ce, xpath, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dbValueString returns the value in the canonical string representation.
+// Values loaded from json file don't hold the yang type (e.g. uint8 is
+// loaded as decimal64), so the numbers are formatted without the fraction
+// when it's not needed.
+func dbValueString(v DBValue) string {
+	if v.Type == yang.Ydecimal64 {
+		return strconv.FormatFloat(v.Decimal64, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v.ToValue())
+}
+
+// dbNodeValueString returns the value of leaf or leaf-list node. The items
+// of leaf-list are joined with space.
+func dbNodeValueString(n *DBNode) string {
+	if n.Type == LeafList {
+		items := []string{}
+		for _, v := range n.ArrayValue {
+			items = append(items, dbValueString(v))
+		}
+		return strings.Join(items, " ")
+	}
+	return dbValueString(n.Value)
+}
+
+// flattenDBNode returns the values of the leaves and leaf-lists of root
+// indexed by their xpath.
+func flattenDBNode(root *DBNode) (map[string]string, error) {
+	ret := map[string]string{}
+	if err := walkDBNode(root, func(xpath XPath, n *DBNode) error {
+		ret[xpath.String()] = dbNodeValueString(n)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -3,6 +3,7 @@ package vtyang
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/openconfig/goyang/pkg/yang"
@@ -64,15 +65,32 @@ func (x XPath) String() string {
 	s := ""
 	for _, w := range x.Words {
 		s = fmt.Sprintf("%s/%s:%s", s, w.Module, w.Word)
-		if w.Keys != nil {
-			for k, v := range w.Keys {
-				s = fmt.Sprintf("%s[%s='%s']", s, k, v.Value.ToString())
+		for _, k := range w.keysOrder() {
+			v := w.Keys[k]
+			if v.Value.Type == yang.Ynone {
+				// The key isn't specified (e.g. "show" of the whole list)
+				continue
 			}
+			s = fmt.Sprintf("%s[%s='%s']", s, k, dbValueString(v.Value))
 		}
 	}
 	return s
 }
 
+// keysOrder returns the list keys in schema order. When the schema order
+// is unknown, keys are sorted to keep the output stable.
+func (w XWord) keysOrder() []string {
+	if len(w.KeysIndex) == len(w.Keys) {
+		return w.KeysIndex
+	}
+	keys := []string{}
+	for k := range w.Keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func validateStringValue(valueStr string, yangType *yang.YangType) error {
 	valid := true
 	for _, pattern := range yangType.Pattern {
@@ -408,10 +426,13 @@ func ParseXPathArgs(dbm *DatabaseManager, args []string,
 		module := &yang.Entry{}
 		module.Dir = map[string]*yang.Entry{}
 		module.Dir[ent.Name] = ent
-		xpath, vals, err = ParseXPathArgsImpl(module, args, setmode)
-		if err == nil {
+		var err0 error
+		xpath, vals, err0 = ParseXPathArgsImpl(module, args, setmode)
+		if err0 == nil {
+			err = nil
 			break
 		}
+		err = moreSpecificError(err, err0)
 	}
 	if err != nil {
 		return XPath{}, nil, err
@@ -426,6 +447,7 @@ func ParseXPathArgsImpl(module *yang.Entry, args []string,
 	value := []DBValue{}
 	for len(words) != 0 {
 		xword := XWord{Word: words[0]}
+		pos := len(args) - len(words)
 
 		var foundNode *yang.Entry = nil
 		for n := range module.Dir {
@@ -454,7 +476,27 @@ func ParseXPathArgsImpl(module *yang.Entry, args []string,
 			}
 		}
 		if foundNode == nil {
-			return XPath{}, nil, errors.Errorf("entry %s is not found", words[0])
+			return XPath{}, nil, &CommandError{
+				XPath:  xpath.String(),
+				ArgPos: pos,
+				Err:    errors.Errorf("entry %s is not found", words[0]),
+			}
+		}
+
+		mod, err := foundNode.InstantiatingModule()
+		if err != nil {
+			return XPath{}, nil, errors.Wrap(err, "InstantiationgModule")
+		}
+		xword.Module = mod
+		// valueError is the error of the value at the argument position
+		valueError := func(err error, argPos int, e *yang.Entry) error {
+			words := append(append([]XWord{}, xpath.Words...), xword)
+			return &CommandError{
+				XPath:  XPath{Words: words}.String(),
+				ArgPos: pos + argPos,
+				AppTag: entryErrorAppTag(e),
+				Err:    err,
+			}
 		}
 
 		argumentCount := 1
@@ -470,7 +512,8 @@ func ParseXPathArgsImpl(module *yang.Entry, args []string,
 				if len(words) > 1 {
 					v, err := validateValue(words[argumentCount], foundNode.Type)
 					if err != nil {
-						return XPath{}, nil, errors.Wrap(err, "validateValue")
+						return XPath{}, nil, valueError(errors.Wrap(err, "validateValue"),
+							argumentCount, foundNode)
 					}
 					value = append(value, v)
 					argumentExist = true
@@ -507,7 +550,8 @@ func ParseXPathArgsImpl(module *yang.Entry, args []string,
 					}
 					v, err := validateValue(words[argumentCount], keyLeafNode.Type)
 					if err != nil {
-						return XPath{}, nil, errors.Wrap(err, "validateValue(%s)")
+						return XPath{}, nil, valueError(errors.Wrap(err, "validateValue(%s)"),
+							argumentCount, keyLeafNode)
 					}
 					tmp := xword.Keys[w]
 					tmp.Value = v
@@ -521,12 +565,15 @@ func ParseXPathArgsImpl(module *yang.Entry, args []string,
 		case foundNode.IsLeafList():
 			if setmode {
 				if len(words) < 2 {
-					return XPath{}, nil, fmt.Errorf("is-leaf-list invalid args len")
+					return XPath{}, nil, valueError(
+						fmt.Errorf("is-leaf-list invalid args len"), 0, nil)
 				}
 				for argumentCount < len(words) {
 					v, err := validateValue(words[argumentCount], foundNode.Type)
 					if err != nil {
-						return XPath{}, nil, errors.Wrap(err, "validateValue(leaf-list)")
+						return XPath{}, nil, valueError(
+							errors.Wrap(err, "validateValue(leaf-list)"),
+							argumentCount, foundNode)
 					}
 					value = append(value, v)
 					argumentCount++
@@ -545,12 +592,6 @@ func ParseXPathArgsImpl(module *yang.Entry, args []string,
 			xword.ytype = *foundNode.Type
 		}
 
-		mod, err := foundNode.InstantiatingModule()
-		if err != nil {
-			return XPath{}, nil, errors.Wrap(err, "InstantiationgModule")
-		}
-		xword.Module = mod
-
 		xpath.Words = append(xpath.Words, xword)
 		words = words[1:]
 		if argumentExist {